@@ -2,13 +2,16 @@ module github.com/altuxa/payment-service-emulator
 
 go 1.17
 
-require github.com/mattn/go-sqlite3 v1.14.13
+require (
+	github.com/golang/mock v1.6.0
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.13
+	github.com/stretchr/testify v1.7.2
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.0 // indirect
-	github.com/golang/mock v1.6.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/testify v1.7.2 // indirect
 	golang.org/x/mod v0.4.2 // indirect
 	golang.org/x/sys v0.0.0-20210510120138-977fb7262007 // indirect
 	golang.org/x/tools v0.1.1 // indirect
@@ -0,0 +1,255 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+type Config struct {
+	CurrencyLimits string
+	// AdminToken guards the API key endpoints, they are disabled when empty
+	AdminToken     string
+	IdempotencyTTL time.Duration
+	Storage        Storage
+	Outcome        Outcome
+	Processing     Processing
+	Authorization  Authorization
+	Retention      Retention
+	Webhook        Webhook
+	JWT            JWT
+}
+
+// Storage selects the repository backend: sqlite, postgres or memory.
+type Storage struct {
+	Backend     string
+	SQLitePath  string
+	PostgresDSN string
+	// AutoMigrate applies pending migrations at startup, without it the
+	// service refuses to start until "migrate up" is run
+	AutoMigrate bool
+}
+
+// Source is the file or connection string of the selected backend.
+func (s Storage) Source() string {
+	if s.Backend == "postgres" {
+		return s.PostgresDSN
+	}
+	return s.SQLitePath
+}
+
+type Outcome struct {
+	// Seed is zero when OUTCOME_SEED is unset, a random seed is used then
+	Seed           int64
+	ErrorRate      float64
+	FailRate       float64
+	RefundFailRate float64
+	// decline reason weights, see helpers.ParseWeights
+	ErrorReasons string
+	FailReasons  string
+}
+
+type Processing struct {
+	Workers      int
+	Delay        time.Duration
+	PollInterval time.Duration
+}
+
+type Authorization struct {
+	TTL           time.Duration
+	CheckInterval time.Duration
+}
+
+// Retention keeps cancelled payments forever when Cancelled is zero.
+type Retention struct {
+	Cancelled     time.Duration
+	PurgeInterval time.Duration
+}
+
+// JWT configures bearer tokens, they are rejected when no key is configured.
+type JWT struct {
+	Audience       string
+	Issuer         string
+	Secret         string
+	JWKSFile       string
+	PrivateKeyFile string
+	// KeyID is the kid of the emulator's own secret and private key
+	KeyID  string
+	Leeway time.Duration
+	TTL    time.Duration
+}
+
+type Webhook struct {
+	MaxAttempts  int
+	Backoff      time.Duration
+	MaxBackoff   time.Duration
+	Timeout      time.Duration
+	PollInterval time.Duration
+	SecretGrace  time.Duration
+}
+
+func Load() (*Config, error) {
+	var err error
+	cfg := &Config{
+		CurrencyLimits: env("CURRENCY_LIMITS", ""),
+		AdminToken:     env("ADMIN_TOKEN", ""),
+	}
+	if cfg.IdempotencyTTL, err = envDuration("IDEMPOTENCY_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	cfg.Storage.Backend = env("STORAGE_BACKEND", "sqlite")
+	cfg.Storage.SQLitePath = env("SQLITE_PATH", "./sqlite3.db")
+	cfg.Storage.PostgresDSN = env("POSTGRES_DSN", "")
+	if cfg.Storage.AutoMigrate, err = envBool("AUTO_MIGRATE", true); err != nil {
+		return nil, err
+	}
+	if cfg.Outcome.Seed, err = envInt64("OUTCOME_SEED", 0); err != nil {
+		return nil, err
+	}
+	if cfg.Outcome.ErrorRate, err = envRate("OUTCOME_ERROR_RATE", 0.38); err != nil {
+		return nil, err
+	}
+	if cfg.Outcome.FailRate, err = envRate("OUTCOME_FAIL_RATE", 0.26); err != nil {
+		return nil, err
+	}
+	if cfg.Outcome.RefundFailRate, err = envRate("OUTCOME_REFUND_FAIL_RATE", 0.1); err != nil {
+		return nil, err
+	}
+	cfg.Outcome.ErrorReasons = env("OUTCOME_ERROR_REASONS", "")
+	cfg.Outcome.FailReasons = env("OUTCOME_FAIL_REASONS", "")
+	if cfg.Processing.Workers, err = envInt("PROCESSING_WORKERS", 4); err != nil {
+		return nil, err
+	}
+	if cfg.Processing.Delay, err = envDuration("PROCESSING_DELAY", 0); err != nil {
+		return nil, err
+	}
+	if cfg.Processing.PollInterval, err = envInterval("PROCESSING_POLL_INTERVAL", time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.Authorization.TTL, err = envDuration("AUTHORIZATION_TTL", 7*24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.Authorization.CheckInterval, err = envInterval("AUTHORIZATION_CHECK_INTERVAL", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.Retention.Cancelled, err = envDuration("CANCELLED_RETENTION", 0); err != nil {
+		return nil, err
+	}
+	if cfg.Retention.PurgeInterval, err = envInterval("PURGE_INTERVAL", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.Webhook.MaxAttempts, err = envInt("WEBHOOK_MAX_ATTEMPTS", 6); err != nil {
+		return nil, err
+	}
+	if cfg.Webhook.Backoff, err = envDuration("WEBHOOK_BACKOFF", time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.Webhook.MaxBackoff, err = envDuration("WEBHOOK_MAX_BACKOFF", 10*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.Webhook.Timeout, err = envDuration("WEBHOOK_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.Webhook.PollInterval, err = envInterval("WEBHOOK_POLL_INTERVAL", time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.Webhook.SecretGrace, err = envDuration("WEBHOOK_SECRET_GRACE", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	cfg.JWT.Audience = env("JWT_AUDIENCE", "payment-service-emulator")
+	cfg.JWT.Issuer = env("JWT_ISSUER", "")
+	cfg.JWT.Secret = env("JWT_HS256_SECRET", "")
+	cfg.JWT.JWKSFile = env("JWT_JWKS_FILE", "")
+	cfg.JWT.PrivateKeyFile = env("JWT_RS256_PRIVATE_KEY_FILE", "")
+	cfg.JWT.KeyID = env("JWT_KEY_ID", "emulator")
+	if cfg.JWT.Leeway, err = envDuration("JWT_LEEWAY", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.JWT.TTL, err = envDuration("JWT_TTL", time.Hour); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func env(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) (int, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+func envInt64(key string, def int64) (int64, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+func envBool(key string, def bool) (bool, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return b, nil
+}
+
+func envRate(key string, def float64) (float64, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if f < 0 || f > 1 {
+		return 0, fmt.Errorf("invalid %s: must be between 0 and 1", key)
+	}
+	return f, nil
+}
+
+func envDuration(key string, def time.Duration) (time.Duration, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// envInterval is a duration that drives a ticker, so it must be positive.
+func envInterval(key string, def time.Duration) (time.Duration, error) {
+	d, err := envDuration(key, def)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s: must be positive", key)
+	}
+	return d, nil
+}
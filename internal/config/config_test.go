@@ -0,0 +1,36 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadIntervals(t *testing.T) {
+	tData := map[string]struct {
+		Key   string
+		Value string
+		Err   string
+	}{
+		"processing poll":     {Key: "PROCESSING_POLL_INTERVAL", Value: "250ms"},
+		"zero processing":     {Key: "PROCESSING_POLL_INTERVAL", Value: "0s", Err: "invalid PROCESSING_POLL_INTERVAL: must be positive"},
+		"negative webhook":    {Key: "WEBHOOK_POLL_INTERVAL", Value: "-1s", Err: "invalid WEBHOOK_POLL_INTERVAL: must be positive"},
+		"zero authorization":  {Key: "AUTHORIZATION_CHECK_INTERVAL", Value: "0", Err: "invalid AUTHORIZATION_CHECK_INTERVAL: must be positive"},
+		"zero purge":          {Key: "PURGE_INTERVAL", Value: "0s", Err: "invalid PURGE_INTERVAL: must be positive"},
+		"unparsable interval": {Key: "WEBHOOK_POLL_INTERVAL", Value: "soon", Err: `invalid WEBHOOK_POLL_INTERVAL: time: invalid duration "soon"`},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			t.Setenv(v.Key, v.Value)
+			cfg, err := Load()
+			if v.Err != "" {
+				assert.EqualError(t, err, v.Err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 250*time.Millisecond, cfg.Processing.PollInterval)
+		})
+	}
+}
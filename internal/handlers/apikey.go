@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+func (h *Handler) APIKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		merchantID := 0
+		if v := r.URL.Query().Get("merchant_id"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				httpError(w, "invalid merchant_id", http.StatusBadRequest)
+				return
+			}
+			merchantID = id
+		}
+		keys, err := h.apiKeyService.APIKeys(merchantID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, keys)
+	case http.MethodPost:
+		input := models.APIKeyInput{}
+		reqBody, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(bytes.TrimSpace(reqBody)) != 0 {
+			err = json.Unmarshal(reqBody, &input)
+			if err != nil {
+				httpError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		key, err := h.apiKeyService.IssueAPIKey(input)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, key)
+	default:
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request, id int) {
+	err := h.apiKeyService.RevokeAPIKey(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) MintToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	input := models.TokenInput{}
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = json.Unmarshal(reqBody, &input)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token, err := h.tokenService.MintToken(input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, token)
+}
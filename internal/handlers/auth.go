@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/altuxa/payment-service-emulator/internal/service"
+)
+
+type contextKey int
+
+const (
+	principalKey contextKey = iota
+	pathParamsKey
+	violationsKey
+)
+
+// Authenticate requires an API key or a JWT of one of the given kinds in the
+// Authorization header and passes the caller on in the request context.
+func (h *Handler) Authenticate(next http.HandlerFunc, kinds ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			unauthorized(w, "api key is required")
+			return
+		}
+		var principal models.Principal
+		var err error
+		if isJWT(token) {
+			principal, err = h.tokenService.AuthenticateToken(token)
+		} else {
+			principal, err = h.apiKeyService.Authenticate(token)
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidAPIKey), errors.Is(err, service.ErrAPIKeyRevoked), errors.Is(err, service.ErrInvalidToken):
+			unauthorized(w, err.Error())
+			return
+		case err != nil:
+			writeError(w, err)
+			return
+		}
+		if !allowedKind(principal.Kind, kinds) {
+			httpError(w, fmt.Sprintf("%s key is not allowed here, use a %s key", principal.Kind, strings.Join(kinds, " or ")), http.StatusForbidden)
+			return
+		}
+		if !validRequest(w, r) {
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey, principal)))
+	}
+}
+
+// Admin requires the ADMIN_TOKEN bearer token.
+func (h *Handler) Admin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, _ := bearerToken(r)
+		err := h.apiKeyService.AuthenticateAdmin(token)
+		switch {
+		case errors.Is(err, service.ErrAdminDisabled):
+			httpError(w, err.Error(), http.StatusForbidden)
+			return
+		case errors.Is(err, service.ErrInvalidAdmin):
+			unauthorized(w, err.Error())
+			return
+		case err != nil:
+			writeError(w, err)
+			return
+		}
+		if !validRequest(w, r) {
+			return
+		}
+		next(w, r)
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(prefix):]), true
+}
+
+// isJWT tells tokens from API keys, which never contain dots.
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="payment-service-emulator"`)
+	httpError(w, message, http.StatusUnauthorized)
+}
+
+func allowedKind(kind string, kinds []string) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func principalFrom(r *http.Request) (models.Principal, bool) {
+	principal, ok := r.Context().Value(principalKey).(models.Principal)
+	return principal, ok
+}
+
+// merchantID is the merchant every service call of the request is scoped to.
+func merchantID(r *http.Request) int {
+	principal, _ := principalFrom(r)
+	return principal.MerchantID
+}
+
+// ownPayment loads a payment the caller may see, payments of other merchants
+// and users are reported as missing so their IDs can not be probed.
+func (h *Handler) ownPayment(w http.ResponseWriter, r *http.Request, id int) (models.Transaction, bool) {
+	payment, err := h.paymentService.GetPayment(merchantID(r), id)
+	if err == nil && !owns(r, payment) {
+		err = &service.NotFoundError{Err: repository.ErrPaymentNotFound}
+	}
+	if err != nil {
+		writeError(w, err)
+		return payment, false
+	}
+	return payment, true
+}
+
+func owns(r *http.Request, payment models.Transaction) bool {
+	principal, ok := principalFrom(r)
+	switch {
+	case !ok:
+		return false
+	case payment.MerchantID != principal.MerchantID:
+		return false
+	case principal.Kind == models.KeyUser:
+		return payment.UserID == principal.UserID
+	default:
+		return true
+	}
+}
+
+func ownPayments(r *http.Request, payments []models.Transaction) []models.Transaction {
+	own := []models.Transaction{}
+	for _, payment := range payments {
+		if owns(r, payment) {
+			own = append(own, payment)
+		}
+	}
+	return own
+}
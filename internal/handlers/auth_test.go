@@ -0,0 +1,468 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/jwt"
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/altuxa/payment-service-emulator/internal/service"
+	mock_service "github.com/altuxa/payment-service-emulator/internal/service/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testMerchantID     = 1
+	testKeyID          = 1
+	testSecretKey      = "sk_test_secret"
+	testPublishableKey = "pk_test_publishable"
+	testToken          = "eyJhbGciOiJIUzI1NiJ9.eyJleHAiOjF9.c2ln"
+	testRequestID      = "req_test"
+)
+
+// errorJSON is the envelope of an error without field details answered to a
+// request with testRequestID.
+func errorJSON(code, message string) string {
+	body, _ := json.Marshal(map[string]errorBody{"error": {Code: code, Message: message, RequestID: testRequestID}})
+	return string(body)
+}
+
+// withKey authenticates a request passed to a handler directly with the
+// secret key testKeyID of testMerchantID.
+func withKey(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), principalKey, models.Principal{MerchantID: testMerchantID, APIKeyID: testKeyID, Kind: models.KeySecret}))
+}
+
+// expectOwned makes every payment belong to testMerchantID.
+func expectOwned(s *mock_service.MockPayment) {
+	s.EXPECT().GetPayment(testMerchantID, gomock.Any()).DoAndReturn(func(merchantID, id int) (models.Transaction, error) {
+		return models.Transaction{ID: id, MerchantID: merchantID}, nil
+	}).AnyTimes()
+}
+
+func expectSecretKey(s *mock_service.MockAPIKey) {
+	s.EXPECT().Authenticate(testSecretKey).Return(models.Principal{MerchantID: testMerchantID, APIKeyID: testKeyID, Kind: models.KeySecret}, nil).AnyTimes()
+}
+
+func TestAuthenticate(t *testing.T) {
+	type mockKey func(s *mock_service.MockAPIKey)
+	type mockToken func(s *mock_service.MockToken)
+	type mockPay func(s *mock_service.MockPayment)
+	tData := map[string]struct {
+		URL                 string
+		Method              string
+		Authorization       string
+		InputBody           string
+		ExpectedRequestBody string
+		ExpectedStatusCode  int
+		MockKey             mockKey
+		MockToken           mockToken
+		MockPay             mockPay
+	}{
+		"secret key": {
+			URL:                 "/payments/status/1",
+			Method:              "GET",
+			Authorization:       "Bearer " + testSecretKey,
+			ExpectedRequestBody: `{"ID":1,"Status":"SUCCESS"}`,
+			ExpectedStatusCode:  200,
+			MockKey:             expectSecretKey,
+			MockPay: func(s *mock_service.MockPayment) {
+				s.EXPECT().GetPayment(testMerchantID, 1).Return(models.Transaction{ID: 1, Status: models.StatusSuccess, MerchantID: testMerchantID}, nil)
+			},
+		},
+		"publishable key": {
+			URL:                 "/payments/status/1",
+			Method:              "GET",
+			Authorization:       "bearer " + testPublishableKey,
+			ExpectedRequestBody: `{"ID":1,"Status":"SUCCESS"}`,
+			ExpectedStatusCode:  200,
+			MockKey: func(s *mock_service.MockAPIKey) {
+				s.EXPECT().Authenticate(testPublishableKey).Return(models.Principal{MerchantID: testMerchantID, APIKeyID: testKeyID, Kind: models.KeyPublishable}, nil)
+			},
+			MockPay: func(s *mock_service.MockPayment) {
+				s.EXPECT().GetPayment(testMerchantID, 1).Return(models.Transaction{ID: 1, Status: models.StatusSuccess, MerchantID: testMerchantID}, nil)
+			},
+		},
+		"publishable key on secret route": {
+			URL:                 "/payments/1/history",
+			Method:              "GET",
+			Authorization:       "Bearer " + testPublishableKey,
+			ExpectedRequestBody: errorJSON(codeForbidden, "publishable key is not allowed here, use a secret key"),
+			ExpectedStatusCode:  403,
+			MockKey: func(s *mock_service.MockAPIKey) {
+				s.EXPECT().Authenticate(testPublishableKey).Return(models.Principal{MerchantID: testMerchantID, APIKeyID: testKeyID, Kind: models.KeyPublishable}, nil)
+			},
+			MockPay: func(s *mock_service.MockPayment) {},
+		},
+		"payment of another merchant": {
+			URL:                 "/payments/status/2",
+			Method:              "GET",
+			Authorization:       "Bearer " + testSecretKey,
+			ExpectedRequestBody: errorJSON(codeNotFound, "payment not found"),
+			ExpectedStatusCode:  404,
+			MockKey:             expectSecretKey,
+			MockPay: func(s *mock_service.MockPayment) {
+				s.EXPECT().GetPayment(testMerchantID, 2).Return(models.Transaction{ID: 2, Status: models.StatusSuccess, MerchantID: 2}, nil)
+			},
+		},
+		"merchant token": {
+			URL:                 "/payments/1/history",
+			Method:              "GET",
+			Authorization:       "Bearer " + testToken,
+			ExpectedRequestBody: "[]",
+			ExpectedStatusCode:  200,
+			MockKey:             func(s *mock_service.MockAPIKey) {},
+			MockToken: func(s *mock_service.MockToken) {
+				s.EXPECT().AuthenticateToken(testToken).Return(models.Principal{MerchantID: testMerchantID, Kind: models.KeySecret}, nil)
+			},
+			MockPay: func(s *mock_service.MockPayment) {
+				s.EXPECT().GetPayment(testMerchantID, 1).Return(models.Transaction{ID: 1, MerchantID: testMerchantID}, nil)
+				s.EXPECT().History(testMerchantID, 1).Return([]models.PaymentEvent{}, nil)
+			},
+		},
+		"user token own payment": {
+			URL:                 "/payments/status/1",
+			Method:              "GET",
+			Authorization:       "Bearer " + testToken,
+			ExpectedRequestBody: `{"ID":1,"Status":"SUCCESS"}`,
+			ExpectedStatusCode:  200,
+			MockKey:             func(s *mock_service.MockAPIKey) {},
+			MockToken: func(s *mock_service.MockToken) {
+				s.EXPECT().AuthenticateToken(testToken).Return(models.Principal{MerchantID: testMerchantID, UserID: 5, Kind: models.KeyUser}, nil)
+			},
+			MockPay: func(s *mock_service.MockPayment) {
+				s.EXPECT().GetPayment(testMerchantID, 1).Return(models.Transaction{ID: 1, UserID: 5, Status: models.StatusSuccess, MerchantID: testMerchantID}, nil)
+			},
+		},
+		"user token payment of another user": {
+			URL:                 "/payments/status/1",
+			Method:              "GET",
+			Authorization:       "Bearer " + testToken,
+			ExpectedRequestBody: errorJSON(codeNotFound, "payment not found"),
+			ExpectedStatusCode:  404,
+			MockKey:             func(s *mock_service.MockAPIKey) {},
+			MockToken: func(s *mock_service.MockToken) {
+				s.EXPECT().AuthenticateToken(testToken).Return(models.Principal{MerchantID: testMerchantID, UserID: 5, Kind: models.KeyUser}, nil)
+			},
+			MockPay: func(s *mock_service.MockPayment) {
+				s.EXPECT().GetPayment(testMerchantID, 1).Return(models.Transaction{ID: 1, UserID: 6, Status: models.StatusSuccess, MerchantID: testMerchantID}, nil)
+			},
+		},
+		"user token payment for another user": {
+			URL:                 "/payments/new",
+			Method:              "POST",
+			Authorization:       "Bearer " + testToken,
+			InputBody:           `{"UserID":6,"Email":"ann@mail.ru","Sum":"10.00","Currency":"USD"}`,
+			ExpectedRequestBody: errorJSON(codeForbidden, "user token can not create payments for another user"),
+			ExpectedStatusCode:  403,
+			MockKey:             func(s *mock_service.MockAPIKey) {},
+			MockToken: func(s *mock_service.MockToken) {
+				s.EXPECT().AuthenticateToken(testToken).Return(models.Principal{MerchantID: testMerchantID, UserID: 5, Kind: models.KeyUser}, nil)
+			},
+			MockPay: func(s *mock_service.MockPayment) {},
+		},
+		"user token on merchant route": {
+			URL:                 "/payments/cancel/1",
+			Method:              "POST",
+			Authorization:       "Bearer " + testToken,
+			ExpectedRequestBody: errorJSON(codeForbidden, "user key is not allowed here, use a secret key"),
+			ExpectedStatusCode:  403,
+			MockKey:             func(s *mock_service.MockAPIKey) {},
+			MockToken: func(s *mock_service.MockToken) {
+				s.EXPECT().AuthenticateToken(testToken).Return(models.Principal{MerchantID: testMerchantID, UserID: 5, Kind: models.KeyUser}, nil)
+			},
+			MockPay: func(s *mock_service.MockPayment) {},
+		},
+		"invalid token": {
+			URL:                 "/payments/status/1",
+			Method:              "GET",
+			Authorization:       "Bearer " + testToken,
+			ExpectedRequestBody: errorJSON(codeUnauthorized, "invalid token: jwt: token is expired"),
+			ExpectedStatusCode:  401,
+			MockKey:             func(s *mock_service.MockAPIKey) {},
+			MockToken: func(s *mock_service.MockToken) {
+				s.EXPECT().AuthenticateToken(testToken).Return(models.Principal{}, fmt.Errorf("%w: %v", service.ErrInvalidToken, jwt.ErrExpired))
+			},
+			MockPay: func(s *mock_service.MockPayment) {},
+		},
+		"missing key": {
+			URL:                 "/payments/status/1",
+			Method:              "GET",
+			ExpectedRequestBody: errorJSON(codeUnauthorized, "api key is required"),
+			ExpectedStatusCode:  401,
+			MockKey:             func(s *mock_service.MockAPIKey) {},
+			MockPay:             func(s *mock_service.MockPayment) {},
+		},
+		"invalid key": {
+			URL:                 "/payments/new",
+			Method:              "POST",
+			Authorization:       "Bearer sk_test_unknown",
+			ExpectedRequestBody: errorJSON(codeUnauthorized, "invalid api key"),
+			ExpectedStatusCode:  401,
+			MockKey: func(s *mock_service.MockAPIKey) {
+				s.EXPECT().Authenticate("sk_test_unknown").Return(models.Principal{}, service.ErrInvalidAPIKey)
+			},
+			MockPay: func(s *mock_service.MockPayment) {},
+		},
+		"revoked key": {
+			URL:                 "/refunds/1",
+			Method:              "GET",
+			Authorization:       "Bearer " + testSecretKey,
+			ExpectedRequestBody: errorJSON(codeUnauthorized, "api key has been revoked"),
+			ExpectedStatusCode:  401,
+			MockKey: func(s *mock_service.MockAPIKey) {
+				s.EXPECT().Authenticate(testSecretKey).Return(models.Principal{}, service.ErrAPIKeyRevoked)
+			},
+			MockPay: func(s *mock_service.MockPayment) {},
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			key := mock_service.NewMockAPIKey(c)
+			v.MockKey(key)
+			token := mock_service.NewMockToken(c)
+			if v.MockToken != nil {
+				v.MockToken(token)
+			}
+			pay := mock_service.NewMockPayment(c)
+			v.MockPay(pay)
+			services := service.Services{
+				APIKey:  key,
+				Token:   token,
+				Payment: pay,
+			}
+			handler := NewHandler(&services)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(v.Method, v.URL, bytes.NewBufferString(v.InputBody))
+			if v.Authorization != "" {
+				req.Header.Set("Authorization", v.Authorization)
+			}
+			req.Header.Set(requestIDHeader, testRequestID)
+			handler.Routes().ServeHTTP(w, req)
+			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
+			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assertDocumented(t, req, w)
+			if v.ExpectedStatusCode == 401 {
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAdmin(t *testing.T) {
+	type mockKey func(s *mock_service.MockAPIKey)
+	type mockToken func(s *mock_service.MockToken)
+	type mockMerchant func(s *mock_service.MockMerchant)
+	rate := 0.5
+	created := time.Date(2022, 06, 11, 18, 45, 47, 724748010, time.Local)
+	tData := map[string]struct {
+		URL                 string
+		Method              string
+		InputBody           string
+		ExpectedRequestBody string
+		ExpectedStatusCode  int
+		MockKey             mockKey
+		MockToken           mockToken
+		MockMerchant        mockMerchant
+	}{
+		"create merchant": {
+			URL:                 "/admin/merchants",
+			Method:              "POST",
+			InputBody:           `{"Name":"shop","Currencies":["USD"],"FailRate":0.5}`,
+			ExpectedRequestBody: `{"ID":1,"Name":"shop","Currencies":["USD"],"FailRate":0.5,"CreatedAt":"2022-06-11T18:45:47.72474801+06:00","UpdatedAt":"2022-06-11T18:45:47.72474801+06:00"}`,
+			ExpectedStatusCode:  201,
+			MockKey: func(s *mock_service.MockAPIKey) {
+				s.EXPECT().AuthenticateAdmin("admin").Return(nil)
+			},
+			MockMerchant: func(s *mock_service.MockMerchant) {
+				name := "shop"
+				input := models.MerchantInput{Name: &name, Currencies: []string{"USD"}, OutcomeRates: models.OutcomeRates{FailRate: &rate}}
+				s.EXPECT().CreateMerchant(input).Return(models.Merchant{
+					ID:           1,
+					Name:         "shop",
+					Currencies:   []string{"USD"},
+					OutcomeRates: models.OutcomeRates{FailRate: &rate},
+					CreatedAt:    created,
+					UpdatedAt:    created,
+				}, nil)
+			},
+		},
+		"update merchant": {
+			URL:                 "/admin/merchants/1",
+			Method:              "PATCH",
+			InputBody:           `{"Currencies":[]}`,
+			ExpectedRequestBody: `{"ID":1,"Name":"shop","Currencies":[],"CreatedAt":"2022-06-11T18:45:47.72474801+06:00","UpdatedAt":"2022-06-11T18:45:47.72474801+06:00"}`,
+			ExpectedStatusCode:  200,
+			MockKey: func(s *mock_service.MockAPIKey) {
+				s.EXPECT().AuthenticateAdmin("admin").Return(nil)
+			},
+			MockMerchant: func(s *mock_service.MockMerchant) {
+				s.EXPECT().UpdateMerchant(1, models.MerchantInput{Currencies: []string{}}).Return(models.Merchant{
+					ID:         1,
+					Name:       "shop",
+					Currencies: []string{},
+					CreatedAt:  created,
+					UpdatedAt:  created,
+				}, nil)
+			},
+		},
+		"unknown merchant": {
+			URL:                 "/admin/merchants/2",
+			Method:              "GET",
+			ExpectedRequestBody: errorJSON(codeNotFound, "merchant not found"),
+			ExpectedStatusCode:  404,
+			MockKey: func(s *mock_service.MockAPIKey) {
+				s.EXPECT().AuthenticateAdmin("admin").Return(nil)
+			},
+			MockMerchant: func(s *mock_service.MockMerchant) {
+				s.EXPECT().GetMerchant(2).Return(models.Merchant{}, &service.NotFoundError{Err: repository.ErrMerchantNotFound})
+			},
+		},
+		"issue without merchant": {
+			URL:                 "/admin/api-keys",
+			Method:              "POST",
+			InputBody:           `{"Name":"shop"}`,
+			ExpectedRequestBody: `{"error":{"code":"validation_error","message":"invalid input","fields":[{"field":"MerchantID","message":"is required"}],"request_id":"req_test"}}`,
+			ExpectedStatusCode:  422,
+			MockKey: func(s *mock_service.MockAPIKey) {
+				s.EXPECT().AuthenticateAdmin("admin").Return(nil)
+			},
+		},
+		"issue": {
+			URL:                 "/admin/api-keys",
+			Method:              "POST",
+			InputBody:           `{"MerchantID":1,"Name":"shop"}`,
+			ExpectedRequestBody: `{"ID":1,"MerchantID":1,"Name":"shop","SecretKey":"sk_test_secret","PublishableKey":"pk_test_publishable","SecretPrefix":"sk_test_secr","PublishablePrefix":"pk_test_publ","CreatedAt":"2022-06-11T18:45:47.72474801+06:00"}`,
+			ExpectedStatusCode:  201,
+			MockKey: func(s *mock_service.MockAPIKey) {
+				s.EXPECT().AuthenticateAdmin("admin").Return(nil)
+				s.EXPECT().IssueAPIKey(models.APIKeyInput{MerchantID: 1, Name: "shop"}).Return(models.APIKey{
+					ID:                1,
+					MerchantID:        1,
+					Name:              "shop",
+					SecretKey:         testSecretKey,
+					PublishableKey:    testPublishableKey,
+					SecretPrefix:      "sk_test_secr",
+					PublishablePrefix: "pk_test_publ",
+					CreatedAt:         created,
+				}, nil)
+			},
+		},
+		"list": {
+			URL:                 "/admin/api-keys?merchant_id=1",
+			Method:              "GET",
+			ExpectedRequestBody: `[{"ID":1,"MerchantID":1,"Name":"shop","SecretPrefix":"sk_test_secr","PublishablePrefix":"pk_test_publ","CreatedAt":"2022-06-11T18:45:47.72474801+06:00","RevokedAt":"2022-06-11T18:45:47.72474801+06:00"}]`,
+			ExpectedStatusCode:  200,
+			MockKey: func(s *mock_service.MockAPIKey) {
+				s.EXPECT().AuthenticateAdmin("admin").Return(nil)
+				s.EXPECT().APIKeys(1).Return([]models.APIKey{{
+					ID:                1,
+					MerchantID:        1,
+					Name:              "shop",
+					SecretPrefix:      "sk_test_secr",
+					PublishablePrefix: "pk_test_publ",
+					CreatedAt:         created,
+					RevokedAt:         &created,
+				}}, nil)
+			},
+		},
+		"revoke": {
+			URL:                 "/admin/api-keys/1",
+			Method:              "DELETE",
+			ExpectedRequestBody: "",
+			ExpectedStatusCode:  204,
+			MockKey: func(s *mock_service.MockAPIKey) {
+				s.EXPECT().AuthenticateAdmin("admin").Return(nil)
+				s.EXPECT().RevokeAPIKey(1).Return(nil)
+			},
+		},
+		"mint token": {
+			URL:                 "/admin/tokens",
+			Method:              "POST",
+			InputBody:           `{"MerchantID":1,"TTL":"15m"}`,
+			ExpectedRequestBody: `{"Token":"` + testToken + `","Algorithm":"HS256","ExpiresAt":"2022-06-11T18:45:47.72474801+06:00"}`,
+			ExpectedStatusCode:  201,
+			MockKey: func(s *mock_service.MockAPIKey) {
+				s.EXPECT().AuthenticateAdmin("admin").Return(nil)
+			},
+			MockToken: func(s *mock_service.MockToken) {
+				s.EXPECT().MintToken(models.TokenInput{MerchantID: 1, TTL: "15m"}).Return(models.Token{Token: testToken, Algorithm: "HS256", ExpiresAt: created}, nil)
+			},
+		},
+		"mint token without signing key": {
+			URL:                 "/admin/tokens",
+			Method:              "POST",
+			InputBody:           `{"MerchantID":1,"UserID":5,"Algorithm":"RS256"}`,
+			ExpectedRequestBody: `{"error":{"code":"validation_error","message":"invalid input","fields":[{"field":"Algorithm","message":"no RS256 private key is configured, set JWT_RS256_PRIVATE_KEY_FILE"}],"request_id":"req_test"}}`,
+			ExpectedStatusCode:  422,
+			MockKey: func(s *mock_service.MockAPIKey) {
+				s.EXPECT().AuthenticateAdmin("admin").Return(nil)
+			},
+			MockToken: func(s *mock_service.MockToken) {
+				vErr := &service.ValidationError{}
+				vErr.Add("Algorithm", "no RS256 private key is configured, set JWT_RS256_PRIVATE_KEY_FILE")
+				s.EXPECT().MintToken(models.TokenInput{MerchantID: 1, UserID: 5, Algorithm: "RS256"}).Return(models.Token{}, vErr)
+			},
+		},
+		"invalid admin token": {
+			URL:                 "/admin/api-keys",
+			Method:              "GET",
+			ExpectedRequestBody: errorJSON(codeUnauthorized, "invalid admin token"),
+			ExpectedStatusCode:  401,
+			MockKey: func(s *mock_service.MockAPIKey) {
+				s.EXPECT().AuthenticateAdmin("admin").Return(service.ErrInvalidAdmin)
+			},
+		},
+		"admin disabled": {
+			URL:                 "/admin/api-keys",
+			Method:              "GET",
+			ExpectedRequestBody: errorJSON(codeForbidden, service.ErrAdminDisabled.Error()),
+			ExpectedStatusCode:  403,
+			MockKey: func(s *mock_service.MockAPIKey) {
+				s.EXPECT().AuthenticateAdmin("admin").Return(service.ErrAdminDisabled)
+			},
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			key := mock_service.NewMockAPIKey(c)
+			v.MockKey(key)
+			token := mock_service.NewMockToken(c)
+			if v.MockToken != nil {
+				v.MockToken(token)
+			}
+			merchant := mock_service.NewMockMerchant(c)
+			if v.MockMerchant != nil {
+				v.MockMerchant(merchant)
+			}
+			services := service.Services{
+				Merchant: merchant,
+				APIKey:   key,
+				Token:    token,
+			}
+			handler := NewHandler(&services)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(v.Method, v.URL, bytes.NewBufferString(v.InputBody))
+			req.Header.Set("Authorization", "Bearer admin")
+			req.Header.Set(requestIDHeader, testRequestID)
+			handler.Routes().ServeHTTP(w, req)
+			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
+			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assertDocumented(t, req, w)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/altuxa/payment-service-emulator/internal/service"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// Codes of the error envelope, they are stable while messages may change.
+const (
+	codeBadRequest       = "bad_request"
+	codeUnauthorized     = "unauthorized"
+	codeForbidden        = "forbidden"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeConflict         = "conflict"
+	codeUnprocessable    = "unprocessable_entity"
+	codeValidation       = "validation_error"
+	codeInternal         = "internal_error"
+)
+
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          codeBadRequest,
+	http.StatusUnauthorized:        codeUnauthorized,
+	http.StatusForbidden:           codeForbidden,
+	http.StatusNotFound:            codeNotFound,
+	http.StatusMethodNotAllowed:    codeMethodNotAllowed,
+	http.StatusConflict:            codeConflict,
+	http.StatusUnprocessableEntity: codeUnprocessable,
+	http.StatusInternalServerError: codeInternal,
+}
+
+type errorBody struct {
+	Code      string               `json:"code"`
+	Message   string               `json:"message"`
+	Fields    []service.FieldError `json:"fields,omitempty"`
+	RequestID string               `json:"request_id,omitempty"`
+}
+
+// writeError answers with the status code of a typed service error. Errors
+// of unknown type are internal, their details are logged instead of sent.
+func writeError(w http.ResponseWriter, err error) {
+	var vErr *service.ValidationError
+	var notFound *service.NotFoundError
+	var conflict *service.ConflictError
+	switch {
+	case errors.As(err, &vErr):
+		writeErrorBody(w, http.StatusUnprocessableEntity, errorBody{
+			Code:    codeValidation,
+			Message: "invalid input",
+			Fields:  vErr.Fields,
+		})
+	case errors.As(err, &notFound):
+		httpError(w, err.Error(), http.StatusNotFound)
+	case errors.As(err, &conflict):
+		httpError(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("request %s failed: %v", w.Header().Get(requestIDHeader), err)
+		httpError(w, "internal error", http.StatusInternalServerError)
+	}
+}
+
+// httpError is http.Error with the error envelope.
+func httpError(w http.ResponseWriter, message string, code int) {
+	writeErrorBody(w, code, errorBody{Code: statusCodes[code], Message: message})
+}
+
+func writeErrorBody(w http.ResponseWriter, code int, body errorBody) {
+	body.RequestID = w.Header().Get(requestIDHeader)
+	output, _ := json.Marshal(struct {
+		Error errorBody `json:"error"`
+	}{body})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	w.Write(output)
+}
+
+// notFound replaces http.NotFound for paths no route serves.
+func notFound(w http.ResponseWriter, r *http.Request) {
+	httpError(w, "route not found", http.StatusNotFound)
+}
+
+// withRequestID tags every response with the X-Request-ID of the request, or
+// a new one when the client sent none, so errors can be traced in the logs.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/altuxa/payment-service-emulator/internal/service"
+	"github.com/altuxa/payment-service-emulator/internal/statemachine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteError(t *testing.T) {
+	vErr := &service.ValidationError{}
+	vErr.Add("Email", "is required")
+	tData := map[string]struct {
+		Err                 error
+		ExpectedRequestBody string
+		ExpectedStatusCode  int
+	}{
+		"validation": {
+			Err:                 vErr,
+			ExpectedRequestBody: `{"error":{"code":"validation_error","message":"invalid input","fields":[{"field":"Email","message":"is required"}],"request_id":"req_test"}}`,
+			ExpectedStatusCode:  422,
+		},
+		"not found": {
+			Err:                 &service.NotFoundError{Err: repository.ErrUserNotFound},
+			ExpectedRequestBody: errorJSON(codeNotFound, "user not found"),
+			ExpectedStatusCode:  404,
+		},
+		"conflict": {
+			Err:                 &service.ConflictError{Err: &statemachine.TransitionError{From: "FAIL", To: "REFUNDED"}},
+			ExpectedRequestBody: errorJSON(codeConflict, "payment status transition FAIL -> REFUNDED is not allowed"),
+			ExpectedStatusCode:  409,
+		},
+		"internal": {
+			Err:                 &service.InternalError{Err: errors.New("database is locked")},
+			ExpectedRequestBody: errorJSON(codeInternal, "internal error"),
+			ExpectedStatusCode:  500,
+		},
+		"untyped": {
+			Err:                 errors.New("database is locked"),
+			ExpectedRequestBody: errorJSON(codeInternal, "internal error"),
+			ExpectedStatusCode:  500,
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			w.Header().Set(requestIDHeader, testRequestID)
+			writeError(w, v.Err)
+			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
+			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	handler := NewHandler(&service.Services{})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/v1/unknown", nil)
+	req.Header.Set(requestIDHeader, testRequestID)
+	handler.Routes().ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+	assert.Equal(t, testRequestID, w.Header().Get(requestIDHeader))
+	assert.Equal(t, errorJSON(codeNotFound, "route not found"), w.Body.String())
+
+	w = httptest.NewRecorder()
+	handler.Routes().ServeHTTP(w, httptest.NewRequest("GET", "/unknown", nil))
+	assert.Equal(t, 404, w.Code)
+	assert.Len(t, w.Header().Get(requestIDHeader), 24)
+	assert.Contains(t, w.Body.String(), `"request_id":"`+w.Header().Get(requestIDHeader)+`"`)
+}
@@ -1,37 +1,109 @@
 package handlers
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
 
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/openapi"
 	"github.com/altuxa/payment-service-emulator/internal/service"
 )
 
 const addr = ":8080"
 
 type Handler struct {
-	userService    service.User
-	paymentService service.Payment
+	userService        service.User
+	merchantService    service.Merchant
+	apiKeyService      service.APIKey
+	tokenService       service.Token
+	paymentService     service.Payment
+	refundService      service.Refund
+	webhookService     service.Webhook
+	idempotencyService service.Idempotency
 }
 
 func NewHandler(service *service.Services) *Handler {
 	return &Handler{
-		userService:    service.User,
-		paymentService: service.Payment,
+		userService:        service.User,
+		merchantService:    service.Merchant,
+		apiKeyService:      service.APIKey,
+		tokenService:       service.Token,
+		paymentService:     service.Payment,
+		refundService:      service.Refund,
+		webhookService:     service.Webhook,
+		idempotencyService: service.Idempotency,
 	}
 }
 
 func (h *Handler) Server() {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/payments/new", h.NewTransaction)
-	mux.HandleFunc("/payments/status/", h.StatusByID)
-	mux.HandleFunc("/payments/processing/", h.PaymentProcessing)
-	mux.HandleFunc("/payments/byid/", h.ByUserID)
-	mux.HandleFunc("/payments/byemail", h.ByUserEmail)
-	mux.HandleFunc("/payments/cancel/", h.CancelPayment)
 	log.Println("Server started at localhost:8080")
-	err := http.ListenAndServe(addr, mux)
+	err := http.ListenAndServe(addr, h.Routes())
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func (h *Handler) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/v1/", h.v1())
+	mux.HandleFunc("/openapi.json", h.OpenAPI)
+	mux.HandleFunc("/", notFound)
+	h.legacyRoutes(mux)
+	doc, err := openapi.Load()
 	if err != nil {
 		log.Fatalln(err)
 	}
+	return withRequestID(validateRequests(doc, mux))
+}
+
+func (h *Handler) v1() *router {
+	secret := func(next http.HandlerFunc) http.HandlerFunc {
+		return h.Authenticate(next, models.KeySecret)
+	}
+	rt := &router{}
+	rt.handle(http.MethodGet, "/v1/payments", h.Authenticate(h.ListPayments, models.KeySecret, models.KeyUser))
+	rt.handle(http.MethodPost, "/v1/payments", h.Authenticate(h.Idempotent(h.CreatePayment), models.KeySecret, models.KeyPublishable, models.KeyUser))
+	rt.handle(http.MethodGet, "/v1/payments/{id}", h.Authenticate(withID(h.Payment), models.KeySecret, models.KeyUser))
+	rt.handle(http.MethodGet, "/v1/payments/{id}/status", h.Authenticate(withID(h.PaymentStatus), models.KeySecret, models.KeyPublishable, models.KeyUser))
+	rt.handle(http.MethodGet, "/v1/payments/{id}/history", secret(h.withPayment(h.PaymentHistory)))
+	rt.handle(http.MethodPost, "/v1/payments/{id}/process", secret(h.withPayment(h.ProcessPayment)))
+	rt.handle(http.MethodPost, "/v1/payments/{id}/cancel", secret(h.withPayment(h.Cancel)))
+	rt.handle(http.MethodPost, "/v1/payments/{id}/capture", secret(h.withPayment(h.Capture)))
+	rt.handle(http.MethodPost, "/v1/payments/{id}/void", secret(h.withPayment(h.Void)))
+	rt.handle(http.MethodGet, "/v1/payments/{id}/refunds", secret(h.withPayment(h.PaymentRefunds)))
+	rt.handle(http.MethodPost, "/v1/payments/{id}/refunds", secret(h.Idempotent(h.withPayment(h.PaymentRefunds))))
+	rt.handle(http.MethodGet, "/v1/refunds/{id}", secret(withID(h.Refund)))
+	rt.handle(http.MethodPost, "/v1/users", secret(h.Users))
+	rt.handle(http.MethodGet, "/v1/users/{id}", secret(withID(h.User)))
+	rt.handle(http.MethodPut, "/v1/users/{id}", secret(withID(h.User)))
+	rt.handle(http.MethodPatch, "/v1/users/{id}", secret(withID(h.User)))
+	rt.handle(http.MethodGet, "/v1/users/{id}/payments", h.Authenticate(withID(h.UserPayments), models.KeySecret, models.KeyUser))
+	rt.handle(http.MethodGet, "/v1/webhooks/endpoints", secret(h.WebhookEndpoints))
+	rt.handle(http.MethodPost, "/v1/webhooks/endpoints", secret(h.WebhookEndpoints))
+	rt.handle(http.MethodDelete, "/v1/webhooks/endpoints/{id}", secret(withID(h.DeleteEndpoint)))
+	rt.handle(http.MethodGet, "/v1/webhooks/endpoints/{id}/deliveries", secret(withID(h.EndpointDeliveries)))
+	rt.handle(http.MethodPost, "/v1/webhooks/endpoints/{id}/rotate", secret(withID(h.RotateEndpointSecret)))
+	rt.handle(http.MethodPost, "/v1/webhooks/deliveries/{id}/replay", secret(withID(h.ReplayDelivery)))
+	rt.handle(http.MethodGet, "/v1/scenarios", h.Scenarios)
+	rt.handle(http.MethodGet, "/v1/admin/merchants", h.Admin(h.Merchants))
+	rt.handle(http.MethodPost, "/v1/admin/merchants", h.Admin(h.Merchants))
+	rt.handle(http.MethodGet, "/v1/admin/merchants/{id}", h.Admin(withID(h.Merchant)))
+	rt.handle(http.MethodPatch, "/v1/admin/merchants/{id}", h.Admin(withID(h.Merchant)))
+	rt.handle(http.MethodGet, "/v1/admin/api-keys", h.Admin(h.APIKeys))
+	rt.handle(http.MethodPost, "/v1/admin/api-keys", h.Admin(h.APIKeys))
+	rt.handle(http.MethodDelete, "/v1/admin/api-keys/{id}", h.Admin(withID(h.RevokeAPIKey)))
+	rt.handle(http.MethodPost, "/v1/admin/tokens", h.Admin(h.MintToken))
+	return rt
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	output, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(output)
 }
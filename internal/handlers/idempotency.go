@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/altuxa/payment-service-emulator/internal/service"
+)
+
+const (
+	idempotencyHeader    = "Idempotency-Key"
+	idempotencyKeyMaxLen = 255
+)
+
+type responseRecorder struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+	// persisted is set once the request stored something
+	persisted bool
+}
+
+// markPersisted tells Idempotent that the request stored something, its
+// response is then kept for retries even when it is a server error.
+func markPersisted(w http.ResponseWriter) {
+	if rec, ok := w.(*responseRecorder); ok {
+		rec.persisted = true
+	}
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// Idempotent replays the stored response when a request is retried with the
+// same Idempotency-Key and body. Reusing a key with a different body is
+// rejected, as is a retry that arrives while the original is still running.
+// The key is released for a new attempt only when a request failed before
+// storing anything.
+func (h *Handler) Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > idempotencyKeyMaxLen {
+			httpError(w, "idempotency key is too long", http.StatusBadRequest)
+			return
+		}
+		// keys of different merchants never collide
+		if principal, ok := principalFrom(r); ok {
+			key = strconv.Itoa(principal.MerchantID) + ":" + key
+		}
+		reqBody, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(reqBody))
+		record, err := h.idempotencyService.Begin(key, fingerprint(r, reqBody))
+		switch {
+		case errors.Is(err, service.ErrIdempotencyMismatch):
+			httpError(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		case errors.Is(err, service.ErrIdempotencyInProgress):
+			httpError(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			writeError(w, err)
+			return
+		case record != nil:
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(record.ResponseCode)
+			w.Write(record.ResponseBody)
+			return
+		}
+		rec := &responseRecorder{ResponseWriter: w}
+		next(rec, r)
+		if !rec.persisted && (rec.code == 0 || rec.code >= http.StatusInternalServerError) {
+			err = h.idempotencyService.Release(key)
+		} else {
+			err = h.idempotencyService.Complete(key, rec.code, rec.body.Bytes())
+		}
+		if err != nil {
+			log.Printf("failed to store idempotent response for key %q: %v", key, err)
+		}
+	}
+}
+
+func fingerprint(r *http.Request, body []byte) string {
+	compact := &bytes.Buffer{}
+	if json.Compact(compact, body) == nil {
+		body = compact.Bytes()
+	}
+	sum := sha256.New()
+	sum.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/money"
+	"github.com/altuxa/payment-service-emulator/internal/service"
+	mock_service "github.com/altuxa/payment-service-emulator/internal/service/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotent(t *testing.T) {
+	type mock func(s *mock_service.MockIdempotency)
+	body := `{"UserID":1,"Email":"ann@mail.ru","Sum":"502.30","Currency":"USD"}`
+	fp := fingerprint(httptest.NewRequest("POST", "/payments/new", nil), []byte(body))
+	tData := map[string]struct {
+		Key                 string
+		HandlerCode         int
+		Persisted           bool
+		ExpectedRequestBody string
+		ExpectedStatusCode  int
+		ExpectedCalls       int
+		Mock                mock
+	}{
+		"No key": {
+			HandlerCode:         200,
+			ExpectedRequestBody: "created",
+			ExpectedStatusCode:  200,
+			ExpectedCalls:       1,
+			Mock:                func(s *mock_service.MockIdempotency) {},
+		},
+		"First request": {
+			Key:                 "key-1",
+			HandlerCode:         200,
+			ExpectedRequestBody: "created",
+			ExpectedStatusCode:  200,
+			ExpectedCalls:       1,
+			Mock: func(s *mock_service.MockIdempotency) {
+				s.EXPECT().Begin("1:key-1", fp).Return(nil, nil)
+				s.EXPECT().Complete("1:key-1", 200, []byte("created")).Return(nil)
+			},
+		},
+		"Replay": {
+			Key:                 "key-1",
+			ExpectedRequestBody: "created",
+			ExpectedStatusCode:  200,
+			Mock: func(s *mock_service.MockIdempotency) {
+				s.EXPECT().Begin("1:key-1", fp).Return(&models.IdempotencyRecord{
+					Key:          "key-1",
+					Fingerprint:  fp,
+					ResponseCode: 200,
+					ResponseBody: []byte("created"),
+				}, nil)
+			},
+		},
+		"Different body": {
+			Key:                 "key-1",
+			ExpectedRequestBody: errorJSON(codeUnprocessable, "idempotency key was already used with a different request"),
+			ExpectedStatusCode:  422,
+			Mock: func(s *mock_service.MockIdempotency) {
+				s.EXPECT().Begin("1:key-1", fp).Return(nil, service.ErrIdempotencyMismatch)
+			},
+		},
+		"In progress": {
+			Key:                 "key-1",
+			ExpectedRequestBody: errorJSON(codeConflict, "a request with this idempotency key is still in progress"),
+			ExpectedStatusCode:  409,
+			Mock: func(s *mock_service.MockIdempotency) {
+				s.EXPECT().Begin("1:key-1", fp).Return(nil, service.ErrIdempotencyInProgress)
+			},
+		},
+		"Server error releases key": {
+			Key:                 "key-1",
+			HandlerCode:         500,
+			ExpectedRequestBody: "created",
+			ExpectedStatusCode:  500,
+			ExpectedCalls:       1,
+			Mock: func(s *mock_service.MockIdempotency) {
+				s.EXPECT().Begin("1:key-1", fp).Return(nil, nil)
+				s.EXPECT().Release("1:key-1").Return(nil)
+			},
+		},
+		"Server error after storing keeps key": {
+			Key:                 "key-1",
+			HandlerCode:         500,
+			Persisted:           true,
+			ExpectedRequestBody: "created",
+			ExpectedStatusCode:  500,
+			ExpectedCalls:       1,
+			Mock: func(s *mock_service.MockIdempotency) {
+				s.EXPECT().Begin("1:key-1", fp).Return(nil, nil)
+				s.EXPECT().Complete("1:key-1", 500, []byte("created")).Return(nil)
+			},
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			idem := mock_service.NewMockIdempotency(c)
+			v.Mock(idem)
+			services := service.Services{
+				Idempotency: idem,
+			}
+			handler := NewHandler(&services)
+			calls := 0
+			r := handler.Idempotent(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if v.Persisted {
+					markPersisted(w)
+				}
+				w.WriteHeader(v.HandlerCode)
+				w.Write([]byte("created"))
+			})
+			w := httptest.NewRecorder()
+			w.Header().Set(requestIDHeader, testRequestID)
+			req := withKey(httptest.NewRequest("POST", "/payments/new", bytes.NewBufferString(body)))
+			if v.Key != "" {
+				req.Header.Set("Idempotency-Key", v.Key)
+			}
+			r.ServeHTTP(w, req)
+			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
+			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assert.Equal(t, v.ExpectedCalls, calls)
+		})
+	}
+}
+
+func TestIdempotentRefund(t *testing.T) {
+	type mockIdem func(s *mock_service.MockIdempotency)
+	type mockRefund func(s *mock_service.MockRefund)
+	body := `{"Amount":"20.00"}`
+	fp := fingerprint(httptest.NewRequest("POST", "/v1/payments/1/refunds", nil), []byte(body))
+	refund := models.Refund{ID: 1, PaymentID: 1, Amount: money.New(2000, "USD"), Status: models.RefundPending}
+	refundJSON := `{"ID":1,"PaymentID":1,"Amount":"20.00","Status":"PENDING","CreatedAt":"0001-01-01T00:00:00Z","UpdatedAt":"0001-01-01T00:00:00Z","Currency":"USD"}`
+	tData := map[string]struct {
+		ExpectedRequestBody string
+		ExpectedStatusCode  int
+		ExpectedReplayed    string
+		MockIdem            mockIdem
+		MockRefund          mockRefund
+	}{
+		"First request": {
+			ExpectedRequestBody: refundJSON,
+			ExpectedStatusCode:  201,
+			MockIdem: func(s *mock_service.MockIdempotency) {
+				s.EXPECT().Begin("1:key-1", fp).Return(nil, nil)
+				s.EXPECT().Complete("1:key-1", 201, []byte(refundJSON)).Return(nil)
+			},
+			MockRefund: func(s *mock_service.MockRefund) {
+				s.EXPECT().CreateRefund(testMerchantID, 1, models.RefundInput{Amount: json.RawMessage(`"20.00"`)}).Return(refund, nil)
+			},
+		},
+		"Replay": {
+			ExpectedRequestBody: refundJSON,
+			ExpectedStatusCode:  201,
+			ExpectedReplayed:    "true",
+			MockIdem: func(s *mock_service.MockIdempotency) {
+				s.EXPECT().Begin("1:key-1", fp).Return(&models.IdempotencyRecord{
+					Key:          "key-1",
+					Fingerprint:  fp,
+					ResponseCode: 201,
+					ResponseBody: []byte(refundJSON),
+				}, nil)
+			},
+			MockRefund: func(s *mock_service.MockRefund) {},
+		},
+		"Different body": {
+			ExpectedRequestBody: errorJSON(codeUnprocessable, "idempotency key was already used with a different request"),
+			ExpectedStatusCode:  422,
+			MockIdem: func(s *mock_service.MockIdempotency) {
+				s.EXPECT().Begin("1:key-1", fp).Return(nil, service.ErrIdempotencyMismatch)
+			},
+			MockRefund: func(s *mock_service.MockRefund) {},
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			idem := mock_service.NewMockIdempotency(c)
+			v.MockIdem(idem)
+			ref := mock_service.NewMockRefund(c)
+			v.MockRefund(ref)
+			pay := mock_service.NewMockPayment(c)
+			expectOwned(pay)
+			key := mock_service.NewMockAPIKey(c)
+			expectSecretKey(key)
+			services := service.Services{
+				APIKey:      key,
+				Payment:     pay,
+				Refund:      ref,
+				Idempotency: idem,
+			}
+			handler := NewHandler(&services)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/v1/payments/1/refunds", bytes.NewBufferString(body))
+			req.Header.Set("Authorization", "Bearer "+testSecretKey)
+			req.Header.Set("Idempotency-Key", "key-1")
+			req.Header.Set(requestIDHeader, testRequestID)
+			handler.Routes().ServeHTTP(w, req)
+			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
+			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assert.Equal(t, v.ExpectedReplayed, w.Header().Get("Idempotent-Replayed"))
+			assertDocumented(t, req, w)
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/altuxa/payment-service-emulator/internal/service"
+)
+
+// legacyRoutes keeps the routes that predate /v1 working for existing
+// clients. They parse IDs out of the path themselves and answer with the
+// status of a payment where /v1 returns the whole payment.
+func (h *Handler) legacyRoutes(mux *http.ServeMux) {
+	legacy := func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, deprecated(handler))
+	}
+	legacy("/payments", h.Authenticate(h.ListPayments, models.KeySecret, models.KeyUser))
+	legacy("/payments/new", h.Authenticate(h.Idempotent(h.NewTransaction), models.KeySecret, models.KeyPublishable, models.KeyUser))
+	legacy("/payments/status/", h.Authenticate(h.StatusByID, models.KeySecret, models.KeyPublishable, models.KeyUser))
+	legacy("/payments/processing/", h.Authenticate(h.PaymentProcessing, models.KeySecret))
+	legacy("/payments/byid/", h.Authenticate(h.ByUserID, models.KeySecret, models.KeyUser))
+	legacy("/payments/byemail", h.Authenticate(h.ByUserEmail, models.KeySecret))
+	legacy("/payments/cancel/", h.Authenticate(h.CancelPayment, models.KeySecret))
+	legacy("/payments/", h.Authenticate(h.PaymentResource, models.KeySecret))
+	legacy("/refunds/", h.Authenticate(h.RefundByID, models.KeySecret))
+	legacy("/admin/merchants", h.Admin(h.Merchants))
+	legacy("/admin/merchants/", h.Admin(h.MerchantResource))
+	legacy("/admin/api-keys", h.Admin(h.APIKeys))
+	legacy("/admin/api-keys/", h.Admin(h.APIKeyResource))
+	legacy("/admin/tokens", h.Admin(h.MintToken))
+	legacy("/users", h.Authenticate(h.Users, models.KeySecret))
+	legacy("/users/", h.Authenticate(h.UserResource, models.KeySecret))
+	legacy("/scenarios", h.Scenarios)
+	legacy("/webhooks/endpoints", h.Authenticate(h.WebhookEndpoints, models.KeySecret))
+	legacy("/webhooks/endpoints/", h.Authenticate(h.WebhookEndpointResource, models.KeySecret))
+	legacy("/webhooks/deliveries/", h.Authenticate(h.WebhookDeliveryResource, models.KeySecret))
+}
+
+// deprecated marks responses of legacy routes, clients should move to /v1.
+func deprecated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", `</v1/>; rel="successor-version"`)
+		next(w, r)
+	}
+}
+
+func (h *Handler) NewTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	payment, ok := h.createPayment(w, r, true)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, models.StatusOutput{ID: payment.ID, Status: payment.Status})
+}
+
+func (h *Handler) StatusByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	strId := strings.TrimPrefix(r.URL.Path, "/payments/status/")
+	id, err := strconv.Atoi(strId)
+	if err != nil {
+		httpError(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	h.PaymentStatus(w, r, id)
+}
+
+func (h *Handler) PaymentProcessing(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	strId := strings.TrimPrefix(r.URL.Path, "/payments/processing/")
+	id, err := strconv.Atoi(strId)
+	if err != nil {
+		httpError(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.ownPayment(w, r, id); !ok {
+		return
+	}
+	status, ok := h.processPayment(w, r, id)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, models.StatusOutput{ID: id, Status: status})
+}
+
+// ByUserID returns every payment of a user at once, GET /v1/users/{id}/payments
+// pages through them.
+func (h *Handler) ByUserID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	strId := strings.TrimPrefix(r.URL.Path, "/payments/byid/")
+	userID, err := strconv.Atoi(strId)
+	if err != nil {
+		httpError(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	transactions, err := h.paymentService.ByUserID(merchantID(r), userID)
+	if err == nil {
+		transactions = ownPayments(r, transactions)
+		if len(transactions) == 0 {
+			err = &service.NotFoundError{Err: repository.ErrPaymentNotFound}
+		}
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, transactions)
+}
+
+// ByUserEmail reads the email from a GET body, GET /v1/payments?email= takes
+// it as a query parameter.
+func (h *Handler) ByUserEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	input := models.InputByUserEmail{}
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = json.Unmarshal(reqBody, &input)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	transactions, err := h.paymentService.ByUserEmail(merchantID(r), input.Email)
+	if err == nil {
+		transactions = ownPayments(r, transactions)
+		if len(transactions) == 0 {
+			err = &service.NotFoundError{Err: repository.ErrPaymentNotFound}
+		}
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, transactions)
+}
+
+func (h *Handler) CancelPayment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	strID := strings.TrimPrefix(r.URL.Path, "/payments/cancel/")
+	id, err := strconv.Atoi(strID)
+	if err != nil {
+		httpError(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.ownPayment(w, r, id); !ok {
+		return
+	}
+	if !h.cancelPayment(w, r, id) {
+		return
+	}
+	writeJSON(w, http.StatusOK, models.StatusOutput{ID: id, Status: models.StatusCancelled})
+}
+
+func (h *Handler) PaymentResource(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/payments/"), "/")
+	if len(parts) != 2 {
+		notFound(w, r)
+		return
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		httpError(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.ownPayment(w, r, id); !ok {
+		return
+	}
+	switch parts[1] {
+	case "history":
+		h.PaymentHistory(w, r, id)
+	case "capture":
+		h.Capture(w, r, id)
+	case "void":
+		h.Void(w, r, id)
+	case "refunds":
+		h.PaymentRefunds(w, r, id)
+	default:
+		notFound(w, r)
+	}
+}
+
+func (h *Handler) RefundByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/refunds/"))
+	if err != nil {
+		httpError(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	h.Refund(w, r, id)
+}
+
+func (h *Handler) UserResource(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/users/"))
+	if err != nil {
+		httpError(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	h.User(w, r, id)
+}
+
+func (h *Handler) WebhookEndpointResource(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/webhooks/endpoints/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		httpError(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		h.DeleteEndpoint(w, r, id)
+	case len(parts) == 2 && parts[1] == "deliveries" && r.Method == http.MethodGet:
+		h.EndpointDeliveries(w, r, id)
+	case len(parts) == 2 && parts[1] == "rotate" && r.Method == http.MethodPost:
+		h.RotateEndpointSecret(w, r, id)
+	case len(parts) == 1 || len(parts) == 2 && (parts[1] == "deliveries" || parts[1] == "rotate"):
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		notFound(w, r)
+	}
+}
+
+func (h *Handler) WebhookDeliveryResource(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/webhooks/deliveries/"), "/")
+	if len(parts) != 2 || parts[1] != "replay" {
+		notFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		httpError(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	h.ReplayDelivery(w, r, id)
+}
+
+func (h *Handler) MerchantResource(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/admin/merchants/"))
+	if err != nil {
+		httpError(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	h.Merchant(w, r, id)
+}
+
+func (h *Handler) APIKeyResource(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/admin/api-keys/"))
+	if err != nil {
+		httpError(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	h.RevokeAPIKey(w, r, id)
+}
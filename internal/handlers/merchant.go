@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+func (h *Handler) Merchants(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		merchants, err := h.merchantService.Merchants()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, merchants)
+	case http.MethodPost:
+		input, ok := readMerchantInput(w, r)
+		if !ok {
+			return
+		}
+		merchant, err := h.merchantService.CreateMerchant(input)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, merchant)
+	default:
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) Merchant(w http.ResponseWriter, r *http.Request, id int) {
+	var merchant models.Merchant
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		merchant, err = h.merchantService.GetMerchant(id)
+	case http.MethodPatch:
+		input, ok := readMerchantInput(w, r)
+		if !ok {
+			return
+		}
+		merchant, err = h.merchantService.UpdateMerchant(id, input)
+	default:
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, merchant)
+}
+
+func readMerchantInput(w http.ResponseWriter, r *http.Request) (models.MerchantInput, bool) {
+	input := models.MerchantInput{}
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return input, false
+	}
+	err = json.Unmarshal(reqBody, &input)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return input, false
+	}
+	return input, true
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/altuxa/payment-service-emulator/internal/openapi"
+	"github.com/altuxa/payment-service-emulator/internal/service"
+)
+
+// OpenAPI serves the OpenAPI 3 document describing every route.
+func (h *Handler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openapi.JSON())
+}
+
+// validateRequests checks the query and JSON body of requests against the
+// document. Paths the document does not know and bodies that are not JSON are
+// left to the handlers. Violations are only attached to the request, they are
+// rejected by validRequest once the caller is authenticated so a request
+// without credentials still gets 401.
+func validateRequests(doc *openapi.Document, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, ok := doc.Operation(r.Method, r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		reqBody, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(reqBody))
+		violations, err := op.ValidateRequest(r.URL.Query(), reqBody)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if len(violations) != 0 {
+			vErr := &service.ValidationError{}
+			for _, v := range violations {
+				vErr.Add(v.Field, v.Message)
+			}
+			r = r.WithContext(context.WithValue(r.Context(), violationsKey, vErr))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validRequest answers with 422 when validateRequests found violations.
+func validRequest(w http.ResponseWriter, r *http.Request) bool {
+	vErr, ok := r.Context().Value(violationsKey).(*service.ValidationError)
+	if ok {
+		writeError(w, vErr)
+	}
+	return !ok
+}
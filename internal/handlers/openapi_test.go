@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/altuxa/payment-service-emulator/internal/openapi"
+	"github.com/altuxa/payment-service-emulator/internal/service"
+	mock_service "github.com/altuxa/payment-service-emulator/internal/service/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+var testDocument, _ = openapi.Load()
+
+// assertDocumented fails when the response to req does not match the schema
+// the document gives for its status code. Requests the document does not know,
+// like those with a wrong method, are skipped.
+func assertDocumented(t *testing.T, req *http.Request, w *httptest.ResponseRecorder) {
+	t.Helper()
+	op, ok := testDocument.Operation(req.Method, req.URL.Path)
+	if !ok {
+		return
+	}
+	assert.NoError(t, op.ValidateResponse(w.Code, w.Body.Bytes()))
+}
+
+func TestOpenAPI(t *testing.T) {
+	handler := NewHandler(&service.Services{})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/openapi.json", nil)
+	handler.Routes().ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, openapi.JSON(), w.Body.Bytes())
+	assertDocumented(t, req, w)
+}
+
+func TestRoutesDocumented(t *testing.T) {
+	for _, route := range (&Handler{}).v1().routes {
+		path := "/"
+		for i, segment := range route.segments {
+			if i > 0 {
+				path += "/"
+			}
+			if strings.HasPrefix(segment, "{") {
+				segment = "1"
+			}
+			path += segment
+		}
+		_, ok := testDocument.Operation(route.method, path)
+		assert.True(t, ok, "%s %s is not documented", route.method, path)
+	}
+}
+
+func TestValidateRequests(t *testing.T) {
+	tData := map[string]struct {
+		URL                 string
+		Method              string
+		InputBody           string
+		Authorization       string
+		ExpectedRequestBody string
+		ExpectedStatusCode  int
+	}{
+		"missing fields": {
+			URL:                 "/v1/payments",
+			Method:              "POST",
+			InputBody:           `{"Sum":"12.00"}`,
+			Authorization:       "Bearer " + testSecretKey,
+			ExpectedRequestBody: `{"error":{"code":"validation_error","message":"invalid input","fields":[{"field":"Email","message":"is required"},{"field":"Currency","message":"is required"}],"request_id":"req_test"}}`,
+			ExpectedStatusCode:  422,
+		},
+		"wrong types": {
+			URL:                 "/v1/payments",
+			Method:              "POST",
+			InputBody:           `{"email":5,"Sum":true,"Currency":"USD"}`,
+			Authorization:       "Bearer " + testSecretKey,
+			ExpectedRequestBody: `{"error":{"code":"validation_error","message":"invalid input","fields":[{"field":"Sum","message":"must be a string or integer"},{"field":"Email","message":"must be a string"}],"request_id":"req_test"}}`,
+			ExpectedStatusCode:  422,
+		},
+		"invalid query": {
+			URL:                 "/v1/payments?limit=ten&sort=newest",
+			Method:              "GET",
+			Authorization:       "Bearer " + testSecretKey,
+			ExpectedRequestBody: `{"error":{"code":"validation_error","message":"invalid input","fields":[{"field":"sort","message":"must be one of created_at, -created_at, amount, -amount"},{"field":"limit","message":"must be an integer"}],"request_id":"req_test"}}`,
+			ExpectedStatusCode:  422,
+		},
+		"unauthenticated": {
+			URL:                 "/v1/payments",
+			Method:              "POST",
+			InputBody:           `{}`,
+			ExpectedRequestBody: errorJSON(codeUnauthorized, "api key is required"),
+			ExpectedStatusCode:  401,
+		},
+		"not JSON": {
+			URL:                 "/v1/payments",
+			Method:              "POST",
+			InputBody:           `{`,
+			Authorization:       "Bearer " + testSecretKey,
+			ExpectedRequestBody: errorJSON(codeBadRequest, "unexpected end of JSON input"),
+			ExpectedStatusCode:  400,
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			key := mock_service.NewMockAPIKey(c)
+			expectSecretKey(key)
+			handler := NewHandler(&service.Services{APIKey: key})
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(v.Method, v.URL, bytes.NewBufferString(v.InputBody))
+			if v.Authorization != "" {
+				req.Header.Set("Authorization", v.Authorization)
+			}
+			req.Header.Set(requestIDHeader, testRequestID)
+			handler.Routes().ServeHTTP(w, req)
+			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
+			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assertDocumented(t, req, w)
+		})
+	}
+}
@@ -3,203 +3,270 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/altuxa/payment-service-emulator/internal/models"
 )
 
-func (h *Handler) NewTransaction(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	newPayment := models.Transaction{}
-	reqBody, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+// CreatePayment serves POST /v1/payments and returns the created payment.
+func (h *Handler) CreatePayment(w http.ResponseWriter, r *http.Request) {
+	created, ok := h.createPayment(w, r, false)
+	if !ok {
 		return
 	}
-	err = json.Unmarshal(reqBody, &newPayment)
+	payment, err := h.paymentService.GetPayment(merchantID(r), created.ID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		// the payment is stored, so it is reported with what is known of it
+		// rather than as an error a retry would repeat
+		log.Printf("failed to read created payment %d: %v", created.ID, err)
+		payment = created
 	}
-	id, status, err := h.paymentService.CreatePayment(newPayment.UserID, newPayment.UserEmail, newPayment.Sum, newPayment.Currency)
+	writeJSON(w, http.StatusCreated, payment)
+}
+
+// createPayment returns the input of the created payment with its ID and
+// status, a numeric Sum of a legacy request is in major units.
+func (h *Handler) createPayment(w http.ResponseWriter, r *http.Request, legacy bool) (models.Transaction, bool) {
+	newPayment := models.Transaction{}
+	reqBody, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return models.Transaction{}, false
 	}
 	defer r.Body.Close()
-	paymentID := strconv.Itoa(id)
-	output, err := json.Marshal("paymentID: " + strconv.Itoa(id) + " status: " + status)
+	if legacy {
+		input := models.LegacyTransaction{}
+		err = json.Unmarshal(reqBody, &input)
+		newPayment = input.Transaction
+	} else {
+		err = json.Unmarshal(reqBody, &newPayment)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return models.Transaction{}, false
 	}
-	input := models.PaymentProcessingInput{
-		Email: newPayment.UserEmail,
+	principal, _ := principalFrom(r)
+	newPayment.APIKeyID = principal.APIKeyID
+	newPayment.MerchantID = principal.MerchantID
+	if principal.Kind == models.KeyUser {
+		if newPayment.UserID != 0 && newPayment.UserID != principal.UserID {
+			httpError(w, "user token can not create payments for another user", http.StatusForbidden)
+			return models.Transaction{}, false
+		}
+		newPayment.UserID = principal.UserID
 	}
-	data, err := json.Marshal(input)
+	newPayment.ID, newPayment.Status, err = h.paymentService.CreatePayment(newPayment)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		writeError(w, err)
+		return models.Transaction{}, false
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(output)
-	http.Post("http://localhost:8080/payments/processing/"+paymentID, "application/json", bytes.NewBuffer(data))
+	markPersisted(w)
+	return newPayment, true
 }
 
-func (h *Handler) StatusByID(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	strId := strings.TrimPrefix(r.URL.Path, "/payments/status/")
-	id, err := strconv.Atoi(strId)
-	if err != nil {
-		http.Error(w, "invalid input", http.StatusBadRequest)
-		return
-	}
-	status, err := h.paymentService.PaymentStatus(id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	outputStatus, err := json.Marshal(status)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+func (h *Handler) Payment(w http.ResponseWriter, r *http.Request, id int) {
+	payment, ok := h.ownPayment(w, r, id)
+	if !ok {
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(outputStatus)
+	writeJSON(w, http.StatusOK, payment)
 }
 
-func (h *Handler) PaymentProcessing(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+func (h *Handler) PaymentStatus(w http.ResponseWriter, r *http.Request, id int) {
+	payment, ok := h.ownPayment(w, r, id)
+	if !ok {
 		return
 	}
-	strId := strings.TrimPrefix(r.URL.Path, "/payments/processing/")
-	id, err := strconv.Atoi(strId)
-	if err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+	writeJSON(w, http.StatusOK, models.StatusOutput{
+		ID:            payment.ID,
+		Status:        payment.Status,
+		DeclineReason: payment.DeclineReason,
+	})
+}
+
+// ProcessPayment serves POST /v1/payments/{id}/process and returns the
+// processed payment.
+func (h *Handler) ProcessPayment(w http.ResponseWriter, r *http.Request, id int) {
+	if _, ok := h.processPayment(w, r, id); !ok {
 		return
 	}
+	h.Payment(w, r, id)
+}
+
+// processPayment checks the Email of the body against the payment's user
+// before processing it.
+func (h *Handler) processPayment(w http.ResponseWriter, r *http.Request, id int) (string, bool) {
 	input := models.PaymentProcessingInput{}
 	reqBody, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return "", false
 	}
 	err = json.Unmarshal(reqBody, &input)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return "", false
 	}
-	checkEmail, err := h.userService.Verification(id, input.Email)
+	checkEmail, err := h.userService.Verification(merchantID(r), id, input.Email)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("not enough rights %v", err), http.StatusBadRequest)
-		return
+		writeError(w, err)
+		return "", false
 	}
 	if !checkEmail {
-		http.Error(w, "not enough rights", http.StatusUnauthorized)
-		return
+		httpError(w, "not enough rights", http.StatusForbidden)
+		return "", false
 	}
-	status, err := h.paymentService.PaymentProcessing(id)
+	status, err := h.paymentService.PaymentProcessing(merchantID(r), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		writeError(w, err)
+		return "", false
 	}
-	outputStatus, err := json.Marshal(status)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(outputStatus)
+	return status, true
 }
 
-func (h *Handler) ByUserID(w http.ResponseWriter, r *http.Request) {
+// ListPayments serves GET /payments, a user token only sees its own payments.
+func (h *Handler) ListPayments(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	strId := strings.TrimPrefix(r.URL.Path, "/payments/byid/")
-	userID, err := strconv.Atoi(strId)
-	if err != nil {
-		http.Error(w, "invalid input", http.StatusBadRequest)
-		return
+	h.listPayments(w, r, listInput(r))
+}
+
+// UserPayments serves GET /v1/users/{id}/payments with the filters of
+// ListPayments.
+func (h *Handler) UserPayments(w http.ResponseWriter, r *http.Request, id int) {
+	input := listInput(r)
+	input.UserID = strconv.Itoa(id)
+	h.listPayments(w, r, input)
+}
+
+func listInput(r *http.Request) models.PaymentListInput {
+	query := r.URL.Query()
+	return models.PaymentListInput{
+		Status:      query.Get("status"),
+		Currency:    query.Get("currency"),
+		MinAmount:   query.Get("min_amount"),
+		MaxAmount:   query.Get("max_amount"),
+		CreatedFrom: query.Get("created_from"),
+		CreatedTo:   query.Get("created_to"),
+		ChangedFrom: query.Get("changed_from"),
+		ChangedTo:   query.Get("changed_to"),
+		UserID:      query.Get("user_id"),
+		Email:       query.Get("email"),
+		Sort:        query.Get("sort"),
+		Limit:       query.Get("limit"),
+		Cursor:      query.Get("cursor"),
 	}
-	transactions, err := h.paymentService.ByUserID(userID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+}
+
+func (h *Handler) listPayments(w http.ResponseWriter, r *http.Request, input models.PaymentListInput) {
+	principal, _ := principalFrom(r)
+	if principal.Kind == models.KeyUser {
+		own := strconv.Itoa(principal.UserID)
+		if input.UserID != "" && input.UserID != own {
+			httpError(w, "user token can not list payments of another user", http.StatusForbidden)
+			return
+		}
+		input.UserID = own
 	}
-	allTransactions, err := json.Marshal(transactions)
+	list, err := h.paymentService.ListPayments(principal.MerchantID, input)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(allTransactions)
+	writeJSON(w, http.StatusOK, list)
 }
 
-func (h *Handler) ByUserEmail(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+// Cancel serves POST /v1/payments/{id}/cancel and returns the cancelled
+// payment.
+func (h *Handler) Cancel(w http.ResponseWriter, r *http.Request, id int) {
+	if !h.cancelPayment(w, r, id) {
 		return
 	}
-	input := models.InputByUserEmail{}
+	h.Payment(w, r, id)
+}
+
+func (h *Handler) cancelPayment(w http.ResponseWriter, r *http.Request, id int) bool {
+	input := models.CancelInput{}
 	reqBody, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return false
 	}
-	err = json.Unmarshal(reqBody, &input)
+	if len(bytes.TrimSpace(reqBody)) != 0 {
+		err = json.Unmarshal(reqBody, &input)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return false
+		}
+	}
+	err = h.paymentService.CancelPayment(merchantID(r), id, input)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
+		return false
+	}
+	return true
+}
+
+func (h *Handler) PaymentHistory(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodGet {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	transactions, err := h.paymentService.ByUserEmail(input.Email)
+	events, err := h.paymentService.History(merchantID(r), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
-	allTransactions, err := json.Marshal(transactions)
+	history, err := json.Marshal(events)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	w.Write(allTransactions)
+	w.Write(history)
 }
 
-func (h *Handler) CancelPayment(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Capture(w http.ResponseWriter, r *http.Request, id int) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	strID := strings.TrimPrefix(r.URL.Path, "/payments/cancel/")
-	id, err := strconv.Atoi(strID)
+	input := models.CaptureInput{}
+	reqBody, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "invalid input", http.StatusBadRequest)
+		httpError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	err = h.paymentService.CancelPayment(id)
+	if len(bytes.TrimSpace(reqBody)) != 0 {
+		err = json.Unmarshal(reqBody, &input)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	payment, err := h.paymentService.Capture(merchantID(r), id, input)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Done"))
+	writeJSON(w, http.StatusOK, payment)
+}
+
+func (h *Handler) Void(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodPost {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	payment, err := h.paymentService.Void(merchantID(r), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, payment)
 }
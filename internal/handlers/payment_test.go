@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -9,8 +11,11 @@ import (
 	"time"
 
 	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/money"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
 	"github.com/altuxa/payment-service-emulator/internal/service"
 	mock_service "github.com/altuxa/payment-service-emulator/internal/service/mocks"
+	"github.com/altuxa/payment-service-emulator/internal/statemachine"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 )
@@ -27,49 +32,85 @@ func TestNewTransaction(t *testing.T) {
 	}{
 		"Success": {
 			Input: models.Transaction{
-				UserID:    1,
-				UserEmail: "ann@mail.ru",
-				Sum:       502.3,
-				Currency:  "USD",
+				UserID:     1,
+				UserEmail:  "ann@mail.ru",
+				Sum:        money.New(50230, "USD"),
+				APIKeyID:   testKeyID,
+				MerchantID: testMerchantID,
 			},
 			InputBody: `{"UserID":1,"Email":"ann@mail.ru","Sum":502.3,"Currency":"USD"}`,
 			Method:    "POST",
 			mock: func(s *mock_service.MockPayment, tr models.Transaction) {
-				s.EXPECT().CreatePayment(tr.UserID, tr.UserEmail, tr.Sum, tr.Currency).Return(1, models.StatusNew, nil)
+				s.EXPECT().CreatePayment(tr).Return(1, models.StatusNew, nil)
 			},
-			ExpectedRequestBody: "\"paymentID: 1 status: NEW\"",
+			ExpectedRequestBody: `{"ID":1,"Status":"NEW"}`,
+			ExpectedStatusCode:  200,
+		},
+		"integer sum in major units": {
+			Input: models.Transaction{
+				UserID:     1,
+				UserEmail:  "ann@mail.ru",
+				Sum:        money.New(100000, "USD"),
+				APIKeyID:   testKeyID,
+				MerchantID: testMerchantID,
+			},
+			InputBody: `{"UserID":1,"Email":"ann@mail.ru","Sum":1000,"Currency":"USD"}`,
+			Method:    "POST",
+			mock: func(s *mock_service.MockPayment, tr models.Transaction) {
+				s.EXPECT().CreatePayment(tr).Return(1, models.StatusNew, nil)
+			},
+			ExpectedRequestBody: `{"ID":1,"Status":"NEW"}`,
 			ExpectedStatusCode:  200,
 		},
 		"bad req": {
 			Input: models.Transaction{
-				UserID:    1,
-				UserEmail: "ann@mail.ru",
-				Currency:  "USD",
+				UserID:     1,
+				UserEmail:  "ann@mail.ru",
+				Sum:        money.New(0, "USD"),
+				APIKeyID:   testKeyID,
+				MerchantID: testMerchantID,
 			},
 			InputBody: `{"UserID":1,"Email":"ann@mail.ru","Currency":"USD"}`,
 			Method:    "POST",
 			mock: func(s *mock_service.MockPayment, tr models.Transaction) {
-				s.EXPECT().CreatePayment(tr.UserID, tr.UserEmail, tr.Sum, tr.Currency).Return(0, "", errors.New("bad req"))
+				s.EXPECT().CreatePayment(tr).Return(0, "", errors.New("bad req"))
 			},
-			ExpectedRequestBody: "bad req\n",
-			ExpectedStatusCode:  400,
+			ExpectedRequestBody: errorJSON(codeInternal, "internal error"),
+			ExpectedStatusCode:  500,
+		},
+		"validation error": {
+			Input: models.Transaction{
+				UserID:     1,
+				UserEmail:  "ann@mail.ru",
+				Sum:        money.New(50230, "usd"),
+				APIKeyID:   testKeyID,
+				MerchantID: testMerchantID,
+			},
+			InputBody: `{"UserID":1,"Email":"ann@mail.ru","Sum":"502.30","Currency":"usd"}`,
+			Method:    "POST",
+			mock: func(s *mock_service.MockPayment, tr models.Transaction) {
+				vErr := &service.ValidationError{}
+				vErr.Add("Currency", `"usd" is not a supported ISO 4217 currency code`)
+				s.EXPECT().CreatePayment(tr).Return(0, "", vErr)
+			},
+			ExpectedRequestBody: `{"error":{"code":"validation_error","message":"invalid input","fields":[{"field":"Currency","message":"\"usd\" is not a supported ISO 4217 currency code"}],"request_id":"req_test"}}`,
+			ExpectedStatusCode:  422,
 		},
 		"Invalid method": {
 			Method:              "GET",
 			mock:                func(s *mock_service.MockPayment, tr models.Transaction) {},
-			ExpectedRequestBody: "method not allowed\n",
+			ExpectedRequestBody: errorJSON(codeMethodNotAllowed, "method not allowed"),
 			ExpectedStatusCode:  405,
 		},
 		"unmarshal error": {
 			Input: models.Transaction{
 				UserID:    1,
 				UserEmail: "ann@mail.ru",
-				Sum:       502.3,
-				Currency:  "USD",
+				Sum:       money.New(50230, "USD"),
 			},
 			Method:              "POST",
 			mock:                func(s *mock_service.MockPayment, tr models.Transaction) {},
-			ExpectedRequestBody: "unexpected end of JSON input\n",
+			ExpectedRequestBody: errorJSON(codeBadRequest, "unexpected end of JSON input"),
 			ExpectedStatusCode:  400,
 		},
 	}
@@ -86,10 +127,63 @@ func TestNewTransaction(t *testing.T) {
 			handler := NewHandler(services)
 			r := http.HandlerFunc(handler.NewTransaction)
 			w := httptest.NewRecorder()
-			req := httptest.NewRequest(v.Method, "/payments/new", bytes.NewBufferString(v.InputBody))
+			w.Header().Set(requestIDHeader, testRequestID)
+			req := withKey(httptest.NewRequest(v.Method, "/payments/new", bytes.NewBufferString(v.InputBody)))
 			r.ServeHTTP(w, req)
 			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
 			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assertDocumented(t, req, w)
+		})
+	}
+}
+
+func TestCreatePaymentSum(t *testing.T) {
+	type mockPay func(s *mock_service.MockPayment)
+	payment := models.Transaction{ID: 1, UserID: 5, UserEmail: "ann@mail.ru", Sum: money.New(1200, "USD"), Status: models.StatusNew, MerchantID: testMerchantID}
+	tData := map[string]struct {
+		InputBody           string
+		ExpectedRequestBody string
+		ExpectedStatusCode  int
+		MockPay             mockPay
+	}{
+		"integer sum in minor units": {
+			InputBody:           `{"UserID":5,"Email":"ann@mail.ru","Sum":1200,"Currency":"USD"}`,
+			ExpectedRequestBody: `{"ID":1,"UserID":5,"Email":"ann@mail.ru","Sum":"12.00","CreationDate":"0001-01-01T00:00:00Z","ChangeDate":"0001-01-01T00:00:00Z","Status":"NEW","Currency":"USD"}`,
+			ExpectedStatusCode:  201,
+			MockPay: func(s *mock_service.MockPayment) {
+				s.EXPECT().CreatePayment(models.Transaction{UserID: 5, UserEmail: "ann@mail.ru", Sum: money.New(1200, "USD"), APIKeyID: testKeyID, MerchantID: testMerchantID}).Return(1, models.StatusNew, nil)
+				s.EXPECT().GetPayment(testMerchantID, 1).Return(payment, nil)
+			},
+		},
+		"fractional number": {
+			InputBody:           `{"UserID":5,"Email":"ann@mail.ru","Sum":12.5,"Currency":"USD"}`,
+			ExpectedRequestBody: `{"error":{"code":"validation_error","message":"invalid input","fields":[{"field":"Sum","message":"must be a string or integer"}],"request_id":"req_test"}}`,
+			ExpectedStatusCode:  422,
+			MockPay:             func(s *mock_service.MockPayment) {},
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			key := mock_service.NewMockAPIKey(c)
+			expectSecretKey(key)
+			pay := mock_service.NewMockPayment(c)
+			v.MockPay(pay)
+			services := service.Services{
+				APIKey:  key,
+				Payment: pay,
+			}
+			handler := NewHandler(&services)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/v1/payments", bytes.NewBufferString(v.InputBody))
+			req.Header.Set("Authorization", "Bearer "+testSecretKey)
+			req.Header.Set(requestIDHeader, testRequestID)
+			handler.Routes().ServeHTTP(w, req)
+			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
+			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assertDocumented(t, req, w)
 		})
 	}
 }
@@ -109,9 +203,19 @@ func TestStatusByID(t *testing.T) {
 			Input:               1,
 			Method:              "GET",
 			ExpectedStatusCode:  200,
-			ExpectedRequestBody: `"SUCCESS"`,
+			ExpectedRequestBody: `{"ID":1,"Status":"SUCCESS"}`,
+			Mock: func(s *mock_service.MockPayment, id int) {
+				s.EXPECT().GetPayment(testMerchantID, id).Return(models.Transaction{ID: id, Status: models.StatusSuccess, MerchantID: testMerchantID}, nil)
+			},
+		},
+		"declined": {
+			URL:                 "/payments/status/2",
+			Input:               2,
+			Method:              "GET",
+			ExpectedStatusCode:  200,
+			ExpectedRequestBody: `{"ID":2,"Status":"FAIL","DeclineReason":"insufficient_funds"}`,
 			Mock: func(s *mock_service.MockPayment, id int) {
-				s.EXPECT().PaymentStatus(id).Return(models.StatusSuccess, nil)
+				s.EXPECT().GetPayment(testMerchantID, id).Return(models.Transaction{ID: id, Status: models.StatusFail, DeclineReason: models.DeclineInsufficientFunds, MerchantID: testMerchantID}, nil)
 			},
 		},
 		"invalid input": {
@@ -119,17 +223,17 @@ func TestStatusByID(t *testing.T) {
 			Input:               1,
 			Method:              "GET",
 			ExpectedStatusCode:  400,
-			ExpectedRequestBody: "invalid input\n",
+			ExpectedRequestBody: errorJSON(codeBadRequest, "invalid input"),
 			Mock:                func(s *mock_service.MockPayment, id int) {},
 		},
 		"payment not found": {
 			URL:                 "/payments/status/999",
 			Input:               999,
 			Method:              "GET",
-			ExpectedStatusCode:  400,
-			ExpectedRequestBody: "payment not found\n",
+			ExpectedStatusCode:  404,
+			ExpectedRequestBody: errorJSON(codeNotFound, "payment not found"),
 			Mock: func(s *mock_service.MockPayment, id int) {
-				s.EXPECT().PaymentStatus(id).Return("", errors.New("payment not found"))
+				s.EXPECT().GetPayment(testMerchantID, id).Return(models.Transaction{}, &service.NotFoundError{Err: repository.ErrPaymentNotFound})
 			},
 		},
 		"invalid method": {
@@ -137,7 +241,7 @@ func TestStatusByID(t *testing.T) {
 			Input:               1,
 			Method:              "POST",
 			ExpectedStatusCode:  405,
-			ExpectedRequestBody: "method not allowed\n",
+			ExpectedRequestBody: errorJSON(codeMethodNotAllowed, "method not allowed"),
 			Mock:                func(s *mock_service.MockPayment, id int) {},
 		},
 	}
@@ -154,10 +258,12 @@ func TestStatusByID(t *testing.T) {
 			handler := NewHandler(&services)
 			r := http.HandlerFunc(handler.StatusByID)
 			w := httptest.NewRecorder()
-			req := httptest.NewRequest(v.Method, v.URL, nil)
+			w.Header().Set(requestIDHeader, testRequestID)
+			req := withKey(httptest.NewRequest(v.Method, v.URL, nil))
 			r.ServeHTTP(w, req)
 			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
 			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assertDocumented(t, req, w)
 		})
 	}
 }
@@ -177,18 +283,18 @@ func TestByUserID(t *testing.T) {
 			ID:                  1,
 			Method:              "GET",
 			ExpectedStatusCode:  200,
-			ExpectedRequestBody: `[{"ID":114,"UserID":1,"Email":"ann@mail.ru","Sum":1000,"Currency":"KZ","CreationDate":"2022-06-11T18:45:47.72474801+06:00","ChangeDate":"2022-06-11T18:47:22.683292944+06:00","Status":"SUCCESS"}]`,
+			ExpectedRequestBody: `[{"ID":114,"UserID":1,"Email":"ann@mail.ru","Sum":"1000.00","CreationDate":"2022-06-11T18:45:47.72474801+06:00","ChangeDate":"2022-06-11T18:47:22.683292944+06:00","Status":"SUCCESS","Currency":"KZ"}]`,
 			Mock: func(s *mock_service.MockPayment, id int) {
-				s.EXPECT().ByUserID(id).Return([]models.Transaction{
+				s.EXPECT().ByUserID(testMerchantID, id).Return([]models.Transaction{
 					models.Transaction{
 						ID:           114,
 						UserID:       1,
 						UserEmail:    "ann@mail.ru",
-						Sum:          1000,
+						Sum:          money.New(100000, "KZ"),
 						CreationDate: time.Date(2022, 06, 11, 18, 45, 47, 724748010, time.Local),
 						ChangeDate:   time.Date(2022, 06, 11, 18, 47, 22, 683292944, time.Local),
-						Currency:     "KZ",
 						Status:       "SUCCESS",
+						MerchantID:   testMerchantID,
 					},
 				}, nil)
 			},
@@ -198,17 +304,17 @@ func TestByUserID(t *testing.T) {
 			ID:                  1,
 			Method:              "POST",
 			ExpectedStatusCode:  405,
-			ExpectedRequestBody: "method not allowed\n",
+			ExpectedRequestBody: errorJSON(codeMethodNotAllowed, "method not allowed"),
 			Mock:                func(s *mock_service.MockPayment, id int) {},
 		},
 		"payment not found": {
 			URL:                 "/payments/byid/1",
 			ID:                  1,
 			Method:              "GET",
-			ExpectedStatusCode:  400,
-			ExpectedRequestBody: "not found\n",
+			ExpectedStatusCode:  404,
+			ExpectedRequestBody: errorJSON(codeNotFound, "payment not found"),
 			Mock: func(s *mock_service.MockPayment, id int) {
-				s.EXPECT().ByUserID(id).Return(nil, errors.New("not found"))
+				s.EXPECT().ByUserID(testMerchantID, id).Return([]models.Transaction{}, nil)
 			},
 		},
 		"ivalid url input": {
@@ -216,7 +322,7 @@ func TestByUserID(t *testing.T) {
 			ID:                  1,
 			Method:              "GET",
 			ExpectedStatusCode:  400,
-			ExpectedRequestBody: "invalid input\n",
+			ExpectedRequestBody: errorJSON(codeBadRequest, "invalid input"),
 			Mock:                func(s *mock_service.MockPayment, id int) {},
 		},
 	}
@@ -233,10 +339,126 @@ func TestByUserID(t *testing.T) {
 			handler := NewHandler(&services)
 			r := http.HandlerFunc(handler.ByUserID)
 			w := httptest.NewRecorder()
-			req := httptest.NewRequest(v.Method, v.URL, nil)
+			w.Header().Set(requestIDHeader, testRequestID)
+			req := withKey(httptest.NewRequest(v.Method, v.URL, nil))
 			r.ServeHTTP(w, req)
 			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
 			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assertDocumented(t, req, w)
+		})
+	}
+}
+
+func TestListPayments(t *testing.T) {
+	type mock func(s *mock_service.MockPayment)
+	userToken := models.Principal{MerchantID: testMerchantID, UserID: 5, Kind: models.KeyUser}
+	tData := map[string]struct {
+		URL                 string
+		Method              string
+		Principal           *models.Principal
+		ExpectedStatusCode  int
+		ExpectedRequestBody string
+		Mock                mock
+	}{
+		"success": {
+			URL:                 "/payments?status=success,fail&currency=USD&min_amount=10&max_amount=20.5&created_from=2022-06-11T00:00:00Z&sort=-amount&limit=1&email=ann@mail.ru",
+			Method:              "GET",
+			ExpectedStatusCode:  200,
+			ExpectedRequestBody: `{"Data":[{"ID":114,"UserID":1,"Email":"ann@mail.ru","Sum":"12.00","CreationDate":"2022-06-11T18:45:47.72474801+06:00","ChangeDate":"2022-06-11T18:47:22.683292944+06:00","Status":"SUCCESS","Currency":"USD"}],"HasMore":true,"NextCursor":"next"}`,
+			Mock: func(s *mock_service.MockPayment) {
+				s.EXPECT().ListPayments(testMerchantID, models.PaymentListInput{
+					Status:      "success,fail",
+					Currency:    "USD",
+					MinAmount:   "10",
+					MaxAmount:   "20.5",
+					CreatedFrom: "2022-06-11T00:00:00Z",
+					Email:       "ann@mail.ru",
+					Sort:        "-amount",
+					Limit:       "1",
+				}).Return(models.PaymentList{
+					Data: []models.Transaction{{
+						ID:           114,
+						UserID:       1,
+						UserEmail:    "ann@mail.ru",
+						Sum:          money.New(1200, "USD"),
+						CreationDate: time.Date(2022, 06, 11, 18, 45, 47, 724748010, time.Local),
+						ChangeDate:   time.Date(2022, 06, 11, 18, 47, 22, 683292944, time.Local),
+						Status:       "SUCCESS",
+						MerchantID:   testMerchantID,
+					}},
+					HasMore:    true,
+					NextCursor: "next",
+				}, nil)
+			},
+		},
+		"empty page": {
+			URL:                 "/payments?cursor=next",
+			Method:              "GET",
+			ExpectedStatusCode:  200,
+			ExpectedRequestBody: `{"Data":[],"HasMore":false}`,
+			Mock: func(s *mock_service.MockPayment) {
+				s.EXPECT().ListPayments(testMerchantID, models.PaymentListInput{Cursor: "next"}).Return(models.PaymentList{Data: []models.Transaction{}}, nil)
+			},
+		},
+		"validation error": {
+			URL:                 "/payments?limit=0",
+			Method:              "GET",
+			ExpectedStatusCode:  422,
+			ExpectedRequestBody: `{"error":{"code":"validation_error","message":"invalid input","fields":[{"field":"limit","message":"must be a number between 1 and 500"}],"request_id":"req_test"}}`,
+			Mock: func(s *mock_service.MockPayment) {
+				vErr := &service.ValidationError{}
+				vErr.Add("limit", "must be a number between 1 and 500")
+				s.EXPECT().ListPayments(testMerchantID, models.PaymentListInput{Limit: "0"}).Return(models.PaymentList{}, vErr)
+			},
+		},
+		"user token lists own payments": {
+			URL:                 "/payments",
+			Method:              "GET",
+			Principal:           &userToken,
+			ExpectedStatusCode:  200,
+			ExpectedRequestBody: `{"Data":[],"HasMore":false}`,
+			Mock: func(s *mock_service.MockPayment) {
+				s.EXPECT().ListPayments(testMerchantID, models.PaymentListInput{UserID: "5"}).Return(models.PaymentList{Data: []models.Transaction{}}, nil)
+			},
+		},
+		"user token of another user": {
+			URL:                 "/payments?user_id=6",
+			Method:              "GET",
+			Principal:           &userToken,
+			ExpectedStatusCode:  403,
+			ExpectedRequestBody: errorJSON(codeForbidden, "user token can not list payments of another user"),
+			Mock:                func(s *mock_service.MockPayment) {},
+		},
+		"invalid method": {
+			URL:                 "/payments",
+			Method:              "POST",
+			ExpectedStatusCode:  405,
+			ExpectedRequestBody: errorJSON(codeMethodNotAllowed, "method not allowed"),
+			Mock:                func(s *mock_service.MockPayment) {},
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			pay := mock_service.NewMockPayment(c)
+			v.Mock(pay)
+			services := service.Services{
+				Payment: pay,
+			}
+			handler := NewHandler(&services)
+			r := http.HandlerFunc(handler.ListPayments)
+			w := httptest.NewRecorder()
+			w.Header().Set(requestIDHeader, testRequestID)
+			req := withKey(httptest.NewRequest(v.Method, v.URL, nil))
+			if v.Principal != nil {
+				req = req.WithContext(context.WithValue(req.Context(), principalKey, *v.Principal))
+			}
+			r.ServeHTTP(w, req)
+			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
+			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assertDocumented(t, req, w)
 		})
 	}
 }
@@ -261,19 +483,19 @@ func TestPaymentProcessing(t *testing.T) {
 				Email: "ann@mail.ru",
 			},
 			Method:              "POST",
-			ExpectedRequestBody: "\"SUCCESS\"",
+			ExpectedRequestBody: `{"ID":1,"Status":"SUCCESS"}`,
 			ExpectedStatusCode:  200,
 			MockUser: func(s *mock_service.MockUser, payId int, in models.PaymentProcessingInput) {
-				s.EXPECT().Verification(payId, in.Email).Return(true, nil)
+				s.EXPECT().Verification(testMerchantID, payId, in.Email).Return(true, nil)
 			},
 			MockPay: func(s *mock_service.MockPayment, payId int) {
-				s.EXPECT().PaymentProcessing(payId).Return(models.StatusSuccess, nil)
+				s.EXPECT().PaymentProcessing(testMerchantID, payId).Return(models.StatusSuccess, nil)
 			},
 		},
 		"Invalid method": {
 			URL:                 "/payments/processing/1",
 			Method:              "GET",
-			ExpectedRequestBody: "method not allowed\n",
+			ExpectedRequestBody: errorJSON(codeMethodNotAllowed, "method not allowed"),
 			ExpectedStatusCode:  405,
 			MockUser:            func(s *mock_service.MockUser, payId int, in models.PaymentProcessingInput) {},
 			MockPay:             func(s *mock_service.MockPayment, payId int) {},
@@ -281,7 +503,7 @@ func TestPaymentProcessing(t *testing.T) {
 		"Invalid payID input": {
 			URL:                 "/payments/processing/a",
 			Method:              "POST",
-			ExpectedRequestBody: "Invalid input\n",
+			ExpectedRequestBody: errorJSON(codeBadRequest, "Invalid input"),
 			ExpectedStatusCode:  400,
 			MockUser:            func(s *mock_service.MockUser, payId int, in models.PaymentProcessingInput) {},
 			MockPay:             func(s *mock_service.MockPayment, payId int) {},
@@ -293,10 +515,10 @@ func TestPaymentProcessing(t *testing.T) {
 				Email: "aboba@mail.ru",
 			},
 			Method:              "POST",
-			ExpectedRequestBody: "not enough rights not found\n",
-			ExpectedStatusCode:  400,
+			ExpectedRequestBody: errorJSON(codeNotFound, "payment not found"),
+			ExpectedStatusCode:  404,
 			MockUser: func(s *mock_service.MockUser, payId int, in models.PaymentProcessingInput) {
-				s.EXPECT().Verification(payId, in.Email).Return(false, errors.New("not found"))
+				s.EXPECT().Verification(testMerchantID, payId, in.Email).Return(false, &service.NotFoundError{Err: repository.ErrPaymentNotFound})
 			},
 			MockPay: func(s *mock_service.MockPayment, payId int) {},
 		},
@@ -307,10 +529,10 @@ func TestPaymentProcessing(t *testing.T) {
 				Email: "alex@mail.ru",
 			},
 			Method:              "POST",
-			ExpectedRequestBody: "not enough rights\n",
-			ExpectedStatusCode:  401,
+			ExpectedRequestBody: errorJSON(codeForbidden, "not enough rights"),
+			ExpectedStatusCode:  403,
 			MockUser: func(s *mock_service.MockUser, payId int, in models.PaymentProcessingInput) {
-				s.EXPECT().Verification(payId, in.Email).Return(false, nil)
+				s.EXPECT().Verification(testMerchantID, payId, in.Email).Return(false, nil)
 			},
 			MockPay: func(s *mock_service.MockPayment, payId int) {},
 		},
@@ -321,8 +543,8 @@ func TestPaymentProcessing(t *testing.T) {
 				Email: "alex@mail.ru",
 			},
 			Method:              "POST",
-			ExpectedRequestBody: "unexpected end of JSON input\n",
-			ExpectedStatusCode:  500,
+			ExpectedRequestBody: errorJSON(codeBadRequest, "unexpected end of JSON input"),
+			ExpectedStatusCode:  400,
 			MockUser:            func(s *mock_service.MockUser, payId int, in models.PaymentProcessingInput) {},
 			MockPay:             func(s *mock_service.MockPayment, payId int) {},
 		},
@@ -333,13 +555,13 @@ func TestPaymentProcessing(t *testing.T) {
 				Email: "alex@mail.ru",
 			},
 			Method:              "POST",
-			ExpectedRequestBody: "invalid payment status\n",
-			ExpectedStatusCode:  400,
+			ExpectedRequestBody: errorJSON(codeConflict, "invalid payment status"),
+			ExpectedStatusCode:  409,
 			MockUser: func(s *mock_service.MockUser, payId int, in models.PaymentProcessingInput) {
-				s.EXPECT().Verification(payId, in.Email).Return(true, nil)
+				s.EXPECT().Verification(testMerchantID, payId, in.Email).Return(true, nil)
 			},
 			MockPay: func(s *mock_service.MockPayment, payId int) {
-				s.EXPECT().PaymentProcessing(payId).Return("", errors.New("invalid payment status"))
+				s.EXPECT().PaymentProcessing(testMerchantID, payId).Return("", &service.ConflictError{Err: errors.New("invalid payment status")})
 			},
 		},
 	}
@@ -351,6 +573,7 @@ func TestPaymentProcessing(t *testing.T) {
 			user := mock_service.NewMockUser(c)
 			v.MockUser(user, 1, v.Input)
 			pay := mock_service.NewMockPayment(c)
+			expectOwned(pay)
 			v.MockPay(pay, 1)
 			services := service.Services{
 				Payment: pay,
@@ -359,10 +582,12 @@ func TestPaymentProcessing(t *testing.T) {
 			handler := NewHandler(&services)
 			r := http.HandlerFunc(handler.PaymentProcessing)
 			w := httptest.NewRecorder()
-			req := httptest.NewRequest(v.Method, v.URL, bytes.NewBufferString(v.InputBody))
+			w.Header().Set(requestIDHeader, testRequestID)
+			req := withKey(httptest.NewRequest(v.Method, v.URL, bytes.NewBufferString(v.InputBody)))
 			r.ServeHTTP(w, req)
 			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
 			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assertDocumented(t, req, w)
 		})
 	}
 }
@@ -383,26 +608,26 @@ func TestByUserEmail(t *testing.T) {
 				Email: "ann@mail.ru",
 			},
 			Method:              "GET",
-			ExpectedRequestBody: `[{"ID":114,"UserID":1,"Email":"ann@mail.ru","Sum":1000,"Currency":"KZ","CreationDate":"2022-06-11T18:45:47.72474801+06:00","ChangeDate":"2022-06-11T18:47:22.683292944+06:00","Status":"SUCCESS"}]`,
+			ExpectedRequestBody: `[{"ID":114,"UserID":1,"Email":"ann@mail.ru","Sum":"1000.00","CreationDate":"2022-06-11T18:45:47.72474801+06:00","ChangeDate":"2022-06-11T18:47:22.683292944+06:00","Status":"SUCCESS","Currency":"KZ"}]`,
 			ExpectedStatusCode:  200,
 			MockPay: func(s *mock_service.MockPayment, in models.InputByUserEmail) {
-				s.EXPECT().ByUserEmail(in.Email).Return([]models.Transaction{
+				s.EXPECT().ByUserEmail(testMerchantID, in.Email).Return([]models.Transaction{
 					models.Transaction{
 						ID:           114,
 						UserID:       1,
 						UserEmail:    "ann@mail.ru",
-						Sum:          1000,
+						Sum:          money.New(100000, "KZ"),
 						CreationDate: time.Date(2022, 06, 11, 18, 45, 47, 724748010, time.Local),
 						ChangeDate:   time.Date(2022, 06, 11, 18, 47, 22, 683292944, time.Local),
-						Currency:     "KZ",
 						Status:       "SUCCESS",
+						MerchantID:   testMerchantID,
 					},
 				}, nil)
 			},
 		},
 		"Invalid method": {
 			Method:              "POST",
-			ExpectedRequestBody: "method not allowed\n",
+			ExpectedRequestBody: errorJSON(codeMethodNotAllowed, "method not allowed"),
 			ExpectedStatusCode:  405,
 			MockPay:             func(s *mock_service.MockPayment, in models.InputByUserEmail) {},
 		},
@@ -412,10 +637,10 @@ func TestByUserEmail(t *testing.T) {
 				Email: "ann@mail.ru",
 			},
 			Method:              "GET",
-			ExpectedRequestBody: "not found payments\n",
-			ExpectedStatusCode:  400,
+			ExpectedRequestBody: errorJSON(codeNotFound, "payment not found"),
+			ExpectedStatusCode:  404,
 			MockPay: func(s *mock_service.MockPayment, in models.InputByUserEmail) {
-				s.EXPECT().ByUserEmail(in.Email).Return(nil, errors.New("not found payments"))
+				s.EXPECT().ByUserEmail(testMerchantID, in.Email).Return([]models.Transaction{}, nil)
 			},
 		},
 	}
@@ -432,10 +657,12 @@ func TestByUserEmail(t *testing.T) {
 			handler := NewHandler(&services)
 			r := http.HandlerFunc(handler.ByUserEmail)
 			w := httptest.NewRecorder()
-			req := httptest.NewRequest(v.Method, "/payments/byemail", bytes.NewBufferString(v.InputBody))
+			w.Header().Set(requestIDHeader, testRequestID)
+			req := withKey(httptest.NewRequest(v.Method, "/payments/byemail", bytes.NewBufferString(v.InputBody)))
 			r.ServeHTTP(w, req)
 			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
 			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assertDocumented(t, req, w)
 		})
 	}
 }
@@ -445,6 +672,7 @@ func TestCancelPayment(t *testing.T) {
 	tData := map[string]struct {
 		URL                 string
 		Method              string
+		InputBody           string
 		ExpectedRequestBody string
 		ExpectedStatusCode  int
 		MockPay             mockPay
@@ -452,25 +680,43 @@ func TestCancelPayment(t *testing.T) {
 		"Success": {
 			URL:                 "/payments/cancel/1",
 			Method:              "POST",
-			ExpectedRequestBody: "Done",
+			ExpectedRequestBody: `{"ID":1,"Status":"CANCELLED"}`,
 			ExpectedStatusCode:  200,
 			MockPay: func(s *mock_service.MockPayment, payId int) {
-				s.EXPECT().CancelPayment(payId).Return(nil)
+				s.EXPECT().CancelPayment(testMerchantID, payId, models.CancelInput{}).Return(nil)
 			},
 		},
-		"Invalid payment status": {
+		"with reason and actor": {
 			URL:                 "/payments/cancel/1",
 			Method:              "POST",
-			ExpectedRequestBody: "invalid status\n",
+			InputBody:           `{"Reason":"duplicate order","Actor":"support"}`,
+			ExpectedRequestBody: `{"ID":1,"Status":"CANCELLED"}`,
+			ExpectedStatusCode:  200,
+			MockPay: func(s *mock_service.MockPayment, payId int) {
+				s.EXPECT().CancelPayment(testMerchantID, payId, models.CancelInput{Reason: "duplicate order", Actor: "support"}).Return(nil)
+			},
+		},
+		"bad body": {
+			URL:                 "/payments/cancel/1",
+			Method:              "POST",
+			InputBody:           `{"Reason":`,
+			ExpectedRequestBody: errorJSON(codeBadRequest, "unexpected end of JSON input"),
 			ExpectedStatusCode:  400,
+			MockPay:             func(s *mock_service.MockPayment, payId int) {},
+		},
+		"Invalid payment status": {
+			URL:                 "/payments/cancel/1",
+			Method:              "POST",
+			ExpectedRequestBody: errorJSON(codeConflict, "invalid status"),
+			ExpectedStatusCode:  409,
 			MockPay: func(s *mock_service.MockPayment, payId int) {
-				s.EXPECT().CancelPayment(payId).Return(errors.New("invalid status"))
+				s.EXPECT().CancelPayment(testMerchantID, payId, models.CancelInput{}).Return(&service.ConflictError{Err: errors.New("invalid status")})
 			},
 		},
 		"method not allowed": {
 			URL:                 "/payments/cancel/1",
 			Method:              "GET",
-			ExpectedRequestBody: "method not allowed\n",
+			ExpectedRequestBody: errorJSON(codeMethodNotAllowed, "method not allowed"),
 			ExpectedStatusCode:  405,
 			MockPay:             func(s *mock_service.MockPayment, payId int) {},
 		},
@@ -481,6 +727,7 @@ func TestCancelPayment(t *testing.T) {
 			c := gomock.NewController(t)
 			defer c.Finish()
 			pay := mock_service.NewMockPayment(c)
+			expectOwned(pay)
 			v.MockPay(pay, 1)
 			services := service.Services{
 				Payment: pay,
@@ -488,10 +735,203 @@ func TestCancelPayment(t *testing.T) {
 			handler := NewHandler(&services)
 			r := http.HandlerFunc(handler.CancelPayment)
 			w := httptest.NewRecorder()
-			req := httptest.NewRequest(v.Method, v.URL, nil)
+			w.Header().Set(requestIDHeader, testRequestID)
+			req := withKey(httptest.NewRequest(v.Method, v.URL, bytes.NewBufferString(v.InputBody)))
+			r.ServeHTTP(w, req)
+			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
+			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assertDocumented(t, req, w)
+		})
+	}
+}
+
+func TestPaymentHistory(t *testing.T) {
+	type mockPay func(s *mock_service.MockPayment, payId int)
+	tData := map[string]struct {
+		URL                 string
+		Method              string
+		ExpectedRequestBody string
+		ExpectedStatusCode  int
+		MockPay             mockPay
+	}{
+		"Success": {
+			URL:                 "/payments/1/history",
+			Method:              "GET",
+			ExpectedRequestBody: `[{"ID":1,"PaymentID":1,"FromStatus":"","ToStatus":"NEW","Reason":"payment created","CreatedAt":"2022-06-11T18:45:47.72474801+06:00"},{"ID":2,"PaymentID":1,"FromStatus":"NEW","ToStatus":"PROCESSING","Reason":"processing started","CreatedAt":"2022-06-11T18:45:48.72474801+06:00"}]`,
+			ExpectedStatusCode:  200,
+			MockPay: func(s *mock_service.MockPayment, payId int) {
+				s.EXPECT().History(testMerchantID, payId).Return([]models.PaymentEvent{
+					{
+						ID:        1,
+						PaymentID: 1,
+						ToStatus:  models.StatusNew,
+						Reason:    "payment created",
+						CreatedAt: time.Date(2022, 06, 11, 18, 45, 47, 724748010, time.Local),
+					},
+					{
+						ID:         2,
+						PaymentID:  1,
+						FromStatus: models.StatusNew,
+						ToStatus:   models.StatusProcessing,
+						Reason:     "processing started",
+						CreatedAt:  time.Date(2022, 06, 11, 18, 45, 48, 724748010, time.Local),
+					},
+				}, nil)
+			},
+		},
+		"Payment not found": {
+			URL:                 "/payments/1/history",
+			Method:              "GET",
+			ExpectedRequestBody: errorJSON(codeNotFound, "payment not found"),
+			ExpectedStatusCode:  404,
+			MockPay: func(s *mock_service.MockPayment, payId int) {
+				s.EXPECT().History(testMerchantID, payId).Return(nil, &service.NotFoundError{Err: repository.ErrPaymentNotFound})
+			},
+		},
+		"Invalid payment id": {
+			URL:                 "/payments/a/history",
+			Method:              "GET",
+			ExpectedRequestBody: errorJSON(codeBadRequest, "invalid input"),
+			ExpectedStatusCode:  400,
+			MockPay:             func(s *mock_service.MockPayment, payId int) {},
+		},
+		"Unknown resource": {
+			URL:                 "/payments/1/unknown",
+			Method:              "GET",
+			ExpectedRequestBody: errorJSON(codeNotFound, "route not found"),
+			ExpectedStatusCode:  404,
+			MockPay:             func(s *mock_service.MockPayment, payId int) {},
+		},
+		"method not allowed": {
+			URL:                 "/payments/1/history",
+			Method:              "POST",
+			ExpectedRequestBody: errorJSON(codeMethodNotAllowed, "method not allowed"),
+			ExpectedStatusCode:  405,
+			MockPay:             func(s *mock_service.MockPayment, payId int) {},
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			pay := mock_service.NewMockPayment(c)
+			expectOwned(pay)
+			v.MockPay(pay, 1)
+			services := service.Services{
+				Payment: pay,
+			}
+			handler := NewHandler(&services)
+			r := http.HandlerFunc(handler.PaymentResource)
+			w := httptest.NewRecorder()
+			w.Header().Set(requestIDHeader, testRequestID)
+			req := withKey(httptest.NewRequest(v.Method, v.URL, nil))
+			r.ServeHTTP(w, req)
+			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
+			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assertDocumented(t, req, w)
+		})
+	}
+}
+
+func TestCaptureAndVoid(t *testing.T) {
+	type mockPay func(s *mock_service.MockPayment, payId int)
+	captured := models.Transaction{
+		ID:            1,
+		UserID:        1,
+		UserEmail:     "ann@mail.ru",
+		Sum:           money.New(50000, "USD"),
+		Status:        models.StatusCaptured,
+		CaptureMethod: models.CaptureManual,
+		Captured:      money.New(20000, "USD"),
+		CreationDate:  time.Date(2022, 06, 11, 18, 45, 47, 724748010, time.Local),
+		ChangeDate:    time.Date(2022, 06, 11, 18, 47, 22, 683292944, time.Local),
+	}
+	tData := map[string]struct {
+		URL                 string
+		Method              string
+		InputBody           string
+		ExpectedRequestBody string
+		ExpectedStatusCode  int
+		MockPay             mockPay
+	}{
+		"partial capture": {
+			URL:                 "/payments/1/capture",
+			Method:              "POST",
+			InputBody:           `{"Amount":"200.00"}`,
+			ExpectedRequestBody: `{"ID":1,"UserID":1,"Email":"ann@mail.ru","Sum":"500.00","CreationDate":"2022-06-11T18:45:47.72474801+06:00","ChangeDate":"2022-06-11T18:47:22.683292944+06:00","Status":"CAPTURED","CaptureMethod":"manual","Captured":"200.00","Currency":"USD"}`,
+			ExpectedStatusCode:  200,
+			MockPay: func(s *mock_service.MockPayment, payId int) {
+				s.EXPECT().Capture(testMerchantID, payId, models.CaptureInput{Amount: json.RawMessage(`"200.00"`)}).Return(captured, nil)
+			},
+		},
+		"full capture without body": {
+			URL:                 "/payments/1/capture",
+			Method:              "POST",
+			ExpectedRequestBody: `{"ID":1,"UserID":1,"Email":"ann@mail.ru","Sum":"500.00","CreationDate":"2022-06-11T18:45:47.72474801+06:00","ChangeDate":"2022-06-11T18:47:22.683292944+06:00","Status":"CAPTURED","CaptureMethod":"manual","Captured":"200.00","Currency":"USD"}`,
+			ExpectedStatusCode:  200,
+			MockPay: func(s *mock_service.MockPayment, payId int) {
+				s.EXPECT().Capture(testMerchantID, payId, models.CaptureInput{}).Return(captured, nil)
+			},
+		},
+		"capture above authorized": {
+			URL:                 "/payments/1/capture",
+			Method:              "POST",
+			InputBody:           `{"Amount":"600.00"}`,
+			ExpectedRequestBody: `{"error":{"code":"validation_error","message":"invalid input","fields":[{"field":"Amount","message":"must not exceed the authorized 500.00 USD"}],"request_id":"req_test"}}`,
+			ExpectedStatusCode:  422,
+			MockPay: func(s *mock_service.MockPayment, payId int) {
+				vErr := &service.ValidationError{}
+				vErr.Add("Amount", "must not exceed the authorized 500.00 USD")
+				s.EXPECT().Capture(testMerchantID, payId, models.CaptureInput{Amount: json.RawMessage(`"600.00"`)}).Return(models.Transaction{}, vErr)
+			},
+		},
+		"capture not authorized": {
+			URL:                 "/payments/1/capture",
+			Method:              "POST",
+			ExpectedRequestBody: errorJSON(codeConflict, "payment status transition SUCCESS -> CAPTURED is not allowed"),
+			ExpectedStatusCode:  409,
+			MockPay: func(s *mock_service.MockPayment, payId int) {
+				s.EXPECT().Capture(testMerchantID, payId, models.CaptureInput{}).Return(models.Transaction{}, &service.ConflictError{Err: &statemachine.TransitionError{From: models.StatusSuccess, To: models.StatusCaptured}})
+			},
+		},
+		"void": {
+			URL:                 "/payments/1/void",
+			Method:              "POST",
+			ExpectedRequestBody: `{"ID":1,"UserID":0,"Email":"","Sum":"0.00","CreationDate":"0001-01-01T00:00:00Z","ChangeDate":"0001-01-01T00:00:00Z","Status":"VOIDED","Currency":""}`,
+			ExpectedStatusCode:  200,
+			MockPay: func(s *mock_service.MockPayment, payId int) {
+				s.EXPECT().Void(testMerchantID, payId).Return(models.Transaction{ID: payId, Status: models.StatusVoided}, nil)
+			},
+		},
+		"void method not allowed": {
+			URL:                 "/payments/1/void",
+			Method:              "GET",
+			ExpectedRequestBody: errorJSON(codeMethodNotAllowed, "method not allowed"),
+			ExpectedStatusCode:  405,
+			MockPay:             func(s *mock_service.MockPayment, payId int) {},
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			pay := mock_service.NewMockPayment(c)
+			expectOwned(pay)
+			v.MockPay(pay, 1)
+			services := service.Services{
+				Payment: pay,
+			}
+			handler := NewHandler(&services)
+			r := http.HandlerFunc(handler.PaymentResource)
+			w := httptest.NewRecorder()
+			w.Header().Set(requestIDHeader, testRequestID)
+			req := withKey(httptest.NewRequest(v.Method, v.URL, bytes.NewBufferString(v.InputBody)))
 			r.ServeHTTP(w, req)
 			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
 			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assertDocumented(t, req, w)
 		})
 	}
 }
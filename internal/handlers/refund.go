@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+func (h *Handler) PaymentRefunds(w http.ResponseWriter, r *http.Request, id int) {
+	switch r.Method {
+	case http.MethodGet:
+		refunds, err := h.refundService.Refunds(merchantID(r), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, refunds)
+	case http.MethodPost:
+		input := models.RefundInput{}
+		reqBody, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(bytes.TrimSpace(reqBody)) != 0 {
+			err = json.Unmarshal(reqBody, &input)
+			if err != nil {
+				httpError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		refund, err := h.refundService.CreateRefund(merchantID(r), id, input)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		markPersisted(w)
+		writeJSON(w, http.StatusCreated, refund)
+	default:
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) Refund(w http.ResponseWriter, r *http.Request, id int) {
+	refund, err := h.refundService.GetRefund(merchantID(r), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, refund)
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/money"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/altuxa/payment-service-emulator/internal/service"
+	mock_service "github.com/altuxa/payment-service-emulator/internal/service/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefunds(t *testing.T) {
+	type mockRefund func(s *mock_service.MockRefund)
+	refund := models.Refund{
+		ID:        1,
+		PaymentID: 1,
+		Amount:    money.New(2000, "USD"),
+		Status:    models.RefundPending,
+		Reason:    "damaged",
+		CreatedAt: time.Date(2022, 06, 11, 18, 45, 47, 724748010, time.Local),
+		UpdatedAt: time.Date(2022, 06, 11, 18, 45, 47, 724748010, time.Local),
+	}
+	refundJSON := `{"ID":1,"PaymentID":1,"Amount":"20.00","Status":"PENDING","Reason":"damaged","CreatedAt":"2022-06-11T18:45:47.72474801+06:00","UpdatedAt":"2022-06-11T18:45:47.72474801+06:00","Currency":"USD"}`
+	tData := map[string]struct {
+		URL                 string
+		Method              string
+		InputBody           string
+		ExpectedRequestBody string
+		ExpectedStatusCode  int
+		MockRefund          mockRefund
+	}{
+		"create": {
+			URL:                 "/payments/1/refunds",
+			Method:              "POST",
+			InputBody:           `{"Amount":"20.00","Reason":"damaged"}`,
+			ExpectedRequestBody: refundJSON,
+			ExpectedStatusCode:  201,
+			MockRefund: func(s *mock_service.MockRefund) {
+				s.EXPECT().CreateRefund(testMerchantID, 1, models.RefundInput{Amount: json.RawMessage(`"20.00"`), Reason: "damaged"}).Return(refund, nil)
+			},
+		},
+		"create above refundable": {
+			URL:                 "/payments/1/refunds",
+			Method:              "POST",
+			InputBody:           `{"Amount":"200.00"}`,
+			ExpectedRequestBody: `{"error":{"code":"validation_error","message":"invalid input","fields":[{"field":"Amount","message":"must not exceed the refundable 20.00 USD"}],"request_id":"req_test"}}`,
+			ExpectedStatusCode:  422,
+			MockRefund: func(s *mock_service.MockRefund) {
+				vErr := &service.ValidationError{}
+				vErr.Add("Amount", "must not exceed the refundable 20.00 USD")
+				s.EXPECT().CreateRefund(testMerchantID, 1, models.RefundInput{Amount: json.RawMessage(`"200.00"`)}).Return(models.Refund{}, vErr)
+			},
+		},
+		"create not refundable": {
+			URL:                 "/payments/1/refunds",
+			Method:              "POST",
+			ExpectedRequestBody: errorJSON(codeConflict, "payment in status FAIL can not be refunded"),
+			ExpectedStatusCode:  409,
+			MockRefund: func(s *mock_service.MockRefund) {
+				s.EXPECT().CreateRefund(testMerchantID, 1, models.RefundInput{}).Return(models.Refund{}, &service.ConflictError{Err: errors.New("payment in status FAIL can not be refunded")})
+			},
+		},
+		"list": {
+			URL:                 "/payments/1/refunds",
+			Method:              "GET",
+			ExpectedRequestBody: "[" + refundJSON + "]",
+			ExpectedStatusCode:  200,
+			MockRefund: func(s *mock_service.MockRefund) {
+				s.EXPECT().Refunds(testMerchantID, 1).Return([]models.Refund{refund}, nil)
+			},
+		},
+		"get": {
+			URL:                 "/refunds/1",
+			Method:              "GET",
+			ExpectedRequestBody: refundJSON,
+			ExpectedStatusCode:  200,
+			MockRefund: func(s *mock_service.MockRefund) {
+				s.EXPECT().GetRefund(testMerchantID, 1).Return(refund, nil)
+			},
+		},
+		"get not found": {
+			URL:                 "/refunds/2",
+			Method:              "GET",
+			ExpectedRequestBody: errorJSON(codeNotFound, "refund not found"),
+			ExpectedStatusCode:  404,
+			MockRefund: func(s *mock_service.MockRefund) {
+				s.EXPECT().GetRefund(testMerchantID, 2).Return(models.Refund{}, &service.NotFoundError{Err: repository.ErrRefundNotFound})
+			},
+		},
+		"method not allowed": {
+			URL:                 "/payments/1/refunds",
+			Method:              "DELETE",
+			ExpectedRequestBody: errorJSON(codeMethodNotAllowed, "method not allowed"),
+			ExpectedStatusCode:  405,
+			MockRefund:          func(s *mock_service.MockRefund) {},
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			ref := mock_service.NewMockRefund(c)
+			v.MockRefund(ref)
+			pay := mock_service.NewMockPayment(c)
+			expectOwned(pay)
+			key := mock_service.NewMockAPIKey(c)
+			expectSecretKey(key)
+			services := service.Services{
+				APIKey:  key,
+				Payment: pay,
+				Refund:  ref,
+			}
+			handler := NewHandler(&services)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(v.Method, v.URL, bytes.NewBufferString(v.InputBody))
+			req.Header.Set("Authorization", "Bearer "+testSecretKey)
+			req.Header.Set(requestIDHeader, testRequestID)
+			handler.Routes().ServeHTTP(w, req)
+			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
+			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assertDocumented(t, req, w)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// router dispatches resource style routes such as "/v1/payments/{id}/refunds"
+// on method and path. A path served only for other methods gets 405 with an
+// Allow header.
+type router struct {
+	routes []route
+}
+
+type route struct {
+	method   string
+	segments []string
+	handler  http.HandlerFunc
+}
+
+func (rt *router) handle(method, pattern string, handler http.HandlerFunc) {
+	rt.routes = append(rt.routes, route{
+		method:   method,
+		segments: splitPath(pattern),
+		handler:  handler,
+	})
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := splitPath(r.URL.Path)
+	allowed := []string{}
+	for _, route := range rt.routes {
+		params, ok := route.match(path)
+		if !ok {
+			continue
+		}
+		if route.method != r.Method {
+			allowed = append(allowed, route.method)
+			continue
+		}
+		route.handler(w, r.WithContext(context.WithValue(r.Context(), pathParamsKey, params)))
+		return
+	}
+	if len(allowed) != 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	notFound(w, r)
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// match fills the {name} segments of the route from path.
+func (rt route) match(path []string) (map[string]string, bool) {
+	if len(path) != len(rt.segments) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, segment := range rt.segments {
+		switch {
+		case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"):
+			if path[i] == "" {
+				return nil, false
+			}
+			params[segment[1:len(segment)-1]] = path[i]
+		case segment != path[i]:
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func pathParam(r *http.Request, name string) string {
+	params, _ := r.Context().Value(pathParamsKey).(map[string]string)
+	return params[name]
+}
+
+// withID passes the numeric {id} of the path to next.
+func withID(next func(w http.ResponseWriter, r *http.Request, id int)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(pathParam(r, "id"))
+		if err != nil {
+			httpError(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+		next(w, r, id)
+	}
+}
+
+// withPayment is withID for routes below a payment the caller must own.
+func (h *Handler) withPayment(next func(w http.ResponseWriter, r *http.Request, id int)) http.HandlerFunc {
+	return withID(func(w http.ResponseWriter, r *http.Request, id int) {
+		if _, ok := h.ownPayment(w, r, id); !ok {
+			return
+		}
+		next(w, r, id)
+	})
+}
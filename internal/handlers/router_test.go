@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/money"
+	"github.com/altuxa/payment-service-emulator/internal/service"
+	mock_service "github.com/altuxa/payment-service-emulator/internal/service/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestV1Routes(t *testing.T) {
+	type mockPay func(s *mock_service.MockPayment)
+	payment := models.Transaction{ID: 1, UserID: 5, UserEmail: "ann@mail.ru", Sum: money.New(1200, "USD"), Status: models.StatusNew, MerchantID: testMerchantID}
+	paymentJSON := `{"ID":1,"UserID":5,"Email":"ann@mail.ru","Sum":"12.00","CreationDate":"0001-01-01T00:00:00Z","ChangeDate":"0001-01-01T00:00:00Z","Status":"NEW","Currency":"USD"}`
+	tData := map[string]struct {
+		URL                 string
+		Method              string
+		InputBody           string
+		ExpectedRequestBody string
+		ExpectedStatusCode  int
+		ExpectedAllow       string
+		MockPay             mockPay
+	}{
+		"create payment": {
+			URL:                 "/v1/payments",
+			Method:              "POST",
+			InputBody:           `{"UserID":5,"Email":"ann@mail.ru","Sum":"12.00","Currency":"USD"}`,
+			ExpectedRequestBody: paymentJSON,
+			ExpectedStatusCode:  201,
+			MockPay: func(s *mock_service.MockPayment) {
+				s.EXPECT().CreatePayment(models.Transaction{UserID: 5, UserEmail: "ann@mail.ru", Sum: money.New(1200, "USD"), APIKeyID: testKeyID, MerchantID: testMerchantID}).Return(1, models.StatusNew, nil)
+				s.EXPECT().GetPayment(testMerchantID, 1).Return(payment, nil)
+			},
+		},
+		"created payment not read back": {
+			URL:                 "/v1/payments",
+			Method:              "POST",
+			InputBody:           `{"UserID":5,"Email":"ann@mail.ru","Sum":"12.00","Currency":"USD"}`,
+			ExpectedRequestBody: paymentJSON,
+			ExpectedStatusCode:  201,
+			MockPay: func(s *mock_service.MockPayment) {
+				s.EXPECT().CreatePayment(models.Transaction{UserID: 5, UserEmail: "ann@mail.ru", Sum: money.New(1200, "USD"), APIKeyID: testKeyID, MerchantID: testMerchantID}).Return(1, models.StatusNew, nil)
+				s.EXPECT().GetPayment(testMerchantID, 1).Return(models.Transaction{}, &service.InternalError{Err: errors.New("database is locked")})
+			},
+		},
+		"get payment": {
+			URL:                 "/v1/payments/1",
+			Method:              "GET",
+			ExpectedRequestBody: paymentJSON,
+			ExpectedStatusCode:  200,
+			MockPay: func(s *mock_service.MockPayment) {
+				s.EXPECT().GetPayment(testMerchantID, 1).Return(payment, nil)
+			},
+		},
+		"payment status": {
+			URL:                 "/v1/payments/1/status",
+			Method:              "GET",
+			ExpectedRequestBody: `{"ID":1,"Status":"NEW"}`,
+			ExpectedStatusCode:  200,
+			MockPay: func(s *mock_service.MockPayment) {
+				s.EXPECT().GetPayment(testMerchantID, 1).Return(payment, nil)
+			},
+		},
+		"cancel payment": {
+			URL:                 "/v1/payments/1/cancel",
+			Method:              "POST",
+			InputBody:           `{"Reason":"duplicate"}`,
+			ExpectedRequestBody: `{"ID":1,"UserID":5,"Email":"ann@mail.ru","Sum":"12.00","CreationDate":"0001-01-01T00:00:00Z","ChangeDate":"0001-01-01T00:00:00Z","Status":"CANCELLED","CancellationReason":"duplicate","Currency":"USD"}`,
+			ExpectedStatusCode:  200,
+			MockPay: func(s *mock_service.MockPayment) {
+				cancelled := payment
+				cancelled.Status, cancelled.CancellationReason = models.StatusCancelled, "duplicate"
+				gomock.InOrder(
+					s.EXPECT().GetPayment(testMerchantID, 1).Return(payment, nil),
+					s.EXPECT().CancelPayment(testMerchantID, 1, models.CancelInput{Reason: "duplicate"}).Return(nil),
+					s.EXPECT().GetPayment(testMerchantID, 1).Return(cancelled, nil),
+				)
+			},
+		},
+		"payment of another merchant": {
+			URL:                 "/v1/payments/2/history",
+			Method:              "GET",
+			ExpectedRequestBody: errorJSON(codeNotFound, "payment not found"),
+			ExpectedStatusCode:  404,
+			MockPay: func(s *mock_service.MockPayment) {
+				s.EXPECT().GetPayment(testMerchantID, 2).Return(models.Transaction{ID: 2, MerchantID: 2}, nil)
+			},
+		},
+		"user payments": {
+			URL:                 "/v1/users/5/payments?limit=2&user_id=6",
+			Method:              "GET",
+			ExpectedRequestBody: `{"Data":[],"HasMore":false}`,
+			ExpectedStatusCode:  200,
+			MockPay: func(s *mock_service.MockPayment) {
+				s.EXPECT().ListPayments(testMerchantID, models.PaymentListInput{UserID: "5", Limit: "2"}).Return(models.PaymentList{Data: []models.Transaction{}}, nil)
+			},
+		},
+		"trailing slash": {
+			URL:                 "/v1/payments/1/",
+			Method:              "GET",
+			ExpectedRequestBody: paymentJSON,
+			ExpectedStatusCode:  200,
+			MockPay: func(s *mock_service.MockPayment) {
+				s.EXPECT().GetPayment(testMerchantID, 1).Return(payment, nil)
+			},
+		},
+		"invalid id": {
+			URL:                 "/v1/payments/abc",
+			Method:              "GET",
+			ExpectedRequestBody: errorJSON(codeBadRequest, "invalid input"),
+			ExpectedStatusCode:  400,
+			MockPay:             func(s *mock_service.MockPayment) {},
+		},
+		"method not allowed": {
+			URL:                 "/v1/users/5",
+			Method:              "DELETE",
+			ExpectedRequestBody: errorJSON(codeMethodNotAllowed, "method not allowed"),
+			ExpectedStatusCode:  405,
+			ExpectedAllow:       "GET, PUT, PATCH",
+			MockPay:             func(s *mock_service.MockPayment) {},
+		},
+		"unknown route": {
+			URL:                 "/v1/payments/1/unknown",
+			Method:              "GET",
+			ExpectedRequestBody: errorJSON(codeNotFound, "route not found"),
+			ExpectedStatusCode:  404,
+			MockPay:             func(s *mock_service.MockPayment) {},
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			key := mock_service.NewMockAPIKey(c)
+			expectSecretKey(key)
+			pay := mock_service.NewMockPayment(c)
+			v.MockPay(pay)
+			services := service.Services{
+				APIKey:  key,
+				Payment: pay,
+			}
+			handler := NewHandler(&services)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(v.Method, v.URL, bytes.NewBufferString(v.InputBody))
+			req.Header.Set("Authorization", "Bearer "+testSecretKey)
+			req.Header.Set(requestIDHeader, testRequestID)
+			handler.Routes().ServeHTTP(w, req)
+			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
+			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assertDocumented(t, req, w)
+			assert.Equal(t, v.ExpectedAllow, w.Header().Get("Allow"))
+			assert.Empty(t, w.Header().Get("Deprecation"))
+		})
+	}
+}
+
+func TestLegacyRoutesDeprecated(t *testing.T) {
+	handler := NewHandler(&service.Services{})
+	w := httptest.NewRecorder()
+	handler.Routes().ServeHTTP(w, httptest.NewRequest("GET", "/scenarios", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Equal(t, `</v1/>; rel="successor-version"`, w.Header().Get("Link"))
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+func (h *Handler) Users(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	input, ok := readUserInput(w, r)
+	if !ok {
+		return
+	}
+	user, err := h.userService.CreateUser(merchantID(r), input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, user)
+}
+
+func (h *Handler) User(w http.ResponseWriter, r *http.Request, id int) {
+	var user models.User
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		user, err = h.userService.GetUser(merchantID(r), id)
+	case http.MethodPut, http.MethodPatch:
+		input, ok := readUserInput(w, r)
+		if !ok {
+			return
+		}
+		user, err = h.userService.UpdateUser(merchantID(r), id, input)
+	default:
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+func readUserInput(w http.ResponseWriter, r *http.Request) (models.UserInput, bool) {
+	input := models.UserInput{}
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return input, false
+	}
+	err = json.Unmarshal(reqBody, &input)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return input, false
+	}
+	return input, true
+}
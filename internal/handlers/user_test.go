@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/altuxa/payment-service-emulator/internal/service"
+	mock_service "github.com/altuxa/payment-service-emulator/internal/service/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsers(t *testing.T) {
+	type mockUser func(s *mock_service.MockUser)
+	user := models.User{
+		ID:        1,
+		Email:     "ann@mail.ru",
+		Name:      "Ann",
+		CreatedAt: time.Date(2022, 06, 11, 18, 45, 47, 724748010, time.Local),
+		UpdatedAt: time.Date(2022, 06, 11, 18, 45, 47, 724748010, time.Local),
+	}
+	userJSON := `{"ID":1,"Email":"ann@mail.ru","Name":"Ann","CreatedAt":"2022-06-11T18:45:47.72474801+06:00","UpdatedAt":"2022-06-11T18:45:47.72474801+06:00"}`
+	tData := map[string]struct {
+		URL                 string
+		Method              string
+		InputBody           string
+		ExpectedRequestBody string
+		ExpectedStatusCode  int
+		MockUser            mockUser
+	}{
+		"create": {
+			URL:                 "/users",
+			Method:              "POST",
+			InputBody:           `{"Email":"ann@mail.ru","Name":"Ann"}`,
+			ExpectedRequestBody: userJSON,
+			ExpectedStatusCode:  201,
+			MockUser: func(s *mock_service.MockUser) {
+				s.EXPECT().CreateUser(testMerchantID, models.UserInput{Email: "ann@mail.ru", Name: "Ann"}).Return(user, nil)
+			},
+		},
+		"create duplicate email": {
+			URL:                 "/users",
+			Method:              "POST",
+			InputBody:           `{"Email":"ann@mail.ru"}`,
+			ExpectedRequestBody: `{"error":{"code":"validation_error","message":"invalid input","fields":[{"field":"Email","message":"is already registered"}],"request_id":"req_test"}}`,
+			ExpectedStatusCode:  422,
+			MockUser: func(s *mock_service.MockUser) {
+				vErr := &service.ValidationError{}
+				vErr.Add("Email", "is already registered")
+				s.EXPECT().CreateUser(testMerchantID, models.UserInput{Email: "ann@mail.ru"}).Return(models.User{}, vErr)
+			},
+		},
+		"create bad body": {
+			URL:                 "/users",
+			Method:              "POST",
+			InputBody:           `{"Email":`,
+			ExpectedRequestBody: errorJSON(codeBadRequest, "unexpected end of JSON input"),
+			ExpectedStatusCode:  400,
+			MockUser:            func(s *mock_service.MockUser) {},
+		},
+		"get": {
+			URL:                 "/users/1",
+			Method:              "GET",
+			ExpectedRequestBody: userJSON,
+			ExpectedStatusCode:  200,
+			MockUser: func(s *mock_service.MockUser) {
+				s.EXPECT().GetUser(testMerchantID, 1).Return(user, nil)
+			},
+		},
+		"get not found": {
+			URL:                 "/users/2",
+			Method:              "GET",
+			ExpectedRequestBody: errorJSON(codeNotFound, "user not found"),
+			ExpectedStatusCode:  404,
+			MockUser: func(s *mock_service.MockUser) {
+				s.EXPECT().GetUser(testMerchantID, 2).Return(models.User{}, &service.NotFoundError{Err: repository.ErrUserNotFound})
+			},
+		},
+		"update": {
+			URL:                 "/users/1",
+			Method:              "PATCH",
+			InputBody:           `{"Name":"Ann"}`,
+			ExpectedRequestBody: userJSON,
+			ExpectedStatusCode:  200,
+			MockUser: func(s *mock_service.MockUser) {
+				s.EXPECT().UpdateUser(testMerchantID, 1, models.UserInput{Name: "Ann"}).Return(user, nil)
+			},
+		},
+		"invalid id": {
+			URL:                 "/users/a",
+			Method:              "GET",
+			ExpectedRequestBody: errorJSON(codeBadRequest, "invalid input"),
+			ExpectedStatusCode:  400,
+			MockUser:            func(s *mock_service.MockUser) {},
+		},
+		"method not allowed": {
+			URL:                 "/users/1",
+			Method:              "DELETE",
+			ExpectedRequestBody: errorJSON(codeMethodNotAllowed, "method not allowed"),
+			ExpectedStatusCode:  405,
+			MockUser:            func(s *mock_service.MockUser) {},
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			user := mock_service.NewMockUser(c)
+			v.MockUser(user)
+			key := mock_service.NewMockAPIKey(c)
+			expectSecretKey(key)
+			services := service.Services{
+				APIKey: key,
+				User:   user,
+			}
+			handler := NewHandler(&services)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(v.Method, v.URL, bytes.NewBufferString(v.InputBody))
+			req.Header.Set("Authorization", "Bearer "+testSecretKey)
+			req.Header.Set(requestIDHeader, testRequestID)
+			handler.Routes().ServeHTTP(w, req)
+			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
+			assert.Equal(t, v.ExpectedStatusCode, w.Code)
+			assertDocumented(t, req, w)
+		})
+	}
+}
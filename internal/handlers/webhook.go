@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+func (h *Handler) WebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		endpoints, err := h.webhookService.Endpoints(merchantID(r))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, endpoints)
+	case http.MethodPost:
+		input := models.WebhookEndpointInput{}
+		reqBody, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = json.Unmarshal(reqBody, &input)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		endpoint, err := h.webhookService.RegisterEndpoint(merchantID(r), input)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, endpoint)
+	default:
+		httpError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) DeleteEndpoint(w http.ResponseWriter, r *http.Request, id int) {
+	err := h.webhookService.DeleteEndpoint(merchantID(r), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) EndpointDeliveries(w http.ResponseWriter, r *http.Request, id int) {
+	deliveries, err := h.webhookService.Deliveries(merchantID(r), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+func (h *Handler) RotateEndpointSecret(w http.ResponseWriter, r *http.Request, id int) {
+	endpoint, err := h.webhookService.RotateSecret(merchantID(r), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, endpoint)
+}
+
+func (h *Handler) ReplayDelivery(w http.ResponseWriter, r *http.Request, id int) {
+	delivery, err := h.webhookService.Replay(merchantID(r), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, delivery)
+}
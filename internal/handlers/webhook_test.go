@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/altuxa/payment-service-emulator/internal/service"
+	mock_service "github.com/altuxa/payment-service-emulator/internal/service/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookNotFound(t *testing.T) {
+	type mockWebhook func(s *mock_service.MockWebhook)
+	tData := map[string]struct {
+		URL                 string
+		Method              string
+		ExpectedRequestBody string
+		MockWebhook         mockWebhook
+	}{
+		"deliveries of another merchant's endpoint": {
+			URL:                 "/v1/webhooks/endpoints/3/deliveries",
+			Method:              "GET",
+			ExpectedRequestBody: errorJSON(codeNotFound, "webhook endpoint not found"),
+			MockWebhook: func(s *mock_service.MockWebhook) {
+				s.EXPECT().Deliveries(testMerchantID, 3).Return(nil, &service.NotFoundError{Err: repository.ErrEndpointNotFound})
+			},
+		},
+		"rotate another merchant's endpoint": {
+			URL:                 "/v1/webhooks/endpoints/3/rotate",
+			Method:              "POST",
+			ExpectedRequestBody: errorJSON(codeNotFound, "webhook endpoint not found"),
+			MockWebhook: func(s *mock_service.MockWebhook) {
+				s.EXPECT().RotateSecret(testMerchantID, 3).Return(models.WebhookEndpoint{}, &service.NotFoundError{Err: repository.ErrEndpointNotFound})
+			},
+		},
+		"delete another merchant's endpoint": {
+			URL:                 "/v1/webhooks/endpoints/3",
+			Method:              "DELETE",
+			ExpectedRequestBody: errorJSON(codeNotFound, "webhook endpoint not found"),
+			MockWebhook: func(s *mock_service.MockWebhook) {
+				s.EXPECT().DeleteEndpoint(testMerchantID, 3).Return(&service.NotFoundError{Err: repository.ErrEndpointNotFound})
+			},
+		},
+		"replay another merchant's delivery": {
+			URL:                 "/v1/webhooks/deliveries/5/replay",
+			Method:              "POST",
+			ExpectedRequestBody: errorJSON(codeNotFound, "webhook delivery not found"),
+			MockWebhook: func(s *mock_service.MockWebhook) {
+				s.EXPECT().Replay(testMerchantID, 5).Return(models.WebhookDelivery{}, &service.NotFoundError{Err: repository.ErrDeliveryNotFound})
+			},
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			hook := mock_service.NewMockWebhook(c)
+			v.MockWebhook(hook)
+			key := mock_service.NewMockAPIKey(c)
+			expectSecretKey(key)
+			services := service.Services{
+				APIKey:  key,
+				Webhook: hook,
+			}
+			handler := NewHandler(&services)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(v.Method, v.URL, nil)
+			req.Header.Set("Authorization", "Bearer "+testSecretKey)
+			req.Header.Set(requestIDHeader, testRequestID)
+			handler.Routes().ServeHTTP(w, req)
+			assert.Equal(t, v.ExpectedRequestBody, w.Body.String())
+			assert.Equal(t, 404, w.Code)
+			assertDocumented(t, req, w)
+		})
+	}
+}
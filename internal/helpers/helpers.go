@@ -1,26 +1,21 @@
 package helpers
 
 import (
-	"math/rand"
+	"crypto/rand"
+	"encoding/hex"
 	"net/mail"
-	"time"
 )
 
-func PaymentErrorImitation() bool {
-	rand.Seed(time.Now().UnixNano())
-	a := rand.Intn(60)
-	b := rand.Intn(45)
-	return a > b
-}
-
-func FailStatusImitation() bool {
-	rand.Seed(time.Now().UnixNano())
-	a := rand.Intn(60)
-	b := rand.Intn(30)
-	return a > b
-}
-
 func ValidEmail(email string) error {
 	_, err := mail.ParseAddress(email)
 	return err
 }
+
+func RandomToken(prefix string, size int) (string, error) {
+	b := make([]byte, size)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,175 @@
+package helpers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+type Stage string
+
+const (
+	StageCreation   Stage = "creation"
+	StageProcessing Stage = "processing"
+	// StageRefund is decided on a transaction carrying the refund ID and amount
+	StageRefund Stage = "refund"
+)
+
+type Outcome struct {
+	Status        string
+	DeclineReason string
+}
+
+// OutcomeEngine decides how the emulated processor answers at each stage of a
+// payment, rates are the overrides of the merchant owning it.
+type OutcomeEngine interface {
+	Decide(stage Stage, payment models.Transaction, rates models.OutcomeRates) Outcome
+}
+
+// Probabilities are the chances, from 0 to 1, of the unhappy outcome at each
+// stage and the weights of the decline reasons reported with it.
+type Probabilities struct {
+	Error        float64
+	Fail         float64
+	RefundFail   float64
+	ErrorReasons Weights
+	FailReasons  Weights
+}
+
+// DefaultProbabilities match the rates of the original random imitation.
+var DefaultProbabilities = Probabilities{
+	Error:      0.38,
+	Fail:       0.26,
+	RefundFail: 0.1,
+	ErrorReasons: Weights{
+		{models.DeclineProcessorUnavailable, 3},
+		{models.DeclineProcessingError, 2},
+		{models.DeclineFraudSuspected, 1},
+	},
+	FailReasons: Weights{
+		{models.DeclineInsufficientFunds, 4},
+		{models.DeclineDoNotHonor, 3},
+		{models.DeclineCardExpired, 2},
+		{models.DeclineIncorrectCVC, 1},
+		{models.DeclineLimitExceeded, 1},
+		{models.DeclineFraudSuspected, 1},
+	},
+}
+
+// Override replaces the rates set by a merchant.
+func (p Probabilities) Override(rates models.OutcomeRates) Probabilities {
+	if rates.ErrorRate != nil {
+		p.Error = *rates.ErrorRate
+	}
+	if rates.FailRate != nil {
+		p.Fail = *rates.FailRate
+	}
+	if rates.RefundFailRate != nil {
+		p.RefundFail = *rates.RefundFailRate
+	}
+	return p
+}
+
+type Weight struct {
+	Reason string
+	Weight float64
+}
+
+// Weights keep their order so that a seed picks the same reason every run.
+type Weights []Weight
+
+// ParseWeights reads weights in the "insufficient_funds=3,card_expired=1"
+// format, an empty string gives def.
+func ParseWeights(s string, def Weights) (Weights, error) {
+	if strings.TrimSpace(s) == "" {
+		return def, nil
+	}
+	weights := Weights{}
+	for _, item := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid weight %q, expected reason=weight", item)
+		}
+		if !models.KnownDeclineReason(parts[0]) {
+			return nil, fmt.Errorf("unknown decline reason %q", parts[0])
+		}
+		w, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid weight for %s", parts[0])
+		}
+		weights = append(weights, Weight{Reason: parts[0], Weight: w})
+	}
+	return weights, nil
+}
+
+func (w Weights) pick(rng *rand.Rand) string {
+	total := 0.0
+	for _, weight := range w {
+		total += weight.Weight
+	}
+	if total == 0 {
+		return ""
+	}
+	n := rng.Float64() * total
+	for _, weight := range w {
+		if n < weight.Weight {
+			return weight.Reason
+		}
+		n -= weight.Weight
+	}
+	return w[len(w)-1].Reason
+}
+
+// RandomEngine draws outcomes from a random source derived from the seed, the
+// stage and the payment ID. The same seed always gives a payment the same
+// outcome, no matter in which order payments are processed.
+type RandomEngine struct {
+	seed  int64
+	probs Probabilities
+}
+
+func NewRandomEngine(seed int64, probs Probabilities) *RandomEngine {
+	return &RandomEngine{
+		seed:  seed,
+		probs: probs,
+	}
+}
+
+func (e *RandomEngine) Decide(stage Stage, payment models.Transaction, rates models.OutcomeRates) Outcome {
+	probs := e.probs.Override(rates)
+	rng := rand.New(rand.NewSource(e.source(stage, payment.ID)))
+	switch stage {
+	case StageCreation:
+		if rng.Float64() < probs.Error {
+			return Outcome{Status: models.StatusError, DeclineReason: probs.ErrorReasons.pick(rng)}
+		}
+		return Outcome{Status: models.StatusNew}
+	case StageProcessing:
+		if rng.Float64() < probs.Fail {
+			return Outcome{Status: models.StatusFail, DeclineReason: probs.FailReasons.pick(rng)}
+		}
+		return Outcome{Status: models.StatusSuccess}
+	case StageRefund:
+		if rng.Float64() < probs.RefundFail {
+			return Outcome{Status: models.StatusFail, DeclineReason: probs.ErrorReasons.pick(rng)}
+		}
+		return Outcome{Status: models.StatusSuccess}
+	}
+	return Outcome{}
+}
+
+func (e *RandomEngine) source(stage Stage, id int) int64 {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, uint64(e.seed))
+	h.Write(buf)
+	h.Write([]byte(stage))
+	binary.LittleEndian.PutUint64(buf, uint64(id))
+	h.Write(buf)
+	return int64(h.Sum64())
+}
@@ -0,0 +1,84 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandomEngineDeterministic(t *testing.T) {
+	first := NewRandomEngine(42, DefaultProbabilities)
+	second := NewRandomEngine(42, DefaultProbabilities)
+	outcomes := map[int]Outcome{}
+	for id := 1; id <= 100; id++ {
+		outcomes[id] = first.Decide(StageProcessing, models.Transaction{ID: id}, models.OutcomeRates{})
+	}
+	for id := 100; id >= 1; id-- {
+		assert.Equal(t, outcomes[id], second.Decide(StageProcessing, models.Transaction{ID: id}, models.OutcomeRates{}))
+	}
+}
+
+func TestRandomEngineProbabilities(t *testing.T) {
+	testTable := map[string]struct {
+		Probs    Probabilities
+		Stage    Stage
+		Expected string
+	}{
+		"always error":   {Probs: Probabilities{Error: 1}, Stage: StageCreation, Expected: models.StatusError},
+		"never error":    {Probs: Probabilities{Error: 0}, Stage: StageCreation, Expected: models.StatusNew},
+		"always fail":    {Probs: Probabilities{Fail: 1}, Stage: StageProcessing, Expected: models.StatusFail},
+		"always success": {Probs: Probabilities{Fail: 0}, Stage: StageProcessing, Expected: models.StatusSuccess},
+	}
+	for name, test := range testTable {
+		t.Run(name, func(t *testing.T) {
+			engine := NewRandomEngine(7, test.Probs)
+			for id := 1; id <= 50; id++ {
+				assert.Equal(t, test.Expected, engine.Decide(test.Stage, models.Transaction{ID: id}, models.OutcomeRates{}).Status)
+			}
+		})
+	}
+}
+
+func TestRandomEngineRate(t *testing.T) {
+	engine := NewRandomEngine(1, Probabilities{Fail: 0.3})
+	failed := 0
+	for id := 1; id <= 10000; id++ {
+		if engine.Decide(StageProcessing, models.Transaction{ID: id}, models.OutcomeRates{}).Status == models.StatusFail {
+			failed++
+		}
+	}
+	assert.InDelta(t, 3000, failed, 300)
+}
+
+func TestParseWeights(t *testing.T) {
+	weights, err := ParseWeights("insufficient_funds=3, card_expired=1", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Weights{{models.DeclineInsufficientFunds, 3}, {models.DeclineCardExpired, 1}}, weights)
+	weights, err = ParseWeights("", DefaultProbabilities.FailReasons)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultProbabilities.FailReasons, weights)
+	_, err = ParseWeights("stolen_card=1", nil)
+	assert.Error(t, err)
+	_, err = ParseWeights("card_expired=-1", nil)
+	assert.Error(t, err)
+	_, err = ParseWeights("card_expired", nil)
+	assert.Error(t, err)
+}
+
+func TestRandomEngineDeclineReason(t *testing.T) {
+	engine := NewRandomEngine(3, Probabilities{Fail: 1, FailReasons: Weights{{models.DeclineCardExpired, 0}, {models.DeclineFraudSuspected, 1}}})
+	for id := 1; id <= 50; id++ {
+		assert.Equal(t, Outcome{Status: models.StatusFail, DeclineReason: models.DeclineFraudSuspected}, engine.Decide(StageProcessing, models.Transaction{ID: id}, models.OutcomeRates{}))
+	}
+}
+
+func TestRandomEngineMerchantRates(t *testing.T) {
+	engine := NewRandomEngine(5, Probabilities{Error: 0, Fail: 1})
+	never, always := 0.0, 1.0
+	for id := 1; id <= 50; id++ {
+		assert.Equal(t, models.StatusError, engine.Decide(StageCreation, models.Transaction{ID: id}, models.OutcomeRates{ErrorRate: &always}).Status)
+		assert.Equal(t, models.StatusSuccess, engine.Decide(StageProcessing, models.Transaction{ID: id}, models.OutcomeRates{FailRate: &never}).Status)
+		assert.Equal(t, models.StatusFail, engine.Decide(StageProcessing, models.Transaction{ID: id}, models.OutcomeRates{ErrorRate: &never}).Status)
+	}
+}
@@ -0,0 +1,170 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/money"
+)
+
+// Scenario is a magic value that forces the outcome of a payment, the same way
+// sandbox card numbers work in real gateways.
+type Scenario struct {
+	Trigger       string
+	Value         string
+	Stage         Stage
+	Status        string
+	DeclineReason string `json:",omitempty"`
+	Description   string
+
+	match func(payment models.Transaction) bool
+}
+
+const (
+	TriggerAmount = "amount"
+	TriggerEmail  = "email"
+)
+
+// Scenarios is the documented table of triggers, served at GET /scenarios.
+// Email triggers take precedence over amount triggers of the same stage.
+var Scenarios = buildScenarios()
+
+func buildScenarios() []Scenario {
+	scenarios := []Scenario{
+		{
+			Trigger:     TriggerEmail,
+			Value:       "success@*",
+			Stage:       StageProcessing,
+			Status:      models.StatusSuccess,
+			Description: "payment is always approved",
+			match:       emailPrefix("success"),
+		},
+	}
+	for _, code := range models.DeclineReasons {
+		scenarios = append(scenarios, Scenario{
+			Trigger:       TriggerEmail,
+			Value:         "fail+" + code + "@*",
+			Stage:         StageProcessing,
+			Status:        models.StatusFail,
+			DeclineReason: code,
+			Description:   fmt.Sprintf("payment is declined during processing with %s", code),
+			match:         emailPrefix("fail+" + code),
+		})
+	}
+	for _, code := range models.DeclineReasons {
+		scenarios = append(scenarios, Scenario{
+			Trigger:       TriggerEmail,
+			Value:         "error+" + code + "@*",
+			Stage:         StageCreation,
+			Status:        models.StatusError,
+			DeclineReason: code,
+			Description:   fmt.Sprintf("payment is rejected on creation with %s", code),
+			match:         emailPrefix("error+" + code),
+		})
+	}
+	return append(scenarios,
+		Scenario{
+			Trigger:       TriggerAmount,
+			Value:         "*.01",
+			Stage:         StageProcessing,
+			Status:        models.StatusFail,
+			DeclineReason: models.DeclineDoNotHonor,
+			Description:   "amounts with the fraction .01 are declined",
+			match:         amountEnding(1),
+		},
+		Scenario{
+			Trigger:       TriggerAmount,
+			Value:         "*.02",
+			Stage:         StageCreation,
+			Status:        models.StatusError,
+			DeclineReason: models.DeclineProcessorUnavailable,
+			Description:   "amounts with the fraction .02 are rejected on creation",
+			match:         amountEnding(2),
+		},
+		Scenario{
+			Trigger:       TriggerAmount,
+			Value:         "*.03",
+			Stage:         StageProcessing,
+			Status:        models.StatusFail,
+			DeclineReason: models.DeclineInsufficientFunds,
+			Description:   "amounts with the fraction .03 are declined",
+			match:         amountEnding(3),
+		},
+		Scenario{
+			Trigger:     TriggerAmount,
+			Value:       "*.04",
+			Stage:       StageProcessing,
+			Status:      models.StatusSuccess,
+			Description: "amounts with the fraction .04 are always approved",
+			match:       amountEnding(4),
+		},
+		Scenario{
+			Trigger:       TriggerAmount,
+			Value:         "*.05",
+			Stage:         StageRefund,
+			Status:        models.StatusFail,
+			DeclineReason: models.DeclineProcessingError,
+			Description:   "refunds of amounts with the fraction .05 fail",
+			match:         amountEnding(5),
+		},
+	)
+}
+
+func emailPrefix(prefix string) func(models.Transaction) bool {
+	return func(payment models.Transaction) bool {
+		at := strings.LastIndex(payment.UserEmail, "@")
+		return at >= 0 && strings.EqualFold(payment.UserEmail[:at], prefix)
+	}
+}
+
+// amountEnding matches amounts whose fraction of the major unit is cents
+// hundredths, such as 5.01 USD or 5.010 KWD. Currencies without minor units
+// like JPY never match.
+func amountEnding(cents int64) func(models.Transaction) bool {
+	return func(payment models.Transaction) bool {
+		scale := int64(1)
+		for i := 0; i < money.Exponent(payment.Sum.Currency); i++ {
+			scale *= 10
+		}
+		return payment.Sum.Amount%scale*100 == cents*scale
+	}
+}
+
+// ScenarioEngine forces the outcome of payments matching a scenario and leaves
+// the rest to the next engine.
+type ScenarioEngine struct {
+	next OutcomeEngine
+}
+
+func NewScenarioEngine(next OutcomeEngine) *ScenarioEngine {
+	return &ScenarioEngine{
+		next: next,
+	}
+}
+
+func (e *ScenarioEngine) Decide(stage Stage, payment models.Transaction, rates models.OutcomeRates) Outcome {
+	matched := false
+	for _, scenario := range Scenarios {
+		if !scenario.match(payment) {
+			continue
+		}
+		if scenario.Stage == stage {
+			return Outcome{
+				Status:        scenario.Status,
+				DeclineReason: scenario.DeclineReason,
+			}
+		}
+		matched = true
+	}
+	if !matched {
+		return e.next.Decide(stage, payment, rates)
+	}
+	// the forced status belongs to another stage, this one passes through
+	switch stage {
+	case StageCreation:
+		return Outcome{Status: models.StatusNew}
+	default:
+		return Outcome{Status: models.StatusSuccess}
+	}
+}
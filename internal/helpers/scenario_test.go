@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/money"
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedEngine Outcome
+
+func (e fixedEngine) Decide(stage Stage, payment models.Transaction, rates models.OutcomeRates) Outcome {
+	return Outcome(e)
+}
+
+func TestScenarioEngine(t *testing.T) {
+	testTable := map[string]struct {
+		Email    string
+		Amount   int64
+		Currency string
+		Stage    Stage
+		Expected Outcome
+	}{
+		"email fail on processing":  {Email: "fail+insufficient_funds@example.com", Amount: 1000, Stage: StageProcessing, Expected: Outcome{Status: models.StatusFail, DeclineReason: "insufficient_funds"}},
+		"email fail on creation":    {Email: "fail+insufficient_funds@example.com", Amount: 1000, Stage: StageCreation, Expected: Outcome{Status: models.StatusNew}},
+		"email error":               {Email: "Error+Fraud_Suspected@example.com", Amount: 1000, Stage: StageCreation, Expected: Outcome{Status: models.StatusError, DeclineReason: "fraud_suspected"}},
+		"email success":             {Email: "success@example.com", Amount: 1001, Stage: StageProcessing, Expected: Outcome{Status: models.StatusSuccess}},
+		"amount fail":               {Email: "ann@mail.ru", Amount: 50201, Stage: StageProcessing, Expected: Outcome{Status: models.StatusFail, DeclineReason: "do_not_honor"}},
+		"amount error":              {Email: "ann@mail.ru", Amount: 50202, Stage: StageCreation, Expected: Outcome{Status: models.StatusError, DeclineReason: "processor_unavailable"}},
+		"amount fail with 3 digits": {Email: "ann@mail.ru", Amount: 50010, Currency: "KWD", Stage: StageProcessing, Expected: Outcome{Status: models.StatusFail, DeclineReason: "do_not_honor"}},
+		"KWD minor units":           {Email: "ann@mail.ru", Amount: 50001, Currency: "KWD", Stage: StageProcessing, Expected: Outcome{Status: "fallback"}},
+		"JPY has no fraction":       {Email: "ann@mail.ru", Amount: 50201, Currency: "JPY", Stage: StageProcessing, Expected: Outcome{Status: "fallback"}},
+		"JPY error":                 {Email: "ann@mail.ru", Amount: 502, Currency: "JPY", Stage: StageCreation, Expected: Outcome{Status: "fallback"}},
+		"refund amount fail":        {Email: "ann@mail.ru", Amount: 1005, Stage: StageRefund, Expected: Outcome{Status: models.StatusFail, DeclineReason: models.DeclineProcessingError}},
+		"refund of forced payment":  {Email: "success@example.com", Amount: 1005, Stage: StageRefund, Expected: Outcome{Status: models.StatusFail, DeclineReason: models.DeclineProcessingError}},
+		"refund passes through":     {Email: "ann@mail.ru", Amount: 1001, Stage: StageRefund, Expected: Outcome{Status: models.StatusSuccess}},
+		"unknown decline code":      {Email: "fail+whatever@example.com", Amount: 1000, Stage: StageProcessing, Expected: Outcome{Status: "fallback"}},
+		"no scenario":               {Email: "ann@mail.ru", Amount: 1000, Stage: StageProcessing, Expected: Outcome{Status: "fallback"}},
+	}
+	engine := NewScenarioEngine(fixedEngine{Status: "fallback"})
+	for name, test := range testTable {
+		t.Run(name, func(t *testing.T) {
+			currency := test.Currency
+			if currency == "" {
+				currency = "USD"
+			}
+			payment := models.Transaction{ID: 1, UserEmail: test.Email, Sum: money.New(test.Amount, currency)}
+			assert.Equal(t, test.Expected, engine.Decide(test.Stage, payment, models.OutcomeRates{}))
+		})
+	}
+}
@@ -0,0 +1,190 @@
+// Package jwt verifies and signs the JSON Web Tokens accepted in place of
+// API keys. Only the compact serialization with HS256 and RS256 signatures
+// is supported.
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+)
+
+var (
+	ErrMalformed   = errors.New("jwt: malformed token")
+	ErrAlgorithm   = errors.New("jwt: unsupported algorithm")
+	ErrUnknownKey  = errors.New("jwt: no key to verify the token")
+	ErrSignature   = errors.New("jwt: invalid signature")
+	ErrNoExpiry    = errors.New("jwt: token has no expiry")
+	ErrExpired     = errors.New("jwt: token is expired")
+	ErrNotYetValid = errors.New("jwt: token is not valid yet")
+	ErrAudience    = errors.New("jwt: invalid audience")
+	ErrIssuer      = errors.New("jwt: invalid issuer")
+)
+
+// Audience is the aud claim, a single string or an array of strings.
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var list []string
+	err := json.Unmarshal(data, &list)
+	if err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a Audience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims are the registered claims checked by Verify and the emulator's own
+// claims naming the caller.
+type Claims struct {
+	Issuer     string   `json:"iss,omitempty"`
+	Subject    string   `json:"sub,omitempty"`
+	Audience   Audience `json:"aud,omitempty"`
+	ExpiresAt  int64    `json:"exp,omitempty"`
+	NotBefore  int64    `json:"nbf,omitempty"`
+	IssuedAt   int64    `json:"iat,omitempty"`
+	MerchantID int      `json:"merchant_id,omitempty"`
+	UserID     int      `json:"user_id,omitempty"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// Verifier checks tokens against a key set. The audience is required when
+// set, the issuer is only checked when set.
+type Verifier struct {
+	Keys     *KeySet
+	Audience string
+	Issuer   string
+	// Leeway tolerates clock skew in exp and nbf
+	Leeway time.Duration
+}
+
+func (v *Verifier) Verify(token string) (Claims, error) {
+	return v.VerifyAt(token, time.Now())
+}
+
+func (v *Verifier) VerifyAt(token string, now time.Time) (Claims, error) {
+	claims := Claims{}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, ErrMalformed
+	}
+	h := header{}
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return claims, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, ErrMalformed
+	}
+	err = v.Keys.verify(h, []byte(parts[0]+"."+parts[1]), sig)
+	if err != nil {
+		return claims, err
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return claims, err
+	}
+	switch {
+	case claims.ExpiresAt == 0:
+		return claims, ErrNoExpiry
+	case now.Add(-v.Leeway).Unix() >= claims.ExpiresAt:
+		return claims, ErrExpired
+	case claims.NotBefore != 0 && now.Add(v.Leeway).Unix() < claims.NotBefore:
+		return claims, ErrNotYetValid
+	case v.Audience != "" && !claims.Audience.Contains(v.Audience):
+		return claims, ErrAudience
+	case v.Issuer != "" && claims.Issuer != v.Issuer:
+		return claims, ErrIssuer
+	}
+	return claims, nil
+}
+
+// SignHS256 returns a token signed with an HMAC secret.
+func SignHS256(claims Claims, kid string, secret []byte) (string, error) {
+	return sign(claims, header{Alg: HS256, Kid: kid, Typ: "JWT"}, func(data []byte) ([]byte, error) {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(data)
+		return mac.Sum(nil), nil
+	})
+}
+
+// SignRS256 returns a token signed with an RSA private key.
+func SignRS256(claims Claims, kid string, key *rsa.PrivateKey) (string, error) {
+	return sign(claims, header{Alg: RS256, Kid: kid, Typ: "JWT"}, func(data []byte) ([]byte, error) {
+		sum := sha256.Sum256(data)
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	})
+}
+
+func sign(claims Claims, h header, signer func(data []byte) ([]byte, error)) (string, error) {
+	encodedHeader, err := encodeSegment(h)
+	if err != nil {
+		return "", err
+	}
+	encodedClaims, err := encodeSegment(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := encodedHeader + "." + encodedClaims
+	sig, err := signer([]byte(signed))
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func encodeSegment(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrMalformed
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
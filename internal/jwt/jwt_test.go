@@ -0,0 +1,107 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyAt(t *testing.T) {
+	now := time.Unix(1655000000, 0)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	keys := NewKeySet()
+	err = keys.AddJWKS([]byte(fmt.Sprintf(`{"keys":[
+		{"kty":"RSA","kid":"rsa-1","use":"sig","n":%q,"e":%q},
+		{"kty":"oct","kid":"hmac-1","k":%q}
+	]}`,
+		base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+		base64.RawURLEncoding.EncodeToString([]byte("secret")))))
+	assert.NoError(t, err)
+	verifier := &Verifier{Keys: keys, Audience: "emulator", Issuer: "gateway", Leeway: time.Minute}
+	valid := Claims{Issuer: "gateway", Audience: Audience{"emulator"}, ExpiresAt: now.Add(time.Hour).Unix(), MerchantID: 1}
+	with := func(change func(c *Claims)) Claims {
+		c := valid
+		change(&c)
+		return c
+	}
+	hs := func(c Claims, kid string, secret string) string {
+		token, err := SignHS256(c, kid, []byte(secret))
+		assert.NoError(t, err)
+		return token
+	}
+	rs := func(c Claims, kid string, key *rsa.PrivateKey) string {
+		token, err := SignRS256(c, kid, key)
+		assert.NoError(t, err)
+		return token
+	}
+	tData := map[string]struct {
+		Token    string
+		Expected error
+	}{
+		"hs256":                   {Token: hs(valid, "hmac-1", "secret")},
+		"hs256 without kid":       {Token: hs(valid, "", "secret")},
+		"rs256":                   {Token: rs(valid, "rsa-1", rsaKey)},
+		"rs256 without kid":       {Token: rs(valid, "", rsaKey)},
+		"audience list":           {Token: hs(with(func(c *Claims) { c.Audience = Audience{"other", "emulator"} }), "hmac-1", "secret")},
+		"expired within leeway":   {Token: hs(with(func(c *Claims) { c.ExpiresAt = now.Add(-30 * time.Second).Unix() }), "hmac-1", "secret")},
+		"wrong secret":            {Token: hs(valid, "hmac-1", "other"), Expected: ErrSignature},
+		"wrong rsa key":           {Token: rs(valid, "rsa-1", otherKey), Expected: ErrSignature},
+		"unknown kid":             {Token: rs(valid, "rsa-2", rsaKey), Expected: ErrUnknownKey},
+		"expired":                 {Token: hs(with(func(c *Claims) { c.ExpiresAt = now.Add(-time.Hour).Unix() }), "hmac-1", "secret"), Expected: ErrExpired},
+		"no expiry":               {Token: hs(with(func(c *Claims) { c.ExpiresAt = 0 }), "hmac-1", "secret"), Expected: ErrNoExpiry},
+		"not yet valid":           {Token: hs(with(func(c *Claims) { c.NotBefore = now.Add(time.Hour).Unix() }), "hmac-1", "secret"), Expected: ErrNotYetValid},
+		"wrong audience":          {Token: hs(with(func(c *Claims) { c.Audience = Audience{"other"} }), "hmac-1", "secret"), Expected: ErrAudience},
+		"wrong issuer":            {Token: hs(with(func(c *Claims) { c.Issuer = "other" }), "hmac-1", "secret"), Expected: ErrIssuer},
+		"alg none":                {Token: "eyJhbGciOiJub25lIn0." + strings.Split(hs(valid, "", "secret"), ".")[1] + ".", Expected: ErrAlgorithm},
+		"malformed":               {Token: "not-a-token", Expected: ErrMalformed},
+		"tampered claims":         {Token: tamper(hs(valid, "hmac-1", "secret"), hs(with(func(c *Claims) { c.MerchantID = 2 }), "hmac-1", "other")), Expected: ErrSignature},
+		"hs256 signed with rsa n": {Token: hs(valid, "rsa-1", string(rsaKey.N.Bytes())), Expected: ErrUnknownKey},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			claims, err := verifier.VerifyAt(v.Token, now)
+			assert.Equal(t, v.Expected, err)
+			if v.Expected == nil {
+				assert.Equal(t, 1, claims.MerchantID)
+			}
+		})
+	}
+}
+
+// tamper puts the claims of b into the signed token a.
+func tamper(a, b string) string {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	return pa[0] + "." + pb[1] + "." + pa[2]
+}
+
+func TestAudience(t *testing.T) {
+	c := Claims{}
+	assert.NoError(t, decodeSegment(base64.RawURLEncoding.EncodeToString([]byte(`{"aud":"a"}`)), &c))
+	assert.Equal(t, Audience{"a"}, c.Audience)
+	assert.NoError(t, decodeSegment(base64.RawURLEncoding.EncodeToString([]byte(`{"aud":["a","b"]}`)), &c))
+	assert.Equal(t, Audience{"a", "b"}, c.Audience)
+	data, err := Audience{"a"}.MarshalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `"a"`, string(data))
+}
+
+func TestAddJWKS(t *testing.T) {
+	keys := NewKeySet()
+	assert.NoError(t, keys.AddJWKS([]byte(`{"keys":[{"kty":"RSA","use":"enc","n":"AQAB","e":"AQAB"}]}`)))
+	assert.Equal(t, 0, keys.Len())
+	assert.Error(t, keys.AddJWKS([]byte(`{"keys":[{"kty":"EC","kid":"ec"}]}`)))
+	assert.Error(t, keys.AddJWKS([]byte(`{"keys":[{"kty":"oct","kid":"empty"}]}`)))
+	assert.Error(t, keys.AddJWKS([]byte(`not json`)))
+}
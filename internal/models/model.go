@@ -1,23 +1,335 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/money"
+)
 
 const (
-	StatusNew     = "NEW"
-	StatusSuccess = "SUCCESS"
-	StatusFail    = "FAIL"
-	StatusError   = "ERROR"
+	StatusNew               = "NEW"
+	StatusProcessing        = "PROCESSING"
+	StatusSuccess           = "SUCCESS"
+	StatusFail              = "FAIL"
+	StatusError             = "ERROR"
+	StatusCancelled         = "CANCELLED"
+	StatusAuthorized        = "AUTHORIZED"
+	StatusCaptured          = "CAPTURED"
+	StatusVoided            = "VOIDED"
+	StatusPartiallyRefunded = "PARTIALLY_REFUNDED"
+	StatusRefunded          = "REFUNDED"
+)
+
+// Capture methods, manual payments stop at AUTHORIZED until captured or voided.
+const (
+	CaptureAutomatic = "automatic"
+	CaptureManual    = "manual"
 )
 
 type Transaction struct {
 	ID           int
-	UserID       int     `json:"UserID"`
-	UserEmail    string  `json:"Email"`
-	Sum          float64 `json:"Sum"`
-	Currency     string  `json:"Currency"`
+	UserID       int         `json:"UserID"`
+	UserEmail    string      `json:"Email"`
+	Sum          money.Money `json:"Sum"`
 	CreationDate time.Time
 	ChangeDate   time.Time
 	Status       string
+	// DeclineReason explains FAIL and ERROR statuses, one of DeclineReasons
+	DeclineReason        string      `json:",omitempty"`
+	CaptureMethod        string      `json:",omitempty"`
+	Captured             money.Money `json:"-"`
+	Refunded             money.Money `json:"-"`
+	AuthorizationExpires *time.Time  `json:",omitempty"`
+	CancelledAt          *time.Time  `json:",omitempty"`
+	CancellationReason   string      `json:",omitempty"`
+	CancelledBy          string      `json:",omitempty"`
+	// APIKeyID is the key that created the payment
+	APIKeyID int `json:"-"`
+	// MerchantID owns the payment, other merchants never see it
+	MerchantID int `json:"-"`
+}
+
+const (
+	DeclineInsufficientFunds    = "insufficient_funds"
+	DeclineCardExpired          = "card_expired"
+	DeclineDoNotHonor           = "do_not_honor"
+	DeclineIncorrectCVC         = "incorrect_cvc"
+	DeclineLimitExceeded        = "limit_exceeded"
+	DeclineFraudSuspected       = "fraud_suspected"
+	DeclineProcessorUnavailable = "processor_unavailable"
+	DeclineProcessingError      = "processing_error"
+)
+
+var DeclineReasons = []string{
+	DeclineInsufficientFunds,
+	DeclineCardExpired,
+	DeclineDoNotHonor,
+	DeclineIncorrectCVC,
+	DeclineLimitExceeded,
+	DeclineFraudSuspected,
+	DeclineProcessorUnavailable,
+	DeclineProcessingError,
+}
+
+func KnownDeclineReason(reason string) bool {
+	for _, r := range DeclineReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusChange is a single transition of a payment status. Captured,
+// Refunded and AuthorizationExpires are only written when set.
+type StatusChange struct {
+	From                 string
+	To                   string
+	Reason               string
+	DeclineReason        string
+	Captured             *int64
+	Refunded             *int64
+	AuthorizationExpires *time.Time
+	// Actor is who made the change, recorded with cancellations
+	Actor string
+}
+
+type CancelInput struct {
+	Reason string
+	Actor  string
+}
+
+type CaptureInput struct {
+	// Amount is optional, the whole authorized sum is captured without it
+	Amount json.RawMessage
+}
+
+type StatusOutput struct {
+	ID            int
+	Status        string
+	DeclineReason string `json:",omitempty"`
+}
+
+func (t Transaction) MarshalJSON() ([]byte, error) {
+	type alias Transaction
+	return json.Marshal(struct {
+		alias
+		Captured *money.Money `json:"Captured,omitempty"`
+		Refunded *money.Money `json:"Refunded,omitempty"`
+		Currency string       `json:"Currency"`
+	}{
+		alias:    alias(t),
+		Captured: nonZero(t.Captured),
+		Refunded: nonZero(t.Refunded),
+		Currency: t.Sum.Currency,
+	})
+}
+
+func nonZero(m money.Money) *money.Money {
+	if m.Amount == 0 {
+		return nil
+	}
+	return &m
+}
+
+func (t *Transaction) UnmarshalJSON(data []byte) error {
+	type alias Transaction
+	aux := struct {
+		*alias
+		Sum      json.RawMessage `json:"Sum"`
+		Currency string          `json:"Currency"`
+	}{
+		alias: (*alias)(t),
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+	t.Sum, err = money.Decode(aux.Sum, aux.Currency)
+	return err
+}
+
+// LegacyTransaction is the input of /payments/new, whose numeric Sum has
+// always been in major units.
+type LegacyTransaction struct {
+	Transaction
+}
+
+func (t *LegacyTransaction) UnmarshalJSON(data []byte) error {
+	type alias Transaction
+	aux := struct {
+		*alias
+		Sum      json.RawMessage `json:"Sum"`
+		Currency string          `json:"Currency"`
+	}{
+		alias: (*alias)(&t.Transaction),
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+	t.Sum, err = money.DecodeMajor(aux.Sum, aux.Currency)
+	return err
+}
+
+type PaymentEvent struct {
+	ID         int
+	PaymentID  int
+	FromStatus string
+	ToStatus   string
+	Reason     string
+	CreatedAt  time.Time
+}
+
+const (
+	EventPaymentCreated    = "payment.created"
+	EventPaymentSucceeded  = "payment.succeeded"
+	EventPaymentFailed     = "payment.failed"
+	EventPaymentCancelled  = "payment.cancelled"
+	EventPaymentAuthorized = "payment.authorized"
+	EventPaymentCaptured   = "payment.captured"
+	EventPaymentVoided     = "payment.voided"
+	EventPaymentRefunded   = "payment.refunded"
+)
+
+const (
+	DeliveryPending   = "PENDING"
+	DeliverySucceeded = "SUCCEEDED"
+	DeliveryFailed    = "FAILED"
+)
+
+// Signature faults make the emulator sign deliveries incorrectly on purpose
+// so that receivers can test their rejection paths.
+const (
+	FaultInvalidSignature = "invalid_signature"
+	FaultStaleTimestamp   = "stale_timestamp"
+)
+
+type WebhookEndpoint struct {
+	ID                    int
+	MerchantID            int `json:"-"`
+	URL                   string
+	Secret                string     `json:",omitempty"`
+	PreviousSecret        string     `json:",omitempty"`
+	PreviousSecretExpires *time.Time `json:",omitempty"`
+	Events                []string
+	SignatureFault        string `json:",omitempty"`
+	CreatedAt             time.Time
+}
+
+type WebhookEvent struct {
+	ID      int
+	Type    string
+	Created time.Time
+	Data    Transaction
+}
+
+type WebhookDelivery struct {
+	ID           int
+	EndpointID   int
+	EventID      int
+	EventType    string
+	Status       string
+	Attempts     int
+	NextAttempt  time.Time
+	ResponseCode int
+	LastError    string
+	Payload      json.RawMessage
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Endpoint     WebhookEndpoint `json:"-"`
+}
+
+type WebhookEndpointInput struct {
+	URL            string   `json:"URL"`
+	Events         []string `json:"Events"`
+	SignatureFault string   `json:"SignatureFault"`
+}
+
+const (
+	RefundPending   = "PENDING"
+	RefundSucceeded = "SUCCEEDED"
+	RefundFailed    = "FAILED"
+)
+
+type Refund struct {
+	ID            int
+	PaymentID     int
+	MerchantID    int `json:"-"`
+	Amount        money.Money
+	Status        string
+	Reason        string `json:",omitempty"`
+	DeclineReason string `json:",omitempty"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (r Refund) MarshalJSON() ([]byte, error) {
+	type alias Refund
+	return json.Marshal(struct {
+		alias
+		Currency string `json:"Currency"`
+	}{
+		alias:    alias(r),
+		Currency: r.Amount.Currency,
+	})
+}
+
+type RefundInput struct {
+	// Amount is optional, everything not refunded yet is refunded without it
+	Amount json.RawMessage
+	Reason string
+}
+
+const (
+	JobPayment = "payment"
+	JobRefund  = "refund"
+)
+
+const (
+	JobPending = "PENDING"
+	JobRunning = "RUNNING"
+	JobDone    = "DONE"
+	JobFailed  = "FAILED"
+)
+
+type Job struct {
+	ID         int
+	Kind       string
+	MerchantID int
+	PaymentID  int
+	RefundID   int
+	Status     string
+	RunAt      time.Time
+	Attempts   int
+	LastError  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// IdempotencyRecord is a stored request and its response, ResponseCode stays
+// zero while the original request is still being handled.
+type IdempotencyRecord struct {
+	Key          string
+	Fingerprint  string
+	ResponseCode int
+	ResponseBody []byte
+	CreatedAt    time.Time
+}
+
+type User struct {
+	ID         int
+	MerchantID int `json:"-"`
+	Email      string
+	Name       string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type UserInput struct {
+	Email string
+	Name  string
 }
 
 type PaymentProcessingInput struct {
@@ -27,3 +339,155 @@ type PaymentProcessingInput struct {
 type InputByUserEmail struct {
 	Email string `json:"email"`
 }
+
+// Sort orders of payment listings, a leading "-" sorts descending.
+const (
+	SortCreated     = "created_at"
+	SortCreatedDesc = "-created_at"
+	SortAmount      = "amount"
+	SortAmountDesc  = "-amount"
+)
+
+// PaymentListInput is the raw query of GET /payments, the service parses and
+// validates it into a PaymentFilter.
+type PaymentListInput struct {
+	Status      string
+	Currency    string
+	MinAmount   string
+	MaxAmount   string
+	CreatedFrom string
+	CreatedTo   string
+	ChangedFrom string
+	ChangedTo   string
+	UserID      string
+	Email       string
+	Sort        string
+	Limit       string
+	Cursor      string
+}
+
+// PaymentFilter selects a page of a merchant's payments, zero fields match
+// every payment. Date ranges include From and exclude To.
+type PaymentFilter struct {
+	Statuses    []string
+	Currency    string
+	MinAmount   *int64
+	MaxAmount   *int64
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	ChangedFrom *time.Time
+	ChangedTo   *time.Time
+	UserID      int
+	Email       string
+	Sort        string
+	Limit       int
+	// After continues a listing past this payment of the same sort order
+	After *PaymentCursor
+}
+
+// PaymentCursor is the position of the last payment of a page, Created is
+// only used when sorting by creation time and Amount when sorting by amount.
+type PaymentCursor struct {
+	Sort    string
+	ID      int
+	Created time.Time
+	Amount  int64 `json:",omitempty"`
+}
+
+// PaymentList is a page of payments, NextCursor is empty on the last page.
+type PaymentList struct {
+	Data       []Transaction
+	HasMore    bool
+	NextCursor string `json:",omitempty"`
+}
+
+// Principal kinds, a user token acts for one user only.
+const (
+	KeySecret      = "secret"
+	KeyPublishable = "publishable"
+	KeyUser        = "user"
+)
+
+// APIKey is a pair of merchant credentials sharing one scope. The raw keys
+// are only returned when the pair is issued, the database keeps their hashes.
+type APIKey struct {
+	ID                int
+	MerchantID        int
+	Name              string
+	SecretKey         string `json:",omitempty"`
+	PublishableKey    string `json:",omitempty"`
+	SecretPrefix      string
+	PublishablePrefix string
+	CreatedAt         time.Time
+	RevokedAt         *time.Time `json:",omitempty"`
+}
+
+type APIKeyInput struct {
+	MerchantID int
+	Name       string
+}
+
+// Principal is the caller authenticated by an API key or a token, everything
+// it touches is scoped to MerchantID.
+type Principal struct {
+	MerchantID int
+	APIKeyID   int
+	UserID     int
+	Kind       string
+}
+
+// Merchant is a tenant of the emulator with its own keys, users, payments
+// and webhook endpoints. Empty Currencies allows every supported currency.
+type Merchant struct {
+	ID         int
+	Name       string
+	Currencies []string
+	OutcomeRates
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// OutcomeRates override the emulator wide outcome probabilities for one
+// merchant, nil rates keep the defaults.
+type OutcomeRates struct {
+	ErrorRate      *float64 `json:",omitempty"`
+	FailRate       *float64 `json:",omitempty"`
+	RefundFailRate *float64 `json:",omitempty"`
+}
+
+// MerchantInput creates or patches a merchant, fields left out of a patch
+// keep their values.
+type MerchantInput struct {
+	Name       *string
+	Currencies []string
+	OutcomeRates
+}
+
+// AllowsCurrency reports whether the merchant accepts payments in code.
+func (m Merchant) AllowsCurrency(code string) bool {
+	if len(m.Currencies) == 0 {
+		return true
+	}
+	for _, c := range m.Currencies {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenInput describes a token minted for test setups, TTL is a duration
+// such as "15m".
+type TokenInput struct {
+	MerchantID int
+	UserID     int
+	Algorithm  string
+	Audience   string
+	TTL        string
+}
+
+type Token struct {
+	Token     string
+	Algorithm string
+	ExpiresAt time.Time
+}
@@ -0,0 +1,119 @@
+package money
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultMin = 1
+	defaultMax = 99999999
+)
+
+// iso4217 lists active ISO 4217 currency codes with their minor unit exponent.
+// Funds, precious metals and testing codes such as XXX are left out on purpose.
+var iso4217 = map[string]int{
+	"AED": 2, "AFN": 2, "ALL": 2, "AMD": 2, "ANG": 2, "AOA": 2, "ARS": 2, "AUD": 2,
+	"AWG": 2, "AZN": 2, "BAM": 2, "BBD": 2, "BDT": 2, "BGN": 2, "BHD": 3, "BIF": 0,
+	"BMD": 2, "BND": 2, "BOB": 2, "BRL": 2, "BSD": 2, "BTN": 2, "BWP": 2, "BYN": 2,
+	"BZD": 2, "CAD": 2, "CDF": 2, "CHF": 2, "CLP": 0, "CNY": 2, "COP": 2, "CRC": 2,
+	"CUP": 2, "CVE": 2, "CZK": 2, "DJF": 0, "DKK": 2, "DOP": 2, "DZD": 2, "EGP": 2,
+	"ERN": 2, "ETB": 2, "EUR": 2, "FJD": 2, "FKP": 2, "GBP": 2, "GEL": 2, "GHS": 2,
+	"GIP": 2, "GMD": 2, "GNF": 0, "GTQ": 2, "GYD": 2, "HKD": 2, "HNL": 2, "HTG": 2,
+	"HUF": 2, "IDR": 2, "ILS": 2, "INR": 2, "IQD": 3, "IRR": 2, "ISK": 0, "JMD": 2,
+	"JOD": 3, "JPY": 0, "KES": 2, "KGS": 2, "KHR": 2, "KMF": 0, "KPW": 2, "KRW": 0,
+	"KWD": 3, "KYD": 2, "KZT": 2, "LAK": 2, "LBP": 2, "LKR": 2, "LRD": 2, "LSL": 2,
+	"LYD": 3, "MAD": 2, "MDL": 2, "MGA": 2, "MKD": 2, "MMK": 2, "MNT": 2, "MOP": 2,
+	"MRU": 2, "MUR": 2, "MVR": 2, "MWK": 2, "MXN": 2, "MYR": 2, "MZN": 2, "NAD": 2,
+	"NGN": 2, "NIO": 2, "NOK": 2, "NPR": 2, "NZD": 2, "OMR": 3, "PAB": 2, "PEN": 2,
+	"PGK": 2, "PHP": 2, "PKR": 2, "PLN": 2, "PYG": 0, "QAR": 2, "RON": 2, "RSD": 2,
+	"RUB": 2, "RWF": 0, "SAR": 2, "SBD": 2, "SCR": 2, "SDG": 2, "SEK": 2, "SGD": 2,
+	"SHP": 2, "SLE": 2, "SOS": 2, "SRD": 2, "SSP": 2, "STN": 2, "SVC": 2, "SYP": 2,
+	"SZL": 2, "THB": 2, "TJS": 2, "TMT": 2, "TND": 3, "TOP": 2, "TRY": 2, "TTD": 2,
+	"TWD": 2, "TZS": 2, "UAH": 2, "UGX": 0, "USD": 2, "UYU": 2, "UZS": 2, "VES": 2,
+	"VND": 0, "VUV": 0, "WST": 2, "XAF": 0, "XCD": 2, "XOF": 0, "XPF": 0, "YER": 2,
+	"ZAR": 2, "ZMW": 2, "ZWL": 2,
+}
+
+type Currency struct {
+	Code     string
+	Exponent int
+	Min      int64
+	Max      int64
+}
+
+type Registry struct {
+	currencies map[string]Currency
+}
+
+func NewRegistry() *Registry {
+	r := &Registry{currencies: make(map[string]Currency, len(iso4217))}
+	for code, exp := range iso4217 {
+		r.currencies[code] = Currency{Code: code, Exponent: exp, Min: defaultMin, Max: defaultMax}
+	}
+	return r
+}
+
+func (r *Registry) Lookup(code string) (Currency, bool) {
+	c, ok := r.currencies[code]
+	return c, ok
+}
+
+func (r *Registry) Codes() []string {
+	codes := make([]string, 0, len(r.currencies))
+	for code := range r.currencies {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// SetLimits parses a comma separated list of per-currency limits in major
+// units, e.g. "USD=0.50:999999.99,JPY=50:". An empty bound keeps the default.
+func (r *Registry) SetLimits(spec string) error {
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		code, bounds, ok := cut(item, "=")
+		if !ok {
+			return fmt.Errorf("invalid currency limit %q", item)
+		}
+		c, known := r.currencies[code]
+		if !known {
+			return fmt.Errorf("invalid currency limit %q: unknown currency %s", item, code)
+		}
+		min, max, ok := cut(bounds, ":")
+		if !ok {
+			return fmt.Errorf("invalid currency limit %q", item)
+		}
+		if min != "" {
+			m, err := Parse(min, code)
+			if err != nil {
+				return fmt.Errorf("invalid currency limit %q: %w", item, err)
+			}
+			c.Min = m.Amount
+		}
+		if max != "" {
+			m, err := Parse(max, code)
+			if err != nil {
+				return fmt.Errorf("invalid currency limit %q: %w", item, err)
+			}
+			c.Max = m.Amount
+		}
+		if c.Min > c.Max {
+			return fmt.Errorf("invalid currency limit %q: min is greater than max", item)
+		}
+		r.currencies[code] = c
+	}
+	return nil
+}
+
+func cut(s, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,141 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidAmount = errors.New("invalid amount")
+
+const defaultExponent = 2
+
+// Money is an amount in minor units of its currency, e.g. cents for USD.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+func Exponent(currency string) int {
+	if exp, ok := iso4217[currency]; ok {
+		return exp
+	}
+	return defaultExponent
+}
+
+// Parse reads a decimal amount in major units such as "502.30".
+func Parse(s, currency string) (Money, error) {
+	exp := Exponent(currency)
+	value := strings.TrimSpace(s)
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(value, "-")
+	whole, frac := value, ""
+	if i := strings.IndexByte(value, '.'); i >= 0 {
+		whole, frac = value[:i], value[i+1:]
+	}
+	if whole == "" || !digits(whole) || !digits(frac) {
+		return Money{}, fmt.Errorf("%w %q", ErrInvalidAmount, s)
+	}
+	if len(frac) > exp {
+		return Money{}, fmt.Errorf("%w %q: %s allows %d decimal places", ErrInvalidAmount, s, currency, exp)
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+	amount, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w %q", ErrInvalidAmount, s)
+	}
+	if negative {
+		amount = -amount
+	}
+	return New(amount, currency), nil
+}
+
+// Decode reads a JSON amount of the v1 API: a string holds a decimal amount
+// in major units, an integer holds minor units. A number with a fraction is
+// rejected, 502.0 and 502 would otherwise differ a hundredfold.
+func Decode(raw json.RawMessage, currency string) (Money, error) {
+	raw = bytes.TrimSpace(raw)
+	if missing(raw) {
+		return New(0, currency), nil
+	}
+	if raw[0] == '"' {
+		return parseString(raw, currency)
+	}
+	if bytes.ContainsAny(raw, ".eE") {
+		return Money{}, fmt.Errorf("%w %s: a number is in minor units and must be an integer, send major units as a string such as \"12.50\"", ErrInvalidAmount, raw)
+	}
+	amount, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w %s", ErrInvalidAmount, raw)
+	}
+	return New(amount, currency), nil
+}
+
+// DecodeMajor reads a JSON amount of the legacy API, where a number is in
+// major units as a string is, so 1000 is 1000.00. Fractional numbers are read
+// from their literal text so that 502.3 is never rounded through float64.
+func DecodeMajor(raw json.RawMessage, currency string) (Money, error) {
+	raw = bytes.TrimSpace(raw)
+	if missing(raw) {
+		return New(0, currency), nil
+	}
+	if raw[0] == '"' {
+		return parseString(raw, currency)
+	}
+	return Parse(string(raw), currency)
+}
+
+func missing(raw json.RawMessage) bool {
+	return len(raw) == 0 || bytes.Equal(raw, []byte("null"))
+}
+
+func parseString(raw json.RawMessage, currency string) (Money, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return Money{}, err
+	}
+	return Parse(s, currency)
+}
+
+// FromFloat converts a legacy floating point amount, rounding to the minor unit.
+func FromFloat(f float64, currency string) Money {
+	return New(int64(math.Round(f*math.Pow10(Exponent(currency)))), currency)
+}
+
+func (m Money) String() string {
+	exp := Exponent(m.Currency)
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	s := strconv.FormatInt(amount, 10)
+	if exp == 0 {
+		return sign + s
+	}
+	if len(s) <= exp {
+		s = strings.Repeat("0", exp-len(s)+1) + s
+	}
+	return sign + s[:len(s)-exp] + "." + s[len(s)-exp:]
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecode(t *testing.T) {
+	tData := map[string]struct {
+		Raw      string
+		Currency string
+		Expected Money
+		Err      bool
+	}{
+		"decimal string":        {Raw: `"502.3"`, Currency: "USD", Expected: New(50230, "USD")},
+		"minor units":           {Raw: `50230`, Currency: "USD", Expected: New(50230, "USD")},
+		"fractional number":     {Raw: `502.3`, Currency: "USD", Err: true},
+		"zero fraction":         {Raw: `502.0`, Currency: "USD", Err: true},
+		"zero decimals":         {Raw: `"1500"`, Currency: "JPY", Expected: New(1500, "JPY")},
+		"three decimals":        {Raw: `"1.005"`, Currency: "KWD", Expected: New(1005, "KWD")},
+		"too many decimals":     {Raw: `"1.005"`, Currency: "USD", Err: true},
+		"decimals not allowed":  {Raw: `"10.5"`, Currency: "JPY", Err: true},
+		"garbage":               {Raw: `"12a"`, Currency: "USD", Err: true},
+		"exponent notation":     {Raw: `5e2`, Currency: "USD", Err: true},
+		"missing amount":        {Raw: ``, Currency: "USD", Expected: New(0, "USD")},
+		"negative decimal":      {Raw: `"-0.05"`, Currency: "USD", Expected: New(-5, "USD")},
+		"integer part required": {Raw: `".5"`, Currency: "USD", Err: true},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			m, err := Decode(json.RawMessage(v.Raw), v.Currency)
+			if v.Err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, v.Expected, m)
+		})
+	}
+}
+
+func TestDecodeMajor(t *testing.T) {
+	tData := map[string]struct {
+		Raw      string
+		Currency string
+		Expected Money
+		Err      bool
+	}{
+		"decimal string":    {Raw: `"502.3"`, Currency: "USD", Expected: New(50230, "USD")},
+		"integer":           {Raw: `1000`, Currency: "USD", Expected: New(100000, "USD")},
+		"fractional number": {Raw: `502.3`, Currency: "USD", Expected: New(50230, "USD")},
+		"zero decimals":     {Raw: `1500`, Currency: "JPY", Expected: New(1500, "JPY")},
+		"too many decimals": {Raw: `1.005`, Currency: "USD", Err: true},
+		"exponent notation": {Raw: `5e2`, Currency: "USD", Err: true},
+		"missing amount":    {Raw: `null`, Currency: "USD", Expected: New(0, "USD")},
+		"empty string":      {Raw: `""`, Currency: "USD", Err: true},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			m, err := DecodeMajor(json.RawMessage(v.Raw), v.Currency)
+			if v.Err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, v.Expected, m)
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "502.30", New(50230, "USD").String())
+	assert.Equal(t, "0.05", New(5, "USD").String())
+	assert.Equal(t, "-0.05", New(-5, "USD").String())
+	assert.Equal(t, "1500", New(1500, "JPY").String())
+	assert.Equal(t, "1.005", New(1005, "KWD").String())
+	assert.Equal(t, New(50230, "USD"), FromFloat(502.3, "USD"))
+}
+
+func TestSetLimits(t *testing.T) {
+	r := NewRegistry()
+	assert.NoError(t, r.SetLimits("USD=0.50:999.99, JPY=50:"))
+	usd, ok := r.Lookup("USD")
+	assert.True(t, ok)
+	assert.Equal(t, Currency{Code: "USD", Exponent: 2, Min: 50, Max: 99999}, usd)
+	jpy, _ := r.Lookup("JPY")
+	assert.Equal(t, Currency{Code: "JPY", Exponent: 0, Min: 50, Max: defaultMax}, jpy)
+	_, ok = r.Lookup("XXX")
+	assert.False(t, ok)
+	assert.Error(t, r.SetLimits("XXX=1:2"))
+	assert.Error(t, r.SetLimits("USD=5:1"))
+	assert.Error(t, r.SetLimits("USD"))
+}
@@ -0,0 +1,181 @@
+// Package openapi serves the OpenAPI 3 document of the emulator and checks
+// requests and responses against it. Only the parts of the specification
+// the document uses are supported.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//go:embed openapi.json
+var document []byte
+
+// JSON returns the document served at /openapi.json.
+func JSON() []byte {
+	return document
+}
+
+// Document is the part of an OpenAPI document used for validation.
+type Document struct {
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Responses map[string]*Response `json:"responses"`
+		Schemas   map[string]*Schema   `json:"schemas"`
+	} `json:"components"`
+
+	routes []route
+}
+
+// Operation is one method of a path.
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	Deprecated  bool         `json:"deprecated"`
+	Parameters  []Parameter  `json:"parameters"`
+	RequestBody *RequestBody `json:"requestBody"`
+	// Responses are keyed by status code or "default"
+	Responses map[string]*Response `json:"responses"`
+
+	doc *Document
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref     string               `json:"$ref"`
+	Content map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Nullable             bool               `json:"nullable"`
+	Enum                 []interface{}      `json:"enum"`
+	Pattern              string             `json:"pattern"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	AnyOf                []*Schema          `json:"anyOf"`
+}
+
+type route struct {
+	method    string
+	segments  []string
+	literals  int
+	operation *Operation
+}
+
+// Load parses the embedded document.
+func Load() (*Document, error) {
+	return Parse(document)
+}
+
+// Parse parses an OpenAPI document and indexes its paths for Operation.
+func Parse(data []byte) (*Document, error) {
+	doc := &Document{}
+	err := json.Unmarshal(data, doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse openapi document %w", err)
+	}
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			op.doc = doc
+			r := route{
+				method:    strings.ToUpper(method),
+				segments:  strings.Split(strings.Trim(path, "/"), "/"),
+				operation: op,
+			}
+			for _, segment := range r.segments {
+				if !strings.HasPrefix(segment, "{") {
+					r.literals++
+				}
+			}
+			doc.routes = append(doc.routes, r)
+		}
+	}
+	return doc, nil
+}
+
+// Operation finds the operation serving method and path. Of several matching
+// templates the one with the most literal segments wins, so /payments/byid/1
+// is not taken for a payment with the ID "byid".
+func (d *Document) Operation(method, path string) (*Operation, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	var found *route
+	for i, r := range d.routes {
+		if r.method != method || !r.match(segments) {
+			continue
+		}
+		if found == nil || r.literals > found.literals {
+			found = &d.routes[i]
+		}
+	}
+	if found == nil {
+		return nil, false
+	}
+	return found.operation, true
+}
+
+func (r route) match(segments []string) bool {
+	if len(segments) != len(r.segments) {
+		return false
+	}
+	for i, segment := range r.segments {
+		if strings.HasPrefix(segment, "{") {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// resolve follows the $ref of s, refs point into components/schemas.
+func (d *Document) resolve(s *Schema) (*Schema, error) {
+	for s != nil && s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		next, ok := d.Components.Schemas[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown schema %s", s.Ref)
+		}
+		s = next
+	}
+	return s, nil
+}
+
+func (d *Document) response(r *Response) (*Response, error) {
+	if r.Ref == "" {
+		return r, nil
+	}
+	name := strings.TrimPrefix(r.Ref, "#/components/responses/")
+	next, ok := d.Components.Responses[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown response %s", r.Ref)
+	}
+	return next, nil
+}
@@ -0,0 +1,276 @@
+package openapi
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	doc, err := Load()
+	require.NoError(t, err)
+	ids := map[string]bool{}
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			assert.NotEmpty(t, op.OperationID, "%s %s", method, path)
+			assert.False(t, ids[op.OperationID], "duplicate operationId %s", op.OperationID)
+			ids[op.OperationID] = true
+			for _, p := range op.Parameters {
+				assertResolves(t, doc, p.Schema)
+			}
+			if op.RequestBody != nil {
+				for _, media := range op.RequestBody.Content {
+					assertResolves(t, doc, media.Schema)
+				}
+			}
+			for code, resp := range op.Responses {
+				resp, err := doc.response(resp)
+				if !assert.NoError(t, err, "%s %s %s", method, path, code) {
+					continue
+				}
+				for _, media := range resp.Content {
+					assertResolves(t, doc, media.Schema)
+				}
+			}
+		}
+	}
+}
+
+func assertResolves(t *testing.T, doc *Document, s *Schema) {
+	t.Helper()
+	s, err := doc.resolve(s)
+	if !assert.NoError(t, err) || s == nil {
+		return
+	}
+	for _, prop := range s.Properties {
+		assertResolves(t, doc, prop)
+	}
+	for _, option := range s.AnyOf {
+		assertResolves(t, doc, option)
+	}
+	if s.Items != nil {
+		assertResolves(t, doc, s.Items)
+	}
+}
+
+func TestOperation(t *testing.T) {
+	doc, err := Load()
+	require.NoError(t, err)
+	tData := map[string]struct {
+		Method   string
+		Path     string
+		Expected string
+	}{
+		"collection":        {Method: "GET", Path: "/v1/payments", Expected: "listPayments"},
+		"path parameter":    {Method: "GET", Path: "/v1/payments/12", Expected: "getPayment"},
+		"nested resource":   {Method: "POST", Path: "/v1/payments/12/refunds", Expected: "createRefund"},
+		"trailing slash":    {Method: "GET", Path: "/v1/payments/12/", Expected: "getPayment"},
+		"literal wins":      {Method: "GET", Path: "/payments/byid/3", Expected: "legacyPaymentsByUserID"},
+		"legacy resource":   {Method: "GET", Path: "/payments/12/history", Expected: "legacyGetPaymentHistory"},
+		"unknown method":    {Method: "DELETE", Path: "/v1/payments/12"},
+		"unknown path":      {Method: "GET", Path: "/v1/invoices"},
+		"missing parameter": {Method: "GET", Path: "/v1/payments//history"},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			op, ok := doc.Operation(v.Method, v.Path)
+			if v.Expected == "" {
+				assert.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, v.Expected, op.OperationID)
+		})
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	doc, err := Load()
+	require.NoError(t, err)
+	tData := map[string]struct {
+		Method   string
+		Path     string
+		Query    string
+		Body     string
+		Expected []Violation
+	}{
+		"valid body": {
+			Method: "POST",
+			Path:   "/v1/payments",
+			Body:   `{"UserID":5,"Email":"ann@mail.ru","Sum":"12.00","Currency":"USD"}`,
+		},
+		"amount in minor units": {
+			Method: "POST",
+			Path:   "/v1/payments",
+			Body:   `{"Email":"ann@mail.ru","Sum":1250,"Currency":"USD"}`,
+		},
+		"fractional amount": {
+			Method:   "POST",
+			Path:     "/v1/payments",
+			Body:     `{"Email":"ann@mail.ru","Sum":12.5,"Currency":"USD"}`,
+			Expected: []Violation{{Field: "Sum", Message: "must be a string or integer"}},
+		},
+		"legacy amount as number": {
+			Method: "POST",
+			Path:   "/payments/new",
+			Body:   `{"Email":"ann@mail.ru","Sum":12.5,"Currency":"USD"}`,
+		},
+		"names are case insensitive": {
+			Method: "POST",
+			Path:   "/v1/payments",
+			Body:   `{"email":"ann@mail.ru","sum":"12.00","currency":"USD"}`,
+		},
+		"unknown fields are ignored": {
+			Method: "POST",
+			Path:   "/v1/payments",
+			Body:   `{"Email":"ann@mail.ru","Sum":"12.00","Currency":"USD","Comment":"gift"}`,
+		},
+		"missing fields": {
+			Method:   "POST",
+			Path:     "/v1/payments",
+			Body:     `{"Sum":"12.00"}`,
+			Expected: []Violation{{Field: "Email", Message: "is required"}, {Field: "Currency", Message: "is required"}},
+		},
+		"wrong types": {
+			Method:   "POST",
+			Path:     "/v1/payments",
+			Body:     `{"UserID":"5","Email":"ann@mail.ru","Sum":"12.00","Currency":"USD"}`,
+			Expected: []Violation{{Field: "UserID", Message: "must be an integer"}},
+		},
+		"null": {
+			Method:   "POST",
+			Path:     "/v1/payments",
+			Body:     `{"Email":null,"Sum":"12.00","Currency":"USD"}`,
+			Expected: []Violation{{Field: "Email", Message: "must not be null"}},
+		},
+		"not an object": {
+			Method:   "POST",
+			Path:     "/v1/payments",
+			Body:     `[]`,
+			Expected: []Violation{{Field: "body", Message: "must be an object"}},
+		},
+		"missing body": {
+			Method:   "POST",
+			Path:     "/v1/payments",
+			Expected: []Violation{{Field: "body", Message: "is required"}},
+		},
+		"optional body": {
+			Method: "POST",
+			Path:   "/v1/payments/1/cancel",
+		},
+		"not JSON": {
+			Method: "POST",
+			Path:   "/v1/payments",
+			Body:   `{"Email":`,
+		},
+		"valid query": {
+			Method: "GET",
+			Path:   "/v1/payments",
+			Query:  "limit=10&sort=-amount&created_from=2022-06-01T00:00:00Z",
+		},
+		"invalid query": {
+			Method: "GET",
+			Path:   "/v1/payments",
+			Query:  "limit=0&sort=newest&created_from=yesterday&user_id=x",
+			Expected: []Violation{
+				{Field: "created_from", Message: "must be an RFC 3339 time such as 2006-01-02T15:04:05Z"},
+				{Field: "user_id", Message: "must be an integer"},
+				{Field: "sort", Message: "must be one of created_at, -created_at, amount, -amount"},
+				{Field: "limit", Message: "must be at least 1"},
+			},
+		},
+		"limit too high": {
+			Method:   "GET",
+			Path:     "/v1/payments",
+			Query:    "limit=501",
+			Expected: []Violation{{Field: "limit", Message: "must be at most 500"}},
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			op, ok := doc.Operation(v.Method, v.Path)
+			require.True(t, ok)
+			query, err := url.ParseQuery(v.Query)
+			require.NoError(t, err)
+			violations, err := op.ValidateRequest(query, []byte(v.Body))
+			assert.NoError(t, err)
+			assert.Equal(t, v.Expected, violations)
+		})
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	doc, err := Load()
+	require.NoError(t, err)
+	payment := `{"ID":1,"UserID":5,"Email":"ann@mail.ru","Sum":"12.00","CreationDate":"2022-06-11T18:45:47+06:00","ChangeDate":"2022-06-11T18:45:47+06:00","Status":"NEW","Currency":"USD"}`
+	tData := map[string]struct {
+		Method string
+		Path   string
+		Code   int
+		Body   string
+		Err    string
+	}{
+		"payment": {
+			Method: "GET",
+			Path:   "/v1/payments/1",
+			Code:   200,
+			Body:   payment,
+		},
+		"error envelope": {
+			Method: "GET",
+			Path:   "/v1/payments/1",
+			Code:   404,
+			Body:   `{"error":{"code":"not_found","message":"payment not found","request_id":"req_test"}}`,
+		},
+		"no content": {
+			Method: "DELETE",
+			Path:   "/v1/webhooks/endpoints/1",
+			Code:   204,
+		},
+		"undocumented field": {
+			Method: "GET",
+			Path:   "/v1/payments/1",
+			Code:   200,
+			Body:   `{"ID":1,"UserID":5,"Email":"ann@mail.ru","Sum":"12.00","CreationDate":"2022-06-11T18:45:47+06:00","ChangeDate":"2022-06-11T18:45:47+06:00","Status":"NEW","Currency":"USD","Secret":"x"}`,
+			Err:    "getPayment: status 200 body does not match the document: Secret: is not allowed",
+		},
+		"unknown status": {
+			Method: "GET",
+			Path:   "/v1/payments/1",
+			Code:   200,
+			Body:   `{"ID":1,"UserID":5,"Email":"ann@mail.ru","Sum":"12.00","CreationDate":"2022-06-11T18:45:47+06:00","ChangeDate":"2022-06-11T18:45:47+06:00","Status":"DONE","Currency":"USD"}`,
+			Err:    "getPayment: status 200 body does not match the document: Status: must be one of NEW, PROCESSING, SUCCESS, FAIL, ERROR, CANCELLED, AUTHORIZED, CAPTURED, VOIDED, PARTIALLY_REFUNDED, REFUNDED",
+		},
+		"undocumented code": {
+			Method: "GET",
+			Path:   "/v1/payments/1",
+			Code:   418,
+			Body:   payment,
+			Err:    "getPayment: status 418 is not documented",
+		},
+		"unexpected body": {
+			Method: "DELETE",
+			Path:   "/v1/webhooks/endpoints/1",
+			Code:   204,
+			Body:   `{}`,
+			Err:    "deleteWebhookEndpoint: status 204 has no documented body",
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			op, ok := doc.Operation(v.Method, v.Path)
+			require.True(t, ok)
+			err := op.ValidateResponse(v.Code, []byte(v.Body))
+			if v.Err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, v.Err)
+		})
+	}
+}
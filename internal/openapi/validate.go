@@ -0,0 +1,341 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Violation is a part of a request that does not match the document. Field
+// is the name of a query parameter or the path of a body field such as
+// "Events[0]", the body itself is "body".
+type Violation struct {
+	Field   string
+	Message string
+}
+
+// ValidateRequest checks the query parameters and the JSON body of a request
+// to o. Bodies that are not JSON at all are left to the handlers, the error
+// is only returned for a broken document.
+func (o *Operation) ValidateRequest(query url.Values, body []byte) ([]Violation, error) {
+	v := &validator{doc: o.doc, request: true}
+	for _, p := range o.Parameters {
+		if p.In != "query" {
+			continue
+		}
+		value := query.Get(p.Name)
+		if value == "" {
+			if p.Required {
+				v.add(p.Name, "is required")
+			}
+			continue
+		}
+		v.param(p.Name, value, p.Schema)
+	}
+	if o.RequestBody != nil {
+		media, ok := o.RequestBody.Content["application/json"]
+		switch {
+		case !ok:
+		case len(bytes.TrimSpace(body)) == 0:
+			if o.RequestBody.Required {
+				v.add("body", "is required")
+			}
+		case json.Valid(body):
+			v.value("", decode(body), media.Schema)
+		}
+	}
+	return v.violations, v.err
+}
+
+// ValidateResponse checks a response of o against the schema documented for
+// its status code.
+func (o *Operation) ValidateResponse(code int, body []byte) error {
+	resp, ok := o.Responses[strconv.Itoa(code)]
+	if !ok {
+		resp, ok = o.Responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("%s: status %d is not documented", o.OperationID, code)
+	}
+	resp, err := o.doc.response(resp)
+	if err != nil {
+		return err
+	}
+	media, ok := resp.Content["application/json"]
+	if !ok {
+		if len(body) != 0 {
+			return fmt.Errorf("%s: status %d has no documented body", o.OperationID, code)
+		}
+		return nil
+	}
+	if !json.Valid(body) {
+		return fmt.Errorf("%s: status %d body is not JSON", o.OperationID, code)
+	}
+	v := &validator{doc: o.doc}
+	v.value("", decode(body), media.Schema)
+	if v.err != nil {
+		return v.err
+	}
+	if len(v.violations) != 0 {
+		msgs := make([]string, 0, len(v.violations))
+		for _, violation := range v.violations {
+			msgs = append(msgs, violation.Field+": "+violation.Message)
+		}
+		return fmt.Errorf("%s: status %d body does not match the document: %s", o.OperationID, code, strings.Join(msgs, ", "))
+	}
+	return nil
+}
+
+// decode keeps numbers as json.Number so integers can be told from floats.
+func decode(data []byte) interface{} {
+	var value interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	dec.Decode(&value)
+	return value
+}
+
+type validator struct {
+	doc *Document
+	// request matches property names case-insensitively like encoding/json
+	request    bool
+	violations []Violation
+	err        error
+}
+
+func (v *validator) add(field, message string) {
+	if field == "" {
+		field = "body"
+	}
+	v.violations = append(v.violations, Violation{Field: field, Message: message})
+}
+
+func (v *validator) param(name, value string, s *Schema) {
+	s, err := v.doc.resolve(s)
+	if err != nil {
+		v.err = err
+		return
+	}
+	if s == nil {
+		return
+	}
+	switch s.Type {
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			v.add(name, "must be an integer")
+			return
+		}
+		v.number(name, float64(n), s)
+	case "number":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			v.add(name, "must be a number")
+			return
+		}
+		v.number(name, n, s)
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			v.add(name, "must be true or false")
+		}
+	default:
+		v.string(name, value, s)
+	}
+}
+
+func (v *validator) value(field string, value interface{}, s *Schema) {
+	s, err := v.doc.resolve(s)
+	if err != nil {
+		v.err = err
+		return
+	}
+	if s == nil {
+		return
+	}
+	if value == nil {
+		if !s.Nullable && (s.Type != "" || len(s.AnyOf) != 0) {
+			v.add(field, "must not be null")
+		}
+		return
+	}
+	if len(s.AnyOf) != 0 {
+		v.anyOf(field, value, s.AnyOf)
+		return
+	}
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			v.add(field, "must be an object")
+			return
+		}
+		v.object(field, obj, s)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			v.add(field, "must be an array")
+			return
+		}
+		for i, item := range items {
+			v.value(fmt.Sprintf("%s[%d]", field, i), item, s.Items)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			v.add(field, "must be a string")
+			return
+		}
+		v.string(field, str, s)
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			v.add(field, "must be an integer")
+			return
+		}
+		i, err := n.Int64()
+		if err != nil {
+			v.add(field, "must be an integer")
+			return
+		}
+		v.number(field, float64(i), s)
+	case "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			v.add(field, "must be a number")
+			return
+		}
+		f, _ := n.Float64()
+		v.number(field, f, s)
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.add(field, "must be true or false")
+		}
+	}
+}
+
+func (v *validator) anyOf(field string, value interface{}, options []*Schema) {
+	types := []string{}
+	for _, option := range options {
+		sub := &validator{doc: v.doc, request: v.request}
+		sub.value(field, value, option)
+		if sub.err != nil {
+			v.err = sub.err
+			return
+		}
+		if len(sub.violations) == 0 {
+			return
+		}
+		if option, err := v.doc.resolve(option); err == nil && option.Type != "" {
+			types = append(types, option.Type)
+		}
+	}
+	v.add(field, "must be a "+strings.Join(types, " or "))
+}
+
+func (v *validator) object(field string, obj map[string]interface{}, s *Schema) {
+	for _, name := range s.Required {
+		if _, ok := v.property(obj, name); !ok {
+			v.add(join(field, name), "is required")
+		}
+	}
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name, prop := v.propertySchema(s, key)
+		if prop == nil {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				v.add(join(field, key), "is not allowed")
+			}
+			continue
+		}
+		v.value(join(field, name), obj[key], prop)
+	}
+}
+
+// property looks name up in obj the way encoding/json would fill it.
+func (v *validator) property(obj map[string]interface{}, name string) (interface{}, bool) {
+	if value, ok := obj[name]; ok {
+		return value, true
+	}
+	if v.request {
+		for key, value := range obj {
+			if strings.EqualFold(key, name) {
+				return value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (v *validator) propertySchema(s *Schema, key string) (string, *Schema) {
+	if prop, ok := s.Properties[key]; ok {
+		return key, prop
+	}
+	if v.request {
+		for name, prop := range s.Properties {
+			if strings.EqualFold(name, key) {
+				return name, prop
+			}
+		}
+	}
+	return "", nil
+}
+
+func (v *validator) string(field, value string, s *Schema) {
+	if len(s.Enum) != 0 {
+		allowed := make([]string, 0, len(s.Enum))
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == value {
+				return
+			}
+			allowed = append(allowed, fmt.Sprint(e))
+		}
+		v.add(field, "must be one of "+strings.Join(allowed, ", "))
+		return
+	}
+	if s.Format == "date-time" {
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			v.add(field, "must be an RFC 3339 time such as 2006-01-02T15:04:05Z")
+			return
+		}
+	}
+	if s.MaxLength != nil && len(value) > *s.MaxLength {
+		v.add(field, fmt.Sprintf("must be at most %d characters long", *s.MaxLength))
+		return
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			v.err = fmt.Errorf("invalid pattern of %s %w", field, err)
+			return
+		}
+		if !re.MatchString(value) {
+			v.add(field, "must match "+s.Pattern)
+		}
+	}
+}
+
+func (v *validator) number(field string, n float64, s *Schema) {
+	switch {
+	case s.Minimum != nil && n < *s.Minimum:
+		v.add(field, "must be at least "+strconv.FormatFloat(*s.Minimum, 'f', -1, 64))
+	case s.Maximum != nil && n > *s.Maximum:
+		v.add(field, "must be at most "+strconv.FormatFloat(*s.Maximum, 'f', -1, 64))
+	}
+}
+
+func join(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
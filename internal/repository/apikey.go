@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+var ErrAPIKeyNotFound = fmt.Errorf("api key %w", ErrNotFound)
+
+const apiKeyColumns = "ID,MerchantID,Name,SecretPrefix,PublishablePrefix,CreatedAt,RevokedAt"
+
+type APIKeyRepo struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepo(db *sql.DB) *APIKeyRepo {
+	return &APIKeyRepo{
+		db: db,
+	}
+}
+
+func (a *APIKeyRepo) CreateAPIKey(key models.APIKey, secretHash, publishableHash string) (int, error) {
+	return insert(a.db, "INSERT INTO APIKeys(MerchantID,Name,SecretHash,PublishableHash,SecretPrefix,PublishablePrefix,CreatedAt)VALUES(?,?,?,?,?,?,?)",
+		key.MerchantID, key.Name, secretHash, publishableHash, key.SecretPrefix, key.PublishablePrefix, key.CreatedAt)
+}
+
+// APIKeys lists the pairs of the merchant, all pairs when merchantID is zero.
+func (a *APIKeyRepo) APIKeys(merchantID int) ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	row, err := a.db.Query("SELECT "+apiKeyColumns+" FROM APIKeys WHERE ? IN (0, MerchantID) ORDER BY ID", merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		key, err := scanAPIKey(row)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, row.Err()
+}
+
+func (a *APIKeyRepo) APIKey(id int) (models.APIKey, error) {
+	row := a.db.QueryRow("SELECT "+apiKeyColumns+" FROM APIKeys WHERE ID = ?", id)
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return key, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// APIKeyByHash finds the pair one of whose keys has the given hash and
+// reports which of the two it is.
+func (a *APIKeyRepo) APIKeyByHash(hash string) (models.APIKey, string, error) {
+	var secretHash string
+	row := a.db.QueryRow("SELECT "+apiKeyColumns+",SecretHash FROM APIKeys WHERE SecretHash = ? OR PublishableHash = ?", hash, hash)
+	key, err := scanAPIKey(row, &secretHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return key, "", ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return key, "", err
+	}
+	if secretHash == hash {
+		return key, models.KeySecret, nil
+	}
+	return key, models.KeyPublishable, nil
+}
+
+func (a *APIKeyRepo) RevokeAPIKey(id int, at time.Time) error {
+	res, err := a.db.Exec("UPDATE APIKeys SET RevokedAt = COALESCE(RevokedAt, ?) WHERE ID = ?", at, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func scanAPIKey(row scanner, extra ...interface{}) (models.APIKey, error) {
+	key := models.APIKey{}
+	var revoked sql.NullTime
+	dest := append([]interface{}{&key.ID, &key.MerchantID, &key.Name, &key.SecretPrefix, &key.PublishablePrefix, &key.CreatedAt, &revoked}, extra...)
+	err := row.Scan(dest...)
+	if revoked.Valid {
+		key.RevokedAt = &revoked.Time
+	}
+	return key, err
+}
@@ -0,0 +1,662 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backends returns a constructor of empty repositories for every backend,
+// postgres is tested only when POSTGRES_TEST_DSN points at a scratch database.
+func backends() map[string]func(t *testing.T) *Repositories {
+	return map[string]func(t *testing.T) *Repositories{
+		BackendMemory: func(t *testing.T) *Repositories {
+			return NewMemoryRepository()
+		},
+		BackendSQLite: func(t *testing.T) *Repositories {
+			repos, closeDB, err := Open(BackendSQLite, filepath.Join(t.TempDir(), "test.db"), true)
+			require.NoError(t, err)
+			t.Cleanup(func() { closeDB() })
+			return repos
+		},
+		BackendPostgres: func(t *testing.T) *Repositories {
+			dsn := os.Getenv("POSTGRES_TEST_DSN")
+			if dsn == "" {
+				t.Skip("POSTGRES_TEST_DSN is not set")
+			}
+			db, err := NewPostgresDB(dsn)
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+			migrator, err := NewMigrator(db, BackendPostgres)
+			require.NoError(t, err)
+			_, err = migrator.Up(0)
+			require.NoError(t, err)
+			_, err = db.Exec(`TRUNCATE Merchants,Users,APIKeys,Transactions,PaymentEvents,WebhookEndpoints,WebhookEvents,
+				WebhookDeliveries,Refunds,ProcessingJobs,IdempotencyKeys RESTART IDENTITY CASCADE`)
+			require.NoError(t, err)
+			return NewRepository(db)
+		},
+	}
+}
+
+// TestConformance runs the same behaviour checks against every backend.
+func TestConformance(t *testing.T) {
+	suite := map[string]func(t *testing.T, repos *Repositories){
+		"users":                  testUsers,
+		"payments":               testPayments,
+		"payment status":         testPaymentStatus,
+		"payment lookups":        testPaymentLookups,
+		"list payments":          testListPayments,
+		"cancel and purge":       testCancelAndPurge,
+		"expired authorizations": testExpiredAuthorizations,
+		"refunds":                testRefunds,
+		"jobs":                   testJobs,
+		"jobs stored with work":  testStoredJobs,
+		"idempotency":            testIdempotency,
+		"webhooks":               testWebhooks,
+	}
+	for backend, open := range backends() {
+		open := open
+		t.Run(backend, func(t *testing.T) {
+			for name, test := range suite {
+				test := test
+				t.Run(name, func(t *testing.T) {
+					test(t, open(t))
+				})
+			}
+		})
+	}
+}
+
+func newUser(t *testing.T, repos *Repositories, merchantID int, email string) models.User {
+	user := models.User{MerchantID: merchantID, Email: email, Name: "Ann", CreatedAt: time.Now()}
+	id, err := repos.CreateUser(user)
+	require.NoError(t, err)
+	user.ID = id
+	return user
+}
+
+func newPayment(t *testing.T, repos *Repositories, user models.User, amount string) models.Transaction {
+	sum, err := money.Parse(amount, "USD")
+	require.NoError(t, err)
+	payment := models.Transaction{
+		UserID:        user.ID,
+		UserEmail:     user.Email,
+		Sum:           sum,
+		Status:        models.StatusNew,
+		CaptureMethod: models.CaptureAutomatic,
+		MerchantID:    user.MerchantID,
+	}
+	id, err := repos.NewPayment(payment, nil)
+	require.NoError(t, err)
+	payment.ID = id
+	return payment
+}
+
+func testUsers(t *testing.T, repos *Repositories) {
+	ann := newUser(t, repos, 1, "ann@example.com")
+	assert.NotZero(t, ann.ID)
+
+	got, err := repos.GetUser(1, ann.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "ann@example.com", got.Email)
+	assert.Equal(t, "Ann", got.Name)
+	assert.Equal(t, 1, got.MerchantID)
+	assert.WithinDuration(t, ann.CreatedAt, got.CreatedAt, time.Millisecond)
+
+	_, err = repos.GetUser(2, ann.ID)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	_, err = repos.CreateUser(models.User{MerchantID: 1, Email: "ann@example.com", CreatedAt: time.Now()})
+	assert.ErrorIs(t, err, ErrEmailTaken)
+	other := newUser(t, repos, 2, "ann@example.com")
+	assert.NotEqual(t, ann.ID, other.ID)
+
+	bob := newUser(t, repos, 1, "bob@example.com")
+	bob.Email = "ann@example.com"
+	assert.ErrorIs(t, repos.UpdateUser(bob), ErrEmailTaken)
+	bob.Email, bob.Name, bob.UpdatedAt = "robert@example.com", "Robert", time.Now()
+	assert.NoError(t, repos.UpdateUser(bob))
+	got, err = repos.GetUser(1, bob.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "robert@example.com", got.Email)
+	assert.Equal(t, "Robert", got.Name)
+
+	bob.MerchantID = 2
+	assert.ErrorIs(t, repos.UpdateUser(bob), ErrUserNotFound)
+	assert.ErrorIs(t, repos.UpdateUser(models.User{ID: 1000, MerchantID: 1, Email: "nobody@example.com"}), ErrUserNotFound)
+}
+
+func testPayments(t *testing.T, repos *Repositories) {
+	user := newUser(t, repos, 1, "ann@example.com")
+	payment := newPayment(t, repos, user, "10.50")
+	second := newPayment(t, repos, user, "1.00")
+	assert.Greater(t, second.ID, payment.ID)
+
+	got, err := repos.GetPayment(1, payment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, payment.ID, got.ID)
+	assert.Equal(t, user.ID, got.UserID)
+	assert.Equal(t, "ann@example.com", got.UserEmail)
+	assert.Equal(t, int64(1050), got.Sum.Amount)
+	assert.Equal(t, "USD", got.Sum.Currency)
+	assert.Equal(t, "USD", got.Captured.Currency)
+	assert.Zero(t, got.Captured.Amount)
+	assert.Zero(t, got.Refunded.Amount)
+	assert.Equal(t, models.StatusNew, got.Status)
+	assert.Equal(t, models.CaptureAutomatic, got.CaptureMethod)
+	assert.Equal(t, 1, got.MerchantID)
+	assert.Zero(t, got.APIKeyID)
+	assert.Nil(t, got.CancelledAt)
+	assert.Nil(t, got.AuthorizationExpires)
+	assert.WithinDuration(t, time.Now(), got.CreationDate, time.Minute)
+
+	_, err = repos.GetPayment(2, payment.ID)
+	assert.EqualError(t, err, "payment not found")
+	_, err = repos.GetPayment(1, 1000)
+	assert.EqualError(t, err, "payment not found")
+
+	history, err := repos.History(1, payment.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, "", history[0].FromStatus)
+		assert.Equal(t, models.StatusNew, history[0].ToStatus)
+		assert.Equal(t, "payment created", history[0].Reason)
+	}
+	history, err = repos.History(2, payment.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+
+	email, err := repos.UserVerification(1, payment.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "ann@example.com", email)
+	_, err = repos.UserVerification(2, payment.ID)
+	assert.True(t, errors.Is(err, ErrPaymentNotFound))
+}
+
+func testPaymentStatus(t *testing.T, repos *Repositories) {
+	user := newUser(t, repos, 1, "ann@example.com")
+	payment := newPayment(t, repos, user, "10.00")
+
+	status, err := repos.PaymentStatus(1, payment.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusNew, status)
+	_, err = repos.PaymentStatus(2, payment.ID)
+	assert.Error(t, err)
+
+	processing := models.StatusChange{From: models.StatusNew, To: models.StatusProcessing, Reason: "processing started"}
+	assert.ErrorIs(t, repos.SetStatus(2, payment.ID, processing), ErrStatusChanged)
+	assert.NoError(t, repos.SetStatus(1, payment.ID, processing))
+	assert.ErrorIs(t, repos.SetStatus(1, payment.ID, processing), ErrStatusChanged)
+
+	captured := int64(700)
+	expires := time.Now().Add(time.Hour)
+	assert.NoError(t, repos.SetStatus(1, payment.ID, models.StatusChange{
+		From:                 models.StatusProcessing,
+		To:                   models.StatusAuthorized,
+		Reason:               "authorized",
+		Captured:             &captured,
+		AuthorizationExpires: &expires,
+	}))
+	assert.NoError(t, repos.SetStatus(1, payment.ID, models.StatusChange{
+		From:          models.StatusAuthorized,
+		To:            models.StatusVoided,
+		Reason:        "voided",
+		DeclineReason: models.DeclineDoNotHonor,
+	}))
+
+	got, err := repos.GetPayment(1, payment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusVoided, got.Status)
+	assert.Equal(t, models.DeclineDoNotHonor, got.DeclineReason)
+	assert.Equal(t, int64(700), got.Captured.Amount)
+	if assert.NotNil(t, got.AuthorizationExpires) {
+		assert.WithinDuration(t, expires, *got.AuthorizationExpires, time.Millisecond)
+	}
+	assert.False(t, got.ChangeDate.Before(got.CreationDate))
+
+	history, err := repos.History(1, payment.ID)
+	assert.NoError(t, err)
+	statuses := []string{}
+	for _, event := range history {
+		statuses = append(statuses, event.FromStatus+">"+event.ToStatus+":"+event.Reason)
+	}
+	assert.Equal(t, []string{
+		">NEW:payment created",
+		"NEW>PROCESSING:processing started",
+		"PROCESSING>AUTHORIZED:authorized",
+		"AUTHORIZED>VOIDED:voided",
+	}, statuses)
+}
+
+func testPaymentLookups(t *testing.T, repos *Repositories) {
+	ann := newUser(t, repos, 1, "ann@example.com")
+	bob := newUser(t, repos, 1, "bob@example.com")
+	foreign := newUser(t, repos, 2, "ann@example.com")
+	first := newPayment(t, repos, ann, "1.00")
+	newPayment(t, repos, bob, "2.00")
+	second := newPayment(t, repos, ann, "3.00")
+	newPayment(t, repos, foreign, "4.00")
+
+	payments, err := repos.GetAllPaymentsByUserID(1, ann.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{first.ID, second.ID}, paymentIDs(payments))
+	payments, err = repos.GetAllPaymentsByEmail(1, "ann@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{first.ID, second.ID}, paymentIDs(payments))
+
+	_, err = repos.GetAllPaymentsByUserID(2, ann.ID)
+	assert.EqualError(t, err, "not found")
+	_, err = repos.GetAllPaymentsByEmail(1, "nobody@example.com")
+	assert.EqualError(t, err, "not found")
+}
+
+func paymentIDs(payments []models.Transaction) []int {
+	ids := []int{}
+	for _, p := range payments {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func testListPayments(t *testing.T, repos *Repositories) {
+	ann := newUser(t, repos, 1, "ann@example.com")
+	bob := newUser(t, repos, 1, "bob@example.com")
+	foreign := newUser(t, repos, 2, "ann@example.com")
+	a := newPayment(t, repos, ann, "3.00")
+	b := newPayment(t, repos, bob, "1.00")
+	c := newPayment(t, repos, ann, "3.00")
+	d := newPayment(t, repos, bob, "2.00")
+	newPayment(t, repos, foreign, "5.00")
+	require.NoError(t, repos.SetStatus(1, b.ID, models.StatusChange{From: models.StatusNew, To: models.StatusCancelled}))
+
+	list := func(filter models.PaymentFilter) []int {
+		if filter.Limit == 0 {
+			filter.Limit = 10
+		}
+		payments, err := repos.ListPayments(1, filter)
+		require.NoError(t, err)
+		return paymentIDs(payments)
+	}
+	assert.Equal(t, []int{a.ID, b.ID, c.ID, d.ID}, list(models.PaymentFilter{}))
+	assert.Equal(t, []int{d.ID, c.ID, b.ID, a.ID}, list(models.PaymentFilter{Sort: models.SortCreatedDesc}))
+	assert.Equal(t, []int{b.ID, d.ID, a.ID, c.ID}, list(models.PaymentFilter{Sort: models.SortAmount}))
+	assert.Equal(t, []int{c.ID, a.ID, d.ID, b.ID}, list(models.PaymentFilter{Sort: models.SortAmountDesc}))
+	assert.Equal(t, []int{a.ID, b.ID}, list(models.PaymentFilter{Limit: 2}))
+	assert.Empty(t, list(models.PaymentFilter{Currency: "EUR"}))
+
+	stored, err := repos.GetPayment(1, b.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []int{c.ID, d.ID}, list(models.PaymentFilter{After: &models.PaymentCursor{ID: b.ID, Created: stored.CreationDate}}))
+	assert.Equal(t, []int{a.ID}, list(models.PaymentFilter{Sort: models.SortCreatedDesc, After: &models.PaymentCursor{ID: b.ID, Created: stored.CreationDate}}))
+	// pages follow the creation time, the ID only breaks ties
+	before := stored.CreationDate.Add(-time.Nanosecond)
+	assert.Equal(t, []int{b.ID, c.ID, d.ID}, list(models.PaymentFilter{After: &models.PaymentCursor{ID: d.ID, Created: before}}))
+	assert.Equal(t, []int{a.ID}, list(models.PaymentFilter{Sort: models.SortCreatedDesc, After: &models.PaymentCursor{ID: a.ID, Created: before}}))
+	assert.Equal(t, []int{c.ID}, list(models.PaymentFilter{Sort: models.SortAmount, After: &models.PaymentCursor{ID: a.ID, Amount: a.Sum.Amount}}))
+	assert.Equal(t, []int{a.ID, d.ID, b.ID}, list(models.PaymentFilter{Sort: models.SortAmountDesc, After: &models.PaymentCursor{ID: c.ID, Amount: c.Sum.Amount}}))
+
+	assert.Equal(t, []int{b.ID}, list(models.PaymentFilter{Statuses: []string{models.StatusCancelled}}))
+	assert.Equal(t, []int{a.ID, b.ID, c.ID, d.ID}, list(models.PaymentFilter{Statuses: []string{models.StatusNew, models.StatusCancelled}, Currency: "USD"}))
+	min, max := int64(150), int64(300)
+	assert.Equal(t, []int{a.ID, c.ID, d.ID}, list(models.PaymentFilter{MinAmount: &min, MaxAmount: &max}))
+	assert.Equal(t, []int{a.ID, c.ID}, list(models.PaymentFilter{UserID: ann.ID}))
+	assert.Equal(t, []int{b.ID, d.ID}, list(models.PaymentFilter{Email: "bob@example.com"}))
+
+	stored, err = repos.GetPayment(1, c.ID)
+	require.NoError(t, err)
+	created := stored.CreationDate.UTC()
+	later := created.Add(time.Hour)
+	assert.Equal(t, []int{c.ID, d.ID}, list(models.PaymentFilter{CreatedFrom: &created, CreatedTo: &later}))
+	assert.Equal(t, []int{a.ID, b.ID}, list(models.PaymentFilter{CreatedTo: &created}))
+	elsewhere := created.In(time.FixedZone("HST", -10*60*60))
+	assert.Equal(t, []int{a.ID, b.ID}, list(models.PaymentFilter{CreatedTo: &elsewhere}))
+	assert.Equal(t, []int{c.ID, d.ID}, list(models.PaymentFilter{CreatedFrom: &elsewhere}))
+	changed := created.Add(-time.Hour)
+	assert.Equal(t, []int{a.ID, b.ID, c.ID, d.ID}, list(models.PaymentFilter{ChangedFrom: &changed}))
+	assert.Empty(t, list(models.PaymentFilter{ChangedTo: &changed}))
+}
+
+func testCancelAndPurge(t *testing.T, repos *Repositories) {
+	user := newUser(t, repos, 1, "ann@example.com")
+	cancelled := newPayment(t, repos, user, "1.00")
+	kept := newPayment(t, repos, user, "2.00")
+
+	assert.NoError(t, repos.SetStatus(1, cancelled.ID, models.StatusChange{
+		From:   models.StatusNew,
+		To:     models.StatusCancelled,
+		Reason: "customer request",
+		Actor:  "support",
+	}))
+	got, err := repos.GetPayment(1, cancelled.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCancelled, got.Status)
+	assert.Equal(t, "customer request", got.CancellationReason)
+	assert.Equal(t, "support", got.CancelledBy)
+	if assert.NotNil(t, got.CancelledAt) {
+		assert.WithinDuration(t, time.Now(), *got.CancelledAt, time.Minute)
+	}
+
+	n, err := repos.PurgeCancelled(time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+	n, err = repos.PurgeCancelled(time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, err = repos.GetPayment(1, cancelled.ID)
+	assert.Error(t, err)
+	history, err := repos.History(1, cancelled.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+	_, err = repos.GetPayment(1, kept.ID)
+	assert.NoError(t, err)
+}
+
+func testExpiredAuthorizations(t *testing.T, repos *Repositories) {
+	user := newUser(t, repos, 1, "ann@example.com")
+	other := newUser(t, repos, 2, "bob@example.com")
+	now := time.Now()
+	authorize := func(payment models.Transaction, expires time.Time) {
+		assert.NoError(t, repos.SetStatus(payment.MerchantID, payment.ID, models.StatusChange{From: models.StatusNew, To: models.StatusProcessing}))
+		assert.NoError(t, repos.SetStatus(payment.MerchantID, payment.ID, models.StatusChange{From: models.StatusProcessing, To: models.StatusAuthorized, AuthorizationExpires: &expires}))
+	}
+	late := newPayment(t, repos, user, "1.00")
+	authorize(late, now.Add(-time.Minute))
+	early := newPayment(t, repos, other, "2.00")
+	authorize(early, now.Add(-time.Hour))
+	pending := newPayment(t, repos, user, "3.00")
+	authorize(pending, now.Add(time.Hour))
+	newPayment(t, repos, user, "4.00")
+
+	payments, err := repos.ExpiredAuthorizations(now, 10)
+	assert.NoError(t, err)
+	got := []string{}
+	for _, p := range payments {
+		got = append(got, strings.Join([]string{p.Sum.String(), p.Status}, " "))
+	}
+	assert.Equal(t, []string{"2.00 AUTHORIZED", "1.00 AUTHORIZED"}, got)
+
+	payments, err = repos.ExpiredAuthorizations(now, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, payments, 1) {
+		assert.Equal(t, early.ID, payments[0].ID)
+		assert.Equal(t, 2, payments[0].MerchantID)
+	}
+}
+
+func testJobs(t *testing.T, repos *Repositories) {
+	now := time.Now()
+	enqueue := func(paymentID int, runAt time.Time) int {
+		id, err := repos.Enqueue(models.Job{Kind: models.JobPayment, MerchantID: 1, PaymentID: paymentID, RunAt: runAt, CreatedAt: now})
+		require.NoError(t, err)
+		return id
+	}
+	later := enqueue(3, now.Add(-time.Minute))
+	first := enqueue(1, now.Add(-time.Hour))
+	scheduled := enqueue(2, now.Add(time.Hour))
+
+	jobs, err := repos.ClaimDue(now, 1)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, first, jobs[0].ID)
+	assert.Equal(t, models.JobPayment, jobs[0].Kind)
+	assert.Equal(t, 1, jobs[0].PaymentID)
+	assert.Equal(t, models.JobRunning, jobs[0].Status)
+	assert.Equal(t, 1, jobs[0].Attempts)
+
+	jobs, err = repos.ClaimDue(now, 10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, later, jobs[0].ID)
+	jobs, err = repos.ClaimDue(now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+
+	done := models.Job{ID: first, Status: models.JobDone, UpdatedAt: now}
+	require.NoError(t, repos.FinishJob(done))
+	require.NoError(t, repos.ResetRunning(now))
+	jobs, err = repos.ClaimDue(now, 10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, later, jobs[0].ID)
+	assert.Equal(t, 2, jobs[0].Attempts)
+
+	retry := jobs[0]
+	retry.Status, retry.RunAt, retry.LastError = models.JobPending, now.Add(3*time.Hour), "database is locked"
+	require.NoError(t, repos.FinishJob(retry))
+	jobs, err = repos.ClaimDue(now.Add(2*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, scheduled, jobs[0].ID)
+	require.NoError(t, repos.FinishJob(models.Job{ID: scheduled, Status: models.JobDone, UpdatedAt: now}))
+	jobs, err = repos.ClaimDue(now.Add(3*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, later, jobs[0].ID)
+	assert.Equal(t, 3, jobs[0].Attempts)
+	assert.Equal(t, "database is locked", jobs[0].LastError)
+
+	failed := models.Job{ID: later, Status: models.JobFailed, LastError: "payment not found", UpdatedAt: now}
+	require.NoError(t, repos.FinishJob(failed))
+	require.NoError(t, repos.ResetRunning(now))
+	jobs, err = repos.ClaimDue(now.Add(4*time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+func testStoredJobs(t *testing.T, repos *Repositories) {
+	now := time.Now()
+	user := newUser(t, repos, 1, "ann@example.com")
+	paymentID, err := repos.NewPayment(models.Transaction{UserID: user.ID, UserEmail: user.Email, Sum: money.New(1000, "USD"), Status: models.StatusNew, MerchantID: 1},
+		&models.Job{Kind: models.JobPayment, MerchantID: 1, RunAt: now, CreatedAt: now})
+	require.NoError(t, err)
+	refund := models.Refund{PaymentID: paymentID, MerchantID: 1, Amount: money.New(600, "USD"), CreatedAt: now}
+	refundID, err := repos.CreateRefund(refund, 1000, &models.Job{Kind: models.JobRefund, MerchantID: 1, RunAt: now, CreatedAt: now})
+	require.NoError(t, err)
+	// a refund over the limit stores no job either
+	_, err = repos.CreateRefund(refund, 1000, &models.Job{Kind: models.JobRefund, MerchantID: 1, RunAt: now, CreatedAt: now})
+	assert.True(t, errors.Is(err, ErrRefundExceeded))
+
+	jobs, err := repos.ClaimDue(now, 10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, models.JobPayment, jobs[0].Kind)
+	assert.Equal(t, paymentID, jobs[0].PaymentID)
+	assert.Equal(t, models.JobRefund, jobs[1].Kind)
+	assert.Equal(t, refundID, jobs[1].RefundID)
+}
+
+func testRefunds(t *testing.T, repos *Repositories) {
+	user := newUser(t, repos, 1, "ann@example.com")
+	payment := newPayment(t, repos, user, "10.00")
+	captured := payment.Sum.Amount
+	require.NoError(t, repos.SetStatus(1, payment.ID, models.StatusChange{From: models.StatusNew, To: models.StatusProcessing}))
+	require.NoError(t, repos.SetStatus(1, payment.ID, models.StatusChange{From: models.StatusProcessing, To: models.StatusSuccess, Captured: &captured}))
+	newRefund := func(amount int64) int {
+		id, err := repos.CreateRefund(models.Refund{PaymentID: payment.ID, MerchantID: 1, Amount: money.New(amount, "USD"), Status: models.RefundPending, CreatedAt: time.Now()}, captured, nil)
+		require.NoError(t, err)
+		return id
+	}
+	first := newRefund(300)
+	second := newRefund(500)
+	_, err := repos.CreateRefund(models.Refund{PaymentID: payment.ID, MerchantID: 1, Amount: money.New(300, "USD"), CreatedAt: time.Now()}, captured, nil)
+	assert.True(t, errors.Is(err, ErrRefundExceeded))
+
+	reserved, err := repos.Reserved(1, payment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(800), reserved)
+	refund, err := repos.Refund.Refund(1, first)
+	require.NoError(t, err)
+	assert.Equal(t, models.RefundPending, refund.Status)
+	assert.Equal(t, "3.00", refund.Amount.String())
+	assert.Equal(t, "USD", refund.Amount.Currency)
+	_, err = repos.Refund.Refund(2, first)
+	assert.True(t, errors.Is(err, ErrRefundNotFound))
+	refunds, err := repos.Refunds(2, payment.ID)
+	require.NoError(t, err)
+	assert.Empty(t, refunds)
+
+	partially := int64(300)
+	change := models.StatusChange{From: models.StatusSuccess, To: models.StatusPartiallyRefunded, Reason: "refunded 3.00 USD", Refunded: &partially}
+	// a stale status or refunded amount means another refund got there first
+	stale := change
+	stale.From = models.StatusPartiallyRefunded
+	assert.True(t, errors.Is(repos.SucceedRefund(1, first, payment.ID, 0, stale), ErrStatusChanged))
+	assert.True(t, errors.Is(repos.SucceedRefund(1, first, payment.ID, 100, change), ErrStatusChanged))
+	assert.True(t, errors.Is(repos.SucceedRefund(2, first, payment.ID, 0, change), ErrStatusChanged))
+	require.NoError(t, repos.SucceedRefund(1, first, payment.ID, 0, change))
+	assert.True(t, errors.Is(repos.SucceedRefund(1, first, payment.ID, 300, models.StatusChange{From: models.StatusPartiallyRefunded, To: models.StatusPartiallyRefunded, Refunded: &partially}), ErrRefundNotPending))
+
+	stored, err := repos.GetPayment(1, payment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusPartiallyRefunded, stored.Status)
+	assert.Equal(t, int64(300), stored.Refunded.Amount)
+	history, err := repos.History(1, payment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusPartiallyRefunded, history[len(history)-1].ToStatus)
+
+	require.NoError(t, repos.FailRefund(1, second, models.DeclineProcessingError))
+	assert.True(t, errors.Is(repos.FailRefund(1, second, models.DeclineProcessingError), ErrRefundNotPending))
+	refunds, err = repos.Refunds(1, payment.ID)
+	require.NoError(t, err)
+	if assert.Len(t, refunds, 2) {
+		assert.Equal(t, models.RefundSucceeded, refunds[0].Status)
+		assert.Equal(t, models.RefundFailed, refunds[1].Status)
+		assert.Equal(t, models.DeclineProcessingError, refunds[1].DeclineReason)
+	}
+	// failed refunds no longer hold back the refundable amount
+	reserved, err = repos.Reserved(1, payment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(300), reserved)
+}
+
+func testIdempotency(t *testing.T, repos *Repositories) {
+	now := time.Now()
+	record := models.IdempotencyRecord{Key: "1:key", Fingerprint: "POST /v1/payments abc", CreatedAt: now}
+	stored, ok, err := repos.Reserve(record, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Zero(t, stored.ResponseCode)
+
+	// the key is taken while the first request is still running
+	other := record
+	other.Fingerprint = "POST /v1/payments def"
+	stored, ok, err = repos.Reserve(other, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, record.Fingerprint, stored.Fingerprint)
+	assert.Zero(t, stored.ResponseCode)
+
+	require.NoError(t, repos.Complete(record.Key, 201, []byte(`{"ID":1}`)))
+	stored, ok, err = repos.Reserve(other, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 201, stored.ResponseCode)
+	assert.Equal(t, `{"ID":1}`, string(stored.ResponseBody))
+
+	// an expired record is replaced
+	stored, ok, err = repos.Reserve(other, now.Add(time.Second))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, other.Fingerprint, stored.Fingerprint)
+	assert.Zero(t, stored.ResponseCode)
+
+	require.NoError(t, repos.Release(record.Key))
+	_, ok, err = repos.Reserve(record, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func testWebhooks(t *testing.T, repos *Repositories) {
+	now := time.Now()
+	newEndpoint := func(merchantID int, events ...string) models.WebhookEndpoint {
+		endpoint := models.WebhookEndpoint{MerchantID: merchantID, URL: "https://example.com/hook", Secret: "whsec_1", Events: events, CreatedAt: now}
+		id, err := repos.CreateEndpoint(endpoint)
+		require.NoError(t, err)
+		endpoint.ID = id
+		return endpoint
+	}
+	succeeded := newEndpoint(1, models.EventPaymentSucceeded)
+	failed := newEndpoint(1, models.EventPaymentFailed)
+	all := newEndpoint(1)
+	foreign := newEndpoint(2, models.EventPaymentSucceeded)
+
+	endpoints, err := repos.Endpoints(1)
+	require.NoError(t, err)
+	if assert.Len(t, endpoints, 3) {
+		assert.Equal(t, []string{models.EventPaymentSucceeded}, endpoints[0].Events)
+		assert.Equal(t, failed.ID, endpoints[1].ID)
+	}
+	_, err = repos.Endpoint(2, succeeded.ID)
+	assert.True(t, errors.Is(err, ErrEndpointNotFound))
+	assert.True(t, errors.Is(repos.RotateSecret(2, succeeded.ID, "whsec_2", now), ErrEndpointNotFound))
+	require.NoError(t, repos.RotateSecret(1, succeeded.ID, "whsec_2", now.Add(time.Hour)))
+	endpoint, err := repos.Endpoint(1, succeeded.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "whsec_2", endpoint.Secret)
+	assert.Equal(t, "whsec_1", endpoint.PreviousSecret)
+	if assert.NotNil(t, endpoint.PreviousSecretExpires) {
+		assert.WithinDuration(t, now.Add(time.Hour), *endpoint.PreviousSecretExpires, time.Millisecond)
+	}
+
+	eventID, err := repos.CreateEvent(models.WebhookEvent{Type: models.EventPaymentSucceeded, Created: now, Data: models.Transaction{ID: 7, MerchantID: 1, Sum: money.New(100, "USD")}})
+	require.NoError(t, err)
+	due, err := repos.DueDeliveries(now, 10)
+	require.NoError(t, err)
+	endpointIDs := []int{}
+	for _, delivery := range due {
+		endpointIDs = append(endpointIDs, delivery.EndpointID)
+		assert.Equal(t, eventID, delivery.EventID)
+		assert.Equal(t, models.EventPaymentSucceeded, delivery.EventType)
+		assert.Equal(t, models.DeliveryPending, delivery.Status)
+		assert.Contains(t, string(delivery.Payload), `"Type":"payment.succeeded"`)
+		assert.Equal(t, "https://example.com/hook", delivery.Endpoint.URL)
+	}
+	assert.ElementsMatch(t, []int{succeeded.ID, all.ID}, endpointIDs)
+	deliveries, err := repos.Deliveries(2, foreign.ID)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+
+	delivery := due[0]
+	delivery.Status, delivery.Attempts, delivery.ResponseCode, delivery.LastError = models.DeliveryFailed, 3, 500, "endpoint responded with status 500"
+	delivery.NextAttempt, delivery.UpdatedAt = now.Add(time.Minute), now
+	require.NoError(t, repos.UpdateDelivery(delivery))
+	stored, err := repos.Delivery(1, delivery.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeliveryFailed, stored.Status)
+	assert.Equal(t, 3, stored.Attempts)
+	assert.Equal(t, 500, stored.ResponseCode)
+	assert.Equal(t, delivery.LastError, stored.LastError)
+	_, err = repos.Delivery(2, delivery.ID)
+	assert.True(t, errors.Is(err, ErrDeliveryNotFound))
+
+	_, err = repos.Redeliver(2, delivery.ID)
+	assert.True(t, errors.Is(err, ErrDeliveryNotFound))
+	replay, err := repos.Redeliver(1, delivery.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, delivery.ID, replay)
+	deliveries, err = repos.Deliveries(1, delivery.EndpointID)
+	require.NoError(t, err)
+	if assert.Len(t, deliveries, 2) {
+		assert.Equal(t, models.DeliveryFailed, deliveries[0].Status)
+		assert.Equal(t, replay, deliveries[1].ID)
+		assert.Equal(t, models.DeliveryPending, deliveries[1].Status)
+		assert.Equal(t, eventID, deliveries[1].EventID)
+	}
+
+	assert.True(t, errors.Is(repos.DeleteEndpoint(2, delivery.EndpointID), ErrEndpointNotFound))
+	require.NoError(t, repos.DeleteEndpoint(1, delivery.EndpointID))
+	_, err = repos.Delivery(1, delivery.ID)
+	assert.True(t, errors.Is(err, ErrDeliveryNotFound))
+	endpoints, err = repos.Endpoints(1)
+	require.NoError(t, err)
+	assert.Len(t, endpoints, 2)
+}
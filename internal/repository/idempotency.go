@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+type IdempotencyRepo struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepo(db *sql.DB) *IdempotencyRepo {
+	return &IdempotencyRepo{
+		db: db,
+	}
+}
+
+// Reserve stores the record unless the key is already taken by a record
+// created after expired. It reports whether the reservation was made and
+// otherwise returns the existing record.
+func (i *IdempotencyRepo) Reserve(record models.IdempotencyRecord, expired time.Time) (models.IdempotencyRecord, bool, error) {
+	tx, err := i.db.Begin()
+	if err != nil {
+		return record, false, err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec("DELETE FROM IdempotencyKeys WHERE Key = ? AND CreatedAt < ?", record.Key, expired)
+	if err != nil {
+		return record, false, err
+	}
+	res, err := tx.Exec("INSERT INTO IdempotencyKeys(Key,Fingerprint,CreatedAt)VALUES(?,?,?) ON CONFLICT(Key) DO NOTHING", record.Key, record.Fingerprint, record.CreatedAt)
+	if err != nil {
+		return record, false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return record, false, err
+	}
+	if n == 1 {
+		return record, true, tx.Commit()
+	}
+	existing := models.IdempotencyRecord{}
+	err = tx.QueryRow("SELECT Key,Fingerprint,ResponseCode,ResponseBody,CreatedAt FROM IdempotencyKeys WHERE Key = ?", record.Key).
+		Scan(&existing.Key, &existing.Fingerprint, &existing.ResponseCode, &existing.ResponseBody, &existing.CreatedAt)
+	if err != nil {
+		return record, false, err
+	}
+	return existing, false, tx.Commit()
+}
+
+func (i *IdempotencyRepo) Complete(key string, code int, body []byte) error {
+	_, err := i.db.Exec("UPDATE IdempotencyKeys SET ResponseCode = ?,ResponseBody = ? WHERE Key = ?", code, body, key)
+	return err
+}
+
+func (i *IdempotencyRepo) Release(key string) error {
+	_, err := i.db.Exec("DELETE FROM IdempotencyKeys WHERE Key = ?", key)
+	return err
+}
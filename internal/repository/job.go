@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+type JobRepo struct {
+	db *sql.DB
+}
+
+func NewJobRepo(db *sql.DB) *JobRepo {
+	return &JobRepo{
+		db: db,
+	}
+}
+
+func (j *JobRepo) Enqueue(job models.Job) (int, error) {
+	return insertJob(j.db, job)
+}
+
+// insertJob stores a pending job, payments and refunds store theirs in the
+// transaction that creates them so none is left without one.
+func insertJob(db queryRower, job models.Job) (int, error) {
+	return insert(db, "INSERT INTO ProcessingJobs(Kind,MerchantID,PaymentID,RefundID,Status,RunAt,CreatedAt,UpdatedAt)VALUES(?,?,?,?,?,?,?,?)", job.Kind, job.MerchantID, job.PaymentID, job.RefundID, models.JobPending, job.RunAt, job.CreatedAt, job.CreatedAt)
+}
+
+// ClaimDue marks up to limit due jobs as running and returns them.
+func (j *JobRepo) ClaimDue(now time.Time, limit int) ([]models.Job, error) {
+	tx, err := j.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	row, err := tx.Query("SELECT ID,Kind,MerchantID,PaymentID,RefundID,Status,RunAt,Attempts,LastError,CreatedAt,UpdatedAt FROM ProcessingJobs WHERE Status = ? AND RunAt <= ? ORDER BY RunAt, ID LIMIT ?", models.JobPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	jobs := []models.Job{}
+	for row.Next() {
+		job := models.Job{}
+		err := row.Scan(&job.ID, &job.Kind, &job.MerchantID, &job.PaymentID, &job.RefundID, &job.Status, &job.RunAt, &job.Attempts, &job.LastError, &job.CreatedAt, &job.UpdatedAt)
+		if err != nil {
+			row.Close()
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	row.Close()
+	if err := row.Err(); err != nil {
+		return nil, err
+	}
+	claimed := jobs[:0]
+	for _, job := range jobs {
+		job.Status = models.JobRunning
+		job.Attempts++
+		job.UpdatedAt = now
+		// another process may have claimed the job since it was selected
+		res, err := tx.Exec("UPDATE ProcessingJobs SET Status = ?,Attempts = ?,UpdatedAt = ? WHERE ID = ? AND Status = ?", job.Status, job.Attempts, now, job.ID, models.JobPending)
+		if err != nil {
+			return nil, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if n == 1 {
+			claimed = append(claimed, job)
+		}
+	}
+	return claimed, tx.Commit()
+}
+
+// FinishJob stores the outcome of a claimed job, a job put back to pending
+// runs again at RunAt.
+func (j *JobRepo) FinishJob(job models.Job) error {
+	_, err := j.db.Exec("UPDATE ProcessingJobs SET Status = ?,RunAt = ?,LastError = ?,UpdatedAt = ? WHERE ID = ?", job.Status, job.RunAt, job.LastError, job.UpdatedAt, job.ID)
+	return err
+}
+
+// ResetRunning returns jobs left running by a previous process to the queue.
+func (j *JobRepo) ResetRunning(now time.Time) error {
+	_, err := j.db.Exec("UPDATE ProcessingJobs SET Status = ?,UpdatedAt = ? WHERE Status = ?", models.JobPending, now, models.JobRunning)
+	return err
+}
@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+// MemoryRepo keeps all data in maps guarded by a single mutex, it implements
+// every repository interface and loses the data when the process exits.
+type MemoryRepo struct {
+	mu            sync.Mutex
+	lastID        map[string]int
+	users         map[int]models.User
+	merchants     map[int]models.Merchant
+	apiKeys       map[int]memoryAPIKey
+	payments      map[int]models.Transaction
+	events        []models.PaymentEvent
+	refunds       map[int]models.Refund
+	endpoints     map[int]models.WebhookEndpoint
+	webhookEvents map[int]memoryWebhookEvent
+	deliveries    map[int]models.WebhookDelivery
+	jobs          map[int]models.Job
+	idempotency   map[string]models.IdempotencyRecord
+}
+
+type memoryAPIKey struct {
+	key             models.APIKey
+	secretHash      string
+	publishableHash string
+}
+
+type memoryWebhookEvent struct {
+	eventType string
+	payload   json.RawMessage
+}
+
+func NewMemoryRepo() *MemoryRepo {
+	return &MemoryRepo{
+		lastID:        map[string]int{},
+		users:         map[int]models.User{},
+		merchants:     map[int]models.Merchant{},
+		apiKeys:       map[int]memoryAPIKey{},
+		payments:      map[int]models.Transaction{},
+		refunds:       map[int]models.Refund{},
+		endpoints:     map[int]models.WebhookEndpoint{},
+		webhookEvents: map[int]memoryWebhookEvent{},
+		deliveries:    map[int]models.WebhookDelivery{},
+		jobs:          map[int]models.Job{},
+		idempotency:   map[string]models.IdempotencyRecord{},
+	}
+}
+
+// NewMemoryRepository returns repositories sharing one MemoryRepo.
+func NewMemoryRepository() *Repositories {
+	m := NewMemoryRepo()
+	return &Repositories{
+		User:        m,
+		Merchant:    m,
+		APIKey:      m,
+		Payment:     m,
+		Webhook:     m,
+		Refund:      m,
+		Idempotency: m,
+		Job:         m,
+	}
+}
+
+// nextID works like AUTOINCREMENT, IDs of deleted rows are never reused.
+func (m *MemoryRepo) nextID(table string) int {
+	m.lastID[table]++
+	return m.lastID[table]
+}
+
+func (m *MemoryRepo) UserVerification(merchantID, paymentID int) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	payment, ok := m.payments[paymentID]
+	if !ok || payment.MerchantID != merchantID {
+		return "", ErrPaymentNotFound
+	}
+	return payment.UserEmail, nil
+}
+
+func (m *MemoryRepo) CreateUser(user models.User) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := m.emailFree(user.MerchantID, user.Email, 0)
+	if err != nil {
+		return 0, err
+	}
+	user.ID = m.nextID("Users")
+	user.UpdatedAt = user.CreatedAt
+	m.users[user.ID] = user
+	return user.ID, nil
+}
+
+func (m *MemoryRepo) GetUser(merchantID, id int) (models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[id]
+	if !ok || user.MerchantID != merchantID {
+		return models.User{}, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (m *MemoryRepo) UpdateUser(user models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := m.emailFree(user.MerchantID, user.Email, user.ID)
+	if err != nil {
+		return err
+	}
+	stored, ok := m.users[user.ID]
+	if !ok || stored.MerchantID != user.MerchantID {
+		return ErrUserNotFound
+	}
+	stored.Email, stored.Name, stored.UpdatedAt = user.Email, user.Name, user.UpdatedAt
+	m.users[user.ID] = stored
+	return nil
+}
+
+func (m *MemoryRepo) emailFree(merchantID int, email string, id int) error {
+	for _, user := range m.users {
+		if user.MerchantID == merchantID && user.Email == email && user.ID != id {
+			return ErrEmailTaken
+		}
+	}
+	return nil
+}
+
+func (m *MemoryRepo) CreateMerchant(merchant models.Merchant) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	merchant.ID = m.nextID("Merchants")
+	merchant.Currencies = append([]string{}, merchant.Currencies...)
+	m.merchants[merchant.ID] = merchant
+	return merchant.ID, nil
+}
+
+func (m *MemoryRepo) Merchants() ([]models.Merchant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	merchants := []models.Merchant{}
+	for _, merchant := range m.merchants {
+		merchants = append(merchants, merchant)
+	}
+	sort.Slice(merchants, func(i, j int) bool { return merchants[i].ID < merchants[j].ID })
+	return merchants, nil
+}
+
+func (m *MemoryRepo) Merchant(id int) (models.Merchant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	merchant, ok := m.merchants[id]
+	if !ok {
+		return models.Merchant{}, ErrMerchantNotFound
+	}
+	return merchant, nil
+}
+
+func (m *MemoryRepo) UpdateMerchant(merchant models.Merchant) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.merchants[merchant.ID]
+	if !ok {
+		return ErrMerchantNotFound
+	}
+	merchant.CreatedAt = stored.CreatedAt
+	merchant.Currencies = append([]string{}, merchant.Currencies...)
+	m.merchants[merchant.ID] = merchant
+	return nil
+}
+
+func (m *MemoryRepo) CreateAPIKey(key models.APIKey, secretHash, publishableHash string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key.ID = m.nextID("APIKeys")
+	key.SecretKey, key.PublishableKey, key.RevokedAt = "", "", nil
+	m.apiKeys[key.ID] = memoryAPIKey{key: key, secretHash: secretHash, publishableHash: publishableHash}
+	return key.ID, nil
+}
+
+// APIKeys lists the pairs of the merchant, all pairs when merchantID is zero.
+func (m *MemoryRepo) APIKeys(merchantID int) ([]models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := []models.APIKey{}
+	for _, stored := range m.apiKeys {
+		if merchantID == 0 || stored.key.MerchantID == merchantID {
+			keys = append(keys, stored.key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (m *MemoryRepo) APIKey(id int) (models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.apiKeys[id]
+	if !ok {
+		return models.APIKey{}, ErrAPIKeyNotFound
+	}
+	return stored.key, nil
+}
+
+func (m *MemoryRepo) APIKeyByHash(hash string) (models.APIKey, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, stored := range m.apiKeys {
+		switch hash {
+		case stored.secretHash:
+			return stored.key, models.KeySecret, nil
+		case stored.publishableHash:
+			return stored.key, models.KeyPublishable, nil
+		}
+	}
+	return models.APIKey{}, "", ErrAPIKeyNotFound
+}
+
+func (m *MemoryRepo) RevokeAPIKey(id int, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.apiKeys[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	if stored.key.RevokedAt == nil {
+		stored.key.RevokedAt = &at
+		m.apiKeys[id] = stored
+	}
+	return nil
+}
+
+// Reserve stores the record unless the key is already taken by a record
+// created after expired.
+func (m *MemoryRepo) Reserve(record models.IdempotencyRecord, expired time.Time) (models.IdempotencyRecord, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.idempotency[record.Key]
+	if ok && !existing.CreatedAt.Before(expired) {
+		return existing, false, nil
+	}
+	record.ResponseCode, record.ResponseBody = 0, nil
+	m.idempotency[record.Key] = record
+	return record, true, nil
+}
+
+func (m *MemoryRepo) Complete(key string, code int, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.idempotency[key]
+	if ok {
+		record.ResponseCode, record.ResponseBody = code, append([]byte{}, body...)
+		m.idempotency[key] = record
+	}
+	return nil
+}
+
+func (m *MemoryRepo) Release(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.idempotency, key)
+	return nil
+}
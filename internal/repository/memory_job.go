@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+func (m *MemoryRepo) Enqueue(job models.Job) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.enqueue(job), nil
+}
+
+func (m *MemoryRepo) enqueue(job models.Job) int {
+	job.ID = m.nextID("ProcessingJobs")
+	job.Status, job.Attempts, job.LastError, job.UpdatedAt = models.JobPending, 0, "", job.CreatedAt
+	m.jobs[job.ID] = job
+	return job.ID
+}
+
+// ClaimDue marks up to limit due jobs as running and returns them.
+func (m *MemoryRepo) ClaimDue(now time.Time, limit int) ([]models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := []models.Job{}
+	for _, job := range m.jobs {
+		if job.Status == models.JobPending && !job.RunAt.After(now) {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].RunAt.Equal(jobs[j].RunAt) {
+			return jobs[i].ID < jobs[j].ID
+		}
+		return jobs[i].RunAt.Before(jobs[j].RunAt)
+	})
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	for i := range jobs {
+		jobs[i].Status = models.JobRunning
+		jobs[i].Attempts++
+		jobs[i].UpdatedAt = now
+		m.jobs[jobs[i].ID] = jobs[i]
+	}
+	return jobs, nil
+}
+
+// FinishJob stores the outcome of a claimed job, a job put back to pending
+// runs again at RunAt.
+func (m *MemoryRepo) FinishJob(job models.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.jobs[job.ID]
+	if ok {
+		stored.Status, stored.RunAt, stored.LastError, stored.UpdatedAt = job.Status, job.RunAt, job.LastError, job.UpdatedAt
+		m.jobs[job.ID] = stored
+	}
+	return nil
+}
+
+// ResetRunning returns jobs left running by a previous process to the queue.
+func (m *MemoryRepo) ResetRunning(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, job := range m.jobs {
+		if job.Status == models.JobRunning {
+			job.Status, job.UpdatedAt = models.JobPending, now
+			m.jobs[id] = job
+		}
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+func (m *MemoryRepo) NewPayment(payment models.Transaction, job *models.Job) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	date := time.Now()
+	stored := models.Transaction{
+		ID:            m.nextID("Transactions"),
+		UserID:        payment.UserID,
+		UserEmail:     payment.UserEmail,
+		Sum:           payment.Sum,
+		CreationDate:  date,
+		ChangeDate:    date,
+		Status:        payment.Status,
+		CaptureMethod: payment.CaptureMethod,
+		APIKeyID:      payment.APIKeyID,
+		MerchantID:    payment.MerchantID,
+	}
+	stored.Captured.Currency = stored.Sum.Currency
+	stored.Refunded.Currency = stored.Sum.Currency
+	m.payments[stored.ID] = stored
+	m.addEvent(stored.ID, "", stored.Status, "payment created", date)
+	if job != nil {
+		job.PaymentID = stored.ID
+		m.enqueue(*job)
+	}
+	return stored.ID, nil
+}
+
+func (m *MemoryRepo) PaymentStatus(merchantID, paymentId int) (string, error) {
+	payment, err := m.GetPayment(merchantID, paymentId)
+	if err != nil {
+		return "", err
+	}
+	return payment.Status, nil
+}
+
+func (m *MemoryRepo) GetPayment(merchantID, paymentId int) (models.Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	payment, ok := m.payments[paymentId]
+	if !ok || payment.MerchantID != merchantID {
+		return models.Transaction{}, ErrPaymentNotFound
+	}
+	return payment, nil
+}
+
+func (m *MemoryRepo) GetAllPaymentsByUserID(merchantID, userId int) ([]models.Transaction, error) {
+	return m.findPayments(func(p models.Transaction) bool {
+		return p.MerchantID == merchantID && p.UserID == userId
+	})
+}
+
+func (m *MemoryRepo) GetAllPaymentsByEmail(merchantID int, email string) ([]models.Transaction, error) {
+	return m.findPayments(func(p models.Transaction) bool {
+		return p.MerchantID == merchantID && p.UserEmail == email
+	})
+}
+
+func (m *MemoryRepo) ListPayments(merchantID int, filter models.PaymentFilter) ([]models.Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	payments := []models.Transaction{}
+	for _, payment := range m.payments {
+		if payment.MerchantID == merchantID && matchesFilter(payment, filter) {
+			payments = append(payments, payment)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool { return paymentBefore(payments[i], payments[j], filter.Sort) })
+	if len(payments) > filter.Limit {
+		payments = payments[:filter.Limit]
+	}
+	return payments, nil
+}
+
+func matchesFilter(p models.Transaction, filter models.PaymentFilter) bool {
+	if len(filter.Statuses) != 0 {
+		found := false
+		for _, status := range filter.Statuses {
+			found = found || p.Status == status
+		}
+		if !found {
+			return false
+		}
+	}
+	switch {
+	case filter.Currency != "" && p.Sum.Currency != filter.Currency,
+		filter.MinAmount != nil && p.Sum.Amount < *filter.MinAmount,
+		filter.MaxAmount != nil && p.Sum.Amount > *filter.MaxAmount,
+		filter.CreatedFrom != nil && p.CreationDate.Before(*filter.CreatedFrom),
+		filter.CreatedTo != nil && !p.CreationDate.Before(*filter.CreatedTo),
+		filter.ChangedFrom != nil && p.ChangeDate.Before(*filter.ChangedFrom),
+		filter.ChangedTo != nil && !p.ChangeDate.Before(*filter.ChangedTo),
+		filter.UserID != 0 && p.UserID != filter.UserID,
+		filter.Email != "" && p.UserEmail != filter.Email:
+		return false
+	}
+	if after := filter.After; after != nil {
+		last := models.Transaction{ID: after.ID, CreationDate: after.Created}
+		last.Sum.Amount = after.Amount
+		return paymentBefore(last, p, filter.Sort)
+	}
+	return true
+}
+
+// paymentBefore reports whether a comes before b in the sort order.
+func paymentBefore(a, b models.Transaction, order string) bool {
+	switch order {
+	case models.SortCreatedDesc:
+		return a.CreationDate.After(b.CreationDate) || (a.CreationDate.Equal(b.CreationDate) && a.ID > b.ID)
+	case models.SortAmount:
+		return a.Sum.Amount < b.Sum.Amount || (a.Sum.Amount == b.Sum.Amount && a.ID < b.ID)
+	case models.SortAmountDesc:
+		return a.Sum.Amount > b.Sum.Amount || (a.Sum.Amount == b.Sum.Amount && a.ID > b.ID)
+	}
+	return a.CreationDate.Before(b.CreationDate) || (a.CreationDate.Equal(b.CreationDate) && a.ID < b.ID)
+}
+
+func (m *MemoryRepo) findPayments(match func(models.Transaction) bool) ([]models.Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	payments := []models.Transaction{}
+	for _, payment := range m.payments {
+		if match(payment) {
+			payments = append(payments, payment)
+		}
+	}
+	if len(payments) == 0 {
+		return nil, ErrNotFound
+	}
+	sort.Slice(payments, func(i, j int) bool { return payments[i].ID < payments[j].ID })
+	return payments, nil
+}
+
+// PurgeCancelled deletes payments cancelled before the given time together
+// with their history.
+func (m *MemoryRepo) PurgeCancelled(before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	purged := map[int]bool{}
+	for id, payment := range m.payments {
+		if payment.Status == models.StatusCancelled && payment.CancelledAt != nil && payment.CancelledAt.Before(before) {
+			purged[id] = true
+			delete(m.payments, id)
+		}
+	}
+	events := m.events[:0]
+	for _, event := range m.events {
+		if !purged[event.PaymentID] {
+			events = append(events, event)
+		}
+	}
+	m.events = events
+	return len(purged), nil
+}
+
+func (m *MemoryRepo) SetStatus(merchantID, paymentId int, change models.StatusChange) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	payment, ok := m.payments[paymentId]
+	if !ok || payment.MerchantID != merchantID || payment.Status != change.From {
+		return ErrStatusChanged
+	}
+	date := time.Now()
+	payment.Status, payment.DeclineReason, payment.ChangeDate = change.To, change.DeclineReason, date
+	if change.Captured != nil {
+		payment.Captured.Amount = *change.Captured
+	}
+	if change.AuthorizationExpires != nil {
+		expires := *change.AuthorizationExpires
+		payment.AuthorizationExpires = &expires
+	}
+	if change.To == models.StatusCancelled {
+		payment.CancelledAt = &date
+		payment.CancellationReason, payment.CancelledBy = change.Reason, change.Actor
+	}
+	m.payments[paymentId] = payment
+	m.addEvent(paymentId, change.From, change.To, change.Reason, date)
+	return nil
+}
+
+// ExpiredAuthorizations returns authorizations of every merchant that are
+// past their expiry.
+func (m *MemoryRepo) ExpiredAuthorizations(now time.Time, limit int) ([]models.Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	payments := []models.Transaction{}
+	for _, payment := range m.payments {
+		if payment.Status == models.StatusAuthorized && payment.AuthorizationExpires != nil && !payment.AuthorizationExpires.After(now) {
+			payments = append(payments, payment)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		return payments[i].AuthorizationExpires.Before(*payments[j].AuthorizationExpires)
+	})
+	if len(payments) > limit {
+		payments = payments[:limit]
+	}
+	return payments, nil
+}
+
+func (m *MemoryRepo) History(merchantID, paymentId int) ([]models.PaymentEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	events := []models.PaymentEvent{}
+	if payment, ok := m.payments[paymentId]; !ok || payment.MerchantID != merchantID {
+		return events, nil
+	}
+	for _, event := range m.events {
+		if event.PaymentID == paymentId {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (m *MemoryRepo) addEvent(paymentId int, from, to, reason string, date time.Time) {
+	m.events = append(m.events, models.PaymentEvent{
+		ID:         m.nextID("PaymentEvents"),
+		PaymentID:  paymentId,
+		FromStatus: from,
+		ToStatus:   to,
+		Reason:     reason,
+		CreatedAt:  date,
+	})
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+// CreateRefund stores a pending refund unless pending and succeeded refunds
+// of the payment would exceed limit.
+func (m *MemoryRepo) CreateRefund(refund models.Refund, limit int64, job *models.Job) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.reserved(0, refund.PaymentID)+refund.Amount.Amount > limit {
+		return 0, ErrRefundExceeded
+	}
+	refund.ID = m.nextID("Refunds")
+	refund.Status, refund.DeclineReason, refund.UpdatedAt = models.RefundPending, "", refund.CreatedAt
+	m.refunds[refund.ID] = refund
+	if job != nil {
+		job.RefundID = refund.ID
+		m.enqueue(*job)
+	}
+	return refund.ID, nil
+}
+
+// Reserved is the total of pending and succeeded refunds of the payment.
+func (m *MemoryRepo) Reserved(merchantID, paymentId int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reserved(merchantID, paymentId), nil
+}
+
+// reserved sums the refunds of any merchant when merchantID is zero.
+func (m *MemoryRepo) reserved(merchantID, paymentId int) int64 {
+	var reserved int64
+	for _, refund := range m.refunds {
+		if refund.PaymentID != paymentId || (merchantID != 0 && refund.MerchantID != merchantID) {
+			continue
+		}
+		if refund.Status == models.RefundPending || refund.Status == models.RefundSucceeded {
+			reserved += refund.Amount.Amount
+		}
+	}
+	return reserved
+}
+
+func (m *MemoryRepo) Refund(merchantID, id int) (models.Refund, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	refund, ok := m.refunds[id]
+	if !ok || refund.MerchantID != merchantID {
+		return models.Refund{}, ErrRefundNotFound
+	}
+	payment, ok := m.payments[refund.PaymentID]
+	if !ok {
+		return models.Refund{}, ErrRefundNotFound
+	}
+	refund.Amount.Currency = payment.Sum.Currency
+	return refund, nil
+}
+
+func (m *MemoryRepo) Refunds(merchantID, paymentId int) ([]models.Refund, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	refunds := []models.Refund{}
+	payment, ok := m.payments[paymentId]
+	if !ok {
+		return refunds, nil
+	}
+	for _, refund := range m.refunds {
+		if refund.PaymentID == paymentId && refund.MerchantID == merchantID {
+			refund.Amount.Currency = payment.Sum.Currency
+			refunds = append(refunds, refund)
+		}
+	}
+	sort.Slice(refunds, func(i, j int) bool { return refunds[i].ID < refunds[j].ID })
+	return refunds, nil
+}
+
+// SucceedRefund completes a pending refund and applies change to its payment.
+// The payment must still have status change.From and refundedBefore
+// refunded, otherwise ErrStatusChanged is returned.
+func (m *MemoryRepo) SucceedRefund(merchantID, refundId int, paymentId int, refundedBefore int64, change models.StatusChange) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	payment, ok := m.payments[paymentId]
+	if !ok || payment.MerchantID != merchantID || payment.Status != change.From || payment.Refunded.Amount != refundedBefore {
+		return ErrStatusChanged
+	}
+	date := time.Now()
+	err := m.finishRefund(merchantID, refundId, models.RefundSucceeded, "", date)
+	if err != nil {
+		return err
+	}
+	payment.Status, payment.ChangeDate = change.To, date
+	if change.Refunded != nil {
+		payment.Refunded.Amount = *change.Refunded
+	}
+	m.payments[paymentId] = payment
+	m.addEvent(paymentId, change.From, change.To, change.Reason, date)
+	return nil
+}
+
+func (m *MemoryRepo) FailRefund(merchantID, refundId int, declineReason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.finishRefund(merchantID, refundId, models.RefundFailed, declineReason, time.Now())
+}
+
+func (m *MemoryRepo) finishRefund(merchantID, refundId int, status, declineReason string, date time.Time) error {
+	refund, ok := m.refunds[refundId]
+	if !ok || refund.MerchantID != merchantID || refund.Status != models.RefundPending {
+		return ErrRefundNotPending
+	}
+	refund.Status, refund.DeclineReason, refund.UpdatedAt = status, declineReason, date
+	m.refunds[refundId] = refund
+	return nil
+}
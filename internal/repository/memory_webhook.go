@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+func (m *MemoryRepo) CreateEndpoint(endpoint models.WebhookEndpoint) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	endpoint.ID = m.nextID("WebhookEndpoints")
+	endpoint.Events = append([]string{}, endpoint.Events...)
+	endpoint.PreviousSecret, endpoint.PreviousSecretExpires = "", nil
+	m.endpoints[endpoint.ID] = endpoint
+	return endpoint.ID, nil
+}
+
+func (m *MemoryRepo) Endpoints(merchantID int) ([]models.WebhookEndpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.merchantEndpoints(merchantID), nil
+}
+
+func (m *MemoryRepo) merchantEndpoints(merchantID int) []models.WebhookEndpoint {
+	endpoints := []models.WebhookEndpoint{}
+	for _, endpoint := range m.endpoints {
+		if endpoint.MerchantID == merchantID {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].ID < endpoints[j].ID })
+	return endpoints
+}
+
+func (m *MemoryRepo) Endpoint(merchantID, id int) (models.WebhookEndpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	endpoint, ok := m.endpoints[id]
+	if !ok || endpoint.MerchantID != merchantID {
+		return models.WebhookEndpoint{}, ErrEndpointNotFound
+	}
+	return endpoint, nil
+}
+
+func (m *MemoryRepo) RotateSecret(merchantID, id int, secret string, previousExpires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	endpoint, ok := m.endpoints[id]
+	if !ok || endpoint.MerchantID != merchantID {
+		return ErrEndpointNotFound
+	}
+	endpoint.PreviousSecret, endpoint.PreviousSecretExpires, endpoint.Secret = endpoint.Secret, &previousExpires, secret
+	m.endpoints[id] = endpoint
+	return nil
+}
+
+// DeleteEndpoint removes the endpoint with its deliveries.
+func (m *MemoryRepo) DeleteEndpoint(merchantID, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	endpoint, ok := m.endpoints[id]
+	if !ok || endpoint.MerchantID != merchantID {
+		return ErrEndpointNotFound
+	}
+	delete(m.endpoints, id)
+	for deliveryID, delivery := range m.deliveries {
+		if delivery.EndpointID == id {
+			delete(m.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+// CreateEvent stores the event and queues a delivery for every endpoint of the
+// payment's merchant subscribed to it.
+func (m *MemoryRepo) CreateEvent(event models.WebhookEvent) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	event.ID = m.nextID("WebhookEvents")
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+	m.webhookEvents[event.ID] = memoryWebhookEvent{eventType: event.Type, payload: payload}
+	for _, endpoint := range m.merchantEndpoints(event.Data.MerchantID) {
+		if subscribed(strings.Join(endpoint.Events, ","), event.Type) {
+			m.insertDelivery(endpoint.ID, event.ID, event.Created)
+		}
+	}
+	return event.ID, nil
+}
+
+func (m *MemoryRepo) DueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deliveries := m.findDeliveries(func(d models.WebhookDelivery) bool {
+		return d.Status == models.DeliveryPending && !d.NextAttempt.After(now)
+	})
+	sort.SliceStable(deliveries, func(i, j int) bool { return deliveries[i].NextAttempt.Before(deliveries[j].NextAttempt) })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (m *MemoryRepo) Deliveries(merchantID, endpointID int) ([]models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.findDeliveries(func(d models.WebhookDelivery) bool {
+		return d.EndpointID == endpointID && d.Endpoint.MerchantID == merchantID
+	}), nil
+}
+
+func (m *MemoryRepo) Delivery(merchantID, id int) (models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deliveries := m.findDeliveries(func(d models.WebhookDelivery) bool {
+		return d.ID == id && d.Endpoint.MerchantID == merchantID
+	})
+	if len(deliveries) == 0 {
+		return models.WebhookDelivery{}, ErrDeliveryNotFound
+	}
+	return deliveries[0], nil
+}
+
+func (m *MemoryRepo) UpdateDelivery(delivery models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.deliveries[delivery.ID]
+	if ok {
+		stored.Status, stored.Attempts, stored.NextAttempt = delivery.Status, delivery.Attempts, delivery.NextAttempt
+		stored.ResponseCode, stored.LastError, stored.UpdatedAt = delivery.ResponseCode, delivery.LastError, delivery.UpdatedAt
+		m.deliveries[delivery.ID] = stored
+	}
+	return nil
+}
+
+// Redeliver queues a new delivery of the same event so the original attempt stays in the log.
+func (m *MemoryRepo) Redeliver(merchantID, id int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delivery, ok := m.deliveries[id]
+	if !ok || m.endpoints[delivery.EndpointID].MerchantID != merchantID {
+		return 0, ErrDeliveryNotFound
+	}
+	return m.insertDelivery(delivery.EndpointID, delivery.EventID, time.Now()), nil
+}
+
+func (m *MemoryRepo) insertDelivery(endpointID, eventID int, date time.Time) int {
+	id := m.nextID("WebhookDeliveries")
+	m.deliveries[id] = models.WebhookDelivery{
+		ID:          id,
+		EndpointID:  endpointID,
+		EventID:     eventID,
+		Status:      models.DeliveryPending,
+		NextAttempt: date,
+		CreatedAt:   date,
+		UpdatedAt:   date,
+	}
+	return id
+}
+
+// findDeliveries returns matching deliveries ordered by ID with their event
+// and endpoint filled in.
+func (m *MemoryRepo) findDeliveries(match func(models.WebhookDelivery) bool) []models.WebhookDelivery {
+	deliveries := []models.WebhookDelivery{}
+	for _, delivery := range m.deliveries {
+		event := m.webhookEvents[delivery.EventID]
+		delivery.EventType = event.eventType
+		delivery.Payload = append(json.RawMessage{}, event.payload...)
+		delivery.Endpoint = m.endpoints[delivery.EndpointID]
+		if match(delivery) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+	return deliveries
+}
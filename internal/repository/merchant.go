@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+var ErrMerchantNotFound = fmt.Errorf("merchant %w", ErrNotFound)
+
+const merchantColumns = "ID,Name,Currencies,ErrorRate,FailRate,RefundFailRate,CreatedAt,UpdatedAt"
+
+type MerchantRepo struct {
+	db *sql.DB
+}
+
+func NewMerchantRepo(db *sql.DB) *MerchantRepo {
+	return &MerchantRepo{
+		db: db,
+	}
+}
+
+func (m *MerchantRepo) CreateMerchant(merchant models.Merchant) (int, error) {
+	return insert(m.db, "INSERT INTO Merchants(Name,Currencies,ErrorRate,FailRate,RefundFailRate,CreatedAt,UpdatedAt)VALUES(?,?,?,?,?,?,?)",
+		merchant.Name, strings.Join(merchant.Currencies, ","), merchant.ErrorRate, merchant.FailRate, merchant.RefundFailRate, merchant.CreatedAt, merchant.UpdatedAt)
+}
+
+func (m *MerchantRepo) Merchants() ([]models.Merchant, error) {
+	merchants := []models.Merchant{}
+	row, err := m.db.Query("SELECT " + merchantColumns + " FROM Merchants ORDER BY ID")
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		merchant, err := scanMerchant(row)
+		if err != nil {
+			return nil, err
+		}
+		merchants = append(merchants, merchant)
+	}
+	return merchants, row.Err()
+}
+
+func (m *MerchantRepo) Merchant(id int) (models.Merchant, error) {
+	row := m.db.QueryRow("SELECT "+merchantColumns+" FROM Merchants WHERE ID = ?", id)
+	merchant, err := scanMerchant(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return merchant, ErrMerchantNotFound
+	}
+	return merchant, err
+}
+
+func (m *MerchantRepo) UpdateMerchant(merchant models.Merchant) error {
+	res, err := m.db.Exec("UPDATE Merchants SET Name = ?,Currencies = ?,ErrorRate = ?,FailRate = ?,RefundFailRate = ?,UpdatedAt = ? WHERE ID = ?",
+		merchant.Name, strings.Join(merchant.Currencies, ","), merchant.ErrorRate, merchant.FailRate, merchant.RefundFailRate, merchant.UpdatedAt, merchant.ID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrMerchantNotFound
+	}
+	return nil
+}
+
+func scanMerchant(row scanner) (models.Merchant, error) {
+	merchant := models.Merchant{}
+	var currencies string
+	var errorRate, failRate, refundFailRate sql.NullFloat64
+	err := row.Scan(&merchant.ID, &merchant.Name, &currencies, &errorRate, &failRate, &refundFailRate, &merchant.CreatedAt, &merchant.UpdatedAt)
+	merchant.Currencies = []string{}
+	if currencies != "" {
+		merchant.Currencies = strings.Split(currencies, ",")
+	}
+	merchant.ErrorRate = nullRate(errorRate)
+	merchant.FailRate = nullRate(failRate)
+	merchant.RefundFailRate = nullRate(refundFailRate)
+	return merchant, err
+}
+
+func nullRate(rate sql.NullFloat64) *float64 {
+	if !rate.Valid {
+		return nil
+	}
+	return &rate.Float64
+}
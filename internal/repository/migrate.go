@@ -0,0 +1,302 @@
+package repository
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+var ErrSchemaTooNew = errors.New("database schema is newer than this build")
+
+// Migration is a pair of migrations/<backend>/<version>_<name>.up.sql and
+// .down.sql files.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies the embedded migrations of a backend and records them in
+// schema_migrations, every migration runs in its own transaction.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	// adopt brings the tables of a database created before migrations
+	// existed to the first migration and reports whether there was one
+	adopt func(db *sql.DB, baseline Migration) (bool, error)
+}
+
+func NewMigrator(db *sql.DB, backend string) (*Migrator, error) {
+	migrations, err := loadMigrations(backend)
+	if err != nil {
+		return nil, err
+	}
+	m := &Migrator{
+		db:         db,
+		migrations: migrations,
+	}
+	if backend == BackendSQLite {
+		m.adopt = adoptSqlite
+	}
+	return m, nil
+}
+
+func loadMigrations(backend string) ([]Migration, error) {
+	dir := path.Join("migrations", backend)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for backend %q", backend)
+	}
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		base, direction := strings.TrimSuffix(name, ".up.sql"), "up"
+		if strings.HasSuffix(name, ".down.sql") {
+			base, direction = strings.TrimSuffix(name, ".down.sql"), "down"
+		} else if base == name {
+			return nil, fmt.Errorf("migration %s: expected .up.sql or .down.sql", name)
+		}
+		parts := strings.SplitN(base, "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || version <= 0 || len(parts) != 2 {
+			return nil, fmt.Errorf("migration %s: expected <version>_<name>", name)
+		}
+		data, err := fs.ReadFile(migrationFiles, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = migration
+		}
+		if migration.Name != parts[1] {
+			return nil, fmt.Errorf("migration %s: version %d is also named %s", name, version, migration.Name)
+		}
+		if direction == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+	migrations := []Migration{}
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s: both up and down files are required", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Latest is the version the embedded migrations lead to.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version is the highest applied migration, zero for an empty database.
+func (m *Migrator) Version() (int, error) {
+	err := m.createTable()
+	if err != nil {
+		return 0, err
+	}
+	var version int
+	err = m.db.QueryRow("SELECT COALESCE(MAX(version),0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// Check fails unless the database is at the latest version.
+func (m *Migrator) Check() error {
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if version > m.Latest() {
+		return fmt.Errorf("%w: version %d, known up to %d", ErrSchemaTooNew, version, m.Latest())
+	}
+	if version < m.Latest() {
+		return fmt.Errorf("database schema is at version %d, run migrate up to reach %d", version, m.Latest())
+	}
+	return nil
+}
+
+// Up applies pending migrations up to and including target, all of them when
+// target is zero, and returns the applied ones.
+func (m *Migrator) Up(target int) ([]Migration, error) {
+	version, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	if version > m.Latest() {
+		return nil, fmt.Errorf("%w: version %d, known up to %d", ErrSchemaTooNew, version, m.Latest())
+	}
+	if target == 0 {
+		target = m.Latest()
+	}
+	applied := []Migration{}
+	if version == 0 && m.adopt != nil && len(m.migrations) > 0 {
+		baseline := m.migrations[0]
+		adopted, err := m.adopt(m.db, baseline)
+		if err != nil {
+			return nil, fmt.Errorf("upgrade of existing database: %w", err)
+		}
+		if adopted {
+			// the baseline only creates what is missing, running it with the
+			// record adds its indexes and marks the database adopted at once
+			err = m.run(baseline.Up, "INSERT INTO schema_migrations(version,name,applied_at)VALUES(?,?,?)", baseline.Version, baseline.Name, time.Now())
+			if err != nil {
+				return nil, fmt.Errorf("upgrade of existing database: %w", err)
+			}
+			version = baseline.Version
+			applied = append(applied, baseline)
+		}
+	}
+	for _, migration := range m.migrations {
+		if migration.Version <= version || migration.Version > target {
+			continue
+		}
+		err := m.run(migration.Up, "INSERT INTO schema_migrations(version,name,applied_at)VALUES(?,?,?)", migration.Version, migration.Name, time.Now())
+		if err != nil {
+			return applied, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+// Down reverts the last steps applied migrations and returns them.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	version, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	if version > m.Latest() {
+		return nil, fmt.Errorf("%w: version %d, known up to %d", ErrSchemaTooNew, version, m.Latest())
+	}
+	reverted := []Migration{}
+	for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		migration := m.migrations[i]
+		if migration.Version > version {
+			continue
+		}
+		err := m.run(migration.Down, "DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+		if err != nil {
+			return reverted, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		reverted = append(reverted, migration)
+	}
+	return reverted, nil
+}
+
+// Status lists the embedded migrations with the time each was applied.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	err := m.createTable()
+	if err != nil {
+		return nil, err
+	}
+	row, err := m.db.Query("SELECT version,applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	applied := map[int]time.Time{}
+	for row.Next() {
+		var version int
+		var at time.Time
+		if err := row.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	if err := row.Err(); err != nil {
+		return nil, err
+	}
+	statuses := []MigrationStatus{}
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Migration: migration}
+		if at, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) createTable() error {
+	_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version	INTEGER PRIMARY KEY,
+		name	TEXT NOT NULL,
+		applied_at	TIMESTAMP NOT NULL
+	)`)
+	return err
+}
+
+// run executes the statements of script and the bookkeeping query in one
+// transaction.
+func (m *Migrator) run(script, record string, args ...interface{}) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range splitStatements(script) {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(record, args...)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// splitStatements splits a script on semicolons outside of string literals and
+// drops "--" comment lines, postgres can not prepare several statements at once.
+func splitStatements(script string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(script, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+	script = strings.Join(lines, "\n")
+	stmts := []string{}
+	quoted := false
+	start := 0
+	for i, r := range script {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == ';' && !quoted:
+			if stmt := strings.TrimSpace(script[start:i]); stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+			start = i + 1
+		}
+	}
+	if stmt := strings.TrimSpace(script[start:]); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSqliteMigrator(t *testing.T) (*sql.DB, *Migrator) {
+	db, err := NewSqliteDB(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	migrator, err := NewMigrator(db, BackendSQLite)
+	require.NoError(t, err)
+	return db, migrator
+}
+
+func TestMigrationsOfBackends(t *testing.T) {
+	sqlite, err := loadMigrations(BackendSQLite)
+	require.NoError(t, err)
+	postgres, err := loadMigrations(BackendPostgres)
+	require.NoError(t, err)
+	require.NotEmpty(t, sqlite)
+	assert.Equal(t, len(sqlite), len(postgres))
+	for i := range sqlite {
+		if i < len(postgres) {
+			assert.Equal(t, sqlite[i].Version, postgres[i].Version)
+			assert.Equal(t, sqlite[i].Name, postgres[i].Name)
+		}
+	}
+	_, err = loadMigrations(BackendMemory)
+	assert.Error(t, err)
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	db, migrator := newSqliteMigrator(t)
+	version, err := migrator.Version()
+	assert.NoError(t, err)
+	assert.Zero(t, version)
+	assert.Error(t, migrator.Check())
+
+	applied, err := migrator.Up(0)
+	assert.NoError(t, err)
+	assert.Len(t, applied, len(migrator.migrations))
+	assert.NoError(t, migrator.Check())
+	ok, err := tableExists(db, "Transactions")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	applied, err = migrator.Up(0)
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	statuses, err := migrator.Status()
+	assert.NoError(t, err)
+	for _, s := range statuses {
+		assert.NotNil(t, s.AppliedAt, s.Name)
+	}
+
+	reverted, err := migrator.Down(len(migrator.migrations) + 1)
+	assert.NoError(t, err)
+	assert.Len(t, reverted, len(migrator.migrations))
+	version, err = migrator.Version()
+	assert.NoError(t, err)
+	assert.Zero(t, version)
+	ok, err = tableExists(db, "Transactions")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	db, migrator := newSqliteMigrator(t)
+	_, err := migrator.Up(0)
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO schema_migrations(version,name,applied_at)VALUES(?,?,CURRENT_TIMESTAMP)", migrator.Latest()+1, "from_the_future")
+	require.NoError(t, err)
+
+	assert.True(t, errors.Is(migrator.Check(), ErrSchemaTooNew))
+	_, err = migrator.Up(0)
+	assert.True(t, errors.Is(err, ErrSchemaTooNew))
+	_, err = migrator.Down(1)
+	assert.True(t, errors.Is(err, ErrSchemaTooNew))
+}
+
+// TestAdoptLegacySqlite upgrades a database of the first release, payments
+// with a REAL Sum and no other tables.
+func TestAdoptLegacySqlite(t *testing.T) {
+	db, migrator := newSqliteMigrator(t)
+	_, err := db.Exec(`CREATE TABLE "Transactions" (
+		"ID"	INTEGER NOT NULL UNIQUE,
+		"UserID"	INTEGER,
+		"UserEmail"	TEXT,
+		"Sum"	REAL,
+		"Currency"	TEXT,
+		"CreationDate"	DATETIME NOT NULL,
+		"ChangeDate"	DATETIME NOT NULL,
+		"Status"	TEXT,
+		PRIMARY KEY("ID" AUTOINCREMENT)
+	);
+	INSERT INTO Transactions(UserID,UserEmail,Sum,Currency,CreationDate,ChangeDate,Status)
+		VALUES(7,'Ann@Example.com',502.3,'USD',CURRENT_TIMESTAMP,CURRENT_TIMESTAMP,'SUCCESS')`)
+	require.NoError(t, err)
+
+	applied, err := migrator.Up(0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, applied[0].Version)
+	assert.NoError(t, migrator.Check())
+
+	repos := NewRepository(db)
+	user, err := repos.GetUser(0, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "ann@example.com", user.Email)
+	payment, err := repos.GetPayment(0, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50230), payment.Sum.Amount)
+	assert.Equal(t, int64(50230), payment.Captured.Amount)
+}
+
+// newPreMigrationsDB creates the tables the last release before versioned
+// migrations left behind, without the indexes the baseline adds.
+func newPreMigrationsDB(t *testing.T, migrator *Migrator, db *sql.DB) {
+	for _, stmt := range splitStatements(migrator.migrations[0].Up) {
+		if strings.HasPrefix(stmt, "CREATE TABLE") {
+			_, err := db.Exec(stmt)
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestUpgradePreMigrationsDatabase(t *testing.T) {
+	db, migrator := newSqliteMigrator(t)
+	newPreMigrationsDB(t, migrator, db)
+	_, err := db.Exec(`INSERT INTO Users(ID,MerchantID,Email)VALUES(7,0,'ann@example.com');
+	INSERT INTO Transactions(UserID,UserEmail,Amount,Currency,CreationDate,ChangeDate,Status)
+		VALUES(7,'ann@example.com',100,'USD',CURRENT_TIMESTAMP,CURRENT_TIMESTAMP,'SUCCESS')`)
+	require.NoError(t, err)
+
+	applied, err := migrator.Up(0)
+	require.NoError(t, err)
+	assert.Len(t, applied, len(migrator.migrations))
+	assert.NoError(t, migrator.Check())
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	for _, s := range statuses {
+		assert.NotNil(t, s.AppliedAt, s.Name)
+	}
+	var n int
+	err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'UsersByMerchantEmail'`).Scan(&n)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	payment, err := NewRepository(db).GetPayment(0, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), payment.Sum.Amount)
+}
+
+func TestUpgradePreMigrationsDatabaseFailure(t *testing.T) {
+	db, migrator := newSqliteMigrator(t)
+	newPreMigrationsDB(t, migrator, db)
+	// the unique email index of the baseline can not be built
+	_, err := db.Exec(`INSERT INTO Users(ID,MerchantID,Email)VALUES(7,0,'ann@example.com'),(8,0,'ann@example.com')`)
+	require.NoError(t, err)
+
+	_, err = migrator.Up(0)
+	assert.Error(t, err)
+	version, err := migrator.Version()
+	assert.NoError(t, err)
+	assert.Zero(t, version)
+
+	_, err = db.Exec("UPDATE Users SET Email = 'bob@example.com' WHERE ID = 8")
+	require.NoError(t, err)
+	applied, err := migrator.Up(0)
+	assert.NoError(t, err)
+	assert.Len(t, applied, len(migrator.migrations))
+	assert.NoError(t, migrator.Check())
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- comment; with a semicolon
+CREATE TABLE a (b TEXT DEFAULT ';');
+
+INSERT INTO a VALUES('x');
+`
+	assert.Equal(t, []string{"CREATE TABLE a (b TEXT DEFAULT ';')", "INSERT INTO a VALUES('x')"}, splitStatements(script))
+}
+
+// TestMigrateTimesToUTC converts times written in local time by earlier
+// versions, sqlite would compare them as text with those written in UTC.
+func TestMigrateTimesToUTC(t *testing.T) {
+	db, migrator := newSqliteMigrator(t)
+	_, err := migrator.Up(2)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO Users(ID,Email)VALUES(7,'ann@example.com');
+	INSERT INTO Transactions(UserID,UserEmail,Amount,Currency,CreationDate,ChangeDate,Status)
+		VALUES(7,'ann@example.com',100,'USD','2022-06-11 00:45:47.72474801+06:00','2022-06-11 18:47:22-07:00','NEW')`)
+	require.NoError(t, err)
+
+	_, err = migrator.Up(0)
+	require.NoError(t, err)
+	var created, changed string
+	require.NoError(t, db.QueryRow("SELECT CAST(CreationDate AS TEXT),CAST(ChangeDate AS TEXT) FROM Transactions").Scan(&created, &changed))
+	assert.Equal(t, "2022-06-10 18:45:47.72474801+00:00", created)
+	assert.Equal(t, "2022-06-12 01:47:22+00:00", changed)
+
+	payment, err := NewRepository(db).GetPayment(0, 1)
+	require.NoError(t, err)
+	assert.True(t, payment.CreationDate.Equal(time.Date(2022, 6, 10, 18, 45, 47, 724748010, time.UTC)))
+	assert.Equal(t, time.Local, payment.CreationDate.Location())
+	_, err = NewRepository(db).NewPayment(models.Transaction{UserID: 7, UserEmail: "ann@example.com", Status: models.StatusNew, MerchantID: 0}, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.QueryRow("SELECT CAST(CreationDate AS TEXT) FROM Transactions WHERE ID = 2").Scan(&created))
+	assert.True(t, strings.HasSuffix(created, "+00:00"), created)
+}
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS IdempotencyKeys;
+DROP TABLE IF EXISTS ProcessingJobs;
+DROP TABLE IF EXISTS Refunds;
+DROP TABLE IF EXISTS WebhookDeliveries;
+DROP TABLE IF EXISTS WebhookEvents;
+DROP TABLE IF EXISTS WebhookEndpoints;
+DROP TABLE IF EXISTS PaymentEvents;
+DROP TABLE IF EXISTS Transactions;
+DROP TABLE IF EXISTS APIKeys;
+DROP TABLE IF EXISTS Merchants;
+DROP TABLE IF EXISTS Users;
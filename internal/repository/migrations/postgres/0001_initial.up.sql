@@ -0,0 +1,142 @@
+CREATE TABLE IF NOT EXISTS Merchants (
+	ID	SERIAL PRIMARY KEY,
+	Name	TEXT NOT NULL DEFAULT '',
+	Currencies	TEXT NOT NULL DEFAULT '',
+	ErrorRate	DOUBLE PRECISION,
+	FailRate	DOUBLE PRECISION,
+	RefundFailRate	DOUBLE PRECISION,
+	CreatedAt	TIMESTAMPTZ NOT NULL,
+	UpdatedAt	TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Users (
+	ID	SERIAL PRIMARY KEY,
+	MerchantID	INTEGER NOT NULL DEFAULT 0,
+	Email	TEXT,
+	Name	TEXT NOT NULL DEFAULT '',
+	CreatedAt	TIMESTAMPTZ,
+	UpdatedAt	TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS UsersByMerchantEmail ON Users (MerchantID, Email);
+
+CREATE TABLE IF NOT EXISTS APIKeys (
+	ID	SERIAL PRIMARY KEY,
+	MerchantID	INTEGER NOT NULL DEFAULT 0,
+	Name	TEXT NOT NULL DEFAULT '',
+	SecretHash	TEXT NOT NULL UNIQUE,
+	PublishableHash	TEXT NOT NULL UNIQUE,
+	SecretPrefix	TEXT NOT NULL,
+	PublishablePrefix	TEXT NOT NULL,
+	CreatedAt	TIMESTAMPTZ NOT NULL,
+	RevokedAt	TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS Transactions (
+	ID	SERIAL PRIMARY KEY,
+	UserID	INTEGER REFERENCES Users(ID),
+	UserEmail	TEXT,
+	Amount	BIGINT NOT NULL DEFAULT 0,
+	Currency	TEXT,
+	CreationDate	TIMESTAMPTZ NOT NULL,
+	ChangeDate	TIMESTAMPTZ NOT NULL,
+	Status	TEXT,
+	DeclineReason	TEXT NOT NULL DEFAULT '',
+	CaptureMethod	TEXT NOT NULL DEFAULT 'automatic',
+	CapturedAmount	BIGINT NOT NULL DEFAULT 0,
+	AuthorizationExpires	TIMESTAMPTZ,
+	RefundedAmount	BIGINT NOT NULL DEFAULT 0,
+	CancelledAt	TIMESTAMPTZ,
+	CancellationReason	TEXT NOT NULL DEFAULT '',
+	CancelledBy	TEXT NOT NULL DEFAULT '',
+	APIKeyID	INTEGER REFERENCES APIKeys(ID),
+	MerchantID	INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS TransactionsByMerchant ON Transactions (MerchantID, UserID);
+
+CREATE TABLE IF NOT EXISTS PaymentEvents (
+	ID	SERIAL PRIMARY KEY,
+	PaymentID	INTEGER NOT NULL,
+	FromStatus	TEXT NOT NULL,
+	ToStatus	TEXT NOT NULL,
+	Reason	TEXT NOT NULL,
+	CreatedAt	TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS PaymentEventsByPayment ON PaymentEvents (PaymentID);
+
+CREATE TABLE IF NOT EXISTS WebhookEndpoints (
+	ID	SERIAL PRIMARY KEY,
+	MerchantID	INTEGER NOT NULL DEFAULT 0,
+	URL	TEXT NOT NULL,
+	Secret	TEXT NOT NULL,
+	Events	TEXT NOT NULL,
+	CreatedAt	TIMESTAMPTZ NOT NULL,
+	PreviousSecret	TEXT NOT NULL DEFAULT '',
+	PreviousSecretExpires	TIMESTAMPTZ,
+	SignatureFault	TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS WebhookEndpointsByMerchant ON WebhookEndpoints (MerchantID);
+
+CREATE TABLE IF NOT EXISTS WebhookEvents (
+	ID	SERIAL PRIMARY KEY,
+	Type	TEXT NOT NULL,
+	PaymentID	INTEGER NOT NULL,
+	Payload	TEXT NOT NULL,
+	CreatedAt	TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS WebhookDeliveries (
+	ID	SERIAL PRIMARY KEY,
+	EndpointID	INTEGER NOT NULL REFERENCES WebhookEndpoints(ID) ON DELETE CASCADE,
+	EventID	INTEGER NOT NULL REFERENCES WebhookEvents(ID),
+	Status	TEXT NOT NULL,
+	Attempts	INTEGER NOT NULL DEFAULT 0,
+	NextAttempt	TIMESTAMPTZ NOT NULL,
+	ResponseCode	INTEGER NOT NULL DEFAULT 0,
+	LastError	TEXT NOT NULL DEFAULT '',
+	CreatedAt	TIMESTAMPTZ NOT NULL,
+	UpdatedAt	TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS WebhookDeliveriesDue ON WebhookDeliveries (Status, NextAttempt);
+
+CREATE TABLE IF NOT EXISTS Refunds (
+	ID	SERIAL PRIMARY KEY,
+	PaymentID	INTEGER NOT NULL REFERENCES Transactions(ID),
+	MerchantID	INTEGER NOT NULL DEFAULT 0,
+	Amount	BIGINT NOT NULL,
+	Status	TEXT NOT NULL,
+	Reason	TEXT NOT NULL DEFAULT '',
+	DeclineReason	TEXT NOT NULL DEFAULT '',
+	CreatedAt	TIMESTAMPTZ NOT NULL,
+	UpdatedAt	TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS RefundsByPayment ON Refunds (PaymentID);
+
+CREATE TABLE IF NOT EXISTS ProcessingJobs (
+	ID	SERIAL PRIMARY KEY,
+	Kind	TEXT NOT NULL DEFAULT 'payment',
+	MerchantID	INTEGER NOT NULL DEFAULT 0,
+	PaymentID	INTEGER NOT NULL,
+	RefundID	INTEGER NOT NULL DEFAULT 0,
+	Status	TEXT NOT NULL,
+	RunAt	TIMESTAMPTZ NOT NULL,
+	Attempts	INTEGER NOT NULL DEFAULT 0,
+	LastError	TEXT NOT NULL DEFAULT '',
+	CreatedAt	TIMESTAMPTZ NOT NULL,
+	UpdatedAt	TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS ProcessingJobsDue ON ProcessingJobs (Status, RunAt);
+
+CREATE TABLE IF NOT EXISTS IdempotencyKeys (
+	Key	TEXT PRIMARY KEY,
+	Fingerprint	TEXT NOT NULL,
+	ResponseCode	INTEGER NOT NULL DEFAULT 0,
+	ResponseBody	BYTEA,
+	CreatedAt	TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,6 @@
+-- keyset pagination of GET /payments walks these in ID or amount order
+CREATE INDEX IF NOT EXISTS TransactionsByMerchantID ON Transactions (MerchantID, ID);
+
+CREATE INDEX IF NOT EXISTS TransactionsByMerchantAmount ON Transactions (MerchantID, Amount, ID);
+
+CREATE INDEX IF NOT EXISTS TransactionsByMerchantEmail ON Transactions (MerchantID, UserEmail, ID);
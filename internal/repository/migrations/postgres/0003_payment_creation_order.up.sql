@@ -0,0 +1,2 @@
+-- keyset pagination of GET /payments in creation order
+CREATE INDEX IF NOT EXISTS TransactionsByMerchantCreation ON Transactions (MerchantID, CreationDate, ID);
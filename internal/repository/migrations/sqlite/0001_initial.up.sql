@@ -0,0 +1,157 @@
+CREATE TABLE IF NOT EXISTS "Transactions" (
+	"ID"	INTEGER NOT NULL UNIQUE,
+	"UserID"	INTEGER,
+	"UserEmail"	TEXT,
+	"Amount"	INTEGER NOT NULL DEFAULT 0,
+	"Currency"	TEXT,
+	"CreationDate"	DATETIME NOT NULL,
+	"ChangeDate"	DATETIME NOT NULL,
+	"Status"	TEXT,
+	"DeclineReason"	TEXT NOT NULL DEFAULT '',
+	"CaptureMethod"	TEXT NOT NULL DEFAULT 'automatic',
+	"CapturedAmount"	INTEGER NOT NULL DEFAULT 0,
+	"AuthorizationExpires"	DATETIME,
+	"RefundedAmount"	INTEGER NOT NULL DEFAULT 0,
+	"CancelledAt"	DATETIME,
+	"CancellationReason"	TEXT NOT NULL DEFAULT '',
+	"CancelledBy"	TEXT NOT NULL DEFAULT '',
+	"APIKeyID"	INTEGER REFERENCES "APIKeys"("ID"),
+	"MerchantID"	INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY("ID" AUTOINCREMENT),
+	FOREIGN KEY("UserID") REFERENCES "Users"("ID")
+);
+
+CREATE TABLE IF NOT EXISTS "Users" (
+	"ID"	INTEGER NOT NULL UNIQUE,
+	"MerchantID"	INTEGER NOT NULL DEFAULT 0,
+	"Email"	TEXT,
+	"Name"	TEXT NOT NULL DEFAULT '',
+	"CreatedAt"	DATETIME,
+	"UpdatedAt"	DATETIME,
+	PRIMARY KEY("ID" AUTOINCREMENT)
+);
+
+CREATE TABLE IF NOT EXISTS "Merchants" (
+	"ID"	INTEGER NOT NULL UNIQUE,
+	"Name"	TEXT NOT NULL DEFAULT '',
+	"Currencies"	TEXT NOT NULL DEFAULT '',
+	"ErrorRate"	REAL,
+	"FailRate"	REAL,
+	"RefundFailRate"	REAL,
+	"CreatedAt"	DATETIME NOT NULL,
+	"UpdatedAt"	DATETIME NOT NULL,
+	PRIMARY KEY("ID" AUTOINCREMENT)
+);
+
+CREATE TABLE IF NOT EXISTS "APIKeys" (
+	"ID"	INTEGER NOT NULL UNIQUE,
+	"MerchantID"	INTEGER NOT NULL DEFAULT 0,
+	"Name"	TEXT NOT NULL DEFAULT '',
+	"SecretHash"	TEXT NOT NULL UNIQUE,
+	"PublishableHash"	TEXT NOT NULL UNIQUE,
+	"SecretPrefix"	TEXT NOT NULL,
+	"PublishablePrefix"	TEXT NOT NULL,
+	"CreatedAt"	DATETIME NOT NULL,
+	"RevokedAt"	DATETIME,
+	PRIMARY KEY("ID" AUTOINCREMENT)
+);
+
+CREATE TABLE IF NOT EXISTS "PaymentEvents" (
+	"ID"	INTEGER NOT NULL UNIQUE,
+	"PaymentID"	INTEGER NOT NULL,
+	"FromStatus"	TEXT NOT NULL,
+	"ToStatus"	TEXT NOT NULL,
+	"Reason"	TEXT NOT NULL,
+	"CreatedAt"	DATETIME NOT NULL,
+	PRIMARY KEY("ID" AUTOINCREMENT)
+);
+
+CREATE INDEX IF NOT EXISTS "PaymentEventsByPayment" ON "PaymentEvents" ("PaymentID");
+
+CREATE TABLE IF NOT EXISTS "WebhookEndpoints" (
+	"ID"	INTEGER NOT NULL UNIQUE,
+	"MerchantID"	INTEGER NOT NULL DEFAULT 0,
+	"URL"	TEXT NOT NULL,
+	"Secret"	TEXT NOT NULL,
+	"Events"	TEXT NOT NULL,
+	"CreatedAt"	DATETIME NOT NULL,
+	"PreviousSecret"	TEXT NOT NULL DEFAULT '',
+	"PreviousSecretExpires"	DATETIME,
+	"SignatureFault"	TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY("ID" AUTOINCREMENT)
+);
+
+CREATE TABLE IF NOT EXISTS "WebhookEvents" (
+	"ID"	INTEGER NOT NULL UNIQUE,
+	"Type"	TEXT NOT NULL,
+	"PaymentID"	INTEGER NOT NULL,
+	"Payload"	TEXT NOT NULL,
+	"CreatedAt"	DATETIME NOT NULL,
+	PRIMARY KEY("ID" AUTOINCREMENT)
+);
+
+CREATE TABLE IF NOT EXISTS "WebhookDeliveries" (
+	"ID"	INTEGER NOT NULL UNIQUE,
+	"EndpointID"	INTEGER NOT NULL,
+	"EventID"	INTEGER NOT NULL,
+	"Status"	TEXT NOT NULL,
+	"Attempts"	INTEGER NOT NULL DEFAULT 0,
+	"NextAttempt"	DATETIME NOT NULL,
+	"ResponseCode"	INTEGER NOT NULL DEFAULT 0,
+	"LastError"	TEXT NOT NULL DEFAULT '',
+	"CreatedAt"	DATETIME NOT NULL,
+	"UpdatedAt"	DATETIME NOT NULL,
+	PRIMARY KEY("ID" AUTOINCREMENT),
+	FOREIGN KEY("EndpointID") REFERENCES "WebhookEndpoints"("ID") ON DELETE CASCADE,
+	FOREIGN KEY("EventID") REFERENCES "WebhookEvents"("ID")
+);
+
+CREATE INDEX IF NOT EXISTS "WebhookDeliveriesDue" ON "WebhookDeliveries" ("Status", "NextAttempt");
+
+CREATE TABLE IF NOT EXISTS "Refunds" (
+	"ID"	INTEGER NOT NULL UNIQUE,
+	"PaymentID"	INTEGER NOT NULL,
+	"MerchantID"	INTEGER NOT NULL DEFAULT 0,
+	"Amount"	INTEGER NOT NULL,
+	"Status"	TEXT NOT NULL,
+	"Reason"	TEXT NOT NULL DEFAULT '',
+	"DeclineReason"	TEXT NOT NULL DEFAULT '',
+	"CreatedAt"	DATETIME NOT NULL,
+	"UpdatedAt"	DATETIME NOT NULL,
+	PRIMARY KEY("ID" AUTOINCREMENT),
+	FOREIGN KEY("PaymentID") REFERENCES "Transactions"("ID")
+);
+
+CREATE INDEX IF NOT EXISTS "RefundsByPayment" ON "Refunds" ("PaymentID");
+
+CREATE TABLE IF NOT EXISTS "ProcessingJobs" (
+	"ID"	INTEGER NOT NULL UNIQUE,
+	"Kind"	TEXT NOT NULL DEFAULT 'payment',
+	"MerchantID"	INTEGER NOT NULL DEFAULT 0,
+	"PaymentID"	INTEGER NOT NULL,
+	"RefundID"	INTEGER NOT NULL DEFAULT 0,
+	"Status"	TEXT NOT NULL,
+	"RunAt"	DATETIME NOT NULL,
+	"Attempts"	INTEGER NOT NULL DEFAULT 0,
+	"LastError"	TEXT NOT NULL DEFAULT '',
+	"CreatedAt"	DATETIME NOT NULL,
+	"UpdatedAt"	DATETIME NOT NULL,
+	PRIMARY KEY("ID" AUTOINCREMENT)
+);
+
+CREATE INDEX IF NOT EXISTS "ProcessingJobsDue" ON "ProcessingJobs" ("Status", "RunAt");
+
+CREATE TABLE IF NOT EXISTS "IdempotencyKeys" (
+	"Key"	TEXT NOT NULL,
+	"Fingerprint"	TEXT NOT NULL,
+	"ResponseCode"	INTEGER NOT NULL DEFAULT 0,
+	"ResponseBody"	BLOB,
+	"CreatedAt"	DATETIME NOT NULL,
+	PRIMARY KEY("Key")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "UsersByMerchantEmail" ON "Users" ("MerchantID", "Email");
+
+CREATE INDEX IF NOT EXISTS "TransactionsByMerchant" ON "Transactions" ("MerchantID", "UserID");
+
+CREATE INDEX IF NOT EXISTS "WebhookEndpointsByMerchant" ON "WebhookEndpoints" ("MerchantID");
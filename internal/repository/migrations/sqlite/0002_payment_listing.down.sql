@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "TransactionsByMerchantEmail";
+DROP INDEX IF EXISTS "TransactionsByMerchantAmount";
+DROP INDEX IF EXISTS "TransactionsByMerchantID";
@@ -0,0 +1,2 @@
+-- times stay in UTC, earlier versions read them with their offset
+DROP INDEX IF EXISTS "TransactionsByMerchantCreation";
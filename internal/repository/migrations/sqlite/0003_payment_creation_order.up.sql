@@ -0,0 +1,25 @@
+-- times are stored in UTC from now on, sqlite compares them as text so
+-- values written in local time are converted to keep them in order
+UPDATE "Transactions" SET "CreationDate" = strftime('%Y-%m-%d %H:%M:%S', substr("CreationDate", 1, 19) || substr("CreationDate", -6)) || substr("CreationDate", 20, length("CreationDate") - 25) || '+00:00'
+	WHERE "CreationDate" GLOB '????-??-?? ??:??:??*[+-]??:??' AND substr("CreationDate", -6) <> '+00:00';
+
+UPDATE "Transactions" SET "ChangeDate" = strftime('%Y-%m-%d %H:%M:%S', substr("ChangeDate", 1, 19) || substr("ChangeDate", -6)) || substr("ChangeDate", 20, length("ChangeDate") - 25) || '+00:00'
+	WHERE "ChangeDate" GLOB '????-??-?? ??:??:??*[+-]??:??' AND substr("ChangeDate", -6) <> '+00:00';
+
+UPDATE "Transactions" SET "AuthorizationExpires" = strftime('%Y-%m-%d %H:%M:%S', substr("AuthorizationExpires", 1, 19) || substr("AuthorizationExpires", -6)) || substr("AuthorizationExpires", 20, length("AuthorizationExpires") - 25) || '+00:00'
+	WHERE "AuthorizationExpires" GLOB '????-??-?? ??:??:??*[+-]??:??' AND substr("AuthorizationExpires", -6) <> '+00:00';
+
+UPDATE "Transactions" SET "CancelledAt" = strftime('%Y-%m-%d %H:%M:%S', substr("CancelledAt", 1, 19) || substr("CancelledAt", -6)) || substr("CancelledAt", 20, length("CancelledAt") - 25) || '+00:00'
+	WHERE "CancelledAt" GLOB '????-??-?? ??:??:??*[+-]??:??' AND substr("CancelledAt", -6) <> '+00:00';
+
+UPDATE "WebhookDeliveries" SET "NextAttempt" = strftime('%Y-%m-%d %H:%M:%S', substr("NextAttempt", 1, 19) || substr("NextAttempt", -6)) || substr("NextAttempt", 20, length("NextAttempt") - 25) || '+00:00'
+	WHERE "NextAttempt" GLOB '????-??-?? ??:??:??*[+-]??:??' AND substr("NextAttempt", -6) <> '+00:00';
+
+UPDATE "ProcessingJobs" SET "RunAt" = strftime('%Y-%m-%d %H:%M:%S', substr("RunAt", 1, 19) || substr("RunAt", -6)) || substr("RunAt", 20, length("RunAt") - 25) || '+00:00'
+	WHERE "RunAt" GLOB '????-??-?? ??:??:??*[+-]??:??' AND substr("RunAt", -6) <> '+00:00';
+
+UPDATE "IdempotencyKeys" SET "CreatedAt" = strftime('%Y-%m-%d %H:%M:%S', substr("CreatedAt", 1, 19) || substr("CreatedAt", -6)) || substr("CreatedAt", 20, length("CreatedAt") - 25) || '+00:00'
+	WHERE "CreatedAt" GLOB '????-??-?? ??:??:??*[+-]??:??' AND substr("CreatedAt", -6) <> '+00:00';
+
+-- keyset pagination of GET /payments in creation order
+CREATE INDEX IF NOT EXISTS "TransactionsByMerchantCreation" ON "Transactions" ("MerchantID", "CreationDate", "ID");
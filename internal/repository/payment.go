@@ -3,11 +3,20 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/altuxa/payment-service-emulator/internal/models"
 )
 
+var (
+	ErrPaymentNotFound = fmt.Errorf("payment %w", ErrNotFound)
+	ErrStatusChanged   = errors.New("payment status was changed concurrently")
+)
+
+const paymentColumns = "ID,UserID,UserEmail,Amount,Currency,CreationDate,ChangeDate,Status,DeclineReason,CaptureMethod,CapturedAmount,RefundedAmount,AuthorizationExpires,CancelledAt,CancellationReason,CancelledBy,APIKeyID,MerchantID"
+
 type PaymentRepo struct {
 	db *sql.DB
 }
@@ -18,51 +27,67 @@ func NewPaymentRepo(db *sql.DB) *PaymentRepo {
 	}
 }
 
-func (p *PaymentRepo) NewPayment(id int, email string, sum float64, val string, status string) (int, error) {
-	stmt, err := p.db.Prepare("INSERT INTO Transactions(UserID, UserEmail,Sum,Currency,CreationDate,ChangeDate,Status)VALUES(?,?,?,?,?,?,?)")
+// NewPayment stores the payment together with its processing job when job is
+// not nil.
+func (p *PaymentRepo) NewPayment(payment models.Transaction, job *models.Job) (int, error) {
+	tx, err := p.db.Begin()
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback()
 	date := time.Now()
-	res, err := stmt.Exec(id, email, sum, val, date, date, status)
+	apiKeyID := sql.NullInt64{Int64: int64(payment.APIKeyID), Valid: payment.APIKeyID != 0}
+	paymentID, err := insert(tx, "INSERT INTO Transactions(UserID, UserEmail,Amount,Currency,CreationDate,ChangeDate,Status,CaptureMethod,APIKeyID,MerchantID)VALUES(?,?,?,?,?,?,?,?,?,?)",
+		payment.UserID, payment.UserEmail, payment.Sum.Amount, payment.Sum.Currency, date, date, payment.Status, payment.CaptureMethod, apiKeyID, payment.MerchantID)
 	if err != nil {
 		return 0, err
 	}
-	paymentID, err := res.LastInsertId()
+	err = addEvent(tx, paymentID, "", payment.Status, "payment created", date)
 	if err != nil {
 		return 0, err
 	}
-	if paymentID == 0 {
-		return 0, errors.New("payment not create")
+	if job != nil {
+		job.PaymentID = paymentID
+		_, err = insertJob(tx, *job)
+		if err != nil {
+			return 0, err
+		}
 	}
-	defer stmt.Close()
-	return int(paymentID), nil
+	return paymentID, tx.Commit()
 }
 
-func (p *PaymentRepo) PaymentStatus(paymentId int) (string, error) {
+func (p *PaymentRepo) PaymentStatus(merchantID, paymentId int) (string, error) {
 	status := ""
-	stmt, err := p.db.Prepare("SELECT Status FROM Transactions WHERE ID = ?")
+	stmt, err := p.db.Prepare("SELECT Status FROM Transactions WHERE ID = ? AND MerchantID = ?")
 	if err != nil {
 		return "", err
 	}
-	row := stmt.QueryRow(paymentId)
+	row := stmt.QueryRow(paymentId, merchantID)
 	row.Scan(&status)
 	if len(status) == 0 {
-		return "", errors.New("payment not found")
+		return "", ErrPaymentNotFound
 	}
 	defer stmt.Close()
 	return status, nil
 }
 
-func (p *PaymentRepo) GetAllPaymentsByUserID(userId int) ([]models.Transaction, error) {
+func (p *PaymentRepo) GetPayment(merchantID, paymentId int) (models.Transaction, error) {
+	row := p.db.QueryRow("SELECT "+paymentColumns+" FROM Transactions WHERE ID = ? AND MerchantID = ?", paymentId, merchantID)
+	payment, err := scanPayment(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return payment, ErrPaymentNotFound
+	}
+	return payment, err
+}
+
+func (p *PaymentRepo) GetAllPaymentsByUserID(merchantID, userId int) ([]models.Transaction, error) {
 	payments := []models.Transaction{}
-	row, err := p.db.Query("SELECT ID,UserID, UserEmail,Sum,Currency,CreationDate,ChangeDate,Status FROM Transactions WHERE UserID = ?", userId)
+	row, err := p.db.Query("SELECT "+paymentColumns+" FROM Transactions WHERE UserID = ? AND MerchantID = ?", userId, merchantID)
 	if err != nil {
 		return nil, err
 	}
 	for row.Next() {
-		payment := models.Transaction{}
-		err := row.Scan(&payment.ID, &payment.UserID, &payment.UserEmail, &payment.Sum, &payment.Currency, &payment.CreationDate, &payment.ChangeDate, &payment.Status)
+		payment, err := scanPayment(row)
 		if err != nil {
 			return nil, err
 		}
@@ -70,20 +95,19 @@ func (p *PaymentRepo) GetAllPaymentsByUserID(userId int) ([]models.Transaction,
 	}
 	defer row.Close()
 	if len(payments) == 0 {
-		return nil, errors.New("not found")
+		return nil, ErrNotFound
 	}
 	return payments, nil
 }
 
-func (p *PaymentRepo) GetAllPaymentsByEmail(email string) ([]models.Transaction, error) {
+func (p *PaymentRepo) GetAllPaymentsByEmail(merchantID int, email string) ([]models.Transaction, error) {
 	payments := []models.Transaction{}
-	row, err := p.db.Query("SELECT ID,UserID, UserEmail,Sum,Currency,CreationDate,ChangeDate,Status FROM Transactions WHERE UserEmail = ?", email)
+	row, err := p.db.Query("SELECT "+paymentColumns+" FROM Transactions WHERE UserEmail = ? AND MerchantID = ?", email, merchantID)
 	if err != nil {
 		return nil, err
 	}
 	for row.Next() {
-		payment := models.Transaction{}
-		err := row.Scan(&payment.ID, &payment.UserID, &payment.UserEmail, &payment.Sum, &payment.Currency, &payment.CreationDate, &payment.ChangeDate, &payment.Status)
+		payment, err := scanPayment(row)
 		if err != nil {
 			return nil, err
 		}
@@ -91,31 +115,209 @@ func (p *PaymentRepo) GetAllPaymentsByEmail(email string) ([]models.Transaction,
 	}
 	defer row.Close()
 	if len(payments) == 0 {
-		return nil, errors.New("not found")
+		return nil, ErrNotFound
 	}
 	return payments, nil
 }
 
-func (p *PaymentRepo) DeletePayment(paymentId int) error {
-	_, err := p.db.Exec("DELETE FROM Transactions WHERE ID = ?", paymentId)
+// ListPayments returns up to filter.Limit payments of the merchant matching
+// filter in its sort order, ties are broken by ID.
+func (p *PaymentRepo) ListPayments(merchantID int, filter models.PaymentFilter) ([]models.Transaction, error) {
+	where := []string{"MerchantID = ?"}
+	args := []interface{}{merchantID}
+	add := func(cond string, values ...interface{}) {
+		where = append(where, cond)
+		args = append(args, values...)
+	}
+	if len(filter.Statuses) != 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(filter.Statuses)), ",")
+		statuses := []interface{}{}
+		for _, status := range filter.Statuses {
+			statuses = append(statuses, status)
+		}
+		add("Status IN ("+placeholders+")", statuses...)
+	}
+	if filter.Currency != "" {
+		add("Currency = ?", filter.Currency)
+	}
+	if filter.MinAmount != nil {
+		add("Amount >= ?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		add("Amount <= ?", *filter.MaxAmount)
+	}
+	if filter.CreatedFrom != nil {
+		add("CreationDate >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		add("CreationDate < ?", *filter.CreatedTo)
+	}
+	if filter.ChangedFrom != nil {
+		add("ChangeDate >= ?", *filter.ChangedFrom)
+	}
+	if filter.ChangedTo != nil {
+		add("ChangeDate < ?", *filter.ChangedTo)
+	}
+	if filter.UserID != 0 {
+		add("UserID = ?", filter.UserID)
+	}
+	if filter.Email != "" {
+		add("UserEmail = ?", filter.Email)
+	}
+	order := "CreationDate,ID"
+	after := filter.After
+	switch filter.Sort {
+	case models.SortCreatedDesc:
+		order = "CreationDate DESC,ID DESC"
+		if after != nil {
+			add("(CreationDate < ? OR (CreationDate = ? AND ID < ?))", after.Created, after.Created, after.ID)
+		}
+	case models.SortAmount:
+		order = "Amount,ID"
+		if after != nil {
+			add("(Amount > ? OR (Amount = ? AND ID > ?))", after.Amount, after.Amount, after.ID)
+		}
+	case models.SortAmountDesc:
+		order = "Amount DESC,ID DESC"
+		if after != nil {
+			add("(Amount < ? OR (Amount = ? AND ID < ?))", after.Amount, after.Amount, after.ID)
+		}
+	default:
+		if after != nil {
+			add("(CreationDate > ? OR (CreationDate = ? AND ID > ?))", after.Created, after.Created, after.ID)
+		}
+	}
+	args = append(args, filter.Limit)
+	payments := []models.Transaction{}
+	row, err := p.db.Query("SELECT "+paymentColumns+" FROM Transactions WHERE "+strings.Join(where, " AND ")+" ORDER BY "+order+" LIMIT ?", args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	defer row.Close()
+	for row.Next() {
+		payment, err := scanPayment(row)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, row.Err()
 }
 
-func (p *PaymentRepo) SetStatusSuccess(paymentId int) error {
-	_, err := p.db.Exec("UPDATE Transactions Set Status = ?,ChangeDate = ? WHERE ID = ?", models.StatusSuccess, time.Now(), paymentId)
+// PurgeCancelled deletes payments cancelled before the given time together
+// with their history.
+func (p *PaymentRepo) PurgeCancelled(before time.Time) (int, error) {
+	tx, err := p.db.Begin()
 	if err != nil {
-		return err
+		return 0, err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec("DELETE FROM PaymentEvents WHERE PaymentID IN (SELECT ID FROM Transactions WHERE Status = ? AND CancelledAt < ?)", models.StatusCancelled, before)
+	if err != nil {
+		return 0, err
 	}
-	return nil
+	res, err := tx.Exec("DELETE FROM Transactions WHERE Status = ? AND CancelledAt < ?", models.StatusCancelled, before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), tx.Commit()
 }
 
-func (p *PaymentRepo) SetStatusFail(paymentId int) error {
-	_, err := p.db.Exec("UPDATE Transactions Set Status = ?,ChangeDate = ? WHERE ID = ?", models.StatusFail, time.Now(), paymentId)
+func (p *PaymentRepo) SetStatus(merchantID, paymentId int, change models.StatusChange) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	date := time.Now()
+	res, err := tx.Exec(`UPDATE Transactions Set Status = ?,DeclineReason = ?,ChangeDate = ?,
+		CapturedAmount = COALESCE(?, CapturedAmount),AuthorizationExpires = COALESCE(?, AuthorizationExpires)
+		WHERE ID = ? AND MerchantID = ? AND Status = ?`,
+		change.To, change.DeclineReason, date, change.Captured, change.AuthorizationExpires, paymentId, merchantID, change.From)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
-	return nil
+	if n == 0 {
+		return ErrStatusChanged
+	}
+	if change.To == models.StatusCancelled {
+		_, err = tx.Exec("UPDATE Transactions SET CancelledAt = ?,CancellationReason = ?,CancelledBy = ? WHERE ID = ?", date, change.Reason, change.Actor, paymentId)
+		if err != nil {
+			return err
+		}
+	}
+	err = addEvent(tx, paymentId, change.From, change.To, change.Reason, date)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ExpiredAuthorizations returns authorizations of every merchant that are
+// past their expiry.
+func (p *PaymentRepo) ExpiredAuthorizations(now time.Time, limit int) ([]models.Transaction, error) {
+	payments := []models.Transaction{}
+	row, err := p.db.Query("SELECT "+paymentColumns+" FROM Transactions WHERE Status = ? AND AuthorizationExpires <= ? ORDER BY AuthorizationExpires LIMIT ?", models.StatusAuthorized, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		payment, err := scanPayment(row)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, row.Err()
+}
+
+func (p *PaymentRepo) History(merchantID, paymentId int) ([]models.PaymentEvent, error) {
+	events := []models.PaymentEvent{}
+	row, err := p.db.Query(`SELECT e.ID,e.PaymentID,e.FromStatus,e.ToStatus,e.Reason,e.CreatedAt FROM PaymentEvents e
+		JOIN Transactions t ON t.ID = e.PaymentID WHERE e.PaymentID = ? AND t.MerchantID = ? ORDER BY e.ID`, paymentId, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		event := models.PaymentEvent{}
+		err := row.Scan(&event.ID, &event.PaymentID, &event.FromStatus, &event.ToStatus, &event.Reason, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, row.Err()
+}
+
+func scanPayment(row scanner) (models.Transaction, error) {
+	payment := models.Transaction{}
+	var expires, cancelled sql.NullTime
+	var apiKeyID sql.NullInt64
+	err := row.Scan(&payment.ID, &payment.UserID, &payment.UserEmail, &payment.Sum.Amount, &payment.Sum.Currency, &payment.CreationDate, &payment.ChangeDate, &payment.Status, &payment.DeclineReason,
+		&payment.CaptureMethod, &payment.Captured.Amount, &payment.Refunded.Amount, &expires, &cancelled, &payment.CancellationReason, &payment.CancelledBy, &apiKeyID, &payment.MerchantID)
+	payment.APIKeyID = int(apiKeyID.Int64)
+	payment.Captured.Currency = payment.Sum.Currency
+	payment.Refunded.Currency = payment.Sum.Currency
+	if expires.Valid {
+		payment.AuthorizationExpires = &expires.Time
+	}
+	if cancelled.Valid {
+		payment.CancelledAt = &cancelled.Time
+	}
+	return payment, err
+}
+
+func addEvent(tx *sql.Tx, paymentId int, from, to, reason string, date time.Time) error {
+	_, err := tx.Exec("INSERT INTO PaymentEvents(PaymentID,FromStatus,ToStatus,Reason,CreatedAt)VALUES(?,?,?,?,?)", paymentId, from, to, reason, date)
+	return err
 }
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebind(t *testing.T) {
+	tData := map[string]struct {
+		Query    string
+		Expected string
+	}{
+		"no placeholders": {Query: "SELECT 1", Expected: "SELECT 1"},
+		"placeholders":    {Query: "SELECT ID FROM Users WHERE ID = ? AND MerchantID = ?", Expected: "SELECT ID FROM Users WHERE ID = $1 AND MerchantID = $2"},
+		"string literal":  {Query: "SELECT '?' FROM Users WHERE Email = ?", Expected: "SELECT '?' FROM Users WHERE Email = $1"},
+		"escaped quote":   {Query: "SELECT 'it''s ?' WHERE ID IN (?,?)", Expected: "SELECT 'it''s ?' WHERE ID IN ($1,$2)"},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			assert.Equal(t, v.Expected, rebind(v.Query))
+		})
+	}
+}
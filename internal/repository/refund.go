@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+var (
+	ErrRefundNotFound   = fmt.Errorf("refund %w", ErrNotFound)
+	ErrRefundExceeded   = errors.New("refunds exceed the captured amount")
+	ErrRefundNotPending = errors.New("refund is not pending")
+)
+
+const refundColumns = "r.ID,r.PaymentID,r.MerchantID,r.Amount,t.Currency,r.Status,r.Reason,r.DeclineReason,r.CreatedAt,r.UpdatedAt"
+
+type RefundRepo struct {
+	db *sql.DB
+}
+
+func NewRefundRepo(db *sql.DB) *RefundRepo {
+	return &RefundRepo{
+		db: db,
+	}
+}
+
+// CreateRefund stores a pending refund unless pending and succeeded refunds
+// of the payment would exceed limit.
+// CreateRefund stores the refund together with its processing job when job
+// is not nil.
+func (r *RefundRepo) CreateRefund(refund models.Refund, limit int64, job *models.Job) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	// locks the payment row in postgres so concurrent refunds can not both
+	// pass the limit check, sqlite already serializes writers
+	_, err = tx.Exec("UPDATE Transactions SET RefundedAmount = RefundedAmount WHERE ID = ?", refund.PaymentID)
+	if err != nil {
+		return 0, err
+	}
+	var reserved int64
+	err = tx.QueryRow("SELECT COALESCE(SUM(Amount),0) FROM Refunds WHERE PaymentID = ? AND Status IN (?,?)", refund.PaymentID, models.RefundPending, models.RefundSucceeded).Scan(&reserved)
+	if err != nil {
+		return 0, err
+	}
+	if reserved+refund.Amount.Amount > limit {
+		return 0, ErrRefundExceeded
+	}
+	id, err := insert(tx, "INSERT INTO Refunds(PaymentID,MerchantID,Amount,Status,Reason,DeclineReason,CreatedAt,UpdatedAt)VALUES(?,?,?,?,?,?,?,?)",
+		refund.PaymentID, refund.MerchantID, refund.Amount.Amount, models.RefundPending, refund.Reason, "", refund.CreatedAt, refund.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	if job != nil {
+		job.RefundID = id
+		_, err = insertJob(tx, *job)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+// Reserved is the total of pending and succeeded refunds of the payment.
+func (r *RefundRepo) Reserved(merchantID, paymentId int) (int64, error) {
+	var reserved int64
+	err := r.db.QueryRow("SELECT COALESCE(SUM(Amount),0) FROM Refunds WHERE PaymentID = ? AND MerchantID = ? AND Status IN (?,?)", paymentId, merchantID, models.RefundPending, models.RefundSucceeded).Scan(&reserved)
+	return reserved, err
+}
+
+func (r *RefundRepo) Refund(merchantID, id int) (models.Refund, error) {
+	row := r.db.QueryRow("SELECT "+refundColumns+" FROM Refunds r JOIN Transactions t ON t.ID = r.PaymentID WHERE r.ID = ? AND r.MerchantID = ?", id, merchantID)
+	refund, err := scanRefund(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return refund, ErrRefundNotFound
+	}
+	return refund, err
+}
+
+func (r *RefundRepo) Refunds(merchantID, paymentId int) ([]models.Refund, error) {
+	refunds := []models.Refund{}
+	row, err := r.db.Query("SELECT "+refundColumns+" FROM Refunds r JOIN Transactions t ON t.ID = r.PaymentID WHERE r.PaymentID = ? AND r.MerchantID = ? ORDER BY r.ID", paymentId, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		refund, err := scanRefund(row)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, refund)
+	}
+	return refunds, row.Err()
+}
+
+// SucceedRefund completes a pending refund and applies change to its payment
+// in one transaction. The payment must still have status change.From and
+// refundedBefore refunded, otherwise ErrStatusChanged is returned.
+func (r *RefundRepo) SucceedRefund(merchantID, refundId int, paymentId int, refundedBefore int64, change models.StatusChange) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	date := time.Now()
+	res, err := tx.Exec("UPDATE Transactions SET Status = ?,RefundedAmount = ?,ChangeDate = ? WHERE ID = ? AND MerchantID = ? AND Status = ? AND RefundedAmount = ?",
+		change.To, change.Refunded, date, paymentId, merchantID, change.From, refundedBefore)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrStatusChanged
+	}
+	err = finishRefund(tx, merchantID, refundId, models.RefundSucceeded, "", date)
+	if err != nil {
+		return err
+	}
+	err = addEvent(tx, paymentId, change.From, change.To, change.Reason, date)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *RefundRepo) FailRefund(merchantID, refundId int, declineReason string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = finishRefund(tx, merchantID, refundId, models.RefundFailed, declineReason, time.Now())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func finishRefund(tx *sql.Tx, merchantID, refundId int, status, declineReason string, date time.Time) error {
+	res, err := tx.Exec("UPDATE Refunds SET Status = ?,DeclineReason = ?,UpdatedAt = ? WHERE ID = ? AND MerchantID = ? AND Status = ?", status, declineReason, date, refundId, merchantID, models.RefundPending)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRefundNotPending
+	}
+	return nil
+}
+
+func scanRefund(row scanner) (models.Refund, error) {
+	refund := models.Refund{}
+	err := row.Scan(&refund.ID, &refund.PaymentID, &refund.MerchantID, &refund.Amount.Amount, &refund.Amount.Currency, &refund.Status, &refund.Reason, &refund.DeclineReason, &refund.CreatedAt, &refund.UpdatedAt)
+	return refund, err
+}
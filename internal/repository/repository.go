@@ -2,32 +2,161 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/altuxa/payment-service-emulator/internal/models"
 )
 
+// ErrNotFound is wrapped by the errors about missing rows, rows of other
+// merchants are missing as well.
+var ErrNotFound = errors.New("not found")
+
 type User interface {
-	UserVerification(paymentID int, email string) (string, error)
+	UserVerification(merchantID, paymentID int) (string, error)
+	CreateUser(user models.User) (int, error)
+	GetUser(merchantID, id int) (models.User, error)
+	UpdateUser(user models.User) error
+}
+
+type Merchant interface {
+	CreateMerchant(merchant models.Merchant) (int, error)
+	Merchants() ([]models.Merchant, error)
+	Merchant(id int) (models.Merchant, error)
+	UpdateMerchant(merchant models.Merchant) error
+}
+
+type APIKey interface {
+	CreateAPIKey(key models.APIKey, secretHash, publishableHash string) (int, error)
+	APIKeys(merchantID int) ([]models.APIKey, error)
+	APIKey(id int) (models.APIKey, error)
+	APIKeyByHash(hash string) (models.APIKey, string, error)
+	RevokeAPIKey(id int, at time.Time) error
 }
 
 type Payment interface {
-	NewPayment(id int, email string, sum float64, val string, status string) (int, error)
-	PaymentStatus(paymentId int) (string, error)
-	GetAllPaymentsByUserID(userId int) ([]models.Transaction, error)
-	GetAllPaymentsByEmail(email string) ([]models.Transaction, error)
-	DeletePayment(paymentId int) error
-	SetStatusSuccess(paymentId int) error
-	SetStatusFail(paymentId int) error
+	NewPayment(payment models.Transaction, job *models.Job) (int, error)
+	PaymentStatus(merchantID, paymentId int) (string, error)
+	GetPayment(merchantID, paymentId int) (models.Transaction, error)
+	GetAllPaymentsByUserID(merchantID, userId int) ([]models.Transaction, error)
+	GetAllPaymentsByEmail(merchantID int, email string) ([]models.Transaction, error)
+	ListPayments(merchantID int, filter models.PaymentFilter) ([]models.Transaction, error)
+	PurgeCancelled(before time.Time) (int, error)
+	SetStatus(merchantID, paymentId int, change models.StatusChange) error
+	ExpiredAuthorizations(now time.Time, limit int) ([]models.Transaction, error)
+	History(merchantID, paymentId int) ([]models.PaymentEvent, error)
+}
+
+type Webhook interface {
+	CreateEndpoint(endpoint models.WebhookEndpoint) (int, error)
+	Endpoints(merchantID int) ([]models.WebhookEndpoint, error)
+	Endpoint(merchantID, id int) (models.WebhookEndpoint, error)
+	RotateSecret(merchantID, id int, secret string, previousExpires time.Time) error
+	DeleteEndpoint(merchantID, id int) error
+	CreateEvent(event models.WebhookEvent) (int, error)
+	DueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error)
+	Deliveries(merchantID, endpointID int) ([]models.WebhookDelivery, error)
+	Delivery(merchantID, id int) (models.WebhookDelivery, error)
+	UpdateDelivery(delivery models.WebhookDelivery) error
+	Redeliver(merchantID, id int) (int, error)
+}
+
+type Refund interface {
+	CreateRefund(refund models.Refund, limit int64, job *models.Job) (int, error)
+	Reserved(merchantID, paymentId int) (int64, error)
+	Refund(merchantID, id int) (models.Refund, error)
+	Refunds(merchantID, paymentId int) ([]models.Refund, error)
+	SucceedRefund(merchantID, refundId int, paymentId int, refundedBefore int64, change models.StatusChange) error
+	FailRefund(merchantID, refundId int, declineReason string) error
+}
+
+type Idempotency interface {
+	Reserve(record models.IdempotencyRecord, expired time.Time) (models.IdempotencyRecord, bool, error)
+	Complete(key string, code int, body []byte) error
+	Release(key string) error
+}
+
+type Job interface {
+	Enqueue(job models.Job) (int, error)
+	ClaimDue(now time.Time, limit int) ([]models.Job, error)
+	FinishJob(job models.Job) error
+	ResetRunning(now time.Time) error
 }
 
 type Repositories struct {
 	User
+	Merchant
+	APIKey
 	Payment
+	Webhook
+	Refund
+	Idempotency
+	Job
 }
 
+// NewRepository returns the repositories of a sqlite or postgres database.
 func NewRepository(db *sql.DB) *Repositories {
 	return &Repositories{
-		User:    NewUserRepo(db),
-		Payment: NewPaymentRepo(db),
+		User:        NewUserRepo(db),
+		Merchant:    NewMerchantRepo(db),
+		APIKey:      NewAPIKeyRepo(db),
+		Payment:     NewPaymentRepo(db),
+		Webhook:     NewWebhookRepo(db),
+		Refund:      NewRefundRepo(db),
+		Idempotency: NewIdempotencyRepo(db),
+		Job:         NewJobRepo(db),
 	}
 }
+
+const (
+	BackendSQLite   = "sqlite"
+	BackendPostgres = "postgres"
+	BackendMemory   = "memory"
+)
+
+// Open connects to the storage backend and returns the repositories with a
+// function releasing the connection. source is the sqlite file or the postgres
+// connection string, the memory backend ignores it. With migrate pending
+// migrations are applied, otherwise the schema must already be up to date.
+func Open(backend, source string, migrate bool) (*Repositories, func() error, error) {
+	var db *sql.DB
+	var err error
+	switch backend {
+	case BackendMemory:
+		return NewMemoryRepository(), func() error { return nil }, nil
+	case BackendSQLite:
+		db, err = NewSqliteDB(source)
+	case BackendPostgres:
+		db, err = NewPostgresDB(source)
+	default:
+		return nil, nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	migrator, err := NewMigrator(db, backend)
+	if err == nil && migrate {
+		_, err = migrator.Up(0)
+	}
+	if err == nil {
+		err = migrator.Check()
+	}
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return NewRepository(db), db.Close, nil
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// insert runs an INSERT and returns the ID of the new row, postgres does not
+// support LastInsertId.
+func insert(db queryRower, query string, args ...interface{}) (int, error) {
+	var id int
+	err := db.QueryRow(query+" RETURNING ID", args...).Scan(&id)
+	return id, err
+}
@@ -1,41 +1,312 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/altuxa/payment-service-emulator/internal/money"
+	"github.com/mattn/go-sqlite3"
 )
 
-func NewSqliteDB() (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", "./sqlite3.db?_foreign_keys=on")
-	if err != nil {
-		return nil, err
-	}
-	if err := db.Ping(); err != nil {
-		return nil, err
-	}
-	return db, nil
-}
-
-func CreateTable(db *sql.DB) error {
-	_, err := db.Exec(`CREATE TABLE "Transactions" (
+// transactionsTable is formatted with the table name, upgradeUsers rebuilds
+// the table of older databases from it.
+const transactionsTable = `CREATE TABLE IF NOT EXISTS "%s" (
 		"ID"	INTEGER NOT NULL UNIQUE,
 		"UserID"	INTEGER,
 		"UserEmail"	TEXT,
-		"Sum"	REAL,
+		"Amount"	INTEGER NOT NULL DEFAULT 0,
 		"Currency"	TEXT,
 		"CreationDate"	DATETIME NOT NULL,
 		"ChangeDate"	DATETIME NOT NULL,
 		"Status"	TEXT,
-		PRIMARY KEY("ID" AUTOINCREMENT)
-	)`)
+		"DeclineReason"	TEXT NOT NULL DEFAULT '',
+		"CaptureMethod"	TEXT NOT NULL DEFAULT 'automatic',
+		"CapturedAmount"	INTEGER NOT NULL DEFAULT 0,
+		"AuthorizationExpires"	DATETIME,
+		"RefundedAmount"	INTEGER NOT NULL DEFAULT 0,
+		"CancelledAt"	DATETIME,
+		"CancellationReason"	TEXT NOT NULL DEFAULT '',
+		"CancelledBy"	TEXT NOT NULL DEFAULT '',
+		"APIKeyID"	INTEGER REFERENCES "APIKeys"("ID"),
+		"MerchantID"	INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY("ID" AUTOINCREMENT),
+		FOREIGN KEY("UserID") REFERENCES "Users"("ID")
+	)`
+
+// columns added after a table was first released, adoptSqlite adds them to
+// databases created by older versions and runs backfill once they exist.
+var columns = []struct {
+	table, name, definition, backfill string
+}{
+	{"Users", "Name", `TEXT NOT NULL DEFAULT ''`, ""},
+	{"Users", "CreatedAt", `DATETIME`, ""},
+	{"Users", "UpdatedAt", `DATETIME`, ""},
+	{"Transactions", "DeclineReason", `TEXT NOT NULL DEFAULT ''`, ""},
+	{"Transactions", "CaptureMethod", `TEXT NOT NULL DEFAULT 'automatic'`, ""},
+	{"Transactions", "CapturedAmount", `INTEGER NOT NULL DEFAULT 0`, `UPDATE Transactions SET CapturedAmount = Amount WHERE Status = 'SUCCESS'`},
+	{"Transactions", "AuthorizationExpires", `DATETIME`, ""},
+	{"Transactions", "RefundedAmount", `INTEGER NOT NULL DEFAULT 0`, ""},
+	{"Transactions", "CancelledAt", `DATETIME`, ""},
+	{"Transactions", "CancellationReason", `TEXT NOT NULL DEFAULT ''`, ""},
+	{"Transactions", "CancelledBy", `TEXT NOT NULL DEFAULT ''`, ""},
+	{"Transactions", "APIKeyID", `INTEGER REFERENCES "APIKeys"("ID")`, ""},
+	{"ProcessingJobs", "Kind", `TEXT NOT NULL DEFAULT 'payment'`, ""},
+	{"ProcessingJobs", "RefundID", `INTEGER NOT NULL DEFAULT 0`, ""},
+	{"WebhookEndpoints", "PreviousSecret", `TEXT NOT NULL DEFAULT ''`, ""},
+	{"WebhookEndpoints", "PreviousSecretExpires", `DATETIME`, ""},
+	{"WebhookEndpoints", "SignatureFault", `TEXT NOT NULL DEFAULT ''`, ""},
+	// every key pair of an older database becomes a merchant with the same
+	// ID, so merchant_id claims keep pointing at the same data
+	{"APIKeys", "MerchantID", `INTEGER NOT NULL DEFAULT 0`, `INSERT INTO Merchants(ID,Name,CreatedAt,UpdatedAt) SELECT ID,Name,CreatedAt,CreatedAt FROM APIKeys;
+		UPDATE APIKeys SET MerchantID = ID`},
+	// payments created without a key stay with merchant 0, no key can see them
+	{"Transactions", "MerchantID", `INTEGER NOT NULL DEFAULT 0`, `UPDATE Transactions SET MerchantID = COALESCE(APIKeyID, 0)`},
+	{"Users", "MerchantID", `INTEGER NOT NULL DEFAULT 0`, `UPDATE Users SET MerchantID = COALESCE((SELECT MIN(MerchantID) FROM Transactions WHERE UserID = Users.ID AND MerchantID > 0), 0)`},
+	{"Refunds", "MerchantID", `INTEGER NOT NULL DEFAULT 0`, `UPDATE Refunds SET MerchantID = COALESCE((SELECT MerchantID FROM Transactions WHERE ID = Refunds.PaymentID), 0)`},
+	{"ProcessingJobs", "MerchantID", `INTEGER NOT NULL DEFAULT 0`, `UPDATE ProcessingJobs SET MerchantID = COALESCE(
+		(SELECT MerchantID FROM Transactions WHERE ID = ProcessingJobs.PaymentID),
+		(SELECT MerchantID FROM Refunds WHERE ID = ProcessingJobs.RefundID), 0)`},
+	{"WebhookEndpoints", "MerchantID", `INTEGER NOT NULL DEFAULT 0`, ""},
+}
+
+// NewSqliteDB opens a sqlite database that stores times in UTC and reads them
+// back in local time. sqlite compares dates as text, so times written with
+// different offsets would not sort in the order of the instants they stand for.
+func NewSqliteDB(path string) (*sql.DB, error) {
+	db := sql.OpenDB(sqliteConnector{dsn: path + "?_foreign_keys=on&_loc=auto"})
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	// background workers share the database with handlers, sqlite allows a single writer
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+type sqliteConnector struct {
+	dsn string
+}
+
+func (c sqliteConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Driver().Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return sqliteConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+func (c sqliteConnector) Driver() driver.Driver {
+	return &sqlite3.SQLiteDriver{}
+}
+
+// sqliteConn converts the time arguments of every query to UTC.
+type sqliteConn struct {
+	*sqlite3.SQLiteConn
+}
+
+func (c sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.SQLiteConn.ExecContext(ctx, query, utcArgs(args))
+}
+
+func (c sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.SQLiteConn.QueryContext(ctx, query, utcArgs(args))
+}
+
+func (c sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.SQLiteConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return sqliteStmt{stmt.(*sqlite3.SQLiteStmt)}, nil
+}
+
+func (c sqliteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+type sqliteStmt struct {
+	*sqlite3.SQLiteStmt
+}
+
+func (s sqliteStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.SQLiteStmt.ExecContext(ctx, utcArgs(args))
+}
+
+func (s sqliteStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.SQLiteStmt.QueryContext(ctx, utcArgs(args))
+}
+
+func utcArgs(args []driver.NamedValue) []driver.NamedValue {
+	for i := range args {
+		if t, ok := args[i].Value.(time.Time); ok {
+			args[i].Value = t.UTC()
+		}
+	}
+	return args
+}
+
+// adoptSqlite upgrades the tables of a database created before versioned
+// migrations to those of the baseline migration, it reports false for a
+// database without tables. Older releases evolved the schema at every start
+// with these steps, they are frozen now and later changes are migrations.
+func adoptSqlite(db *sql.DB, baseline Migration) (bool, error) {
+	ok, err := tableExists(db, "Transactions")
+	if err != nil || !ok {
+		return false, err
+	}
+	stmts := splitStatements(baseline.Up)
+	for _, stmt := range stmts {
+		if !strings.HasPrefix(stmt, "CREATE TABLE") {
+			continue
+		}
+		if _, err := db.Exec(stmt); err != nil {
+			return false, err
+		}
+	}
+	err = upgradeAmounts(db)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range columns {
+		ok, err := columnExists(db, c.table, c.name)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			continue
+		}
+		_, err = db.Exec(`ALTER TABLE "` + c.table + `" ADD COLUMN "` + c.name + `" ` + c.definition)
+		if err != nil {
+			return false, err
+		}
+		if c.backfill == "" {
+			continue
+		}
+		_, err = db.Exec(c.backfill)
+		if err != nil {
+			return false, err
+		}
+	}
+	err = upgradeUsers(db)
+	if err != nil {
+		return false, err
+	}
+	// emails became unique per merchant
+	_, err = db.Exec(`DROP INDEX IF EXISTS "UsersByEmail"`)
 	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// upgradeUsers registers the users referenced by existing payments and
+// rebuilds Transactions with a foreign key to Users, sqlite can not add one
+// to an existing table.
+func upgradeUsers(db *sql.DB) error {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_foreign_key_list('Transactions') WHERE "table" = 'Users'`).Scan(&n)
+	if err != nil || n > 0 {
 		return err
 	}
-	_, err = db.Exec(`CREATE TABLE "Users" (
-		"ID"	INTEGER NOT NULL UNIQUE,
-		"Email"	TEXT,
-		PRIMARY KEY("ID" AUTOINCREMENT)
-	)`)
-	return err
+	_, err = db.Exec("PRAGMA foreign_keys = OFF")
+	if err != nil {
+		return err
+	}
+	defer db.Exec("PRAGMA foreign_keys = ON")
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmts := []string{
+		// an email used by several user IDs is kept by the first one only
+		`INSERT OR IGNORE INTO Users(ID,Email,CreatedAt,UpdatedAt)
+			SELECT UserID,LOWER(MIN(UserEmail)),MIN(CreationDate),MIN(CreationDate) FROM Transactions
+			WHERE UserID NOT IN (SELECT ID FROM Users) GROUP BY UserID ORDER BY UserID`,
+		`INSERT OR IGNORE INTO Users(ID,CreatedAt,UpdatedAt)
+			SELECT UserID,MIN(CreationDate),MIN(CreationDate) FROM Transactions
+			WHERE UserID NOT IN (SELECT ID FROM Users) GROUP BY UserID`,
+		fmt.Sprintf(transactionsTable, "TransactionsUpgrade"),
+		`INSERT INTO "TransactionsUpgrade"(` + paymentColumns + `) SELECT ` + paymentColumns + ` FROM "Transactions"`,
+		`DROP TABLE "Transactions"`,
+		`ALTER TABLE "TransactionsUpgrade" RENAME TO "Transactions"`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// upgradeAmounts moves databases created with the REAL "Sum" column
+// to integer minor units stored in "Amount".
+func upgradeAmounts(db *sql.DB) error {
+	ok, err := columnExists(db, "Transactions", "Amount")
+	if err != nil || ok {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`ALTER TABLE "Transactions" ADD COLUMN "Amount" INTEGER NOT NULL DEFAULT 0`)
+	if err != nil {
+		return err
+	}
+	rows, err := tx.Query("SELECT ID,COALESCE(Sum,0),COALESCE(Currency,'') FROM Transactions")
+	if err != nil {
+		return err
+	}
+	amounts := map[int]int64{}
+	for rows.Next() {
+		var id int
+		var sum float64
+		var currency string
+		if err := rows.Scan(&id, &sum, &currency); err != nil {
+			rows.Close()
+			return err
+		}
+		amounts[id] = money.FromFloat(sum, currency).Amount
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, amount := range amounts {
+		_, err = tx.Exec("UPDATE Transactions SET Amount = ? WHERE ID = ?", amount, id)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+func tableExists(db *sql.DB, table string) (bool, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&n)
+	return n > 0, err
 }
@@ -3,6 +3,9 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
 )
 
 type UserRepo struct {
@@ -15,17 +18,82 @@ func NewUserRepo(db *sql.DB) *UserRepo {
 	}
 }
 
-func (u *UserRepo) UserVerification(paymentID int, email string) (string, error) {
-	var res string
-	stmt, err := u.db.Prepare("SELECT UserEmail FROM Transactions WHERE ID = ? AND UserEmail = ?")
+// UserVerification returns the email of the payer of the payment.
+func (u *UserRepo) UserVerification(merchantID, paymentID int) (string, error) {
+	var res sql.NullString
+	err := u.db.QueryRow("SELECT UserEmail FROM Transactions WHERE ID = ? AND MerchantID = ?", paymentID, merchantID).Scan(&res)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrPaymentNotFound
+	}
+	return res.String, err
+}
+
+var (
+	ErrUserNotFound = fmt.Errorf("user %w", ErrNotFound)
+	ErrEmailTaken   = errors.New("email is already registered")
+)
+
+func (u *UserRepo) CreateUser(user models.User) (int, error) {
+	tx, err := u.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	err = emailFree(tx, user.MerchantID, user.Email, 0)
+	if err != nil {
+		return 0, err
+	}
+	id, err := insert(tx, "INSERT INTO Users(MerchantID,Email,Name,CreatedAt,UpdatedAt)VALUES(?,?,?,?,?)", user.MerchantID, user.Email, user.Name, user.CreatedAt, user.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (u *UserRepo) GetUser(merchantID, id int) (models.User, error) {
+	user := models.User{}
+	var created, updated sql.NullTime
+	err := u.db.QueryRow("SELECT ID,MerchantID,COALESCE(Email,''),Name,CreatedAt,UpdatedAt FROM Users WHERE ID = ? AND MerchantID = ?", id, merchantID).Scan(&user.ID, &user.MerchantID, &user.Email, &user.Name, &created, &updated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrUserNotFound
+	}
+	user.CreatedAt, user.UpdatedAt = created.Time, updated.Time
+	return user, err
+}
+
+func (u *UserRepo) UpdateUser(user models.User) error {
+	tx, err := u.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = emailFree(tx, user.MerchantID, user.Email, user.ID)
+	if err != nil {
+		return err
+	}
+	res, err := tx.Exec("UPDATE Users SET Email = ?,Name = ?,UpdatedAt = ? WHERE ID = ? AND MerchantID = ?", user.Email, user.Name, user.UpdatedAt, user.ID, user.MerchantID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+	return tx.Commit()
+}
+
+// emailFree checks that no user of the merchant other than id has the email.
+func emailFree(tx *sql.Tx, merchantID int, email string, id int) error {
+	var n int
+	err := tx.QueryRow("SELECT COUNT(*) FROM Users WHERE MerchantID = ? AND Email = ? AND ID != ?", merchantID, email, id).Scan(&n)
 	if err != nil {
-		return "", err
+		return err
 	}
-	row := stmt.QueryRow(paymentID, email)
-	row.Scan(&res)
-	if res == "" {
-		return "", errors.New("not found")
+	if n > 0 {
+		return ErrEmailTaken
 	}
-	defer stmt.Close()
-	return res, nil
+	return nil
 }
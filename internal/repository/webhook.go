@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+var (
+	ErrEndpointNotFound = fmt.Errorf("webhook endpoint %w", ErrNotFound)
+	ErrDeliveryNotFound = fmt.Errorf("webhook delivery %w", ErrNotFound)
+)
+
+type WebhookRepo struct {
+	db *sql.DB
+}
+
+func NewWebhookRepo(db *sql.DB) *WebhookRepo {
+	return &WebhookRepo{
+		db: db,
+	}
+}
+
+const endpointColumns = "ID,MerchantID,URL,Secret,PreviousSecret,PreviousSecretExpires,Events,SignatureFault,CreatedAt"
+
+const deliveryColumns = `d.ID,d.EndpointID,d.EventID,e.Type,d.Status,d.Attempts,d.NextAttempt,d.ResponseCode,d.LastError,e.Payload,d.CreatedAt,d.UpdatedAt,
+	w.ID,w.MerchantID,w.URL,w.Secret,w.PreviousSecret,w.PreviousSecretExpires,w.Events,w.SignatureFault,w.CreatedAt
+	FROM WebhookDeliveries d
+	JOIN WebhookEvents e ON e.ID = d.EventID
+	JOIN WebhookEndpoints w ON w.ID = d.EndpointID`
+
+func (w *WebhookRepo) CreateEndpoint(endpoint models.WebhookEndpoint) (int, error) {
+	return insert(w.db, "INSERT INTO WebhookEndpoints(MerchantID,URL,Secret,Events,SignatureFault,CreatedAt)VALUES(?,?,?,?,?,?)", endpoint.MerchantID, endpoint.URL, endpoint.Secret, strings.Join(endpoint.Events, ","), endpoint.SignatureFault, endpoint.CreatedAt)
+}
+
+func (w *WebhookRepo) Endpoints(merchantID int) ([]models.WebhookEndpoint, error) {
+	endpoints := []models.WebhookEndpoint{}
+	row, err := w.db.Query("SELECT "+endpointColumns+" FROM WebhookEndpoints WHERE MerchantID = ? ORDER BY ID", merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		endpoint, err := scanEndpoint(row)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, row.Err()
+}
+
+func (w *WebhookRepo) Endpoint(merchantID, id int) (models.WebhookEndpoint, error) {
+	row := w.db.QueryRow("SELECT "+endpointColumns+" FROM WebhookEndpoints WHERE ID = ? AND MerchantID = ?", id, merchantID)
+	endpoint, err := scanEndpoint(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.WebhookEndpoint{}, ErrEndpointNotFound
+	}
+	return endpoint, err
+}
+
+func (w *WebhookRepo) RotateSecret(merchantID, id int, secret string, previousExpires time.Time) error {
+	res, err := w.db.Exec("UPDATE WebhookEndpoints SET PreviousSecret = Secret,PreviousSecretExpires = ?,Secret = ? WHERE ID = ? AND MerchantID = ?", previousExpires, secret, id, merchantID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrEndpointNotFound
+	}
+	return nil
+}
+
+func (w *WebhookRepo) DeleteEndpoint(merchantID, id int) error {
+	res, err := w.db.Exec("DELETE FROM WebhookEndpoints WHERE ID = ? AND MerchantID = ?", id, merchantID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrEndpointNotFound
+	}
+	return nil
+}
+
+// CreateEvent stores the event and queues a delivery for every endpoint of the
+// payment's merchant subscribed to it.
+func (w *WebhookRepo) CreateEvent(event models.WebhookEvent) (int, error) {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	event.ID, err = insert(tx, "INSERT INTO WebhookEvents(Type,PaymentID,Payload,CreatedAt)VALUES(?,?,?,?)", event.Type, event.Data.ID, "", event.Created)
+	if err != nil {
+		return 0, err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("UPDATE WebhookEvents SET Payload = ? WHERE ID = ?", string(payload), event.ID)
+	if err != nil {
+		return 0, err
+	}
+	row, err := tx.Query("SELECT ID,Events FROM WebhookEndpoints WHERE MerchantID = ?", event.Data.MerchantID)
+	if err != nil {
+		return 0, err
+	}
+	subscribers := []int{}
+	for row.Next() {
+		var endpointID int
+		var events string
+		if err := row.Scan(&endpointID, &events); err != nil {
+			row.Close()
+			return 0, err
+		}
+		if subscribed(events, event.Type) {
+			subscribers = append(subscribers, endpointID)
+		}
+	}
+	row.Close()
+	if err := row.Err(); err != nil {
+		return 0, err
+	}
+	for _, endpointID := range subscribers {
+		_, err = insertDelivery(tx, endpointID, event.ID, event.Created)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return event.ID, tx.Commit()
+}
+
+func (w *WebhookRepo) DueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	return w.deliveries("SELECT "+deliveryColumns+" WHERE d.Status = ? AND d.NextAttempt <= ? ORDER BY d.NextAttempt, d.ID LIMIT ?", models.DeliveryPending, now, limit)
+}
+
+func (w *WebhookRepo) Deliveries(merchantID, endpointID int) ([]models.WebhookDelivery, error) {
+	return w.deliveries("SELECT "+deliveryColumns+" WHERE d.EndpointID = ? AND w.MerchantID = ? ORDER BY d.ID", endpointID, merchantID)
+}
+
+func (w *WebhookRepo) Delivery(merchantID, id int) (models.WebhookDelivery, error) {
+	deliveries, err := w.deliveries("SELECT "+deliveryColumns+" WHERE d.ID = ? AND w.MerchantID = ?", id, merchantID)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	if len(deliveries) == 0 {
+		return models.WebhookDelivery{}, ErrDeliveryNotFound
+	}
+	return deliveries[0], nil
+}
+
+func (w *WebhookRepo) UpdateDelivery(delivery models.WebhookDelivery) error {
+	_, err := w.db.Exec("UPDATE WebhookDeliveries SET Status = ?,Attempts = ?,NextAttempt = ?,ResponseCode = ?,LastError = ?,UpdatedAt = ? WHERE ID = ?",
+		delivery.Status, delivery.Attempts, delivery.NextAttempt, delivery.ResponseCode, delivery.LastError, delivery.UpdatedAt, delivery.ID)
+	return err
+}
+
+// Redeliver queues a new delivery of the same event so the original attempt stays in the log.
+func (w *WebhookRepo) Redeliver(merchantID, id int) (int, error) {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var endpointID, eventID int
+	err = tx.QueryRow(`SELECT d.EndpointID,d.EventID FROM WebhookDeliveries d
+		JOIN WebhookEndpoints w ON w.ID = d.EndpointID WHERE d.ID = ? AND w.MerchantID = ?`, id, merchantID).Scan(&endpointID, &eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	newID, err := insertDelivery(tx, endpointID, eventID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return newID, tx.Commit()
+}
+
+func (w *WebhookRepo) deliveries(query string, args ...interface{}) ([]models.WebhookDelivery, error) {
+	deliveries := []models.WebhookDelivery{}
+	row, err := w.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		d := models.WebhookDelivery{}
+		var payload string
+		var previousExpires sql.NullTime
+		var events string
+		err := row.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.NextAttempt, &d.ResponseCode, &d.LastError, &payload, &d.CreatedAt, &d.UpdatedAt,
+			&d.Endpoint.ID, &d.Endpoint.MerchantID, &d.Endpoint.URL, &d.Endpoint.Secret, &d.Endpoint.PreviousSecret, &previousExpires, &events, &d.Endpoint.SignatureFault, &d.Endpoint.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		setEndpointFields(&d.Endpoint, previousExpires, events)
+		d.Payload = json.RawMessage(payload)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, row.Err()
+}
+
+func insertDelivery(tx *sql.Tx, endpointID, eventID int, date time.Time) (int, error) {
+	return insert(tx, "INSERT INTO WebhookDeliveries(EndpointID,EventID,Status,NextAttempt,CreatedAt,UpdatedAt)VALUES(?,?,?,?,?,?)", endpointID, eventID, models.DeliveryPending, date, date, date)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanEndpoint(row scanner) (models.WebhookEndpoint, error) {
+	endpoint := models.WebhookEndpoint{}
+	var previousExpires sql.NullTime
+	var events string
+	err := row.Scan(&endpoint.ID, &endpoint.MerchantID, &endpoint.URL, &endpoint.Secret, &endpoint.PreviousSecret, &previousExpires, &events, &endpoint.SignatureFault, &endpoint.CreatedAt)
+	if err != nil {
+		return endpoint, err
+	}
+	setEndpointFields(&endpoint, previousExpires, events)
+	return endpoint, nil
+}
+
+func setEndpointFields(endpoint *models.WebhookEndpoint, previousExpires sql.NullTime, events string) {
+	if previousExpires.Valid {
+		endpoint.PreviousSecretExpires = &previousExpires.Time
+	}
+	endpoint.Events = []string{}
+	if events != "" {
+		endpoint.Events = strings.Split(events, ",")
+	}
+}
+
+func subscribed(events, eventType string) bool {
+	if events == "" {
+		return true
+	}
+	for _, e := range strings.Split(events, ",") {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
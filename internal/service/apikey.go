@@ -0,0 +1,127 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/helpers"
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+)
+
+const (
+	secretKeyPrefix      = "sk_test_"
+	publishableKeyPrefix = "pk_test_"
+	// keyPrefixLen characters of a key are kept in clear to tell keys apart
+	keyPrefixLen = 12
+)
+
+var (
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	ErrAPIKeyRevoked = errors.New("api key has been revoked")
+	ErrAdminDisabled = errors.New("admin api is disabled, set ADMIN_TOKEN to enable it")
+	ErrInvalidAdmin  = errors.New("invalid admin token")
+)
+
+type APIKeyService struct {
+	repo       repository.APIKey
+	merchants  repository.Merchant
+	adminToken string
+}
+
+func NewAPIKeyService(repo repository.APIKey, merchants repository.Merchant, adminToken string) *APIKeyService {
+	return &APIKeyService{
+		repo:       repo,
+		merchants:  merchants,
+		adminToken: adminToken,
+	}
+}
+
+// IssueAPIKey creates a secret and publishable key pair of the merchant. The
+// raw keys are in the returned value only, they can not be read back later.
+func (a *APIKeyService) IssueAPIKey(input models.APIKeyInput) (models.APIKey, error) {
+	vErr := &ValidationError{}
+	if input.MerchantID <= 0 {
+		vErr.Add("MerchantID", "is required")
+	} else if _, err := a.merchants.Merchant(input.MerchantID); errors.Is(err, repository.ErrMerchantNotFound) {
+		vErr.Add("MerchantID", "merchant not found")
+	} else if err != nil {
+		return models.APIKey{}, classify(err)
+	}
+	if err := vErr.Err(); err != nil {
+		return models.APIKey{}, err
+	}
+	secret, err := helpers.RandomToken(secretKeyPrefix, 24)
+	if err != nil {
+		return models.APIKey{}, classify(err)
+	}
+	publishable, err := helpers.RandomToken(publishableKeyPrefix, 24)
+	if err != nil {
+		return models.APIKey{}, classify(err)
+	}
+	key := models.APIKey{
+		MerchantID:        input.MerchantID,
+		Name:              strings.TrimSpace(input.Name),
+		SecretKey:         secret,
+		PublishableKey:    publishable,
+		SecretPrefix:      secret[:keyPrefixLen],
+		PublishablePrefix: publishable[:keyPrefixLen],
+		CreatedAt:         time.Now(),
+	}
+	key.ID, err = a.repo.CreateAPIKey(key, hashKey(secret), hashKey(publishable))
+	if err != nil {
+		return models.APIKey{}, classify(err)
+	}
+	return key, nil
+}
+
+// APIKeys lists the pairs of the merchant, every pair when merchantID is zero.
+func (a *APIKeyService) APIKeys(merchantID int) ([]models.APIKey, error) {
+	keys, err := a.repo.APIKeys(merchantID)
+	return keys, classify(err)
+}
+
+func (a *APIKeyService) RevokeAPIKey(id int) error {
+	return classify(a.repo.RevokeAPIKey(id, time.Now()))
+}
+
+func (a *APIKeyService) Authenticate(token string) (models.Principal, error) {
+	if !strings.HasPrefix(token, secretKeyPrefix) && !strings.HasPrefix(token, publishableKeyPrefix) {
+		return models.Principal{}, ErrInvalidAPIKey
+	}
+	key, kind, err := a.repo.APIKeyByHash(hashKey(token))
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return models.Principal{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return models.Principal{}, err
+	}
+	if key.RevokedAt != nil {
+		return models.Principal{}, ErrAPIKeyRevoked
+	}
+	return models.Principal{
+		MerchantID: key.MerchantID,
+		APIKeyID:   key.ID,
+		Kind:       kind,
+	}, nil
+}
+
+func (a *APIKeyService) AuthenticateAdmin(token string) error {
+	if a.adminToken == "" {
+		return ErrAdminDisabled
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(a.adminToken)) != 1 {
+		return ErrInvalidAdmin
+	}
+	return nil
+}
+
+// hashKey does not need a salt, keys are random and long enough.
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/altuxa/payment-service-emulator/internal/statemachine"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Add(field, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Field+": "+f.Message)
+	}
+	return "invalid input: " + strings.Join(msgs, ", ")
+}
+
+// NotFoundError reports a missing resource, resources of other merchants are
+// missing as well.
+type NotFoundError struct {
+	Err error
+}
+
+func (e *NotFoundError) Error() string { return e.Err.Error() }
+func (e *NotFoundError) Unwrap() error { return e.Err }
+
+// ConflictError reports a request the current state of a resource does not
+// allow, such as a forbidden status transition.
+type ConflictError struct {
+	Err error
+}
+
+func (e *ConflictError) Error() string { return e.Err.Error() }
+func (e *ConflictError) Unwrap() error { return e.Err }
+
+// InternalError is a failure the caller can not fix, its details are not
+// meant for clients.
+type InternalError struct {
+	Err error
+}
+
+func (e *InternalError) Error() string { return e.Err.Error() }
+func (e *InternalError) Unwrap() error { return e.Err }
+
+// classify turns the errors of repositories and the state machine into the
+// typed errors above, anything unknown is internal.
+func classify(err error) error {
+	var vErr *ValidationError
+	var notFound *NotFoundError
+	var conflict *ConflictError
+	var internal *InternalError
+	var transition *statemachine.TransitionError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &vErr), errors.As(err, &notFound), errors.As(err, &conflict), errors.As(err, &internal):
+		return err
+	case errors.Is(err, repository.ErrNotFound):
+		return &NotFoundError{Err: err}
+	case errors.As(err, &transition), errors.Is(err, repository.ErrStatusChanged), errors.Is(err, repository.ErrRefundNotPending):
+		return &ConflictError{Err: err}
+	default:
+		return &InternalError{Err: err}
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+)
+
+var (
+	ErrIdempotencyMismatch   = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyInProgress = errors.New("a request with this idempotency key is still in progress")
+)
+
+type IdempotencyService struct {
+	repo repository.Idempotency
+	ttl  time.Duration
+}
+
+func NewIdempotencyService(repo repository.Idempotency, ttl time.Duration) *IdempotencyService {
+	return &IdempotencyService{
+		repo: repo,
+		ttl:  ttl,
+	}
+}
+
+// Begin reserves key for a request with the given fingerprint. A nil record
+// means the caller now owns the key and must Complete or Release it, otherwise
+// the stored response of the original request is returned for replay.
+func (s *IdempotencyService) Begin(key, fingerprint string) (*models.IdempotencyRecord, error) {
+	now := time.Now()
+	record, reserved, err := s.repo.Reserve(models.IdempotencyRecord{
+		Key:         key,
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+	}, now.Add(-s.ttl))
+	if err != nil {
+		return nil, err
+	}
+	if reserved {
+		return nil, nil
+	}
+	if record.Fingerprint != fingerprint {
+		return nil, ErrIdempotencyMismatch
+	}
+	if record.ResponseCode == 0 {
+		return nil, ErrIdempotencyInProgress
+	}
+	return &record, nil
+}
+
+func (s *IdempotencyService) Complete(key string, code int, body []byte) error {
+	return s.repo.Complete(key, code, body)
+}
+
+func (s *IdempotencyService) Release(key string) error {
+	return s.repo.Release(key)
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/money"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+)
+
+type MerchantService struct {
+	repo       repository.Merchant
+	currencies *money.Registry
+}
+
+func NewMerchantService(repo repository.Merchant, currencies *money.Registry) *MerchantService {
+	return &MerchantService{
+		repo:       repo,
+		currencies: currencies,
+	}
+}
+
+func (m *MerchantService) CreateMerchant(input models.MerchantInput) (models.Merchant, error) {
+	now := time.Now()
+	merchant := models.Merchant{
+		Currencies: []string{},
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if input.Name == nil {
+		input.Name = new(string)
+	}
+	m.apply(&merchant, input)
+	err := m.validate(merchant)
+	if err != nil {
+		return models.Merchant{}, classify(err)
+	}
+	merchant.ID, err = m.repo.CreateMerchant(merchant)
+	if err != nil {
+		return models.Merchant{}, classify(err)
+	}
+	return merchant, nil
+}
+
+func (m *MerchantService) Merchants() ([]models.Merchant, error) {
+	merchants, err := m.repo.Merchants()
+	return merchants, classify(err)
+}
+
+func (m *MerchantService) GetMerchant(id int) (models.Merchant, error) {
+	merchant, err := m.repo.Merchant(id)
+	return merchant, classify(err)
+}
+
+// UpdateMerchant changes the fields given in input and keeps the rest, an
+// empty Currencies list allows every currency again.
+func (m *MerchantService) UpdateMerchant(id int, input models.MerchantInput) (models.Merchant, error) {
+	merchant, err := m.repo.Merchant(id)
+	if err != nil {
+		return models.Merchant{}, classify(err)
+	}
+	m.apply(&merchant, input)
+	err = m.validate(merchant)
+	if err != nil {
+		return models.Merchant{}, classify(err)
+	}
+	merchant.UpdatedAt = time.Now()
+	err = m.repo.UpdateMerchant(merchant)
+	if err != nil {
+		return models.Merchant{}, classify(err)
+	}
+	return merchant, nil
+}
+
+func (m *MerchantService) apply(merchant *models.Merchant, input models.MerchantInput) {
+	if input.Name != nil {
+		merchant.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Currencies != nil {
+		merchant.Currencies = make([]string, 0, len(input.Currencies))
+		for _, code := range input.Currencies {
+			merchant.Currencies = append(merchant.Currencies, strings.ToUpper(strings.TrimSpace(code)))
+		}
+	}
+	if input.ErrorRate != nil {
+		merchant.ErrorRate = input.ErrorRate
+	}
+	if input.FailRate != nil {
+		merchant.FailRate = input.FailRate
+	}
+	if input.RefundFailRate != nil {
+		merchant.RefundFailRate = input.RefundFailRate
+	}
+}
+
+func (m *MerchantService) validate(merchant models.Merchant) error {
+	vErr := &ValidationError{}
+	if merchant.Name == "" {
+		vErr.Add("Name", "is required")
+	}
+	for _, code := range merchant.Currencies {
+		if _, ok := m.currencies.Lookup(code); !ok {
+			vErr.Add("Currencies", fmt.Sprintf("%q is not a supported ISO 4217 currency code", code))
+		}
+	}
+	rates := []struct {
+		field string
+		rate  *float64
+	}{
+		{"ErrorRate", merchant.ErrorRate},
+		{"FailRate", merchant.FailRate},
+		{"RefundFailRate", merchant.RefundFailRate},
+	}
+	for _, r := range rates {
+		if r.rate != nil && (*r.rate < 0 || *r.rate > 1) {
+			vErr.Add(r.field, "must be between 0 and 1")
+		}
+	}
+	return vErr.Err()
+}
+
+// merchantSettings loads the merchant owning data, payments created before
+// merchants existed belong to no merchant and get the defaults.
+func merchantSettings(repo repository.Merchant, id int) (models.Merchant, error) {
+	merchant, err := repo.Merchant(id)
+	if errors.Is(err, repository.ErrMerchantNotFound) {
+		return models.Merchant{ID: id}, nil
+	}
+	return merchant, err
+}
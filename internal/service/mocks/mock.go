@@ -5,7 +5,9 @@
 package mock_service
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	models "github.com/altuxa/payment-service-emulator/internal/models"
 	gomock "github.com/golang/mock/gomock"
@@ -34,19 +36,296 @@ func (m *MockUser) EXPECT() *MockUserMockRecorder {
 	return m.recorder
 }
 
+// CreateUser mocks base method.
+func (m *MockUser) CreateUser(merchantID int, input models.UserInput) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", merchantID, input)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockUserMockRecorder) CreateUser(merchantID, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUser)(nil).CreateUser), merchantID, input)
+}
+
+// GetUser mocks base method.
+func (m *MockUser) GetUser(merchantID, id int) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", merchantID, id)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockUserMockRecorder) GetUser(merchantID, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUser)(nil).GetUser), merchantID, id)
+}
+
+// UpdateUser mocks base method.
+func (m *MockUser) UpdateUser(merchantID, id int, input models.UserInput) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", merchantID, id, input)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockUserMockRecorder) UpdateUser(merchantID, id, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockUser)(nil).UpdateUser), merchantID, id, input)
+}
+
 // Verification mocks base method.
-func (m *MockUser) Verification(payId int, email string) (bool, error) {
+func (m *MockUser) Verification(merchantID, payId int, email string) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Verification", payId, email)
+	ret := m.ctrl.Call(m, "Verification", merchantID, payId, email)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Verification indicates an expected call of Verification.
-func (mr *MockUserMockRecorder) Verification(payId, email interface{}) *gomock.Call {
+func (mr *MockUserMockRecorder) Verification(merchantID, payId, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verification", reflect.TypeOf((*MockUser)(nil).Verification), merchantID, payId, email)
+}
+
+// MockMerchant is a mock of Merchant interface.
+type MockMerchant struct {
+	ctrl     *gomock.Controller
+	recorder *MockMerchantMockRecorder
+}
+
+// MockMerchantMockRecorder is the mock recorder for MockMerchant.
+type MockMerchantMockRecorder struct {
+	mock *MockMerchant
+}
+
+// NewMockMerchant creates a new mock instance.
+func NewMockMerchant(ctrl *gomock.Controller) *MockMerchant {
+	mock := &MockMerchant{ctrl: ctrl}
+	mock.recorder = &MockMerchantMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMerchant) EXPECT() *MockMerchantMockRecorder {
+	return m.recorder
+}
+
+// CreateMerchant mocks base method.
+func (m *MockMerchant) CreateMerchant(input models.MerchantInput) (models.Merchant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMerchant", input)
+	ret0, _ := ret[0].(models.Merchant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateMerchant indicates an expected call of CreateMerchant.
+func (mr *MockMerchantMockRecorder) CreateMerchant(input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMerchant", reflect.TypeOf((*MockMerchant)(nil).CreateMerchant), input)
+}
+
+// GetMerchant mocks base method.
+func (m *MockMerchant) GetMerchant(id int) (models.Merchant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMerchant", id)
+	ret0, _ := ret[0].(models.Merchant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMerchant indicates an expected call of GetMerchant.
+func (mr *MockMerchantMockRecorder) GetMerchant(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMerchant", reflect.TypeOf((*MockMerchant)(nil).GetMerchant), id)
+}
+
+// Merchants mocks base method.
+func (m *MockMerchant) Merchants() ([]models.Merchant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Merchants")
+	ret0, _ := ret[0].([]models.Merchant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Merchants indicates an expected call of Merchants.
+func (mr *MockMerchantMockRecorder) Merchants() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Merchants", reflect.TypeOf((*MockMerchant)(nil).Merchants))
+}
+
+// UpdateMerchant mocks base method.
+func (m *MockMerchant) UpdateMerchant(id int, input models.MerchantInput) (models.Merchant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateMerchant", id, input)
+	ret0, _ := ret[0].(models.Merchant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateMerchant indicates an expected call of UpdateMerchant.
+func (mr *MockMerchantMockRecorder) UpdateMerchant(id, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMerchant", reflect.TypeOf((*MockMerchant)(nil).UpdateMerchant), id, input)
+}
+
+// MockAPIKey is a mock of APIKey interface.
+type MockAPIKey struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIKeyMockRecorder
+}
+
+// MockAPIKeyMockRecorder is the mock recorder for MockAPIKey.
+type MockAPIKeyMockRecorder struct {
+	mock *MockAPIKey
+}
+
+// NewMockAPIKey creates a new mock instance.
+func NewMockAPIKey(ctrl *gomock.Controller) *MockAPIKey {
+	mock := &MockAPIKey{ctrl: ctrl}
+	mock.recorder = &MockAPIKeyMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPIKey) EXPECT() *MockAPIKeyMockRecorder {
+	return m.recorder
+}
+
+// APIKeys mocks base method.
+func (m *MockAPIKey) APIKeys(merchantID int) ([]models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "APIKeys", merchantID)
+	ret0, _ := ret[0].([]models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// APIKeys indicates an expected call of APIKeys.
+func (mr *MockAPIKeyMockRecorder) APIKeys(merchantID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "APIKeys", reflect.TypeOf((*MockAPIKey)(nil).APIKeys), merchantID)
+}
+
+// Authenticate mocks base method.
+func (m *MockAPIKey) Authenticate(token string) (models.Principal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Authenticate", token)
+	ret0, _ := ret[0].(models.Principal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Authenticate indicates an expected call of Authenticate.
+func (mr *MockAPIKeyMockRecorder) Authenticate(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authenticate", reflect.TypeOf((*MockAPIKey)(nil).Authenticate), token)
+}
+
+// AuthenticateAdmin mocks base method.
+func (m *MockAPIKey) AuthenticateAdmin(token string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthenticateAdmin", token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AuthenticateAdmin indicates an expected call of AuthenticateAdmin.
+func (mr *MockAPIKeyMockRecorder) AuthenticateAdmin(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateAdmin", reflect.TypeOf((*MockAPIKey)(nil).AuthenticateAdmin), token)
+}
+
+// IssueAPIKey mocks base method.
+func (m *MockAPIKey) IssueAPIKey(input models.APIKeyInput) (models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IssueAPIKey", input)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IssueAPIKey indicates an expected call of IssueAPIKey.
+func (mr *MockAPIKeyMockRecorder) IssueAPIKey(input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssueAPIKey", reflect.TypeOf((*MockAPIKey)(nil).IssueAPIKey), input)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockAPIKey) RevokeAPIKey(id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockAPIKeyMockRecorder) RevokeAPIKey(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockAPIKey)(nil).RevokeAPIKey), id)
+}
+
+// MockToken is a mock of Token interface.
+type MockToken struct {
+	ctrl     *gomock.Controller
+	recorder *MockTokenMockRecorder
+}
+
+// MockTokenMockRecorder is the mock recorder for MockToken.
+type MockTokenMockRecorder struct {
+	mock *MockToken
+}
+
+// NewMockToken creates a new mock instance.
+func NewMockToken(ctrl *gomock.Controller) *MockToken {
+	mock := &MockToken{ctrl: ctrl}
+	mock.recorder = &MockTokenMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockToken) EXPECT() *MockTokenMockRecorder {
+	return m.recorder
+}
+
+// AuthenticateToken mocks base method.
+func (m *MockToken) AuthenticateToken(token string) (models.Principal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthenticateToken", token)
+	ret0, _ := ret[0].(models.Principal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuthenticateToken indicates an expected call of AuthenticateToken.
+func (mr *MockTokenMockRecorder) AuthenticateToken(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateToken", reflect.TypeOf((*MockToken)(nil).AuthenticateToken), token)
+}
+
+// MintToken mocks base method.
+func (m *MockToken) MintToken(input models.TokenInput) (models.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MintToken", input)
+	ret0, _ := ret[0].(models.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MintToken indicates an expected call of MintToken.
+func (mr *MockTokenMockRecorder) MintToken(input interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verification", reflect.TypeOf((*MockUser)(nil).Verification), payId, email)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MintToken", reflect.TypeOf((*MockToken)(nil).MintToken), input)
 }
 
 // MockPayment is a mock of Payment interface.
@@ -73,53 +352,68 @@ func (m *MockPayment) EXPECT() *MockPaymentMockRecorder {
 }
 
 // ByUserEmail mocks base method.
-func (m *MockPayment) ByUserEmail(email string) ([]models.Transaction, error) {
+func (m *MockPayment) ByUserEmail(merchantID int, email string) ([]models.Transaction, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ByUserEmail", email)
+	ret := m.ctrl.Call(m, "ByUserEmail", merchantID, email)
 	ret0, _ := ret[0].([]models.Transaction)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ByUserEmail indicates an expected call of ByUserEmail.
-func (mr *MockPaymentMockRecorder) ByUserEmail(email interface{}) *gomock.Call {
+func (mr *MockPaymentMockRecorder) ByUserEmail(merchantID, email interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ByUserEmail", reflect.TypeOf((*MockPayment)(nil).ByUserEmail), email)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ByUserEmail", reflect.TypeOf((*MockPayment)(nil).ByUserEmail), merchantID, email)
 }
 
 // ByUserID mocks base method.
-func (m *MockPayment) ByUserID(userID int) ([]models.Transaction, error) {
+func (m *MockPayment) ByUserID(merchantID, userID int) ([]models.Transaction, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ByUserID", userID)
+	ret := m.ctrl.Call(m, "ByUserID", merchantID, userID)
 	ret0, _ := ret[0].([]models.Transaction)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ByUserID indicates an expected call of ByUserID.
-func (mr *MockPaymentMockRecorder) ByUserID(userID interface{}) *gomock.Call {
+func (mr *MockPaymentMockRecorder) ByUserID(merchantID, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ByUserID", reflect.TypeOf((*MockPayment)(nil).ByUserID), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ByUserID", reflect.TypeOf((*MockPayment)(nil).ByUserID), merchantID, userID)
 }
 
 // CancelPayment mocks base method.
-func (m *MockPayment) CancelPayment(paymentId int) error {
+func (m *MockPayment) CancelPayment(merchantID, paymentId int, input models.CancelInput) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CancelPayment", paymentId)
+	ret := m.ctrl.Call(m, "CancelPayment", merchantID, paymentId, input)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CancelPayment indicates an expected call of CancelPayment.
-func (mr *MockPaymentMockRecorder) CancelPayment(paymentId interface{}) *gomock.Call {
+func (mr *MockPaymentMockRecorder) CancelPayment(merchantID, paymentId, input interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelPayment", reflect.TypeOf((*MockPayment)(nil).CancelPayment), paymentId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelPayment", reflect.TypeOf((*MockPayment)(nil).CancelPayment), merchantID, paymentId, input)
+}
+
+// Capture mocks base method.
+func (m *MockPayment) Capture(merchantID, paymentId int, input models.CaptureInput) (models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Capture", merchantID, paymentId, input)
+	ret0, _ := ret[0].(models.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Capture indicates an expected call of Capture.
+func (mr *MockPaymentMockRecorder) Capture(merchantID, paymentId, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Capture", reflect.TypeOf((*MockPayment)(nil).Capture), merchantID, paymentId, input)
 }
 
 // CreatePayment mocks base method.
-func (m *MockPayment) CreatePayment(id int, email string, sum float64, val string) (int, string, error) {
+func (m *MockPayment) CreatePayment(payment models.Transaction) (int, string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreatePayment", id, email, sum, val)
+	ret := m.ctrl.Call(m, "CreatePayment", payment)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(string)
 	ret2, _ := ret[2].(error)
@@ -127,37 +421,623 @@ func (m *MockPayment) CreatePayment(id int, email string, sum float64, val strin
 }
 
 // CreatePayment indicates an expected call of CreatePayment.
-func (mr *MockPaymentMockRecorder) CreatePayment(id, email, sum, val interface{}) *gomock.Call {
+func (mr *MockPaymentMockRecorder) CreatePayment(payment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePayment", reflect.TypeOf((*MockPayment)(nil).CreatePayment), payment)
+}
+
+// ExpireAuthorizations mocks base method.
+func (m *MockPayment) ExpireAuthorizations(now time.Time, limit int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireAuthorizations", now, limit)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireAuthorizations indicates an expected call of ExpireAuthorizations.
+func (mr *MockPaymentMockRecorder) ExpireAuthorizations(now, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireAuthorizations", reflect.TypeOf((*MockPayment)(nil).ExpireAuthorizations), now, limit)
+}
+
+// GetPayment mocks base method.
+func (m *MockPayment) GetPayment(merchantID, paymentId int) (models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPayment", merchantID, paymentId)
+	ret0, _ := ret[0].(models.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPayment indicates an expected call of GetPayment.
+func (mr *MockPaymentMockRecorder) GetPayment(merchantID, paymentId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPayment", reflect.TypeOf((*MockPayment)(nil).GetPayment), merchantID, paymentId)
+}
+
+// History mocks base method.
+func (m *MockPayment) History(merchantID, paymentId int) ([]models.PaymentEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "History", merchantID, paymentId)
+	ret0, _ := ret[0].([]models.PaymentEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// History indicates an expected call of History.
+func (mr *MockPaymentMockRecorder) History(merchantID, paymentId interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePayment", reflect.TypeOf((*MockPayment)(nil).CreatePayment), id, email, sum, val)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "History", reflect.TypeOf((*MockPayment)(nil).History), merchantID, paymentId)
+}
+
+// ListPayments mocks base method.
+func (m *MockPayment) ListPayments(merchantID int, input models.PaymentListInput) (models.PaymentList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPayments", merchantID, input)
+	ret0, _ := ret[0].(models.PaymentList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPayments indicates an expected call of ListPayments.
+func (mr *MockPaymentMockRecorder) ListPayments(merchantID, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPayments", reflect.TypeOf((*MockPayment)(nil).ListPayments), merchantID, input)
 }
 
 // PaymentProcessing mocks base method.
-func (m *MockPayment) PaymentProcessing(id int) (string, error) {
+func (m *MockPayment) PaymentProcessing(merchantID, id int) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "PaymentProcessing", id)
+	ret := m.ctrl.Call(m, "PaymentProcessing", merchantID, id)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // PaymentProcessing indicates an expected call of PaymentProcessing.
-func (mr *MockPaymentMockRecorder) PaymentProcessing(id interface{}) *gomock.Call {
+func (mr *MockPaymentMockRecorder) PaymentProcessing(merchantID, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PaymentProcessing", reflect.TypeOf((*MockPayment)(nil).PaymentProcessing), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PaymentProcessing", reflect.TypeOf((*MockPayment)(nil).PaymentProcessing), merchantID, id)
 }
 
 // PaymentStatus mocks base method.
-func (m *MockPayment) PaymentStatus(paymentId int) (string, error) {
+func (m *MockPayment) PaymentStatus(merchantID, paymentId int) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "PaymentStatus", paymentId)
+	ret := m.ctrl.Call(m, "PaymentStatus", merchantID, paymentId)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // PaymentStatus indicates an expected call of PaymentStatus.
-func (mr *MockPaymentMockRecorder) PaymentStatus(paymentId interface{}) *gomock.Call {
+func (mr *MockPaymentMockRecorder) PaymentStatus(merchantID, paymentId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PaymentStatus", reflect.TypeOf((*MockPayment)(nil).PaymentStatus), merchantID, paymentId)
+}
+
+// PurgeCancelled mocks base method.
+func (m *MockPayment) PurgeCancelled(before time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeCancelled", before)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeCancelled indicates an expected call of PurgeCancelled.
+func (mr *MockPaymentMockRecorder) PurgeCancelled(before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeCancelled", reflect.TypeOf((*MockPayment)(nil).PurgeCancelled), before)
+}
+
+// Void mocks base method.
+func (m *MockPayment) Void(merchantID, paymentId int) (models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Void", merchantID, paymentId)
+	ret0, _ := ret[0].(models.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Void indicates an expected call of Void.
+func (mr *MockPaymentMockRecorder) Void(merchantID, paymentId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Void", reflect.TypeOf((*MockPayment)(nil).Void), merchantID, paymentId)
+}
+
+// MockRefund is a mock of Refund interface.
+type MockRefund struct {
+	ctrl     *gomock.Controller
+	recorder *MockRefundMockRecorder
+}
+
+// MockRefundMockRecorder is the mock recorder for MockRefund.
+type MockRefundMockRecorder struct {
+	mock *MockRefund
+}
+
+// NewMockRefund creates a new mock instance.
+func NewMockRefund(ctrl *gomock.Controller) *MockRefund {
+	mock := &MockRefund{ctrl: ctrl}
+	mock.recorder = &MockRefundMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRefund) EXPECT() *MockRefundMockRecorder {
+	return m.recorder
+}
+
+// CreateRefund mocks base method.
+func (m *MockRefund) CreateRefund(merchantID, paymentId int, input models.RefundInput) (models.Refund, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRefund", merchantID, paymentId, input)
+	ret0, _ := ret[0].(models.Refund)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateRefund indicates an expected call of CreateRefund.
+func (mr *MockRefundMockRecorder) CreateRefund(merchantID, paymentId, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRefund", reflect.TypeOf((*MockRefund)(nil).CreateRefund), merchantID, paymentId, input)
+}
+
+// GetRefund mocks base method.
+func (m *MockRefund) GetRefund(merchantID, id int) (models.Refund, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRefund", merchantID, id)
+	ret0, _ := ret[0].(models.Refund)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefund indicates an expected call of GetRefund.
+func (mr *MockRefundMockRecorder) GetRefund(merchantID, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefund", reflect.TypeOf((*MockRefund)(nil).GetRefund), merchantID, id)
+}
+
+// ProcessRefund mocks base method.
+func (m *MockRefund) ProcessRefund(merchantID, refundId int) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProcessRefund", merchantID, refundId)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProcessRefund indicates an expected call of ProcessRefund.
+func (mr *MockRefundMockRecorder) ProcessRefund(merchantID, refundId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessRefund", reflect.TypeOf((*MockRefund)(nil).ProcessRefund), merchantID, refundId)
+}
+
+// Refunds mocks base method.
+func (m *MockRefund) Refunds(merchantID, paymentId int) ([]models.Refund, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refunds", merchantID, paymentId)
+	ret0, _ := ret[0].([]models.Refund)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Refunds indicates an expected call of Refunds.
+func (mr *MockRefundMockRecorder) Refunds(merchantID, paymentId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refunds", reflect.TypeOf((*MockRefund)(nil).Refunds), merchantID, paymentId)
+}
+
+// MockPublisher is a mock of Publisher interface.
+type MockPublisher struct {
+	ctrl     *gomock.Controller
+	recorder *MockPublisherMockRecorder
+}
+
+// MockPublisherMockRecorder is the mock recorder for MockPublisher.
+type MockPublisherMockRecorder struct {
+	mock *MockPublisher
+}
+
+// NewMockPublisher creates a new mock instance.
+func NewMockPublisher(ctrl *gomock.Controller) *MockPublisher {
+	mock := &MockPublisher{ctrl: ctrl}
+	mock.recorder = &MockPublisherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPublisher) EXPECT() *MockPublisherMockRecorder {
+	return m.recorder
+}
+
+// Publish mocks base method.
+func (m *MockPublisher) Publish(eventType string, payment models.Transaction) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Publish", eventType, payment)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockPublisherMockRecorder) Publish(eventType, payment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockPublisher)(nil).Publish), eventType, payment)
+}
+
+// MockWebhook is a mock of Webhook interface.
+type MockWebhook struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebhookMockRecorder
+}
+
+// MockWebhookMockRecorder is the mock recorder for MockWebhook.
+type MockWebhookMockRecorder struct {
+	mock *MockWebhook
+}
+
+// NewMockWebhook creates a new mock instance.
+func NewMockWebhook(ctrl *gomock.Controller) *MockWebhook {
+	mock := &MockWebhook{ctrl: ctrl}
+	mock.recorder = &MockWebhookMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebhook) EXPECT() *MockWebhookMockRecorder {
+	return m.recorder
+}
+
+// DeleteEndpoint mocks base method.
+func (m *MockWebhook) DeleteEndpoint(merchantID, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteEndpoint", merchantID, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteEndpoint indicates an expected call of DeleteEndpoint.
+func (mr *MockWebhookMockRecorder) DeleteEndpoint(merchantID, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEndpoint", reflect.TypeOf((*MockWebhook)(nil).DeleteEndpoint), merchantID, id)
+}
+
+// Deliver mocks base method.
+func (m *MockWebhook) Deliver(ctx context.Context, delivery models.WebhookDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deliver", ctx, delivery)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Deliver indicates an expected call of Deliver.
+func (mr *MockWebhookMockRecorder) Deliver(ctx, delivery interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deliver", reflect.TypeOf((*MockWebhook)(nil).Deliver), ctx, delivery)
+}
+
+// Deliveries mocks base method.
+func (m *MockWebhook) Deliveries(merchantID, endpointID int) ([]models.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deliveries", merchantID, endpointID)
+	ret0, _ := ret[0].([]models.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Deliveries indicates an expected call of Deliveries.
+func (mr *MockWebhookMockRecorder) Deliveries(merchantID, endpointID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deliveries", reflect.TypeOf((*MockWebhook)(nil).Deliveries), merchantID, endpointID)
+}
+
+// DueDeliveries mocks base method.
+func (m *MockWebhook) DueDeliveries(limit int) ([]models.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DueDeliveries", limit)
+	ret0, _ := ret[0].([]models.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DueDeliveries indicates an expected call of DueDeliveries.
+func (mr *MockWebhookMockRecorder) DueDeliveries(limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DueDeliveries", reflect.TypeOf((*MockWebhook)(nil).DueDeliveries), limit)
+}
+
+// Endpoints mocks base method.
+func (m *MockWebhook) Endpoints(merchantID int) ([]models.WebhookEndpoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Endpoints", merchantID)
+	ret0, _ := ret[0].([]models.WebhookEndpoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Endpoints indicates an expected call of Endpoints.
+func (mr *MockWebhookMockRecorder) Endpoints(merchantID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Endpoints", reflect.TypeOf((*MockWebhook)(nil).Endpoints), merchantID)
+}
+
+// Publish mocks base method.
+func (m *MockWebhook) Publish(eventType string, payment models.Transaction) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Publish", eventType, payment)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockWebhookMockRecorder) Publish(eventType, payment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockWebhook)(nil).Publish), eventType, payment)
+}
+
+// RegisterEndpoint mocks base method.
+func (m *MockWebhook) RegisterEndpoint(merchantID int, input models.WebhookEndpointInput) (models.WebhookEndpoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegisterEndpoint", merchantID, input)
+	ret0, _ := ret[0].(models.WebhookEndpoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegisterEndpoint indicates an expected call of RegisterEndpoint.
+func (mr *MockWebhookMockRecorder) RegisterEndpoint(merchantID, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterEndpoint", reflect.TypeOf((*MockWebhook)(nil).RegisterEndpoint), merchantID, input)
+}
+
+// Replay mocks base method.
+func (m *MockWebhook) Replay(merchantID, deliveryID int) (models.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replay", merchantID, deliveryID)
+	ret0, _ := ret[0].(models.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Replay indicates an expected call of Replay.
+func (mr *MockWebhookMockRecorder) Replay(merchantID, deliveryID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replay", reflect.TypeOf((*MockWebhook)(nil).Replay), merchantID, deliveryID)
+}
+
+// RotateSecret mocks base method.
+func (m *MockWebhook) RotateSecret(merchantID, id int) (models.WebhookEndpoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateSecret", merchantID, id)
+	ret0, _ := ret[0].(models.WebhookEndpoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RotateSecret indicates an expected call of RotateSecret.
+func (mr *MockWebhookMockRecorder) RotateSecret(merchantID, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateSecret", reflect.TypeOf((*MockWebhook)(nil).RotateSecret), merchantID, id)
+}
+
+// MockScheduler is a mock of Scheduler interface.
+type MockScheduler struct {
+	ctrl     *gomock.Controller
+	recorder *MockSchedulerMockRecorder
+}
+
+// MockSchedulerMockRecorder is the mock recorder for MockScheduler.
+type MockSchedulerMockRecorder struct {
+	mock *MockScheduler
+}
+
+// NewMockScheduler creates a new mock instance.
+func NewMockScheduler(ctrl *gomock.Controller) *MockScheduler {
+	mock := &MockScheduler{ctrl: ctrl}
+	mock.recorder = &MockSchedulerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockScheduler) EXPECT() *MockSchedulerMockRecorder {
+	return m.recorder
+}
+
+// Job mocks base method.
+func (m *MockScheduler) Job(kind string, merchantID int) models.Job {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Job", kind, merchantID)
+	ret0, _ := ret[0].(models.Job)
+	return ret0
+}
+
+// Job indicates an expected call of Job.
+func (mr *MockSchedulerMockRecorder) Job(kind, merchantID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Job", reflect.TypeOf((*MockScheduler)(nil).Job), kind, merchantID)
+}
+
+// Notify mocks base method.
+func (m *MockScheduler) Notify() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Notify")
+}
+
+// Notify indicates an expected call of Notify.
+func (mr *MockSchedulerMockRecorder) Notify() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Notify", reflect.TypeOf((*MockScheduler)(nil).Notify))
+}
+
+// MockQueue is a mock of Queue interface.
+type MockQueue struct {
+	ctrl     *gomock.Controller
+	recorder *MockQueueMockRecorder
+}
+
+// MockQueueMockRecorder is the mock recorder for MockQueue.
+type MockQueueMockRecorder struct {
+	mock *MockQueue
+}
+
+// NewMockQueue creates a new mock instance.
+func NewMockQueue(ctrl *gomock.Controller) *MockQueue {
+	mock := &MockQueue{ctrl: ctrl}
+	mock.recorder = &MockQueueMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQueue) EXPECT() *MockQueueMockRecorder {
+	return m.recorder
+}
+
+// Claim mocks base method.
+func (m *MockQueue) Claim(limit int) ([]models.Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Claim", limit)
+	ret0, _ := ret[0].([]models.Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Claim indicates an expected call of Claim.
+func (mr *MockQueueMockRecorder) Claim(limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Claim", reflect.TypeOf((*MockQueue)(nil).Claim), limit)
+}
+
+// Complete mocks base method.
+func (m *MockQueue) Complete(job models.Job, jobErr error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Complete", job, jobErr)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Complete indicates an expected call of Complete.
+func (mr *MockQueueMockRecorder) Complete(job, jobErr interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Complete", reflect.TypeOf((*MockQueue)(nil).Complete), job, jobErr)
+}
+
+// Job mocks base method.
+func (m *MockQueue) Job(kind string, merchantID int) models.Job {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Job", kind, merchantID)
+	ret0, _ := ret[0].(models.Job)
+	return ret0
+}
+
+// Job indicates an expected call of Job.
+func (mr *MockQueueMockRecorder) Job(kind, merchantID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Job", reflect.TypeOf((*MockQueue)(nil).Job), kind, merchantID)
+}
+
+// Notify mocks base method.
+func (m *MockQueue) Notify() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Notify")
+}
+
+// Notify indicates an expected call of Notify.
+func (mr *MockQueueMockRecorder) Notify() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Notify", reflect.TypeOf((*MockQueue)(nil).Notify))
+}
+
+// Recover mocks base method.
+func (m *MockQueue) Recover() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Recover")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Recover indicates an expected call of Recover.
+func (mr *MockQueueMockRecorder) Recover() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Recover", reflect.TypeOf((*MockQueue)(nil).Recover))
+}
+
+// Wake mocks base method.
+func (m *MockQueue) Wake() <-chan struct{} {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Wake")
+	ret0, _ := ret[0].(<-chan struct{})
+	return ret0
+}
+
+// Wake indicates an expected call of Wake.
+func (mr *MockQueueMockRecorder) Wake() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Wake", reflect.TypeOf((*MockQueue)(nil).Wake))
+}
+
+// MockIdempotency is a mock of Idempotency interface.
+type MockIdempotency struct {
+	ctrl     *gomock.Controller
+	recorder *MockIdempotencyMockRecorder
+}
+
+// MockIdempotencyMockRecorder is the mock recorder for MockIdempotency.
+type MockIdempotencyMockRecorder struct {
+	mock *MockIdempotency
+}
+
+// NewMockIdempotency creates a new mock instance.
+func NewMockIdempotency(ctrl *gomock.Controller) *MockIdempotency {
+	mock := &MockIdempotency{ctrl: ctrl}
+	mock.recorder = &MockIdempotencyMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIdempotency) EXPECT() *MockIdempotencyMockRecorder {
+	return m.recorder
+}
+
+// Begin mocks base method.
+func (m *MockIdempotency) Begin(key, fingerprint string) (*models.IdempotencyRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Begin", key, fingerprint)
+	ret0, _ := ret[0].(*models.IdempotencyRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Begin indicates an expected call of Begin.
+func (mr *MockIdempotencyMockRecorder) Begin(key, fingerprint interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Begin", reflect.TypeOf((*MockIdempotency)(nil).Begin), key, fingerprint)
+}
+
+// Complete mocks base method.
+func (m *MockIdempotency) Complete(key string, code int, body []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Complete", key, code, body)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Complete indicates an expected call of Complete.
+func (mr *MockIdempotencyMockRecorder) Complete(key, code, body interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Complete", reflect.TypeOf((*MockIdempotency)(nil).Complete), key, code, body)
+}
+
+// Release mocks base method.
+func (m *MockIdempotency) Release(key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Release", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockIdempotencyMockRecorder) Release(key interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PaymentStatus", reflect.TypeOf((*MockPayment)(nil).PaymentStatus), paymentId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockIdempotency)(nil).Release), key)
 }
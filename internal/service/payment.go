@@ -1,105 +1,551 @@
 package service
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/altuxa/payment-service-emulator/internal/helpers"
 	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/money"
 	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/altuxa/payment-service-emulator/internal/statemachine"
 )
 
 type PaymentService struct {
-	repo repository.Payment
+	repo       repository.Payment
+	users      repository.User
+	merchants  repository.Merchant
+	currencies *money.Registry
+	events     Publisher
+	scheduler  Scheduler
+	outcomes   helpers.OutcomeEngine
+	// authorizationTTL is how long a manual capture payment stays AUTHORIZED
+	authorizationTTL time.Duration
 }
 
-func NewPaymentService(repo repository.Payment) *PaymentService {
+func NewPaymentService(repo repository.Payment, users repository.User, merchants repository.Merchant, currencies *money.Registry, events Publisher, scheduler Scheduler, outcomes helpers.OutcomeEngine, authorizationTTL time.Duration) *PaymentService {
 	return &PaymentService{
-		repo: repo,
+		repo:             repo,
+		users:            users,
+		merchants:        merchants,
+		currencies:       currencies,
+		events:           events,
+		scheduler:        scheduler,
+		outcomes:         outcomes,
+		authorizationTTL: authorizationTTL,
 	}
 }
 
-func (p *PaymentService) CancelPayment(paymentId int) error {
-	status, err := p.repo.PaymentStatus(paymentId)
+func (p *PaymentService) CancelPayment(merchantID, paymentId int, input models.CancelInput) error {
+	status, err := p.repo.PaymentStatus(merchantID, paymentId)
 	if err != nil {
-		return err
+		return classify(err)
+	}
+	change := models.StatusChange{
+		From:   status,
+		To:     models.StatusCancelled,
+		Reason: input.Reason,
+		Actor:  input.Actor,
 	}
-	if status != models.StatusNew {
-		return fmt.Errorf("error payment status = %v", status)
+	if change.Reason == "" {
+		change.Reason = "cancelled by client"
 	}
-	err = p.repo.DeletePayment(paymentId)
+	if change.Actor == "" {
+		change.Actor = "client"
+	}
+	err = p.setStatus(merchantID, paymentId, change)
 	if err != nil {
-		return err
+		return classify(err)
 	}
+	p.publish(models.EventPaymentCancelled, merchantID, paymentId)
 	return nil
 }
 
-func (p *PaymentService) CreatePayment(id int, email string, sum float64, val string) (int, string, error) {
-	if id == 0 || email == "" || sum == 0 || val == "" {
-		return 0, "", errors.New("invalid input")
+// PurgeCancelled deletes payments cancelled before the given time.
+func (p *PaymentService) PurgeCancelled(before time.Time) (int, error) {
+	return p.repo.PurgeCancelled(before)
+}
+
+func (p *PaymentService) CreatePayment(input models.Transaction) (int, string, error) {
+	merchantID := input.MerchantID
+	merchant, err := merchantSettings(p.merchants, merchantID)
+	if err != nil {
+		return 0, "", classify(err)
 	}
-	err := helpers.ValidEmail(email)
+	err = p.validatePayment(merchant, input)
 	if err != nil {
-		return 0, "", fmt.Errorf("invalid email %w", err)
+		return 0, "", classify(err)
 	}
 	status := models.StatusNew
-	random := helpers.PaymentErrorImitation()
-	if !random {
-		status = models.StatusError
+	err = statemachine.Initial(status)
+	if err != nil {
+		return 0, "", classify(err)
 	}
-	paymentID, err := p.repo.NewPayment(id, email, sum, val, status)
+	payment := models.Transaction{
+		UserID:        input.UserID,
+		UserEmail:     input.UserEmail,
+		Sum:           input.Sum,
+		Status:        status,
+		CaptureMethod: input.CaptureMethod,
+		APIKeyID:      input.APIKeyID,
+		MerchantID:    merchantID,
+	}
+	if payment.CaptureMethod == "" {
+		payment.CaptureMethod = models.CaptureAutomatic
+	}
+	// the outcome is seeded by the payment ID, so every payment is stored
+	// with a job and the job of a rejected one has nothing left to do
+	job := p.scheduler.Job(models.JobPayment, merchantID)
+	paymentID, err := p.repo.NewPayment(payment, &job)
 	if err != nil {
-		return 0, status, err
+		return 0, status, classify(err)
+	}
+	payment.ID = paymentID
+	outcome := p.outcomes.Decide(helpers.StageCreation, payment, merchant.OutcomeRates)
+	if outcome.Status != status {
+		err = p.setStatus(merchantID, paymentID, models.StatusChange{
+			From:          status,
+			To:            outcome.Status,
+			Reason:        "rejected by processor",
+			DeclineReason: outcome.DeclineReason,
+		})
+		if err == nil {
+			status = outcome.Status
+		} else {
+			log.Printf("failed to reject payment %d, it is processed instead: %v", paymentID, err)
+		}
 	}
+	p.publish(models.EventPaymentCreated, merchantID, paymentID)
+	p.scheduler.Notify()
 	return paymentID, status, nil
 }
 
-func (p *PaymentService) PaymentProcessing(id int) (string, error) {
-	// time.Sleep(2 * time.Second)
-	status, err := p.repo.PaymentStatus(id)
+func (p *PaymentService) validatePayment(merchant models.Merchant, payment models.Transaction) error {
+	vErr := &ValidationError{}
+	email, sum := payment.UserEmail, payment.Sum
+	if payment.UserID <= 0 {
+		vErr.Add("UserID", "is required")
+	}
+	if email == "" {
+		vErr.Add("Email", "is required")
+	} else if err := helpers.ValidEmail(email); err != nil {
+		vErr.Add("Email", fmt.Sprintf("invalid email %v", err))
+	}
+	if payment.UserID > 0 {
+		user, err := p.users.GetUser(merchant.ID, payment.UserID)
+		switch {
+		case errors.Is(err, repository.ErrUserNotFound):
+			vErr.Add("UserID", "user not found")
+		case err != nil:
+			return err
+		case email != "" && !strings.EqualFold(user.Email, email):
+			vErr.Add("Email", "does not match the user account")
+		}
+	}
+	currency, ok := p.currencies.Lookup(sum.Currency)
+	switch {
+	case sum.Currency == "":
+		vErr.Add("Currency", "is required")
+	case !ok:
+		vErr.Add("Currency", fmt.Sprintf("%q is not a supported ISO 4217 currency code", sum.Currency))
+	case !merchant.AllowsCurrency(currency.Code):
+		vErr.Add("Currency", fmt.Sprintf("%s is not enabled for the merchant, allowed %s", currency.Code, strings.Join(merchant.Currencies, ", ")))
+	case sum.Amount <= 0:
+		vErr.Add("Sum", "must be greater than zero")
+	case sum.Amount < currency.Min || sum.Amount > currency.Max:
+		vErr.Add("Sum", fmt.Sprintf("must be between %s and %s %s", money.New(currency.Min, currency.Code), money.New(currency.Max, currency.Code), currency.Code))
+	}
+	switch payment.CaptureMethod {
+	case "", models.CaptureAutomatic, models.CaptureManual:
+	default:
+		vErr.Add("CaptureMethod", fmt.Sprintf("must be %s or %s", models.CaptureAutomatic, models.CaptureManual))
+	}
+	return vErr.Err()
+}
+
+func (p *PaymentService) PaymentProcessing(merchantID, id int) (string, error) {
+	payment, err := p.repo.GetPayment(merchantID, id)
 	if err != nil {
-		return "", fmt.Errorf("%w", err)
+		return "", classify(err)
 	}
-	if status != models.StatusNew {
-		return "", fmt.Errorf("incorrect %s payment status ", status)
+	// the payment was rejected at creation or already processed
+	if payment.Status != models.StatusNew && payment.Status != models.StatusProcessing {
+		return payment.Status, nil
 	}
-	succes := helpers.FailStatusImitation()
-	if succes {
-		err = p.repo.SetStatusSuccess(id)
+	// a job recovered after a crash finds the payment already PROCESSING and
+	// goes on to decide the outcome
+	if payment.Status == models.StatusNew {
+		err = p.setStatus(merchantID, id, models.StatusChange{
+			From:   payment.Status,
+			To:     models.StatusProcessing,
+			Reason: "processing started",
+		})
 		if err != nil {
-			return "", fmt.Errorf("%w", err)
+			return "", classify(err)
+		}
+	}
+	merchant, err := merchantSettings(p.merchants, merchantID)
+	if err != nil {
+		return "", classify(err)
+	}
+	outcome := p.outcomes.Decide(helpers.StageProcessing, payment, merchant.OutcomeRates)
+	change := models.StatusChange{
+		From:          models.StatusProcessing,
+		To:            outcome.Status,
+		Reason:        "declined by processor",
+		DeclineReason: outcome.DeclineReason,
+	}
+	event := models.EventPaymentFailed
+	switch {
+	case outcome.Status == models.StatusSuccess && payment.CaptureMethod == models.CaptureManual:
+		expires := time.Now().Add(p.authorizationTTL)
+		change.To, change.Reason, change.AuthorizationExpires = models.StatusAuthorized, "authorized by processor", &expires
+		event = models.EventPaymentAuthorized
+	case outcome.Status == models.StatusSuccess:
+		change.Reason, change.Captured = "approved by processor", &payment.Sum.Amount
+		event = models.EventPaymentSucceeded
+	}
+	err = p.setStatus(merchantID, id, change)
+	if err != nil {
+		return "", classify(err)
+	}
+	p.publish(event, merchantID, id)
+	return change.To, nil
+}
+
+func (p *PaymentService) Capture(merchantID, paymentId int, input models.CaptureInput) (models.Transaction, error) {
+	payment, err := p.authorized(merchantID, paymentId, models.StatusCaptured)
+	if err != nil {
+		return payment, classify(err)
+	}
+	amount := payment.Sum
+	if len(input.Amount) != 0 {
+		amount, err = money.Decode(input.Amount, payment.Sum.Currency)
+		vErr := &ValidationError{}
+		switch {
+		case err != nil:
+			vErr.Add("Amount", err.Error())
+		case amount.Amount <= 0:
+			vErr.Add("Amount", "must be greater than zero")
+		case amount.Amount > payment.Sum.Amount:
+			vErr.Add("Amount", fmt.Sprintf("must not exceed the authorized %s %s", payment.Sum, payment.Sum.Currency))
 		}
-		return models.StatusSuccess, nil
-	} else if !succes {
-		err = p.repo.SetStatusFail(id)
+		if err := vErr.Err(); err != nil {
+			return payment, err
+		}
+	}
+	reason := "captured by client"
+	if amount.Amount < payment.Sum.Amount {
+		reason = fmt.Sprintf("partially captured %s of %s by client", amount, payment.Sum)
+	}
+	err = p.setStatus(merchantID, paymentId, models.StatusChange{
+		From:     models.StatusAuthorized,
+		To:       models.StatusCaptured,
+		Reason:   reason,
+		Captured: &amount.Amount,
+	})
+	if err != nil {
+		return payment, classify(err)
+	}
+	p.publish(models.EventPaymentCaptured, merchantID, paymentId)
+	payment, err = p.repo.GetPayment(merchantID, paymentId)
+	return payment, classify(err)
+}
+
+func (p *PaymentService) Void(merchantID, paymentId int) (models.Transaction, error) {
+	payment, err := p.authorized(merchantID, paymentId, models.StatusVoided)
+	if err != nil {
+		return payment, classify(err)
+	}
+	err = p.setStatus(merchantID, paymentId, models.StatusChange{
+		From:   models.StatusAuthorized,
+		To:     models.StatusVoided,
+		Reason: "voided by client",
+	})
+	if err != nil {
+		return payment, classify(err)
+	}
+	p.publish(models.EventPaymentVoided, merchantID, paymentId)
+	payment, err = p.repo.GetPayment(merchantID, paymentId)
+	return payment, classify(err)
+}
+
+// authorized loads a payment about to leave AUTHORIZED, voiding it first
+// when the authorization has already expired.
+func (p *PaymentService) authorized(merchantID, paymentId int, to string) (models.Transaction, error) {
+	payment, err := p.repo.GetPayment(merchantID, paymentId)
+	if err != nil {
+		return payment, err
+	}
+	if payment.Status == models.StatusAuthorized && payment.AuthorizationExpires != nil && !payment.AuthorizationExpires.After(time.Now()) {
+		err = p.expire(merchantID, paymentId)
 		if err != nil {
-			return "", fmt.Errorf("%w", err)
+			return payment, err
 		}
-		return models.StatusFail, nil
+		return payment, &statemachine.TransitionError{From: models.StatusVoided, To: to}
+	}
+	return payment, statemachine.Transition(payment.Status, to)
+}
+
+func (p *PaymentService) ExpireAuthorizations(now time.Time, limit int) (int, error) {
+	payments, err := p.repo.ExpiredAuthorizations(now, limit)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, payment := range payments {
+		err = p.expire(payment.MerchantID, payment.ID)
+		if errors.Is(err, repository.ErrStatusChanged) {
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+func (p *PaymentService) expire(merchantID, paymentId int) error {
+	err := p.setStatus(merchantID, paymentId, models.StatusChange{
+		From:   models.StatusAuthorized,
+		To:     models.StatusVoided,
+		Reason: "authorization expired",
+	})
+	if err != nil {
+		return err
+	}
+	p.publish(models.EventPaymentVoided, merchantID, paymentId)
+	return nil
+}
+
+func (p *PaymentService) setStatus(merchantID, id int, change models.StatusChange) error {
+	err := statemachine.Transition(change.From, change.To)
+	if err != nil {
+		return err
+	}
+	if change.DeclineReason != "" {
+		change.Reason += ": " + change.DeclineReason
+	}
+	return p.repo.SetStatus(merchantID, id, change)
+}
+
+// publish notifies webhook subscribers, the status change itself is already
+// committed so a failure here is only logged.
+func (p *PaymentService) publish(eventType string, merchantID, paymentId int) {
+	payment, err := p.repo.GetPayment(merchantID, paymentId)
+	if err == nil {
+		err = p.events.Publish(eventType, payment)
+	}
+	if err != nil {
+		log.Printf("failed to publish %s for payment %d: %v", eventType, paymentId, err)
 	}
-	return "", nil
 }
 
-func (p *PaymentService) PaymentStatus(paymentId int) (string, error) {
-	status, err := p.repo.PaymentStatus(paymentId)
+func (p *PaymentService) GetPayment(merchantID, paymentId int) (models.Transaction, error) {
+	payment, err := p.repo.GetPayment(merchantID, paymentId)
+	return payment, classify(err)
+}
+
+func (p *PaymentService) PaymentStatus(merchantID, paymentId int) (string, error) {
+	status, err := p.repo.PaymentStatus(merchantID, paymentId)
 	if err != nil {
-		return "", err
+		return "", classify(err)
 	}
 	return status, nil
 }
 
-func (p *PaymentService) ByUserID(userID int) ([]models.Transaction, error) {
-	transactions, err := p.repo.GetAllPaymentsByUserID(userID)
+func (p *PaymentService) ByUserID(merchantID, userID int) ([]models.Transaction, error) {
+	transactions, err := p.repo.GetAllPaymentsByUserID(merchantID, userID)
 	if err != nil {
-		return nil, fmt.Errorf("%w", err)
+		return nil, classify(err)
 	}
 	return transactions, nil
 }
 
-func (p *PaymentService) ByUserEmail(email string) ([]models.Transaction, error) {
-	transactions, err := p.repo.GetAllPaymentsByEmail(email)
+func (p *PaymentService) ByUserEmail(merchantID int, email string) ([]models.Transaction, error) {
+	transactions, err := p.repo.GetAllPaymentsByEmail(merchantID, email)
 	if err != nil {
-		return nil, err
+		return nil, classify(err)
 	}
 	return transactions, nil
 }
+
+// Page sizes of ListPayments.
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+var sortOrders = []string{models.SortCreated, models.SortCreatedDesc, models.SortAmount, models.SortAmountDesc}
+
+// ListPayments returns a page of the merchant's payments, newest first unless
+// input.Sort says otherwise. An empty page is not an error.
+func (p *PaymentService) ListPayments(merchantID int, input models.PaymentListInput) (models.PaymentList, error) {
+	list := models.PaymentList{Data: []models.Transaction{}}
+	filter, err := p.paymentFilter(input)
+	if err != nil {
+		return list, classify(err)
+	}
+	limit := filter.Limit
+	filter.Limit++
+	payments, err := p.repo.ListPayments(merchantID, filter)
+	if err != nil {
+		return list, classify(err)
+	}
+	if len(payments) > limit {
+		payments = payments[:limit]
+		last := payments[limit-1]
+		cursor := models.PaymentCursor{Sort: filter.Sort, ID: last.ID}
+		if filter.Sort == models.SortAmount || filter.Sort == models.SortAmountDesc {
+			cursor.Amount = last.Sum.Amount
+		} else {
+			cursor.Created = last.CreationDate
+		}
+		list.HasMore, list.NextCursor = true, encodeCursor(cursor)
+	}
+	list.Data = append(list.Data, payments...)
+	return list, nil
+}
+
+// paymentFilter validates the query of a listing, errors name the query
+// parameters.
+func (p *PaymentService) paymentFilter(input models.PaymentListInput) (models.PaymentFilter, error) {
+	vErr := &ValidationError{}
+	filter := models.PaymentFilter{
+		Sort:  input.Sort,
+		Limit: defaultListLimit,
+		Email: input.Email,
+	}
+	if filter.Sort == "" {
+		filter.Sort = models.SortCreatedDesc
+	}
+	known := false
+	for _, order := range sortOrders {
+		known = known || order == filter.Sort
+	}
+	if !known {
+		vErr.Add("sort", "must be one of "+strings.Join(sortOrders, ", "))
+	}
+	if input.Limit != "" {
+		limit, err := strconv.Atoi(input.Limit)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			vErr.Add("limit", fmt.Sprintf("must be a number between 1 and %d", maxListLimit))
+		}
+		filter.Limit = limit
+	}
+	if input.Status != "" {
+		for _, status := range strings.Split(strings.ToUpper(input.Status), ",") {
+			status = strings.TrimSpace(status)
+			if !statemachine.Known(status) {
+				vErr.Add("status", fmt.Sprintf("unknown payment status %q", status))
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+	if input.Currency != "" {
+		currency, ok := p.currencies.Lookup(strings.ToUpper(input.Currency))
+		if !ok {
+			vErr.Add("currency", fmt.Sprintf("%q is not a supported ISO 4217 currency code", input.Currency))
+		}
+		filter.Currency = currency.Code
+	}
+	amount := func(field, value string) *int64 {
+		if value == "" {
+			return nil
+		}
+		if input.Currency == "" {
+			vErr.Add(field, "requires currency, amounts of different currencies are not comparable")
+			return nil
+		}
+		if filter.Currency == "" {
+			return nil
+		}
+		sum, err := money.Parse(value, filter.Currency)
+		if err != nil {
+			vErr.Add(field, err.Error())
+			return nil
+		}
+		return &sum.Amount
+	}
+	filter.MinAmount = amount("min_amount", input.MinAmount)
+	filter.MaxAmount = amount("max_amount", input.MaxAmount)
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MaxAmount < *filter.MinAmount {
+		vErr.Add("max_amount", "must not be less than min_amount")
+	}
+	date := func(field, value string) *time.Time {
+		if value == "" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			vErr.Add(field, "must be an RFC 3339 time such as 2006-01-02T15:04:05Z")
+			return nil
+		}
+		return &t
+	}
+	filter.CreatedFrom = date("created_from", input.CreatedFrom)
+	filter.CreatedTo = date("created_to", input.CreatedTo)
+	filter.ChangedFrom = date("changed_from", input.ChangedFrom)
+	filter.ChangedTo = date("changed_to", input.ChangedTo)
+	if input.UserID != "" {
+		userID, err := strconv.Atoi(input.UserID)
+		if err != nil || userID <= 0 {
+			vErr.Add("user_id", "must be a positive number")
+		}
+		filter.UserID = userID
+	}
+	if input.Cursor != "" {
+		cursor, err := decodeCursor(input.Cursor)
+		switch {
+		case err != nil:
+			vErr.Add("cursor", "is invalid")
+		case cursor.Sort != filter.Sort:
+			vErr.Add("cursor", fmt.Sprintf("belongs to sort %s, not %s", cursor.Sort, filter.Sort))
+		case (cursor.Sort == models.SortCreated || cursor.Sort == models.SortCreatedDesc) && cursor.Created.IsZero():
+			// issued before pages were kept in creation time order
+			vErr.Add("cursor", "is invalid")
+		}
+		filter.After = &cursor
+	}
+	return filter, vErr.Err()
+}
+
+// encodeCursor makes the position of a page opaque to clients.
+func encodeCursor(cursor models.PaymentCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (models.PaymentCursor, error) {
+	cursor := models.PaymentCursor{}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	if err == nil && cursor.ID <= 0 {
+		err = errors.New("cursor without a payment")
+	}
+	return cursor, err
+}
+
+func (p *PaymentService) History(merchantID, paymentId int) ([]models.PaymentEvent, error) {
+	events, err := p.repo.History(merchantID, paymentId)
+	if err != nil {
+		return nil, classify(err)
+	}
+	if len(events) == 0 {
+		_, err = p.repo.PaymentStatus(merchantID, paymentId)
+		if err != nil {
+			return nil, classify(err)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/helpers"
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/money"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stageOutcomes answers the listed stages with fixed outcomes, a payment is
+// otherwise kept NEW at creation and approved later.
+type stageOutcomes map[helpers.Stage]helpers.Outcome
+
+func (s stageOutcomes) Decide(stage helpers.Stage, payment models.Transaction, rates models.OutcomeRates) helpers.Outcome {
+	if outcome, ok := s[stage]; ok {
+		return outcome
+	}
+	if stage == helpers.StageCreation {
+		return helpers.Outcome{Status: models.StatusNew}
+	}
+	return helpers.Outcome{Status: models.StatusSuccess}
+}
+
+type testPayments struct {
+	repos    *repository.Repositories
+	payments *PaymentService
+	queue    *QueueService
+	user     models.User
+}
+
+func newTestPayments(t *testing.T, outcomes stageOutcomes, authorizationTTL time.Duration) testPayments {
+	repos := repository.NewMemoryRepository()
+	queue := NewQueueService(repos.Job, 0)
+	webhooks := NewWebhookService(repos.Webhook, WebhookConfig{})
+	user := models.User{MerchantID: 1, Email: "ann@example.com"}
+	var err error
+	user.ID, err = repos.CreateUser(user)
+	require.NoError(t, err)
+	return testPayments{
+		repos:    repos,
+		payments: NewPaymentService(repos.Payment, repos.User, repos.Merchant, money.NewRegistry(), webhooks, queue, outcomes, authorizationTTL),
+		queue:    queue,
+		user:     user,
+	}
+}
+
+func (p testPayments) create(t *testing.T, amount int64, captureMethod string) (int, string) {
+	id, status, err := p.payments.CreatePayment(models.Transaction{
+		UserID:        p.user.ID,
+		UserEmail:     p.user.Email,
+		Sum:           money.New(amount, "USD"),
+		CaptureMethod: captureMethod,
+		MerchantID:    p.user.MerchantID,
+	})
+	require.NoError(t, err)
+	return id, status
+}
+
+// authorize creates a manual capture payment of 10.00 USD and processes it.
+func (p testPayments) authorize(t *testing.T) int {
+	id, _ := p.create(t, 1000, models.CaptureManual)
+	status, err := p.payments.PaymentProcessing(1, id)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusAuthorized, status)
+	return id
+}
+
+func (p testPayments) assertStatus(t *testing.T, id int, status string) {
+	payment, err := p.repos.GetPayment(1, id)
+	require.NoError(t, err)
+	assert.Equal(t, status, payment.Status)
+}
+
+func TestCreatePaymentStoresJob(t *testing.T) {
+	p := newTestPayments(t, stageOutcomes{}, time.Hour)
+	id, status := p.create(t, 1000, "")
+	assert.Equal(t, models.StatusNew, status)
+
+	jobs, err := p.queue.Claim(10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, models.JobPayment, jobs[0].Kind)
+	assert.Equal(t, id, jobs[0].PaymentID)
+	status, err = p.payments.PaymentProcessing(1, id)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusSuccess, status)
+}
+
+func TestCreatePaymentRejected(t *testing.T) {
+	p := newTestPayments(t, stageOutcomes{helpers.StageCreation: {Status: models.StatusError}}, time.Hour)
+	id, status := p.create(t, 1000, "")
+	assert.Equal(t, models.StatusError, status)
+
+	// the job stored with the payment finds nothing left to do
+	jobs, err := p.queue.Claim(10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	status, err = p.payments.PaymentProcessing(1, jobs[0].PaymentID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusError, status)
+	history, err := p.repos.History(1, id)
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+}
+
+func TestExpireAuthorizations(t *testing.T) {
+	p := newTestPayments(t, stageOutcomes{}, time.Hour)
+	first, second := p.authorize(t), p.authorize(t)
+	expired, err := p.payments.ExpireAuthorizations(time.Now(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, expired)
+
+	expired, err = p.payments.ExpireAuthorizations(time.Now().Add(2*time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, expired)
+	for _, id := range []int{first, second} {
+		p.assertStatus(t, id, models.StatusVoided)
+		history, err := p.repos.History(1, id)
+		require.NoError(t, err)
+		assert.Equal(t, "authorization expired", history[len(history)-1].Reason)
+	}
+
+	_, err = p.payments.Capture(1, first, models.CaptureInput{})
+	var conflict *ConflictError
+	assert.True(t, errors.As(err, &conflict))
+}
+
+func TestCaptureExpiredAuthorization(t *testing.T) {
+	p := newTestPayments(t, stageOutcomes{}, -time.Minute)
+	id := p.authorize(t)
+	// the expirer has not run yet, the capture voids the payment itself
+	_, err := p.payments.Capture(1, id, models.CaptureInput{})
+	var conflict *ConflictError
+	require.True(t, errors.As(err, &conflict))
+	p.assertStatus(t, id, models.StatusVoided)
+}
+
+func TestCaptureAmount(t *testing.T) {
+	p := newTestPayments(t, stageOutcomes{}, time.Hour)
+	id := p.authorize(t)
+	tData := map[string]struct {
+		Amount   string
+		Expected string
+	}{
+		"above the sum": {Amount: `"10.01"`, Expected: "must not exceed the authorized 10.00 USD"},
+		"zero":          {Amount: `0`, Expected: "must be greater than zero"},
+		"negative":      {Amount: `"-1.00"`, Expected: "must be greater than zero"},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			_, err := p.payments.Capture(1, id, models.CaptureInput{Amount: json.RawMessage(v.Amount)})
+			var vErr *ValidationError
+			require.True(t, errors.As(err, &vErr))
+			assert.Equal(t, []FieldError{{Field: "Amount", Message: v.Expected}}, vErr.Fields)
+		})
+	}
+	p.assertStatus(t, id, models.StatusAuthorized)
+
+	payment, err := p.payments.Capture(1, id, models.CaptureInput{Amount: json.RawMessage(`"4.00"`)})
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCaptured, payment.Status)
+	assert.Equal(t, money.New(400, "USD"), payment.Captured)
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+)
+
+const (
+	// jobMaxAttempts bounds how often a job that failed for a reason that
+	// may pass is run
+	jobMaxAttempts = 5
+	jobBackoff     = time.Second
+	jobMaxBackoff  = time.Minute
+)
+
+type QueueService struct {
+	repo  repository.Job
+	delay time.Duration
+	wake  chan struct{}
+}
+
+func NewQueueService(repo repository.Job, delay time.Duration) *QueueService {
+	return &QueueService{
+		repo:  repo,
+		delay: delay,
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// Job returns a processing job that runs once the configured processing
+// delay has passed, the repository fills in the payment or refund it is
+// stored with.
+func (q *QueueService) Job(kind string, merchantID int) models.Job {
+	now := time.Now()
+	return models.Job{
+		Kind:       kind,
+		MerchantID: merchantID,
+		RunAt:      now.Add(q.delay),
+		CreatedAt:  now,
+	}
+}
+
+// Notify wakes the workers after a job was stored.
+func (q *QueueService) Notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *QueueService) Claim(limit int) ([]models.Job, error) {
+	return q.repo.ClaimDue(time.Now(), limit)
+}
+
+// Complete records the outcome of a claimed job. A job that failed for a
+// reason that may pass runs again with exponential backoff until it used
+// jobMaxAttempts, a missing record or a transition that is not allowed fails
+// it for good.
+func (q *QueueService) Complete(job models.Job, jobErr error) error {
+	now := time.Now()
+	job.Status, job.LastError, job.UpdatedAt = models.JobDone, "", now
+	switch {
+	case jobErr == nil:
+	case retryable(jobErr) && job.Attempts < jobMaxAttempts:
+		job.Status, job.LastError = models.JobPending, jobErr.Error()
+		job.RunAt = now.Add(retryBackoff(job.Attempts))
+	default:
+		job.Status, job.LastError = models.JobFailed, jobErr.Error()
+	}
+	return q.repo.FinishJob(job)
+}
+
+// retryable reports whether a job may succeed when run again, a status
+// changed concurrently is read afresh on the next run.
+func retryable(err error) bool {
+	var vErr *ValidationError
+	var notFound *NotFoundError
+	var conflict *ConflictError
+	switch {
+	case errors.Is(err, repository.ErrStatusChanged):
+		return true
+	case errors.As(err, &vErr), errors.As(err, &notFound), errors.As(err, &conflict):
+		return false
+	default:
+		return true
+	}
+}
+
+func retryBackoff(attempt int) time.Duration {
+	d := jobBackoff
+	for i := 1; i < attempt && d < jobMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > jobMaxBackoff {
+		d = jobMaxBackoff
+	}
+	return d
+}
+
+func (q *QueueService) Recover() error {
+	return q.repo.ResetRunning(time.Now())
+}
+
+// Wake signals that a job was scheduled, so workers need not wait for the next poll.
+func (q *QueueService) Wake() <-chan struct{} {
+	return q.wake
+}
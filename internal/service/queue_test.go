@@ -0,0 +1,122 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/altuxa/payment-service-emulator/internal/statemachine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueJob(t *testing.T) {
+	repo := repository.NewMemoryRepo()
+	q := NewQueueService(repo, 0)
+	payment := q.Job(models.JobPayment, 1)
+	payment.PaymentID = 7
+	_, err := repo.Enqueue(payment)
+	require.NoError(t, err)
+	refund := q.Job(models.JobRefund, 1)
+	refund.RefundID = 3
+	_, err = repo.Enqueue(refund)
+	require.NoError(t, err)
+	q.Notify()
+	select {
+	case <-q.Wake():
+	default:
+		t.Fatal("notify did not wake the workers")
+	}
+
+	jobs, err := q.Claim(10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, models.JobPayment, jobs[0].Kind)
+	assert.Equal(t, 7, jobs[0].PaymentID)
+	assert.Equal(t, models.JobRefund, jobs[1].Kind)
+	assert.Equal(t, 3, jobs[1].RefundID)
+	for _, job := range jobs {
+		assert.Equal(t, 1, job.MerchantID)
+		assert.Equal(t, models.JobRunning, job.Status)
+		assert.Equal(t, 1, job.Attempts)
+	}
+}
+
+func TestQueueDelay(t *testing.T) {
+	repo := repository.NewMemoryRepo()
+	q := NewQueueService(repo, time.Hour)
+	_, err := repo.Enqueue(q.Job(models.JobPayment, 1))
+	require.NoError(t, err)
+	jobs, err := q.Claim(10)
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+func TestQueueCompleteAndRecover(t *testing.T) {
+	repo := repository.NewMemoryRepo()
+	q := NewQueueService(repo, 0)
+	for id := 1; id <= 3; id++ {
+		job := q.Job(models.JobPayment, 1)
+		job.PaymentID = id
+		_, err := repo.Enqueue(job)
+		require.NoError(t, err)
+	}
+	jobs, err := q.Claim(10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 3)
+	require.NoError(t, q.Complete(jobs[0], nil))
+	require.NoError(t, q.Complete(jobs[1], &NotFoundError{Err: repository.ErrPaymentNotFound}))
+
+	// only the job still running when the process stopped is queued again
+	require.NoError(t, q.Recover())
+	recovered, err := q.Claim(10)
+	require.NoError(t, err)
+	require.Len(t, recovered, 1)
+	assert.Equal(t, jobs[2].ID, recovered[0].ID)
+	assert.Equal(t, 2, recovered[0].Attempts)
+}
+
+func TestQueueRetry(t *testing.T) {
+	repo := repository.NewMemoryRepo()
+	q := NewQueueService(repo, 0)
+	for id := 1; id <= 4; id++ {
+		job := q.Job(models.JobPayment, 1)
+		job.PaymentID = id
+		_, err := repo.Enqueue(job)
+		require.NoError(t, err)
+	}
+	jobs, err := q.Claim(10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 4)
+	require.NoError(t, q.Complete(jobs[0], &InternalError{Err: errors.New("database is locked")}))
+	require.NoError(t, q.Complete(jobs[1], &ConflictError{Err: repository.ErrStatusChanged}))
+	require.NoError(t, q.Complete(jobs[2], &ConflictError{Err: &statemachine.TransitionError{From: models.StatusSuccess, To: models.StatusProcessing}}))
+	exhausted := jobs[3]
+	exhausted.Attempts = jobMaxAttempts
+	require.NoError(t, q.Complete(exhausted, &InternalError{Err: errors.New("database is locked")}))
+
+	// retries wait for the backoff
+	retried, err := q.Claim(10)
+	require.NoError(t, err)
+	assert.Empty(t, retried)
+	retried, err = repo.ClaimDue(time.Now().Add(jobBackoff), 10)
+	require.NoError(t, err)
+	require.Len(t, retried, 2)
+	assert.Equal(t, jobs[0].ID, retried[0].ID)
+	assert.Equal(t, 2, retried[0].Attempts)
+	assert.Equal(t, "database is locked", retried[0].LastError)
+	assert.Equal(t, jobs[1].ID, retried[1].ID)
+	failed, err := repo.ClaimDue(time.Now().Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, failed)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}
+	for i, d := range expected {
+		assert.Equal(t, d, retryBackoff(i+1))
+	}
+	assert.Equal(t, jobMaxBackoff, retryBackoff(20))
+}
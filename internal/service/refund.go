@@ -0,0 +1,178 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/helpers"
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/money"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/altuxa/payment-service-emulator/internal/statemachine"
+)
+
+// refundRetries bounds how often a refund is re-applied when other refunds of
+// the same payment complete concurrently.
+const refundRetries = 5
+
+type RefundService struct {
+	repo      repository.Refund
+	payments  repository.Payment
+	merchants repository.Merchant
+	events    Publisher
+	scheduler Scheduler
+	outcomes  helpers.OutcomeEngine
+}
+
+func NewRefundService(repo repository.Refund, payments repository.Payment, merchants repository.Merchant, events Publisher, scheduler Scheduler, outcomes helpers.OutcomeEngine) *RefundService {
+	return &RefundService{
+		repo:      repo,
+		payments:  payments,
+		merchants: merchants,
+		events:    events,
+		scheduler: scheduler,
+		outcomes:  outcomes,
+	}
+}
+
+func (r *RefundService) CreateRefund(merchantID, paymentId int, input models.RefundInput) (models.Refund, error) {
+	payment, err := r.payments.GetPayment(merchantID, paymentId)
+	if err != nil {
+		return models.Refund{}, classify(err)
+	}
+	err = statemachine.Transition(payment.Status, models.StatusRefunded)
+	if err != nil {
+		return models.Refund{}, &ConflictError{Err: fmt.Errorf("payment in status %s can not be refunded", payment.Status)}
+	}
+	reserved, err := r.repo.Reserved(merchantID, paymentId)
+	if err != nil {
+		return models.Refund{}, classify(err)
+	}
+	refundable := money.New(payment.Captured.Amount-reserved, payment.Sum.Currency)
+	amount := refundable
+	vErr := &ValidationError{}
+	if len(input.Amount) != 0 {
+		amount, err = money.Decode(input.Amount, payment.Sum.Currency)
+		if err != nil {
+			vErr.Add("Amount", err.Error())
+		}
+	}
+	switch {
+	case err != nil:
+	case refundable.Amount <= 0:
+		vErr.Add("Amount", "payment is already fully refunded")
+	case amount.Amount <= 0:
+		vErr.Add("Amount", "must be greater than zero")
+	case amount.Amount > refundable.Amount:
+		vErr.Add("Amount", fmt.Sprintf("must not exceed the refundable %s %s", refundable, refundable.Currency))
+	}
+	if err := vErr.Err(); err != nil {
+		return models.Refund{}, err
+	}
+	refund := models.Refund{
+		PaymentID:  paymentId,
+		MerchantID: merchantID,
+		Amount:     amount,
+		Status:     models.RefundPending,
+		Reason:     input.Reason,
+		CreatedAt:  time.Now(),
+	}
+	job := r.scheduler.Job(models.JobRefund, merchantID)
+	refund.ID, err = r.repo.CreateRefund(refund, payment.Captured.Amount, &job)
+	if errors.Is(err, repository.ErrRefundExceeded) {
+		vErr.Add("Amount", "must not exceed the refundable amount")
+		return models.Refund{}, vErr
+	}
+	if err != nil {
+		return models.Refund{}, classify(err)
+	}
+	r.scheduler.Notify()
+	stored, err := r.repo.Refund(merchantID, refund.ID)
+	if err != nil {
+		// the refund is stored and will be processed, report it as created
+		log.Printf("failed to read refund %d: %v", refund.ID, err)
+		return refund, nil
+	}
+	return stored, nil
+}
+
+func (r *RefundService) GetRefund(merchantID, id int) (models.Refund, error) {
+	refund, err := r.repo.Refund(merchantID, id)
+	return refund, classify(err)
+}
+
+func (r *RefundService) Refunds(merchantID, paymentId int) ([]models.Refund, error) {
+	_, err := r.payments.GetPayment(merchantID, paymentId)
+	if err != nil {
+		return nil, classify(err)
+	}
+	refunds, err := r.repo.Refunds(merchantID, paymentId)
+	return refunds, classify(err)
+}
+
+// ProcessRefund asks the emulated processor for the refund outcome and, when
+// it succeeds, adds the amount to the refunded total of the payment.
+func (r *RefundService) ProcessRefund(merchantID, refundId int) (string, error) {
+	refund, err := r.repo.Refund(merchantID, refundId)
+	if err != nil {
+		return "", classify(err)
+	}
+	if refund.Status != models.RefundPending {
+		return "", &ConflictError{Err: fmt.Errorf("refund is already %s", refund.Status)}
+	}
+	merchant, err := merchantSettings(r.merchants, merchantID)
+	if err != nil {
+		return "", classify(err)
+	}
+	for i := 0; i < refundRetries; i++ {
+		payment, err := r.payments.GetPayment(merchantID, refund.PaymentID)
+		if err != nil {
+			return "", classify(err)
+		}
+		outcome := r.outcomes.Decide(helpers.StageRefund, models.Transaction{
+			ID:        refund.ID,
+			UserID:    payment.UserID,
+			UserEmail: payment.UserEmail,
+			Sum:       refund.Amount,
+		}, merchant.OutcomeRates)
+		if outcome.Status != models.StatusSuccess {
+			return models.RefundFailed, classify(r.repo.FailRefund(merchantID, refund.ID, outcome.DeclineReason))
+		}
+		refunded := payment.Refunded.Amount + refund.Amount.Amount
+		change := models.StatusChange{
+			From:     payment.Status,
+			To:       models.StatusPartiallyRefunded,
+			Reason:   fmt.Sprintf("refunded %s %s", refund.Amount, refund.Amount.Currency),
+			Refunded: &refunded,
+		}
+		if refunded >= payment.Captured.Amount {
+			change.To = models.StatusRefunded
+		}
+		err = statemachine.Transition(change.From, change.To)
+		if err != nil {
+			return "", classify(err)
+		}
+		err = r.repo.SucceedRefund(merchantID, refund.ID, payment.ID, payment.Refunded.Amount, change)
+		if errors.Is(err, repository.ErrStatusChanged) {
+			continue
+		}
+		if err != nil {
+			return "", classify(err)
+		}
+		r.publish(merchantID, payment.ID)
+		return models.RefundSucceeded, nil
+	}
+	return "", classify(repository.ErrStatusChanged)
+}
+
+func (r *RefundService) publish(merchantID, paymentId int) {
+	payment, err := r.payments.GetPayment(merchantID, paymentId)
+	if err == nil {
+		err = r.events.Publish(models.EventPaymentRefunded, payment)
+	}
+	if err != nil {
+		log.Printf("failed to publish %s for payment %d: %v", models.EventPaymentRefunded, paymentId, err)
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/money"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// racingRefunds runs race once before a refund is applied, as if another
+// refund of the payment completed meanwhile, and fails every attempt with
+// ErrStatusChanged when always is set.
+type racingRefunds struct {
+	*repository.MemoryRepo
+	race   func()
+	always bool
+}
+
+func (r *racingRefunds) SucceedRefund(merchantID, refundId int, paymentId int, refundedBefore int64, change models.StatusChange) error {
+	if r.always {
+		return repository.ErrStatusChanged
+	}
+	if race := r.race; race != nil {
+		r.race = nil
+		race()
+	}
+	return r.MemoryRepo.SucceedRefund(merchantID, refundId, paymentId, refundedBefore, change)
+}
+
+type testRefunds struct {
+	repos   *repository.MemoryRepo
+	refunds *RefundService
+	repo    *racingRefunds
+	payment int
+}
+
+// newTestRefunds returns a refund service and a captured payment of 10.00 USD.
+func newTestRefunds(t *testing.T) testRefunds {
+	repos := repository.NewMemoryRepo()
+	id, err := repos.NewPayment(models.Transaction{UserID: 1, UserEmail: "ann@example.com", Sum: money.New(1000, "USD"), Status: models.StatusNew, MerchantID: 1}, nil)
+	require.NoError(t, err)
+	captured := int64(1000)
+	require.NoError(t, repos.SetStatus(1, id, models.StatusChange{From: models.StatusNew, To: models.StatusProcessing}))
+	require.NoError(t, repos.SetStatus(1, id, models.StatusChange{From: models.StatusProcessing, To: models.StatusSuccess, Captured: &captured}))
+	repo := &racingRefunds{MemoryRepo: repos}
+	webhooks := NewWebhookService(repos, WebhookConfig{})
+	return testRefunds{
+		repos:   repos,
+		refunds: NewRefundService(repo, repos, repos, webhooks, NewQueueService(repos, 0), stageOutcomes{}),
+		repo:    repo,
+		payment: id,
+	}
+}
+
+func (r testRefunds) create(t *testing.T, amount string) models.Refund {
+	refund, err := r.refunds.CreateRefund(1, r.payment, models.RefundInput{Amount: json.RawMessage(amount)})
+	require.NoError(t, err)
+	assert.Equal(t, models.RefundPending, refund.Status)
+	return refund
+}
+
+func (r testRefunds) process(t *testing.T, refund models.Refund) {
+	status, err := r.refunds.ProcessRefund(1, refund.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RefundSucceeded, status)
+}
+
+func (r testRefunds) assertPayment(t *testing.T, status string, refunded int64) {
+	payment, err := r.repos.GetPayment(1, r.payment)
+	require.NoError(t, err)
+	assert.Equal(t, status, payment.Status)
+	assert.Equal(t, refunded, payment.Refunded.Amount)
+}
+
+func TestRefundPartialAndRemainder(t *testing.T) {
+	r := newTestRefunds(t)
+	r.process(t, r.create(t, `"3.00"`))
+	r.assertPayment(t, models.StatusPartiallyRefunded, 300)
+
+	// without an amount the remainder is refunded
+	remainder := r.create(t, ``)
+	assert.Equal(t, money.New(700, "USD"), remainder.Amount)
+	r.process(t, remainder)
+	r.assertPayment(t, models.StatusRefunded, 1000)
+
+	_, err := r.refunds.CreateRefund(1, r.payment, models.RefundInput{})
+	var conflict *ConflictError
+	assert.True(t, errors.As(err, &conflict))
+}
+
+func TestRefundValidation(t *testing.T) {
+	r := newTestRefunds(t)
+	// pending refunds reserve their amount
+	r.create(t, `"6.00"`)
+	tData := map[string]struct {
+		Amount   string
+		Expected string
+	}{
+		"over the refundable": {Amount: `"5.00"`, Expected: "must not exceed the refundable 4.00 USD"},
+		"zero":                {Amount: `0`, Expected: "must be greater than zero"},
+		"invalid":             {Amount: `"4.005"`, Expected: `invalid amount "4.005": USD allows 2 decimal places`},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			_, err := r.refunds.CreateRefund(1, r.payment, models.RefundInput{Amount: json.RawMessage(v.Amount)})
+			var vErr *ValidationError
+			require.True(t, errors.As(err, &vErr))
+			assert.Equal(t, []FieldError{{Field: "Amount", Message: v.Expected}}, vErr.Fields)
+		})
+	}
+
+	r.create(t, `"4.00"`)
+	_, err := r.refunds.CreateRefund(1, r.payment, models.RefundInput{})
+	var vErr *ValidationError
+	require.True(t, errors.As(err, &vErr))
+	assert.Equal(t, []FieldError{{Field: "Amount", Message: "payment is already fully refunded"}}, vErr.Fields)
+}
+
+func TestRefundConcurrentCompletion(t *testing.T) {
+	r := newTestRefunds(t)
+	first := r.create(t, `"3.00"`)
+	second := r.create(t, `"7.00"`)
+	// the second refund completes while the first is applied, which then
+	// has to start over from the new refunded total
+	r.repo.race = func() { r.process(t, second) }
+	r.process(t, first)
+	r.assertPayment(t, models.StatusRefunded, 1000)
+	history, err := r.repos.History(1, r.payment)
+	require.NoError(t, err)
+	statuses := []string{}
+	for _, event := range history {
+		statuses = append(statuses, event.ToStatus)
+	}
+	assert.Equal(t, []string{models.StatusNew, models.StatusProcessing, models.StatusSuccess, models.StatusPartiallyRefunded, models.StatusRefunded}, statuses)
+}
+
+func TestRefundRetriesExhausted(t *testing.T) {
+	r := newTestRefunds(t)
+	refund := r.create(t, `"3.00"`)
+	r.repo.always = true
+	_, err := r.refunds.ProcessRefund(1, refund.ID)
+	assert.True(t, errors.Is(err, repository.ErrStatusChanged))
+	stored, err := r.refunds.GetRefund(1, refund.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RefundPending, stored.Status)
+	r.assertPayment(t, models.StatusSuccess, 0)
+}
@@ -1,37 +1,140 @@
 package service
 
 import (
+	"context"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/helpers"
 	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/money"
 	"github.com/altuxa/payment-service-emulator/internal/repository"
 )
 
 //go:generate mockgen -source=service.go -destination=mocks/mock.go
 
 type User interface {
-	Verification(payId int, email string) (bool, error)
+	Verification(merchantID, payId int, email string) (bool, error)
+	CreateUser(merchantID int, input models.UserInput) (models.User, error)
+	GetUser(merchantID, id int) (models.User, error)
+	UpdateUser(merchantID, id int, input models.UserInput) (models.User, error)
+}
+
+type Merchant interface {
+	CreateMerchant(input models.MerchantInput) (models.Merchant, error)
+	Merchants() ([]models.Merchant, error)
+	GetMerchant(id int) (models.Merchant, error)
+	UpdateMerchant(id int, input models.MerchantInput) (models.Merchant, error)
+}
+
+type APIKey interface {
+	IssueAPIKey(input models.APIKeyInput) (models.APIKey, error)
+	APIKeys(merchantID int) ([]models.APIKey, error)
+	RevokeAPIKey(id int) error
+	Authenticate(token string) (models.Principal, error)
+	AuthenticateAdmin(token string) error
+}
+
+type Token interface {
+	AuthenticateToken(token string) (models.Principal, error)
+	MintToken(input models.TokenInput) (models.Token, error)
 }
 
 type Payment interface {
-	CancelPayment(paymentId int) error
-	CreatePayment(id int, email string, sum float64, val string) (int, string, error)
-	PaymentProcessing(id int) (string, error)
-	PaymentStatus(paymentId int) (string, error)
-	ByUserID(userID int) ([]models.Transaction, error)
-	ByUserEmail(email string) ([]models.Transaction, error)
+	CancelPayment(merchantID, paymentId int, input models.CancelInput) error
+	PurgeCancelled(before time.Time) (int, error)
+	CreatePayment(payment models.Transaction) (int, string, error)
+	PaymentProcessing(merchantID, id int) (string, error)
+	Capture(merchantID, paymentId int, input models.CaptureInput) (models.Transaction, error)
+	Void(merchantID, paymentId int) (models.Transaction, error)
+	ExpireAuthorizations(now time.Time, limit int) (int, error)
+	GetPayment(merchantID, paymentId int) (models.Transaction, error)
+	PaymentStatus(merchantID, paymentId int) (string, error)
+	ByUserID(merchantID, userID int) ([]models.Transaction, error)
+	ByUserEmail(merchantID int, email string) ([]models.Transaction, error)
+	ListPayments(merchantID int, input models.PaymentListInput) (models.PaymentList, error)
+	History(merchantID, paymentId int) ([]models.PaymentEvent, error)
+}
+
+type Refund interface {
+	CreateRefund(merchantID, paymentId int, input models.RefundInput) (models.Refund, error)
+	GetRefund(merchantID, id int) (models.Refund, error)
+	Refunds(merchantID, paymentId int) ([]models.Refund, error)
+	ProcessRefund(merchantID, refundId int) (string, error)
+}
+
+type Publisher interface {
+	Publish(eventType string, payment models.Transaction) error
+}
+
+type Webhook interface {
+	Publisher
+	RegisterEndpoint(merchantID int, input models.WebhookEndpointInput) (models.WebhookEndpoint, error)
+	Endpoints(merchantID int) ([]models.WebhookEndpoint, error)
+	RotateSecret(merchantID, id int) (models.WebhookEndpoint, error)
+	DeleteEndpoint(merchantID, id int) error
+	Deliveries(merchantID, endpointID int) ([]models.WebhookDelivery, error)
+	Replay(merchantID, deliveryID int) (models.WebhookDelivery, error)
+	DueDeliveries(limit int) ([]models.WebhookDelivery, error)
+	Deliver(ctx context.Context, delivery models.WebhookDelivery) error
+}
+
+// Scheduler builds the processing jobs that payments and refunds are stored
+// with, so none is left without one.
+type Scheduler interface {
+	Job(kind string, merchantID int) models.Job
+	Notify()
+}
+
+type Queue interface {
+	Scheduler
+	Claim(limit int) ([]models.Job, error)
+	Complete(job models.Job, jobErr error) error
+	Recover() error
+	Wake() <-chan struct{}
+}
+
+type Idempotency interface {
+	Begin(key, fingerprint string) (*models.IdempotencyRecord, error)
+	Complete(key string, code int, body []byte) error
+	Release(key string) error
 }
 
 type Services struct {
 	User
+	Merchant
+	APIKey
+	Token
 	Payment
+	Refund
+	Webhook
+	Idempotency
+	Queue
 }
 
 type ServiceDeps struct {
-	Repos *repository.Repositories
+	Repos            *repository.Repositories
+	Currencies       *money.Registry
+	Webhooks         WebhookConfig
+	IdempotencyTTL   time.Duration
+	ProcessingDelay  time.Duration
+	Outcomes         helpers.OutcomeEngine
+	AuthorizationTTL time.Duration
+	AdminToken       string
+	Tokens           TokenConfig
 }
 
-func NewService(repo *repository.Repositories) *Services {
+func NewService(deps ServiceDeps) *Services {
+	webhooks := NewWebhookService(deps.Repos.Webhook, deps.Webhooks)
+	queue := NewQueueService(deps.Repos.Job, deps.ProcessingDelay)
 	return &Services{
-		User:    NewUserService(repo.User),
-		Payment: NewPaymentService(repo.Payment),
+		User:        NewUserService(deps.Repos.User),
+		Merchant:    NewMerchantService(deps.Repos.Merchant, deps.Currencies),
+		APIKey:      NewAPIKeyService(deps.Repos.APIKey, deps.Repos.Merchant, deps.AdminToken),
+		Token:       NewTokenService(deps.Repos.Merchant, deps.Repos.User, deps.Tokens),
+		Payment:     NewPaymentService(deps.Repos.Payment, deps.Repos.User, deps.Repos.Merchant, deps.Currencies, webhooks, queue, deps.Outcomes, deps.AuthorizationTTL),
+		Refund:      NewRefundService(deps.Repos.Refund, deps.Repos.Payment, deps.Repos.Merchant, webhooks, queue, deps.Outcomes),
+		Webhook:     webhooks,
+		Idempotency: NewIdempotencyService(deps.Repos.Idempotency, deps.IdempotencyTTL),
+		Queue:       queue,
 	}
 }
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/jwt"
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// TokenConfig holds the keys bearer tokens are verified with and the keys
+// MintToken signs with, either of which may be missing.
+type TokenConfig struct {
+	Verifier   *jwt.Verifier
+	Secret     []byte
+	PrivateKey *rsa.PrivateKey
+	KeyID      string
+	TTL        time.Duration
+}
+
+type TokenService struct {
+	merchants repository.Merchant
+	users     repository.User
+	config    TokenConfig
+}
+
+func NewTokenService(merchants repository.Merchant, users repository.User, config TokenConfig) *TokenService {
+	if config.Verifier == nil {
+		config.Verifier = &jwt.Verifier{Keys: jwt.NewKeySet()}
+	}
+	return &TokenService{
+		merchants: merchants,
+		users:     users,
+		config:    config,
+	}
+}
+
+// AuthenticateToken maps the merchant_id claim to a merchant and the optional
+// user_id claim to one of its users, a token with a user_id can only reach
+// that user's payments.
+func (t *TokenService) AuthenticateToken(token string) (models.Principal, error) {
+	if t.config.Verifier.Keys.Len() == 0 {
+		return models.Principal{}, fmt.Errorf("%w: token authentication is not configured", ErrInvalidToken)
+	}
+	claims, err := t.config.Verifier.Verify(token)
+	if err != nil {
+		return models.Principal{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	principal := models.Principal{
+		MerchantID: claims.MerchantID,
+		UserID:     claims.UserID,
+		Kind:       models.KeySecret,
+	}
+	if claims.MerchantID <= 0 {
+		return models.Principal{}, fmt.Errorf("%w: merchant_id claim is required", ErrInvalidToken)
+	}
+	_, err = t.merchants.Merchant(claims.MerchantID)
+	if errors.Is(err, repository.ErrMerchantNotFound) {
+		return models.Principal{}, fmt.Errorf("%w: unknown merchant_id", ErrInvalidToken)
+	}
+	if err != nil {
+		return models.Principal{}, err
+	}
+	if claims.UserID > 0 {
+		_, err := t.users.GetUser(claims.MerchantID, claims.UserID)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return models.Principal{}, fmt.Errorf("%w: unknown user_id", ErrInvalidToken)
+		}
+		if err != nil {
+			return models.Principal{}, err
+		}
+		principal.Kind = models.KeyUser
+	}
+	return principal, nil
+}
+
+func (t *TokenService) MintToken(input models.TokenInput) (models.Token, error) {
+	vErr := &ValidationError{}
+	if input.MerchantID <= 0 {
+		vErr.Add("MerchantID", "is required")
+	} else if _, err := t.merchants.Merchant(input.MerchantID); errors.Is(err, repository.ErrMerchantNotFound) {
+		vErr.Add("MerchantID", "merchant not found")
+	} else if err != nil {
+		return models.Token{}, classify(err)
+	}
+	// a token with a user_id must name a user of the same merchant
+	if input.UserID < 0 {
+		vErr.Add("UserID", "must not be negative")
+	} else if input.UserID > 0 && input.MerchantID > 0 {
+		if _, err := t.users.GetUser(input.MerchantID, input.UserID); errors.Is(err, repository.ErrUserNotFound) {
+			vErr.Add("UserID", "user not found")
+		} else if err != nil {
+			return models.Token{}, classify(err)
+		}
+	}
+	ttl := t.config.TTL
+	if input.TTL != "" {
+		d, err := time.ParseDuration(input.TTL)
+		if err != nil || d <= 0 {
+			vErr.Add("TTL", "must be a positive duration such as 15m")
+		}
+		ttl = d
+	}
+	alg := input.Algorithm
+	if alg == "" && t.config.Secret == nil {
+		alg = jwt.RS256
+	} else if alg == "" {
+		alg = jwt.HS256
+	}
+	switch {
+	case alg == jwt.HS256 && t.config.Secret == nil:
+		vErr.Add("Algorithm", "no HS256 secret is configured, set JWT_HS256_SECRET")
+	case alg == jwt.RS256 && t.config.PrivateKey == nil:
+		vErr.Add("Algorithm", "no RS256 private key is configured, set JWT_RS256_PRIVATE_KEY_FILE")
+	case alg != jwt.HS256 && alg != jwt.RS256:
+		vErr.Add("Algorithm", fmt.Sprintf("must be %s or %s", jwt.HS256, jwt.RS256))
+	}
+	if err := vErr.Err(); err != nil {
+		return models.Token{}, err
+	}
+	now := time.Now()
+	claims := jwt.Claims{
+		Issuer:     t.config.Verifier.Issuer,
+		IssuedAt:   now.Unix(),
+		ExpiresAt:  now.Add(ttl).Unix(),
+		MerchantID: input.MerchantID,
+		UserID:     input.UserID,
+	}
+	if aud := input.Audience; aud != "" {
+		claims.Audience = jwt.Audience{aud}
+	} else if aud := t.config.Verifier.Audience; aud != "" {
+		claims.Audience = jwt.Audience{aud}
+	}
+	var token string
+	var err error
+	if alg == jwt.HS256 {
+		token, err = jwt.SignHS256(claims, t.config.KeyID, t.config.Secret)
+	} else {
+		token, err = jwt.SignRS256(claims, t.config.KeyID, t.config.PrivateKey)
+	}
+	if err != nil {
+		return models.Token{}, err
+	}
+	return models.Token{
+		Token:     token,
+		Algorithm: alg,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}, nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMintTokenMerchant(t *testing.T) {
+	repo := repository.NewMemoryRepo()
+	id, err := repo.CreateMerchant(models.Merchant{Name: "shop"})
+	require.NoError(t, err)
+	tokens := NewTokenService(repo, repo, TokenConfig{Secret: []byte("secret")})
+	tData := map[string]struct {
+		MerchantID int
+		Expected   []FieldError
+	}{
+		"existing merchant": {MerchantID: id},
+		"missing merchant":  {Expected: []FieldError{{Field: "MerchantID", Message: "is required"}}},
+		"unknown merchant":  {MerchantID: id + 1, Expected: []FieldError{{Field: "MerchantID", Message: "merchant not found"}}},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			token, err := tokens.MintToken(models.TokenInput{MerchantID: v.MerchantID, TTL: "15m"})
+			if v.Expected == nil {
+				require.NoError(t, err)
+				assert.NotEmpty(t, token.Token)
+				return
+			}
+			var vErr *ValidationError
+			require.True(t, errors.As(err, &vErr))
+			assert.Equal(t, v.Expected, vErr.Fields)
+		})
+	}
+}
+
+func TestMintTokenUser(t *testing.T) {
+	repo := repository.NewMemoryRepo()
+	merchant, err := repo.CreateMerchant(models.Merchant{Name: "shop"})
+	require.NoError(t, err)
+	other, err := repo.CreateMerchant(models.Merchant{Name: "other"})
+	require.NoError(t, err)
+	user, err := repo.CreateUser(models.User{MerchantID: merchant, Email: "ann@example.com"})
+	require.NoError(t, err)
+	foreign, err := repo.CreateUser(models.User{MerchantID: other, Email: "bob@example.com"})
+	require.NoError(t, err)
+	tokens := NewTokenService(repo, repo, TokenConfig{Secret: []byte("secret")})
+	tData := map[string]struct {
+		UserID   int
+		Expected []FieldError
+	}{
+		"merchant token":      {},
+		"merchant's user":     {UserID: user},
+		"negative user":       {UserID: -1, Expected: []FieldError{{Field: "UserID", Message: "must not be negative"}}},
+		"unknown user":        {UserID: foreign + 1, Expected: []FieldError{{Field: "UserID", Message: "user not found"}}},
+		"other merchant user": {UserID: foreign, Expected: []FieldError{{Field: "UserID", Message: "user not found"}}},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			token, err := tokens.MintToken(models.TokenInput{MerchantID: merchant, UserID: v.UserID, TTL: "15m"})
+			if v.Expected == nil {
+				require.NoError(t, err)
+				assert.NotEmpty(t, token.Token)
+				return
+			}
+			var vErr *ValidationError
+			require.True(t, errors.As(err, &vErr))
+			assert.Equal(t, v.Expected, vErr.Fields)
+		})
+	}
+}
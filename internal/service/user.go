@@ -1,6 +1,15 @@
 package service
 
-import "github.com/altuxa/payment-service-emulator/internal/repository"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/helpers"
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+)
 
 type UserService struct {
 	repo repository.User
@@ -12,13 +21,82 @@ func NewUserService(repo repository.User) *UserService {
 	}
 }
 
-func (u *UserService) Verification(payId int, email string) (bool, error) {
-	checkEmail, err := u.repo.UserVerification(payId, email)
+// Verification reports whether email is the payer's, ignoring case.
+func (u *UserService) Verification(merchantID, payId int, email string) (bool, error) {
+	checkEmail, err := u.repo.UserVerification(merchantID, payId)
+	if err != nil {
+		return false, classify(err)
+	}
+	return email != "" && strings.EqualFold(checkEmail, email), nil
+}
+
+func (u *UserService) CreateUser(merchantID int, input models.UserInput) (models.User, error) {
+	user := models.User{
+		MerchantID: merchantID,
+		Email:      normalizeEmail(input.Email),
+		Name:       strings.TrimSpace(input.Name),
+		CreatedAt:  time.Now(),
+	}
+	err := validateUser(user)
+	if err != nil {
+		return models.User{}, classify(err)
+	}
+	user.ID, err = u.repo.CreateUser(user)
+	if err != nil {
+		return models.User{}, userError(err)
+	}
+	user.UpdatedAt = user.CreatedAt
+	return user, nil
+}
+
+func (u *UserService) GetUser(merchantID, id int) (models.User, error) {
+	user, err := u.repo.GetUser(merchantID, id)
+	return user, classify(err)
+}
+
+// UpdateUser changes the fields given in input and keeps the rest.
+func (u *UserService) UpdateUser(merchantID, id int, input models.UserInput) (models.User, error) {
+	user, err := u.repo.GetUser(merchantID, id)
 	if err != nil {
-		return false, err
+		return models.User{}, classify(err)
 	}
-	if checkEmail != email {
-		return false, nil
+	if input.Email != "" {
+		user.Email = normalizeEmail(input.Email)
 	}
-	return true, nil
+	if input.Name != "" {
+		user.Name = strings.TrimSpace(input.Name)
+	}
+	err = validateUser(user)
+	if err != nil {
+		return models.User{}, classify(err)
+	}
+	user.UpdatedAt = time.Now()
+	err = u.repo.UpdateUser(user)
+	if err != nil {
+		return models.User{}, userError(err)
+	}
+	return user, nil
+}
+
+func validateUser(user models.User) error {
+	vErr := &ValidationError{}
+	if user.Email == "" {
+		vErr.Add("Email", "is required")
+	} else if err := helpers.ValidEmail(user.Email); err != nil {
+		vErr.Add("Email", fmt.Sprintf("invalid email %v", err))
+	}
+	return vErr.Err()
+}
+
+func userError(err error) error {
+	if errors.Is(err, repository.ErrEmailTaken) {
+		vErr := &ValidationError{}
+		vErr.Add("Email", "is already registered")
+		return vErr
+	}
+	return err
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
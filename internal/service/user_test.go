@@ -0,0 +1,40 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/money"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerification(t *testing.T) {
+	repo := repository.NewMemoryRepo()
+	id, err := repo.NewPayment(models.Transaction{UserID: 1, UserEmail: "ann@example.com", Sum: money.New(100, "USD"), Status: models.StatusNew, MerchantID: 1}, nil)
+	require.NoError(t, err)
+	u := NewUserService(repo)
+	tData := map[string]struct {
+		MerchantID int
+		Email      string
+		Expected   bool
+		NotFound   bool
+	}{
+		"payer":            {MerchantID: 1, Email: "ann@example.com", Expected: true},
+		"case insensitive": {MerchantID: 1, Email: "Ann@Example.COM", Expected: true},
+		"another email":    {MerchantID: 1, Email: "bob@example.com"},
+		"no email":         {MerchantID: 1},
+		"another merchant": {MerchantID: 2, Email: "ann@example.com", NotFound: true},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			ok, err := u.Verification(v.MerchantID, id, v.Email)
+			var notFound *NotFoundError
+			assert.Equal(t, v.NotFound, errors.As(err, &notFound))
+			assert.Equal(t, v.Expected, ok)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/helpers"
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/altuxa/payment-service-emulator/pkg/webhook"
+)
+
+var webhookEvents = map[string]bool{
+	models.EventPaymentCreated:    true,
+	models.EventPaymentSucceeded:  true,
+	models.EventPaymentFailed:     true,
+	models.EventPaymentCancelled:  true,
+	models.EventPaymentAuthorized: true,
+	models.EventPaymentCaptured:   true,
+	models.EventPaymentVoided:     true,
+	models.EventPaymentRefunded:   true,
+}
+
+var signatureFaults = map[string]bool{
+	"":                           true,
+	models.FaultInvalidSignature: true,
+	models.FaultStaleTimestamp:   true,
+}
+
+type WebhookConfig struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	Timeout     time.Duration
+	SecretGrace time.Duration
+}
+
+type WebhookService struct {
+	repo   repository.Webhook
+	config WebhookConfig
+	client *http.Client
+}
+
+func NewWebhookService(repo repository.Webhook, config WebhookConfig) *WebhookService {
+	return &WebhookService{
+		repo:   repo,
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+func (s *WebhookService) RegisterEndpoint(merchantID int, input models.WebhookEndpointInput) (models.WebhookEndpoint, error) {
+	vErr := &ValidationError{}
+	u, err := url.Parse(input.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		vErr.Add("URL", "must be an absolute http or https URL")
+	}
+	for _, event := range input.Events {
+		if !webhookEvents[event] {
+			vErr.Add("Events", fmt.Sprintf("unknown event %q", event))
+		}
+	}
+	if !signatureFaults[input.SignatureFault] {
+		vErr.Add("SignatureFault", fmt.Sprintf("must be one of %q or %q", models.FaultInvalidSignature, models.FaultStaleTimestamp))
+	}
+	if err := vErr.Err(); err != nil {
+		return models.WebhookEndpoint{}, err
+	}
+	secret, err := helpers.RandomToken("whsec_", 24)
+	if err != nil {
+		return models.WebhookEndpoint{}, classify(err)
+	}
+	endpoint := models.WebhookEndpoint{
+		MerchantID:     merchantID,
+		URL:            input.URL,
+		Secret:         secret,
+		Events:         input.Events,
+		SignatureFault: input.SignatureFault,
+		CreatedAt:      time.Now(),
+	}
+	if endpoint.Events == nil {
+		endpoint.Events = []string{}
+	}
+	endpoint.ID, err = s.repo.CreateEndpoint(endpoint)
+	if err != nil {
+		return models.WebhookEndpoint{}, classify(err)
+	}
+	return endpoint, nil
+}
+
+func (s *WebhookService) Endpoints(merchantID int) ([]models.WebhookEndpoint, error) {
+	endpoints, err := s.repo.Endpoints(merchantID)
+	if err != nil {
+		return nil, classify(err)
+	}
+	for i := range endpoints {
+		endpoints[i].Secret = ""
+		endpoints[i].PreviousSecret = ""
+	}
+	return endpoints, nil
+}
+
+// RotateSecret issues a new signing secret. The old one keeps signing
+// deliveries alongside the new one until the grace period ends.
+func (s *WebhookService) RotateSecret(merchantID, id int) (models.WebhookEndpoint, error) {
+	secret, err := helpers.RandomToken("whsec_", 24)
+	if err != nil {
+		return models.WebhookEndpoint{}, classify(err)
+	}
+	err = s.repo.RotateSecret(merchantID, id, secret, time.Now().Add(s.config.SecretGrace))
+	if err != nil {
+		return models.WebhookEndpoint{}, classify(err)
+	}
+	endpoint, err := s.repo.Endpoint(merchantID, id)
+	return endpoint, classify(err)
+}
+
+func (s *WebhookService) DeleteEndpoint(merchantID, id int) error {
+	return classify(s.repo.DeleteEndpoint(merchantID, id))
+}
+
+func (s *WebhookService) Deliveries(merchantID, endpointID int) ([]models.WebhookDelivery, error) {
+	_, err := s.repo.Endpoint(merchantID, endpointID)
+	if err != nil {
+		return nil, classify(err)
+	}
+	deliveries, err := s.repo.Deliveries(merchantID, endpointID)
+	return deliveries, classify(err)
+}
+
+func (s *WebhookService) Replay(merchantID, deliveryID int) (models.WebhookDelivery, error) {
+	id, err := s.repo.Redeliver(merchantID, deliveryID)
+	if err != nil {
+		return models.WebhookDelivery{}, classify(err)
+	}
+	delivery, err := s.repo.Delivery(merchantID, id)
+	return delivery, classify(err)
+}
+
+func (s *WebhookService) Publish(eventType string, payment models.Transaction) error {
+	_, err := s.repo.CreateEvent(models.WebhookEvent{
+		Type:    eventType,
+		Created: time.Now(),
+		Data:    payment,
+	})
+	return err
+}
+
+func (s *WebhookService) DueDeliveries(limit int) ([]models.WebhookDelivery, error) {
+	return s.repo.DueDeliveries(time.Now(), limit)
+}
+
+// Deliver makes one delivery attempt and records its outcome, scheduling a
+// retry with exponential backoff until the attempts are exhausted.
+func (s *WebhookService) Deliver(ctx context.Context, delivery models.WebhookDelivery) error {
+	code, err := s.send(ctx, delivery)
+	now := time.Now()
+	delivery.Attempts++
+	delivery.ResponseCode = code
+	delivery.UpdatedAt = now
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliverySucceeded
+		delivery.LastError = ""
+	case delivery.Attempts >= s.config.MaxAttempts:
+		delivery.Status = models.DeliveryFailed
+		delivery.LastError = err.Error()
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttempt = now.Add(s.backoff(delivery.Attempts))
+	}
+	return s.repo.UpdateDelivery(delivery)
+}
+
+func (s *WebhookService) send(ctx context.Context, delivery models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "payment-service-emulator")
+	req.Header.Set("X-Emulator-Event", delivery.EventType)
+	req.Header.Set("X-Emulator-Event-ID", strconv.Itoa(delivery.EventID))
+	req.Header.Set("X-Emulator-Delivery", strconv.Itoa(delivery.ID))
+	req.Header.Set(webhook.SignatureHeader, signature(delivery, time.Now()))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func signature(delivery models.WebhookDelivery, now time.Time) string {
+	endpoint := delivery.Endpoint
+	secrets := []string{endpoint.Secret}
+	if endpoint.PreviousSecret != "" && endpoint.PreviousSecretExpires != nil && now.Before(*endpoint.PreviousSecretExpires) {
+		secrets = append(secrets, endpoint.PreviousSecret)
+	}
+	switch endpoint.SignatureFault {
+	case models.FaultInvalidSignature:
+		secrets = []string{endpoint.Secret + "_invalid"}
+	case models.FaultStaleTimestamp:
+		now = now.Add(-2 * webhook.DefaultTolerance)
+	}
+	return webhook.Header(delivery.Payload, now, secrets...)
+}
+
+func (s *WebhookService) backoff(attempt int) time.Duration {
+	d := s.config.Backoff
+	for i := 1; i < attempt && d < s.config.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > s.config.MaxBackoff {
+		d = s.config.MaxBackoff
+	}
+	return d
+}
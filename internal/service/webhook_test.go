@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/money"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is a webhook endpoint answering every request with status and
+// recording the paths it was called on.
+type receiver struct {
+	*httptest.Server
+	mu     sync.Mutex
+	status int
+	paths  []string
+}
+
+func newReceiver(t *testing.T, status int) *receiver {
+	r := &receiver{status: status}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		r.paths = append(r.paths, req.URL.Path)
+		r.mu.Unlock()
+		w.WriteHeader(r.status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) calls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.paths...)
+}
+
+func newTestWebhookService() *WebhookService {
+	return NewWebhookService(repository.NewMemoryRepo(), WebhookConfig{
+		MaxAttempts: 5,
+		Backoff:     time.Second,
+		MaxBackoff:  4 * time.Second,
+		Timeout:     time.Second,
+		SecretGrace: time.Hour,
+	})
+}
+
+func registerEndpoint(t *testing.T, s *WebhookService, merchantID int, url string, events ...string) models.WebhookEndpoint {
+	endpoint, err := s.RegisterEndpoint(merchantID, models.WebhookEndpointInput{URL: url, Events: events})
+	require.NoError(t, err)
+	return endpoint
+}
+
+// deliverDue makes one attempt of every due delivery.
+func deliverDue(t *testing.T, s *WebhookService) []models.WebhookDelivery {
+	deliveries, err := s.DueDeliveries(100)
+	require.NoError(t, err)
+	for _, delivery := range deliveries {
+		require.NoError(t, s.Deliver(context.Background(), delivery))
+	}
+	return deliveries
+}
+
+func TestWebhookPublish(t *testing.T) {
+	rcv := newReceiver(t, http.StatusOK)
+	s := newTestWebhookService()
+	registerEndpoint(t, s, 1, rcv.URL+"/succeeded", models.EventPaymentSucceeded)
+	registerEndpoint(t, s, 1, rcv.URL+"/failed", models.EventPaymentFailed)
+	registerEndpoint(t, s, 1, rcv.URL+"/all")
+	registerEndpoint(t, s, 2, rcv.URL+"/other-merchant", models.EventPaymentSucceeded)
+
+	payment := models.Transaction{ID: 7, MerchantID: 1, Sum: money.New(1000, "USD"), Status: models.StatusSuccess}
+	require.NoError(t, s.Publish(models.EventPaymentSucceeded, payment))
+	deliveries := deliverDue(t, s)
+
+	assert.Len(t, deliveries, 2)
+	assert.ElementsMatch(t, []string{"/succeeded", "/all"}, rcv.calls())
+	due, err := s.DueDeliveries(100)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+}
+
+func TestWebhookRetries(t *testing.T) {
+	rcv := newReceiver(t, http.StatusInternalServerError)
+	s := newTestWebhookService()
+	endpoint := registerEndpoint(t, s, 1, rcv.URL)
+	require.NoError(t, s.Publish(models.EventPaymentCreated, models.Transaction{ID: 7, MerchantID: 1, Status: models.StatusNew}))
+	due, err := s.DueDeliveries(100)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	delivery := due[0]
+
+	delays := []time.Duration{}
+	for i := 0; i < s.config.MaxAttempts; i++ {
+		require.NoError(t, s.Deliver(context.Background(), delivery))
+		delivery, err = s.repo.Delivery(1, delivery.ID)
+		require.NoError(t, err)
+		if delivery.Status == models.DeliveryPending {
+			delays = append(delays, delivery.NextAttempt.Sub(delivery.UpdatedAt))
+		}
+	}
+
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}, delays)
+	assert.Equal(t, models.DeliveryFailed, delivery.Status)
+	assert.Equal(t, 5, delivery.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, delivery.ResponseCode)
+	assert.Equal(t, "endpoint responded with status 500", delivery.LastError)
+	assert.Len(t, rcv.calls(), 5)
+
+	deliveries, err := s.Deliveries(1, endpoint.ID)
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+}
+
+func TestWebhookReplay(t *testing.T) {
+	rcv := newReceiver(t, http.StatusInternalServerError)
+	s := newTestWebhookService()
+	s.config.MaxAttempts = 1
+	endpoint := registerEndpoint(t, s, 1, rcv.URL)
+	require.NoError(t, s.Publish(models.EventPaymentCreated, models.Transaction{ID: 7, MerchantID: 1, Status: models.StatusNew}))
+	original := deliverDue(t, s)[0]
+
+	rcv.mu.Lock()
+	rcv.status = http.StatusOK
+	rcv.mu.Unlock()
+	replay, err := s.Replay(1, original.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, original.ID, replay.ID)
+	assert.Equal(t, original.EventID, replay.EventID)
+	assert.Equal(t, models.DeliveryPending, replay.Status)
+	assert.Zero(t, replay.Attempts)
+	deliverDue(t, s)
+
+	deliveries, err := s.Deliveries(1, endpoint.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, original.ID, deliveries[0].ID)
+	assert.Equal(t, models.DeliveryFailed, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, replay.ID, deliveries[1].ID)
+	assert.Equal(t, models.DeliverySucceeded, deliveries[1].Status)
+	assert.Equal(t, http.StatusOK, deliveries[1].ResponseCode)
+}
+
+func TestWebhookOtherMerchant(t *testing.T) {
+	rcv := newReceiver(t, http.StatusOK)
+	s := newTestWebhookService()
+	endpoint := registerEndpoint(t, s, 1, rcv.URL)
+	require.NoError(t, s.Publish(models.EventPaymentCreated, models.Transaction{ID: 7, MerchantID: 1, Status: models.StatusNew}))
+	delivery := deliverDue(t, s)[0]
+
+	tData := map[string]func() error{
+		"deliveries": func() error {
+			_, err := s.Deliveries(2, endpoint.ID)
+			return err
+		},
+		"rotate": func() error {
+			_, err := s.RotateSecret(2, endpoint.ID)
+			return err
+		},
+		"delete": func() error {
+			return s.DeleteEndpoint(2, endpoint.ID)
+		},
+		"replay": func() error {
+			_, err := s.Replay(2, delivery.ID)
+			return err
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			var notFound *NotFoundError
+			assert.True(t, errors.As(v(), &notFound))
+		})
+	}
+	endpoints, err := s.Endpoints(2)
+	require.NoError(t, err)
+	assert.Empty(t, endpoints)
+	deliveries, err := s.Deliveries(1, endpoint.ID)
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+}
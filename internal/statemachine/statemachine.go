@@ -0,0 +1,80 @@
+package statemachine
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+)
+
+var ErrUnknownStatus = errors.New("unknown payment status")
+
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	if e.From == "" {
+		return fmt.Sprintf("payment can not be created with status %s", e.To)
+	}
+	return fmt.Sprintf("payment status transition %s -> %s is not allowed", e.From, e.To)
+}
+
+var initial = map[string]bool{
+	models.StatusNew: true,
+}
+
+var transitions = map[string][]string{
+	models.StatusNew:        {models.StatusProcessing, models.StatusCancelled, models.StatusError},
+	models.StatusProcessing: {models.StatusSuccess, models.StatusFail, models.StatusAuthorized},
+	models.StatusAuthorized: {models.StatusCaptured, models.StatusVoided},
+	models.StatusSuccess:    {models.StatusPartiallyRefunded, models.StatusRefunded},
+	models.StatusFail:       {},
+	models.StatusError:      {},
+	models.StatusCancelled:  {},
+	models.StatusCaptured:   {models.StatusPartiallyRefunded, models.StatusRefunded},
+	// every further partial refund keeps the payment PARTIALLY_REFUNDED
+	models.StatusPartiallyRefunded: {models.StatusPartiallyRefunded, models.StatusRefunded},
+	models.StatusRefunded:          {},
+	models.StatusVoided:            {},
+}
+
+func Known(status string) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+func Initial(status string) error {
+	if !Known(status) {
+		return fmt.Errorf("%w %q", ErrUnknownStatus, status)
+	}
+	if !initial[status] {
+		return &TransitionError{To: status}
+	}
+	return nil
+}
+
+func Transition(from, to string) error {
+	if !Known(from) {
+		return fmt.Errorf("%w %q", ErrUnknownStatus, from)
+	}
+	if !Known(to) {
+		return fmt.Errorf("%w %q", ErrUnknownStatus, to)
+	}
+	for _, next := range transitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return &TransitionError{From: from, To: to}
+}
+
+func Next(from string) []string {
+	return append([]string(nil), transitions[from]...)
+}
+
+func IsFinal(status string) bool {
+	next, ok := transitions[status]
+	return ok && len(next) == 0
+}
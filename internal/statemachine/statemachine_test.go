@@ -0,0 +1,62 @@
+package statemachine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransition(t *testing.T) {
+	tData := map[string]struct {
+		From    string
+		To      string
+		Allowed bool
+	}{
+		"new to processing":              {From: models.StatusNew, To: models.StatusProcessing, Allowed: true},
+		"new to cancelled":               {From: models.StatusNew, To: models.StatusCancelled, Allowed: true},
+		"new to error":                   {From: models.StatusNew, To: models.StatusError, Allowed: true},
+		"processing to success":          {From: models.StatusProcessing, To: models.StatusSuccess, Allowed: true},
+		"processing to fail":             {From: models.StatusProcessing, To: models.StatusFail, Allowed: true},
+		"processing to authorized":       {From: models.StatusProcessing, To: models.StatusAuthorized, Allowed: true},
+		"authorized to captured":         {From: models.StatusAuthorized, To: models.StatusCaptured, Allowed: true},
+		"authorized to voided":           {From: models.StatusAuthorized, To: models.StatusVoided, Allowed: true},
+		"success to refunded":            {From: models.StatusSuccess, To: models.StatusRefunded, Allowed: true},
+		"captured to partially refunded": {From: models.StatusCaptured, To: models.StatusPartiallyRefunded, Allowed: true},
+		"partially refunded again":       {From: models.StatusPartiallyRefunded, To: models.StatusPartiallyRefunded, Allowed: true},
+		"refunded to partially refunded": {From: models.StatusRefunded, To: models.StatusPartiallyRefunded},
+		"fail to refunded":               {From: models.StatusFail, To: models.StatusRefunded},
+		"new to success":                 {From: models.StatusNew, To: models.StatusSuccess},
+		"new to captured":                {From: models.StatusNew, To: models.StatusCaptured},
+		"voided to captured":             {From: models.StatusVoided, To: models.StatusCaptured},
+		"captured to voided":             {From: models.StatusCaptured, To: models.StatusVoided},
+		"fail to success":                {From: models.StatusFail, To: models.StatusSuccess},
+		"success to fail":                {From: models.StatusSuccess, To: models.StatusFail},
+		"error to processing":            {From: models.StatusError, To: models.StatusProcessing},
+		"processing to new":              {From: models.StatusProcessing, To: models.StatusNew},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			err := Transition(v.From, v.To)
+			if v.Allowed {
+				assert.NoError(t, err)
+				return
+			}
+			var trErr *TransitionError
+			assert.True(t, errors.As(err, &trErr))
+			assert.Equal(t, v.From, trErr.From)
+			assert.Equal(t, v.To, trErr.To)
+		})
+	}
+}
+
+func TestUnknownStatus(t *testing.T) {
+	assert.True(t, errors.Is(Transition("PAID", models.StatusSuccess), ErrUnknownStatus))
+	assert.True(t, errors.Is(Transition(models.StatusNew, "PAID"), ErrUnknownStatus))
+	assert.True(t, errors.Is(Initial("dollars"), ErrUnknownStatus))
+	assert.NoError(t, Initial(models.StatusNew))
+	assert.Error(t, Initial(models.StatusError))
+	assert.Error(t, Initial(models.StatusSuccess))
+}
@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/service"
+)
+
+const expiryBatch = 100
+
+// Expirer voids manual capture payments whose authorization ran out.
+type Expirer struct {
+	payments service.Payment
+	interval time.Duration
+}
+
+func NewExpirer(payments service.Payment, interval time.Duration) *Expirer {
+	return &Expirer{
+		payments: payments,
+		interval: interval,
+	}
+}
+
+func (e *Expirer) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		e.expire()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Expirer) expire() {
+	for {
+		n, err := e.payments.ExpireAuthorizations(time.Now(), expiryBatch)
+		if err != nil {
+			log.Printf("failed to expire authorizations: %v", err)
+			return
+		}
+		if n > 0 {
+			log.Printf("voided %d expired authorizations", n)
+		}
+		if n < expiryBatch {
+			return
+		}
+	}
+}
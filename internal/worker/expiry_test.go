@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpirerVoidsExpiredAuthorizations(t *testing.T) {
+	repos, services, merchantID := newTestServices(t)
+	expires := map[int]time.Time{}
+	for _, at := range []time.Time{time.Now().Add(-time.Minute), time.Now().Add(time.Hour)} {
+		id := newTestPayment(t, repos, services, merchantID)
+		expiry := at
+		require.NoError(t, repos.SetStatus(merchantID, id, models.StatusChange{From: models.StatusNew, To: models.StatusProcessing}))
+		require.NoError(t, repos.SetStatus(merchantID, id, models.StatusChange{From: models.StatusProcessing, To: models.StatusAuthorized, AuthorizationExpires: &expiry}))
+		expires[id] = at
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// a cancelled context still runs a single pass
+	NewExpirer(services.Payment, time.Hour).Run(ctx)
+	for id, at := range expires {
+		payment, err := repos.GetPayment(merchantID, id)
+		require.NoError(t, err)
+		if at.Before(time.Now()) {
+			require.Equal(t, models.StatusVoided, payment.Status)
+		} else {
+			require.Equal(t, models.StatusAuthorized, payment.Status)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/service"
+)
+
+// Pool runs scheduled payment processing jobs on a fixed number of workers.
+// Jobs are persisted, so pending ones survive a restart and are picked up
+// again when the pool starts.
+type Pool struct {
+	queue    service.Queue
+	payments service.Payment
+	refunds  service.Refund
+	workers  int
+	interval time.Duration
+}
+
+func NewPool(queue service.Queue, payments service.Payment, refunds service.Refund, workers int, interval time.Duration) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Pool{
+		queue:    queue,
+		payments: payments,
+		refunds:  refunds,
+		workers:  workers,
+		interval: interval,
+	}
+}
+
+func (p *Pool) Run(ctx context.Context) {
+	if err := p.queue.Recover(); err != nil {
+		log.Printf("failed to recover processing jobs: %v", err)
+	}
+	jobs := make(chan models.Job)
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				p.process(job)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(jobs)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		if !p.dispatch(ctx, jobs) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-p.queue.Wake():
+		}
+	}
+}
+
+// dispatch hands due jobs to the workers, it claims again as long as a full
+// batch was due so a backlog does not drain one batch per poll.
+func (p *Pool) dispatch(ctx context.Context, jobs chan<- models.Job) bool {
+	for {
+		due, err := p.queue.Claim(p.workers)
+		if err != nil {
+			log.Printf("failed to claim processing jobs: %v", err)
+			return true
+		}
+		for i, job := range due {
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				// unsent jobs stay running and are recovered on the next start
+				log.Printf("stopping with %d claimed processing jobs", len(due)-i)
+				return false
+			}
+		}
+		if len(due) < p.workers {
+			return true
+		}
+	}
+}
+
+func (p *Pool) process(job models.Job) {
+	var err error
+	switch job.Kind {
+	case models.JobRefund:
+		var status string
+		status, err = p.refunds.ProcessRefund(job.MerchantID, job.RefundID)
+		if err != nil {
+			log.Printf("processing refund %d failed: %v", job.RefundID, err)
+		} else {
+			log.Printf("refund %d processed with status %s", job.RefundID, status)
+		}
+	default:
+		var status string
+		status, err = p.payments.PaymentProcessing(job.MerchantID, job.PaymentID)
+		if err != nil {
+			log.Printf("processing payment %d failed: %v", job.PaymentID, err)
+		} else {
+			log.Printf("payment %d processed with status %s", job.PaymentID, status)
+		}
+	}
+	if err := p.queue.Complete(job, err); err != nil {
+		log.Printf("failed to complete processing job %d: %v", job.ID, err)
+	}
+}
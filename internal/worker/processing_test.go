@@ -0,0 +1,127 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/helpers"
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/money"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/altuxa/payment-service-emulator/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// approveAll keeps new payments NEW and approves them when processed.
+type approveAll struct{}
+
+func (approveAll) Decide(stage helpers.Stage, payment models.Transaction, rates models.OutcomeRates) helpers.Outcome {
+	if stage == helpers.StageCreation {
+		return helpers.Outcome{Status: models.StatusNew}
+	}
+	return helpers.Outcome{Status: models.StatusSuccess}
+}
+
+func newTestServices(t *testing.T) (*repository.Repositories, *service.Services, int) {
+	repos := repository.NewMemoryRepository()
+	services := service.NewService(service.ServiceDeps{
+		Repos:      repos,
+		Currencies: money.NewRegistry(),
+		Outcomes:   approveAll{},
+		Webhooks:   service.WebhookConfig{MaxAttempts: 1, Backoff: time.Second, MaxBackoff: time.Second, Timeout: time.Second},
+	})
+	name := "worker"
+	merchant, err := services.Merchant.CreateMerchant(models.MerchantInput{Name: &name})
+	require.NoError(t, err)
+	return repos, services, merchant.ID
+}
+
+// newTestPayment stores a payment with its processing job.
+func newTestPayment(t *testing.T, repos *repository.Repositories, services *service.Services, merchantID int) int {
+	job := services.Queue.Job(models.JobPayment, merchantID)
+	id, err := repos.NewPayment(models.Transaction{
+		UserID:        1,
+		UserEmail:     "ann@mail.ru",
+		Sum:           money.New(1000, "USD"),
+		Status:        models.StatusNew,
+		CaptureMethod: models.CaptureAutomatic,
+		MerchantID:    merchantID,
+	}, &job)
+	require.NoError(t, err)
+	services.Queue.Notify()
+	return id
+}
+
+// runPool runs the pool until the test ends.
+func runPool(t *testing.T, services *service.Services, interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		NewPool(services.Queue, services.Payment, services.Refund, 2, interval).Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+}
+
+func assertStatus(t *testing.T, repos *repository.Repositories, merchantID, paymentID int, status string) {
+	assert.Eventually(t, func() bool {
+		payment, err := repos.GetPayment(merchantID, paymentID)
+		return err == nil && payment.Status == status
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestPoolProcessesScheduledPayments(t *testing.T) {
+	repos, services, merchantID := newTestServices(t)
+	first := newTestPayment(t, repos, services, merchantID)
+	runPool(t, services, 10*time.Millisecond)
+	second := newTestPayment(t, repos, services, merchantID)
+
+	assertStatus(t, repos, merchantID, first, models.StatusSuccess)
+	assertStatus(t, repos, merchantID, second, models.StatusSuccess)
+	jobs, err := services.Queue.Claim(10)
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+func TestPoolResumesInterruptedPayments(t *testing.T) {
+	repos, services, merchantID := newTestServices(t)
+	id := newTestPayment(t, repos, services, merchantID)
+	// a previous process claimed the job and crashed after the payment
+	// moved to PROCESSING
+	jobs, err := services.Queue.Claim(10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	require.NoError(t, repos.SetStatus(merchantID, id, models.StatusChange{From: models.StatusNew, To: models.StatusProcessing, Reason: "processing started"}))
+
+	runPool(t, services, 10*time.Millisecond)
+
+	assertStatus(t, repos, merchantID, id, models.StatusSuccess)
+	history, err := repos.History(merchantID, id)
+	require.NoError(t, err)
+	statuses := []string{}
+	for _, event := range history {
+		statuses = append(statuses, event.ToStatus)
+	}
+	assert.Equal(t, []string{models.StatusNew, models.StatusProcessing, models.StatusSuccess}, statuses)
+}
+
+func TestPoolDrainsBacklog(t *testing.T) {
+	repos, services, merchantID := newTestServices(t)
+	ids := []int{}
+	for i := 0; i < 20; i++ {
+		ids = append(ids, newTestPayment(t, repos, services, merchantID))
+	}
+	// the backlog is done long before the next poll
+	runPool(t, services, time.Hour)
+
+	for _, id := range ids {
+		assertStatus(t, repos, merchantID, id, models.StatusSuccess)
+	}
+}
@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/service"
+)
+
+// Purger deletes cancelled payments once they are older than the retention.
+type Purger struct {
+	payments  service.Payment
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewPurger(payments service.Payment, retention, interval time.Duration) *Purger {
+	return &Purger{
+		payments:  payments,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		n, err := p.payments.PurgeCancelled(time.Now().Add(-p.retention))
+		if err != nil {
+			log.Printf("failed to purge cancelled payments: %v", err)
+		} else if n > 0 {
+			log.Printf("purged %d cancelled payments", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurgerRemovesCancelledAfterRetention(t *testing.T) {
+	repos, services, merchantID := newTestServices(t)
+	cancel := func(id int) {
+		require.NoError(t, repos.SetStatus(merchantID, id, models.StatusChange{From: models.StatusNew, To: models.StatusCancelled, Reason: "cancelled by client"}))
+	}
+	old := newTestPayment(t, repos, services, merchantID)
+	cancel(old)
+	pending := newTestPayment(t, repos, services, merchantID)
+	time.Sleep(200 * time.Millisecond)
+	recent := newTestPayment(t, repos, services, merchantID)
+	cancel(recent)
+
+	ctx, stop := context.WithCancel(context.Background())
+	stop()
+	// a cancelled context still runs a single pass
+	NewPurger(services.Payment, 100*time.Millisecond, time.Hour).Run(ctx)
+	_, err := repos.GetPayment(merchantID, old)
+	assert.Error(t, err)
+	for _, id := range []int{pending, recent} {
+		_, err := repos.GetPayment(merchantID, id)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/service"
+)
+
+const deliveryBatch = 50
+
+type Dispatcher struct {
+	webhooks service.Webhook
+	interval time.Duration
+}
+
+func NewDispatcher(webhooks service.Webhook, interval time.Duration) *Dispatcher {
+	return &Dispatcher{
+		webhooks: webhooks,
+		interval: interval,
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		d.dispatch(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	deliveries, err := d.webhooks.DueDeliveries(deliveryBatch)
+	if err != nil {
+		log.Printf("failed to load webhook deliveries: %v", err)
+		return
+	}
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := d.webhooks.Deliver(ctx, deliveries[i]); err != nil {
+				log.Printf("failed to record webhook delivery %d: %v", deliveries[i].ID, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -1,22 +1,124 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"time"
 
+	"github.com/altuxa/payment-service-emulator/internal/config"
 	"github.com/altuxa/payment-service-emulator/internal/handlers"
+	"github.com/altuxa/payment-service-emulator/internal/helpers"
+	"github.com/altuxa/payment-service-emulator/internal/jwt"
+	"github.com/altuxa/payment-service-emulator/internal/money"
 	"github.com/altuxa/payment-service-emulator/internal/repository"
 	"github.com/altuxa/payment-service-emulator/internal/service"
+	"github.com/altuxa/payment-service-emulator/internal/worker"
 )
 
 func main() {
-	db, err := repository.NewSqliteDB()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("failed to load config %s", err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err = migrate(cfg.Storage, os.Args[2:])
+		if err != nil {
+			log.Fatalf("migrate: %s", err)
+		}
+		return
+	}
+	currencies := money.NewRegistry()
+	err = currencies.SetLimits(cfg.CurrencyLimits)
+	if err != nil {
+		log.Fatalf("failed to load currency limits %s", err)
+	}
+	seed := cfg.Outcome.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	log.Printf("payment outcome seed %d, set OUTCOME_SEED to reproduce this run", seed)
+	probs := helpers.DefaultProbabilities
+	probs.Error, probs.Fail, probs.RefundFail = cfg.Outcome.ErrorRate, cfg.Outcome.FailRate, cfg.Outcome.RefundFailRate
+	probs.ErrorReasons, err = helpers.ParseWeights(cfg.Outcome.ErrorReasons, probs.ErrorReasons)
+	if err != nil {
+		log.Fatalf("failed to load OUTCOME_ERROR_REASONS %s", err)
+	}
+	probs.FailReasons, err = helpers.ParseWeights(cfg.Outcome.FailReasons, probs.FailReasons)
+	if err != nil {
+		log.Fatalf("failed to load OUTCOME_FAIL_REASONS %s", err)
+	}
+	outcomes := helpers.NewScenarioEngine(helpers.NewRandomEngine(seed, probs))
+	tokens, err := tokenConfig(cfg.JWT)
+	if err != nil {
+		log.Fatalf("failed to load jwt keys %s", err)
+	}
+	repository, closeDB, err := repository.Open(cfg.Storage.Backend, cfg.Storage.Source(), cfg.Storage.AutoMigrate)
 	if err != nil {
 		log.Fatalf("failed to initialize db %s", err)
 	}
-	defer db.Close()
-	repository.CreateTable(db)
-	repository := repository.NewRepository(db)
-	service := service.NewService(repository)
+	defer closeDB()
+	service := service.NewService(service.ServiceDeps{
+		Repos:            repository,
+		Currencies:       currencies,
+		IdempotencyTTL:   cfg.IdempotencyTTL,
+		ProcessingDelay:  cfg.Processing.Delay,
+		Outcomes:         outcomes,
+		AuthorizationTTL: cfg.Authorization.TTL,
+		AdminToken:       cfg.AdminToken,
+		Tokens:           tokens,
+		Webhooks: service.WebhookConfig{
+			MaxAttempts: cfg.Webhook.MaxAttempts,
+			Backoff:     cfg.Webhook.Backoff,
+			MaxBackoff:  cfg.Webhook.MaxBackoff,
+			Timeout:     cfg.Webhook.Timeout,
+			SecretGrace: cfg.Webhook.SecretGrace,
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go worker.NewDispatcher(service.Webhook, cfg.Webhook.PollInterval).Run(ctx)
+	go worker.NewExpirer(service.Payment, cfg.Authorization.CheckInterval).Run(ctx)
+	if cfg.Retention.Cancelled > 0 {
+		go worker.NewPurger(service.Payment, cfg.Retention.Cancelled, cfg.Retention.PurgeInterval).Run(ctx)
+	}
+	go worker.NewPool(service.Queue, service.Payment, service.Refund, cfg.Processing.Workers, cfg.Processing.PollInterval).Run(ctx)
 	handler := handlers.NewHandler(service)
 	handler.Server()
 }
+
+func tokenConfig(cfg config.JWT) (service.TokenConfig, error) {
+	keys := jwt.NewKeySet()
+	tokens := service.TokenConfig{
+		Verifier: &jwt.Verifier{
+			Keys:     keys,
+			Audience: cfg.Audience,
+			Issuer:   cfg.Issuer,
+			Leeway:   cfg.Leeway,
+		},
+		KeyID: cfg.KeyID,
+		TTL:   cfg.TTL,
+	}
+	if cfg.Secret != "" {
+		tokens.Secret = []byte(cfg.Secret)
+		keys.AddSecret(cfg.KeyID, tokens.Secret)
+	}
+	if cfg.PrivateKeyFile != "" {
+		data, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return tokens, err
+		}
+		tokens.PrivateKey, err = jwt.ParseRSAPrivateKey(data)
+		if err != nil {
+			return tokens, err
+		}
+		keys.AddRSA(cfg.KeyID, &tokens.PrivateKey.PublicKey)
+	}
+	if cfg.JWKSFile != "" {
+		err := keys.LoadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return tokens, err
+		}
+	}
+	return tokens, nil
+}
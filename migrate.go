@@ -0,0 +1,87 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/altuxa/payment-service-emulator/internal/config"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+)
+
+const migrateUsage = `usage: migrate up [version] | down [steps] | status`
+
+// migrate runs "migrate up", "migrate down" or "migrate status" against the
+// configured database.
+func migrate(cfg config.Storage, args []string) error {
+	var db *sql.DB
+	var err error
+	switch cfg.Backend {
+	case repository.BackendSQLite:
+		db, err = repository.NewSqliteDB(cfg.SQLitePath)
+	case repository.BackendPostgres:
+		db, err = repository.NewPostgresDB(cfg.PostgresDSN)
+	default:
+		return fmt.Errorf("storage backend %q has no migrations", cfg.Backend)
+	}
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migrator, err := repository.NewMigrator(db, cfg.Backend)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New(migrateUsage)
+	}
+	n := 0
+	if len(args) == 2 {
+		n, err = strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return errors.New(migrateUsage)
+		}
+	}
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(n)
+		for _, m := range applied {
+			fmt.Printf("applied %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return nil
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		reverted, err := migrator.Down(n)
+		for _, m := range reverted {
+			fmt.Printf("reverted %d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return w.Flush()
+	}
+	return errors.New(migrateUsage)
+}
@@ -0,0 +1,163 @@
+// Package client calls the v1 API of the payment service emulator.
+//
+//	c := client.New("http://localhost:8080", "sk_test_...")
+//	payment, err := c.CreatePayment(ctx, client.PaymentInput{
+//		UserID:   5,
+//		Email:    "ann@mail.ru",
+//		Sum:      "12.00",
+//		Currency: "USD",
+//	})
+//
+// Failed calls return an *Error decoded from the error envelope of the
+// emulator, it can be matched with errors.Is(err, client.ErrNotFound) and
+// the like. Reads and payment creation are retried on network errors and 5xx
+// responses. Payment creation sends the same Idempotency-Key with every
+// attempt, so a retry never creates a second payment.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxRetries is the number of retries New configures.
+	DefaultMaxRetries = 2
+	// DefaultBackoff is the delay before the first retry New configures.
+	DefaultBackoff = 200 * time.Millisecond
+)
+
+const idempotencyHeader = "Idempotency-Key"
+
+// Client is safe for concurrent use. Its fields may be changed before the
+// first call.
+type Client struct {
+	// BaseURL is the address of the emulator such as http://localhost:8080
+	BaseURL string
+	// APIKey is a secret or publishable key or a JWT sent as bearer token
+	APIKey     string
+	HTTPClient *http.Client
+	// MaxRetries is the number of retries after the first attempt of a call
+	// that is safe to repeat
+	MaxRetries int
+	// Backoff is the delay before the first retry, it doubles with every retry
+	Backoff time.Duration
+}
+
+func New(baseURL, apiKey string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		APIKey:     apiKey,
+		HTTPClient: http.DefaultClient,
+		MaxRetries: DefaultMaxRetries,
+		Backoff:    DefaultBackoff,
+	}
+}
+
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   interface{}
+	// idempotencyKey makes a POST safe to retry
+	idempotencyKey string
+}
+
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+	var body []byte
+	if req.body != nil {
+		var err error
+		body, err = json.Marshal(req.body)
+		if err != nil {
+			return err
+		}
+	}
+	retryable := req.method == http.MethodGet || req.idempotencyKey != ""
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, req, body, out)
+		if err == nil || !retryable || attempt >= c.MaxRetries || !temporary(err) || ctx.Err() != nil {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, req request, body []byte, out interface{}) error {
+	u := strings.TrimRight(c.BaseURL, "/") + req.path
+	if len(req.query) != 0 {
+		u += "?" + req.query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	r, err := http.NewRequestWithContext(ctx, req.method, u, reader)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Accept", "application/json")
+	if c.APIKey != "" {
+		r.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if req.idempotencyKey != "" {
+		r.Header.Set(idempotencyHeader, req.idempotencyKey)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp, data)
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// temporary reports whether a failed attempt may succeed when repeated: the
+// request did not reach the emulator or the emulator failed to handle it.
+func temporary(err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= http.StatusInternalServerError || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// NewIdempotencyKey returns a random key for PaymentInput.IdempotencyKey.
+func NewIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
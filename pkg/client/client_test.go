@@ -0,0 +1,258 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/pkg/client"
+	"github.com/altuxa/payment-service-emulator/pkg/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitStatus polls the payment until it leaves NEW and PROCESSING.
+func waitStatus(t *testing.T, c *client.Client, id int) client.Payment {
+	t.Helper()
+	ctx := context.Background()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		payment, err := c.GetPayment(ctx, id)
+		require.NoError(t, err)
+		if payment.Status != client.StatusNew && payment.Status != client.StatusProcessing {
+			return payment
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("payment %d is still %s", id, payment.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPayments(t *testing.T) {
+	ctx := context.Background()
+	c := clienttest.NewClient(t)
+
+	user, err := c.CreateUser(ctx, client.UserInput{Email: "ann@mail.ru", Name: "Ann"})
+	require.NoError(t, err)
+	got, err := c.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Ann", got.Name)
+
+	created, err := c.CreatePayment(ctx, client.PaymentInput{UserID: user.ID, Email: user.Email, Sum: "12.00", Currency: "USD"})
+	require.NoError(t, err)
+	assert.Equal(t, "12.00", created.Sum)
+	payment := waitStatus(t, c, created.ID)
+	assert.Equal(t, client.StatusSuccess, payment.Status)
+
+	status, err := c.GetPaymentStatus(ctx, payment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, client.PaymentStatus{ID: payment.ID, Status: client.StatusSuccess}, status)
+
+	history, err := c.PaymentHistory(ctx, payment.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, history)
+	assert.Equal(t, client.StatusSuccess, history[len(history)-1].ToStatus)
+
+	refundInput := client.RefundInput{Amount: "2.00", Reason: "damaged", IdempotencyKey: client.NewIdempotencyKey()}
+	refund, err := c.Refund(ctx, payment.ID, refundInput)
+	require.NoError(t, err)
+	assert.Equal(t, "2.00", refund.Amount)
+	replayed, err := c.Refund(ctx, payment.ID, refundInput)
+	require.NoError(t, err)
+	assert.Equal(t, refund.ID, replayed.ID)
+	refunds, err := c.Refunds(ctx, payment.ID)
+	require.NoError(t, err)
+	assert.Len(t, refunds, 1)
+	got2, err := c.GetRefund(ctx, refund.ID)
+	require.NoError(t, err)
+	assert.Equal(t, refund.ID, got2.ID)
+
+	manual, err := c.CreatePayment(ctx, client.PaymentInput{UserID: user.ID, Email: user.Email, Sum: "5.00", Currency: "USD", CaptureMethod: client.CaptureManual})
+	require.NoError(t, err)
+	assert.Equal(t, client.StatusAuthorized, waitStatus(t, c, manual.ID).Status)
+	captured, err := c.Capture(ctx, manual.ID, client.CaptureInput{Amount: "4.00"})
+	require.NoError(t, err)
+	assert.Equal(t, "4.00", captured.Captured)
+
+	list, err := c.ListPayments(ctx, client.ListParams{Sort: "amount", Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, manual.ID, list.Data[0].ID)
+	assert.True(t, list.HasMore)
+	next, err := c.ListPayments(ctx, client.ListParams{Sort: "amount", Limit: 1, Cursor: list.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, next.Data, 1)
+	assert.Equal(t, payment.ID, next.Data[0].ID)
+	assert.False(t, next.HasMore)
+
+	own, err := c.UserPayments(ctx, user.ID, client.ListParams{})
+	require.NoError(t, err)
+	assert.Len(t, own.Data, 2)
+}
+
+func TestCancel(t *testing.T) {
+	ctx := context.Background()
+	srv := clienttest.NewServer(t, clienttest.Config{ProcessingDelay: time.Hour})
+	c := srv.Client
+	user, err := c.CreateUser(ctx, client.UserInput{Email: "bob@mail.ru"})
+	require.NoError(t, err)
+	payment, err := c.CreatePayment(ctx, client.PaymentInput{UserID: user.ID, Email: user.Email, Sum: "7.50", Currency: "EUR"})
+	require.NoError(t, err)
+	assert.Equal(t, client.StatusNew, payment.Status)
+
+	cancelled, err := c.Cancel(ctx, payment.ID, client.CancelInput{Reason: "changed mind"})
+	require.NoError(t, err)
+	assert.Equal(t, client.StatusCancelled, cancelled.Status)
+	assert.Equal(t, "changed mind", cancelled.CancellationReason)
+
+	_, err = c.Cancel(ctx, payment.ID, client.CancelInput{})
+	assert.True(t, errors.Is(err, client.ErrConflict))
+
+	publishable := client.New(srv.URL, srv.PublishableKey)
+	status, err := publishable.GetPaymentStatus(ctx, payment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, client.StatusCancelled, status.Status)
+	_, err = publishable.GetPayment(ctx, payment.ID)
+	assert.True(t, errors.Is(err, client.ErrForbidden))
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	srv := clienttest.NewServer(t, clienttest.Config{})
+
+	_, err := srv.Client.GetPayment(ctx, 1000)
+	assert.True(t, errors.Is(err, client.ErrNotFound))
+	var apiErr *client.Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "payment not found", apiErr.Message)
+	assert.NotEmpty(t, apiErr.RequestID)
+	assert.EqualError(t, err, "client: not_found: payment not found")
+
+	_, err = srv.Client.CreatePayment(ctx, client.PaymentInput{Sum: "1.00"})
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, client.CodeValidation, apiErr.Code)
+	assert.Equal(t, []client.FieldError{{Field: "Email", Message: "is required"}, {Field: "Currency", Message: "is required"}}, apiErr.Fields)
+	assert.EqualError(t, err, "client: validation_error: invalid input: Email is required, Currency is required")
+
+	_, err = client.New(srv.URL, "sk_test_unknown").GetPayment(ctx, 1)
+	assert.True(t, errors.Is(err, client.ErrUnauthorized))
+}
+
+func TestRetry(t *testing.T) {
+	tData := map[string]struct {
+		Call          func(c *client.Client) error
+		Failures      int
+		ExpectedCalls int
+		ExpectedErr   error
+		SameKey       bool
+	}{
+		"create is retried with one key": {
+			Call: func(c *client.Client) error {
+				_, err := c.CreatePayment(context.Background(), client.PaymentInput{Email: "ann@mail.ru", Sum: "1.00", Currency: "USD"})
+				return err
+			},
+			Failures:      2,
+			ExpectedCalls: 3,
+			SameKey:       true,
+		},
+		"reads are retried": {
+			Call: func(c *client.Client) error {
+				_, err := c.GetPayment(context.Background(), 1)
+				return err
+			},
+			Failures:      1,
+			ExpectedCalls: 2,
+		},
+		"retries are limited": {
+			Call: func(c *client.Client) error {
+				_, err := c.GetPayment(context.Background(), 1)
+				return err
+			},
+			Failures:      5,
+			ExpectedCalls: 3,
+			ExpectedErr:   client.ErrInternal,
+		},
+		"transitions are not retried": {
+			Call: func(c *client.Client) error {
+				_, err := c.Cancel(context.Background(), 1, client.CancelInput{})
+				return err
+			},
+			Failures:      1,
+			ExpectedCalls: 1,
+			ExpectedErr:   client.ErrInternal,
+		},
+		"client errors are not retried": {
+			Call: func(c *client.Client) error {
+				_, err := c.GetPayment(context.Background(), 1)
+				return err
+			},
+			Failures:      -1,
+			ExpectedCalls: 1,
+			ExpectedErr:   client.ErrNotFound,
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			var mu sync.Mutex
+			keys := []string{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				keys = append(keys, r.Header.Get("Idempotency-Key"))
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case v.Failures < 0:
+					w.WriteHeader(http.StatusNotFound)
+					w.Write([]byte(`{"error":{"code":"not_found","message":"payment not found"}}`))
+				case len(keys) <= v.Failures:
+					w.WriteHeader(http.StatusInternalServerError)
+					w.Write([]byte(`{"error":{"code":"internal_error","message":"internal error"}}`))
+				default:
+					w.Write([]byte(`{"ID":1,"Status":"NEW"}`))
+				}
+			}))
+			defer srv.Close()
+			c := client.New(srv.URL, "sk_test_secret")
+			c.Backoff = time.Millisecond
+			err := v.Call(c)
+			if v.ExpectedErr != nil {
+				assert.True(t, errors.Is(err, v.ExpectedErr), "unexpected error %v", err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Len(t, keys, v.ExpectedCalls)
+			if v.SameKey {
+				assert.NotEmpty(t, keys[0])
+				for _, key := range keys {
+					assert.Equal(t, keys[0], key)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	c := client.New(srv.URL, "sk_test_secret")
+	c.MaxRetries = 10
+	c.Backoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.GetPayment(ctx, 1)
+	var apiErr *client.Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.Equal(t, "Service Unavailable", apiErr.Message)
+	assert.Less(t, time.Since(start), time.Second)
+}
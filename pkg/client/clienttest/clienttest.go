@@ -0,0 +1,128 @@
+// Package clienttest boots the payment service emulator in process for tests
+// of code built on package client.
+//
+//	func TestCheckout(t *testing.T) {
+//		c := clienttest.NewClient(t)
+//		user, err := c.CreateUser(ctx, client.UserInput{Email: "ann@mail.ru"})
+//		...
+//	}
+//
+// Every server has its own in-memory storage, one merchant and one API key
+// pair, and is shut down when the test ends.
+package clienttest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/altuxa/payment-service-emulator/internal/handlers"
+	"github.com/altuxa/payment-service-emulator/internal/helpers"
+	"github.com/altuxa/payment-service-emulator/internal/models"
+	"github.com/altuxa/payment-service-emulator/internal/money"
+	"github.com/altuxa/payment-service-emulator/internal/repository"
+	"github.com/altuxa/payment-service-emulator/internal/service"
+	"github.com/altuxa/payment-service-emulator/internal/worker"
+	"github.com/altuxa/payment-service-emulator/pkg/client"
+)
+
+// pollInterval keeps the background workers fast enough for tests.
+const pollInterval = 10 * time.Millisecond
+
+// Config tunes the emulator, the zero value approves every payment and
+// refund right away except those of the scenario emails listed by
+// GET /v1/scenarios.
+type Config struct {
+	// Seed makes random outcomes reproducible, zero picks one from the clock
+	Seed int64
+	// ErrorRate, FailRate and RefundFailRate are the chances of random
+	// declines like the OUTCOME_*_RATE variables of the emulator
+	ErrorRate      float64
+	FailRate       float64
+	RefundFailRate float64
+	// ProcessingDelay postpones processing of new payments and refunds
+	ProcessingDelay time.Duration
+}
+
+type Server struct {
+	*httptest.Server
+	// Client uses SecretKey
+	Client         *client.Client
+	SecretKey      string
+	PublishableKey string
+	// AdminToken authorizes the /v1/admin routes
+	AdminToken string
+	MerchantID int
+}
+
+// NewClient boots an emulator with the zero Config and returns a client
+// connected to it with the secret key.
+func NewClient(tb testing.TB) *client.Client {
+	return NewServer(tb, Config{}).Client
+}
+
+func NewServer(tb testing.TB, cfg Config) *Server {
+	tb.Helper()
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	probs := helpers.DefaultProbabilities
+	probs.Error, probs.Fail, probs.RefundFail = cfg.ErrorRate, cfg.FailRate, cfg.RefundFailRate
+	adminToken := randomToken()
+	services := service.NewService(service.ServiceDeps{
+		Repos:            repository.NewMemoryRepository(),
+		Currencies:       money.NewRegistry(),
+		IdempotencyTTL:   24 * time.Hour,
+		ProcessingDelay:  cfg.ProcessingDelay,
+		Outcomes:         helpers.NewScenarioEngine(helpers.NewRandomEngine(seed, probs)),
+		AuthorizationTTL: 7 * 24 * time.Hour,
+		AdminToken:       adminToken,
+		Webhooks: service.WebhookConfig{
+			MaxAttempts: 6,
+			Backoff:     time.Second,
+			MaxBackoff:  time.Minute,
+			Timeout:     5 * time.Second,
+			SecretGrace: 24 * time.Hour,
+		},
+	})
+	name := "clienttest"
+	merchant, err := services.Merchant.CreateMerchant(models.MerchantInput{Name: &name})
+	if err != nil {
+		tb.Fatalf("clienttest: failed to create merchant %s", err)
+	}
+	key, err := services.APIKey.IssueAPIKey(models.APIKeyInput{MerchantID: merchant.ID, Name: name})
+	if err != nil {
+		tb.Fatalf("clienttest: failed to issue api key %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go worker.NewPool(services.Queue, services.Payment, services.Refund, 2, pollInterval).Run(ctx)
+	go worker.NewDispatcher(services.Webhook, pollInterval).Run(ctx)
+	go worker.NewExpirer(services.Payment, time.Second).Run(ctx)
+	srv := httptest.NewServer(handlers.NewHandler(services).Routes())
+	tb.Cleanup(func() {
+		cancel()
+		srv.Close()
+	})
+
+	c := client.New(srv.URL, key.SecretKey)
+	c.Backoff = pollInterval
+	return &Server{
+		Server:         srv,
+		Client:         c,
+		SecretKey:      key.SecretKey,
+		PublishableKey: key.PublishableKey,
+		AdminToken:     adminToken,
+		MerchantID:     merchant.ID,
+	}
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
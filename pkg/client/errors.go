@@ -0,0 +1,98 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Codes of the error envelope, they are stable while messages may change.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeUnprocessable    = "unprocessable_entity"
+	CodeValidation       = "validation_error"
+	CodeInternal         = "internal_error"
+)
+
+// Targets for errors.Is, an *Error matches the one with its Code.
+var (
+	ErrBadRequest    = &Error{Code: CodeBadRequest}
+	ErrUnauthorized  = &Error{Code: CodeUnauthorized}
+	ErrForbidden     = &Error{Code: CodeForbidden}
+	ErrNotFound      = &Error{Code: CodeNotFound}
+	ErrConflict      = &Error{Code: CodeConflict}
+	ErrUnprocessable = &Error{Code: CodeUnprocessable}
+	ErrValidation    = &Error{Code: CodeValidation}
+	ErrInternal      = &Error{Code: CodeInternal}
+)
+
+// Error is an error response of the emulator.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	// Fields name the invalid inputs of a validation_error
+	Fields []FieldError
+	// RequestID is the X-Request-ID of the failed request, the emulator logs
+	// the details of internal errors under it
+	RequestID string
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	msg := "client: " + e.Code + ": " + e.Message
+	if len(e.Fields) != 0 {
+		fields := make([]string, 0, len(e.Fields))
+		for _, f := range e.Fields {
+			fields = append(fields, f.Field+" "+f.Message)
+		}
+		msg += ": " + strings.Join(fields, ", ")
+	}
+	return msg
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// decodeError reads the error envelope. Responses without one, such as those
+// of a proxy in front of the emulator, keep their body as Message.
+func decodeError(resp *http.Response, data []byte) *Error {
+	var envelope struct {
+		Error struct {
+			Code      string       `json:"code"`
+			Message   string       `json:"message"`
+			Fields    []FieldError `json:"fields"`
+			RequestID string       `json:"request_id"`
+		} `json:"error"`
+	}
+	err := json.Unmarshal(data, &envelope)
+	if err != nil || envelope.Error.Code == "" {
+		message := strings.TrimSpace(string(data))
+		if message == "" {
+			message = http.StatusText(resp.StatusCode)
+		}
+		return &Error{
+			StatusCode: resp.StatusCode,
+			Message:    message,
+			RequestID:  resp.Header.Get("X-Request-ID"),
+		}
+	}
+	return &Error{
+		StatusCode: resp.StatusCode,
+		Code:       envelope.Error.Code,
+		Message:    envelope.Error.Message,
+		Fields:     envelope.Error.Fields,
+		RequestID:  envelope.Error.RequestID,
+	}
+}
@@ -0,0 +1,261 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Payment statuses.
+const (
+	StatusNew               = "NEW"
+	StatusProcessing        = "PROCESSING"
+	StatusSuccess           = "SUCCESS"
+	StatusFail              = "FAIL"
+	StatusError             = "ERROR"
+	StatusCancelled         = "CANCELLED"
+	StatusAuthorized        = "AUTHORIZED"
+	StatusCaptured          = "CAPTURED"
+	StatusVoided            = "VOIDED"
+	StatusPartiallyRefunded = "PARTIALLY_REFUNDED"
+	StatusRefunded          = "REFUNDED"
+)
+
+// Capture methods, manual payments stop at AUTHORIZED until captured.
+const (
+	CaptureAutomatic = "automatic"
+	CaptureManual    = "manual"
+)
+
+// Amounts are decimal strings in the major units of the currency such as
+// "12.00", they are never floats.
+type Payment struct {
+	ID                   int
+	UserID               int
+	Email                string
+	Sum                  string
+	Currency             string
+	CreationDate         time.Time
+	ChangeDate           time.Time
+	Status               string
+	DeclineReason        string
+	CaptureMethod        string
+	AuthorizationExpires *time.Time
+	CancelledAt          *time.Time
+	CancellationReason   string
+	CancelledBy          string
+	Captured             string
+	Refunded             string
+}
+
+type PaymentInput struct {
+	// UserID is required with API keys, user tokens pay for their own user
+	UserID        int    `json:",omitempty"`
+	Email         string `json:",omitempty"`
+	Sum           string `json:",omitempty"`
+	Currency      string `json:",omitempty"`
+	CaptureMethod string `json:",omitempty"`
+	// IdempotencyKey is sent as Idempotency-Key, a random one is used when it
+	// is empty. Set it to retry a creation across process restarts.
+	IdempotencyKey string `json:"-"`
+}
+
+type PaymentStatus struct {
+	ID            int
+	Status        string
+	DeclineReason string
+}
+
+type PaymentEvent struct {
+	ID         int
+	PaymentID  int
+	FromStatus string
+	ToStatus   string
+	Reason     string
+	CreatedAt  time.Time
+}
+
+// ListParams filters ListPayments, zero fields are not sent.
+type ListParams struct {
+	// Status is a comma separated list such as "success,fail"
+	Status string
+	// Currency is required with MinAmount and MaxAmount
+	Currency    string
+	MinAmount   string
+	MaxAmount   string
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	ChangedFrom time.Time
+	ChangedTo   time.Time
+	UserID      int
+	Email       string
+	// Sort is created_at, -created_at, amount or -amount
+	Sort  string
+	Limit int
+	// Cursor is the NextCursor of the previous page
+	Cursor string
+}
+
+type PaymentList struct {
+	Data       []Payment
+	HasMore    bool
+	NextCursor string
+}
+
+type CancelInput struct {
+	Reason string `json:",omitempty"`
+	Actor  string `json:",omitempty"`
+}
+
+type CaptureInput struct {
+	// Amount is optional, the whole authorized sum is captured without it
+	Amount string `json:",omitempty"`
+}
+
+type Refund struct {
+	ID            int
+	PaymentID     int
+	Amount        string
+	Currency      string
+	Status        string
+	Reason        string
+	DeclineReason string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type RefundInput struct {
+	// Amount is optional, everything not refunded yet is refunded without it
+	Amount string `json:",omitempty"`
+	Reason string `json:",omitempty"`
+	// IdempotencyKey is sent as Idempotency-Key when set
+	IdempotencyKey string `json:"-"`
+}
+
+// CreatePayment creates a payment, it is processed asynchronously unless it
+// is declined right away.
+func (c *Client) CreatePayment(ctx context.Context, input PaymentInput) (Payment, error) {
+	key := input.IdempotencyKey
+	if key == "" {
+		key = NewIdempotencyKey()
+	}
+	var payment Payment
+	err := c.do(ctx, request{method: http.MethodPost, path: "/v1/payments", body: input, idempotencyKey: key}, &payment)
+	return payment, err
+}
+
+func (c *Client) GetPayment(ctx context.Context, id int) (Payment, error) {
+	var payment Payment
+	err := c.do(ctx, request{method: http.MethodGet, path: paymentPath(id, "")}, &payment)
+	return payment, err
+}
+
+// GetPaymentStatus also works with a publishable key.
+func (c *Client) GetPaymentStatus(ctx context.Context, id int) (PaymentStatus, error) {
+	var status PaymentStatus
+	err := c.do(ctx, request{method: http.MethodGet, path: paymentPath(id, "status")}, &status)
+	return status, err
+}
+
+// ListPayments returns one page of payments, newest first unless
+// params.Sort says otherwise.
+func (c *Client) ListPayments(ctx context.Context, params ListParams) (PaymentList, error) {
+	var list PaymentList
+	err := c.do(ctx, request{method: http.MethodGet, path: "/v1/payments", query: params.query()}, &list)
+	return list, err
+}
+
+func (c *Client) PaymentHistory(ctx context.Context, id int) ([]PaymentEvent, error) {
+	var events []PaymentEvent
+	err := c.do(ctx, request{method: http.MethodGet, path: paymentPath(id, "history")}, &events)
+	return events, err
+}
+
+// ProcessPayment processes a NEW payment right away instead of waiting for
+// the processing delay, email must match the payment.
+func (c *Client) ProcessPayment(ctx context.Context, id int, email string) (Payment, error) {
+	var payment Payment
+	body := struct{ Email string }{email}
+	err := c.do(ctx, request{method: http.MethodPost, path: paymentPath(id, "process"), body: body}, &payment)
+	return payment, err
+}
+
+func (c *Client) Cancel(ctx context.Context, id int, input CancelInput) (Payment, error) {
+	var payment Payment
+	err := c.do(ctx, request{method: http.MethodPost, path: paymentPath(id, "cancel"), body: input}, &payment)
+	return payment, err
+}
+
+func (c *Client) Capture(ctx context.Context, id int, input CaptureInput) (Payment, error) {
+	var payment Payment
+	err := c.do(ctx, request{method: http.MethodPost, path: paymentPath(id, "capture"), body: input}, &payment)
+	return payment, err
+}
+
+func (c *Client) Void(ctx context.Context, id int) (Payment, error) {
+	var payment Payment
+	err := c.do(ctx, request{method: http.MethodPost, path: paymentPath(id, "void")}, &payment)
+	return payment, err
+}
+
+// Refund refunds a successful payment, the refund is PENDING until it is
+// processed.
+func (c *Client) Refund(ctx context.Context, paymentID int, input RefundInput) (Refund, error) {
+	var refund Refund
+	err := c.do(ctx, request{method: http.MethodPost, path: paymentPath(paymentID, "refunds"), body: input, idempotencyKey: input.IdempotencyKey}, &refund)
+	return refund, err
+}
+
+func (c *Client) Refunds(ctx context.Context, paymentID int) ([]Refund, error) {
+	var refunds []Refund
+	err := c.do(ctx, request{method: http.MethodGet, path: paymentPath(paymentID, "refunds")}, &refunds)
+	return refunds, err
+}
+
+func (c *Client) GetRefund(ctx context.Context, id int) (Refund, error) {
+	var refund Refund
+	err := c.do(ctx, request{method: http.MethodGet, path: "/v1/refunds/" + strconv.Itoa(id)}, &refund)
+	return refund, err
+}
+
+func paymentPath(id int, action string) string {
+	path := "/v1/payments/" + strconv.Itoa(id)
+	if action != "" {
+		path += "/" + action
+	}
+	return path
+}
+
+func (p ListParams) query() url.Values {
+	query := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	setTime := func(key string, t time.Time) {
+		if !t.IsZero() {
+			query.Set(key, t.Format(time.RFC3339Nano))
+		}
+	}
+	set("status", p.Status)
+	set("currency", p.Currency)
+	set("min_amount", p.MinAmount)
+	set("max_amount", p.MaxAmount)
+	setTime("created_from", p.CreatedFrom)
+	setTime("created_to", p.CreatedTo)
+	setTime("changed_from", p.ChangedFrom)
+	setTime("changed_to", p.ChangedTo)
+	if p.UserID != 0 {
+		query.Set("user_id", strconv.Itoa(p.UserID))
+	}
+	set("email", p.Email)
+	set("sort", p.Sort)
+	if p.Limit != 0 {
+		query.Set("limit", strconv.Itoa(p.Limit))
+	}
+	set("cursor", p.Cursor)
+	return query
+}
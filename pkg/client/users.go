@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type User struct {
+	ID        int
+	Email     string
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type UserInput struct {
+	Email string `json:",omitempty"`
+	Name  string `json:",omitempty"`
+}
+
+// CreateUser registers a user, emails are unique per merchant.
+func (c *Client) CreateUser(ctx context.Context, input UserInput) (User, error) {
+	var user User
+	err := c.do(ctx, request{method: http.MethodPost, path: "/v1/users", body: input}, &user)
+	return user, err
+}
+
+func (c *Client) GetUser(ctx context.Context, id int) (User, error) {
+	var user User
+	err := c.do(ctx, request{method: http.MethodGet, path: "/v1/users/" + strconv.Itoa(id)}, &user)
+	return user, err
+}
+
+// UserPayments lists the payments of a user with the filters of ListPayments,
+// params.UserID is ignored.
+func (c *Client) UserPayments(ctx context.Context, userID int, params ListParams) (PaymentList, error) {
+	params.UserID = 0
+	var list PaymentList
+	err := c.do(ctx, request{method: http.MethodGet, path: "/v1/users/" + strconv.Itoa(userID) + "/payments", query: params.query()}, &list)
+	return list, err
+}
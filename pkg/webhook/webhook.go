@@ -0,0 +1,126 @@
+// Package webhook signs and verifies the webhook requests sent by the payment
+// service emulator.
+//
+// Every delivery carries a header of the form
+//
+//	X-Emulator-Signature: t=1655000000,v1=5257a869...,v1=9e1f3b0c...
+//
+// where t is the unix time of signing and each v1 is the hex encoded
+// HMAC-SHA256 of "<t>.<body>" keyed with one of the endpoint's active secrets.
+// Two v1 values are present while a rotated secret is still in its grace
+// period, so receivers can switch secrets without dropping deliveries.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const SignatureHeader = "X-Emulator-Signature"
+
+// DefaultTolerance is the maximum age of a signature accepted by Verify.
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	ErrInvalidHeader    = errors.New("webhook: invalid signature header")
+	ErrNoSignature      = errors.New("webhook: no signature matches the payload")
+	ErrTimestampExpired = errors.New("webhook: timestamp outside the tolerance window")
+)
+
+// Sign returns the hex encoded signature of payload for the given time and secret.
+func Sign(payload []byte, secret string, t time.Time) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(t.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Header builds the signature header value with one v1 entry per secret.
+func Header(payload []byte, t time.Time, secrets ...string) string {
+	parts := []string{"t=" + strconv.FormatInt(t.Unix(), 10)}
+	for _, secret := range secrets {
+		parts = append(parts, "v1="+Sign(payload, secret, t))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Verify checks the signature header against payload using the current time.
+// Any of the given secrets may match, which lets receivers accept both the old
+// and the new secret during a rotation. A tolerance of zero disables the
+// timestamp check.
+func Verify(payload []byte, header string, tolerance time.Duration, secrets ...string) error {
+	return VerifyAt(payload, header, tolerance, time.Now(), secrets...)
+}
+
+func VerifyAt(payload []byte, header string, tolerance time.Duration, now time.Time, secrets ...string) error {
+	ts, signatures, err := parseHeader(header)
+	if err != nil {
+		return err
+	}
+	if tolerance > 0 {
+		age := now.Sub(ts)
+		if age > tolerance || age < -tolerance {
+			return ErrTimestampExpired
+		}
+	}
+	for _, secret := range secrets {
+		expected, _ := hex.DecodeString(Sign(payload, secret, ts))
+		for _, sig := range signatures {
+			if hmac.Equal(expected, sig) {
+				return nil
+			}
+		}
+	}
+	return ErrNoSignature
+}
+
+// VerifyRequest reads the request body and verifies its signature header.
+// The body is returned so the caller can decode the event.
+func VerifyRequest(r *http.Request, tolerance time.Duration, secrets ...string) ([]byte, error) {
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	err = Verify(payload, r.Header.Get(SignatureHeader), tolerance, secrets...)
+	if err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func parseHeader(header string) (time.Time, [][]byte, error) {
+	var ts time.Time
+	var signatures [][]byte
+	for _, item := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			return ts, nil, ErrInvalidHeader
+		}
+		switch kv[0] {
+		case "t":
+			sec, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return ts, nil, ErrInvalidHeader
+			}
+			ts = time.Unix(sec, 0)
+		case "v1":
+			sig, err := hex.DecodeString(kv[1])
+			if err != nil {
+				return ts, nil, ErrInvalidHeader
+			}
+			signatures = append(signatures, sig)
+		}
+	}
+	if ts.IsZero() || len(signatures) == 0 {
+		return ts, nil, ErrInvalidHeader
+	}
+	return ts, signatures, nil
+}
@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyAt(t *testing.T) {
+	payload := []byte(`{"ID":1,"Type":"payment.succeeded"}`)
+	now := time.Unix(1655000000, 0)
+	tData := map[string]struct {
+		Header   string
+		Payload  []byte
+		Secrets  []string
+		Expected error
+	}{
+		"valid": {
+			Header:  Header(payload, now, "whsec_new"),
+			Payload: payload,
+			Secrets: []string{"whsec_new"},
+		},
+		"rotation old secret": {
+			Header:  Header(payload, now, "whsec_new", "whsec_old"),
+			Payload: payload,
+			Secrets: []string{"whsec_old"},
+		},
+		"rotation new secret": {
+			Header:  Header(payload, now, "whsec_new", "whsec_old"),
+			Payload: payload,
+			Secrets: []string{"whsec_other", "whsec_new"},
+		},
+		"wrong secret": {
+			Header:   Header(payload, now, "whsec_new"),
+			Payload:  payload,
+			Secrets:  []string{"whsec_old"},
+			Expected: ErrNoSignature,
+		},
+		"tampered payload": {
+			Header:   Header(payload, now, "whsec_new"),
+			Payload:  []byte(`{"ID":1,"Type":"payment.failed"}`),
+			Secrets:  []string{"whsec_new"},
+			Expected: ErrNoSignature,
+		},
+		"stale timestamp": {
+			Header:   Header(payload, now.Add(-10*time.Minute), "whsec_new"),
+			Payload:  payload,
+			Secrets:  []string{"whsec_new"},
+			Expected: ErrTimestampExpired,
+		},
+		"timestamp from the future": {
+			Header:   Header(payload, now.Add(10*time.Minute), "whsec_new"),
+			Payload:  payload,
+			Secrets:  []string{"whsec_new"},
+			Expected: ErrTimestampExpired,
+		},
+		"missing timestamp": {
+			Header:   "v1=" + Sign(payload, "whsec_new", now),
+			Payload:  payload,
+			Secrets:  []string{"whsec_new"},
+			Expected: ErrInvalidHeader,
+		},
+		"missing signature": {
+			Header:   "t=1655000000",
+			Payload:  payload,
+			Secrets:  []string{"whsec_new"},
+			Expected: ErrInvalidHeader,
+		},
+		"garbage": {
+			Header:   "sha256=abc",
+			Payload:  payload,
+			Secrets:  []string{"whsec_new"},
+			Expected: ErrInvalidHeader,
+		},
+	}
+	for tName, tCase := range tData {
+		v := tCase
+		t.Run(tName, func(t *testing.T) {
+			err := VerifyAt(v.Payload, v.Header, DefaultTolerance, now, v.Secrets...)
+			assert.Equal(t, v.Expected, err)
+		})
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	payload := []byte(`{"ID":1}`)
+	req := httptest.NewRequest("POST", "/hook", bytes.NewReader(payload))
+	req.Header.Set(SignatureHeader, Header(payload, time.Now(), "whsec_new"))
+	body, err := VerifyRequest(req, DefaultTolerance, "whsec_new")
+	assert.NoError(t, err)
+	assert.Equal(t, payload, body)
+}